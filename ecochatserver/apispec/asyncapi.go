@@ -0,0 +1,84 @@
+package apispec
+
+// Направления WebSocket-сообщений относительно клиента
+const (
+    Publish   = "publish"   // клиент → сервер (команда)
+    Subscribe = "subscribe" // сервер → клиент (ответ или событие)
+)
+
+// Message — тип сообщения в конверте {"type": Name, "payload": ...}
+type Message struct {
+    Name      string
+    Summary   string
+    Direction string // Publish | Subscribe
+    Clients   string // кому доступно: "admin", "widget" или "admin, widget"
+    Payload   any    // нулевое значение типа payload
+}
+
+// AsyncAPI строит документ AsyncAPI 2.6 для одного WebSocket-канала.
+// envelope — необязательные поля конверта по направлениям (Publish, Subscribe).
+func AsyncAPI(info Info, channel, description string, messages []Message, envelope map[string]Schema) map[string]any {
+    g := NewGenerator()
+    components := map[string]any{}
+    var publish, subscribe []map[string]any
+
+    for _, m := range messages {
+        summary := m.Summary
+        if m.Clients != "" {
+            summary += " (" + m.Clients + ")"
+        }
+        // Команда и событие с одним type (например, typing) — разные сообщения
+        key := m.Name
+        if _, dup := components[key]; dup {
+            key += "." + m.Direction
+        }
+        props := Schema{
+            "type":    Schema{"type": "string", "enum": []string{m.Name}},
+            "payload": g.SchemaOf(m.Payload),
+        }
+        for name, field := range envelope[m.Direction] {
+            props[name] = field
+        }
+        components[key] = map[string]any{
+            "name":    m.Name,
+            "title":   m.Name,
+            "summary": summary,
+            "payload": Schema{
+                "type":       "object",
+                "required":   []string{"type", "payload"},
+                "properties": props,
+            },
+        }
+
+        ref := map[string]any{"$ref": "#/components/messages/" + key}
+        if m.Direction == Publish {
+            publish = append(publish, ref)
+        } else {
+            subscribe = append(subscribe, ref)
+        }
+    }
+
+    ch := map[string]any{"description": description}
+    if len(publish) > 0 {
+        ch["publish"] = map[string]any{"operationId": "sendCommand", "message": map[string]any{"oneOf": publish}}
+    }
+    if len(subscribe) > 0 {
+        ch["subscribe"] = map[string]any{"operationId": "receiveEvent", "message": map[string]any{"oneOf": subscribe}}
+    }
+
+    infoDoc := map[string]any{"title": info.Title, "version": info.Version}
+    if info.Description != "" {
+        infoDoc["description"] = info.Description
+    }
+
+    return map[string]any{
+        "asyncapi":           "2.6.0",
+        "info":               infoDoc,
+        "defaultContentType": "application/json",
+        "channels":           map[string]any{channel: ch},
+        "components": map[string]any{
+            "messages": components,
+            "schemas":  g.Schemas(),
+        },
+    }
+}
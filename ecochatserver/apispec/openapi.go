@@ -0,0 +1,205 @@
+package apispec
+
+import (
+    "net/http"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// Info — заголовок документа
+type Info struct {
+    Title       string
+    Version     string
+    Description string
+}
+
+// Param — query- или header-параметр операции
+type Param struct {
+    Name        string
+    In          string // "query" | "header"
+    Description string
+    Required    bool
+    Example     any // значение того же типа задаёт тип параметра; nil — строка
+}
+
+// Response — тело ответа с кодом статуса
+type Response struct {
+    Description string
+    Body        any    // нулевое значение типа тела; nil — без тела
+    ContentType string // по умолчанию application/json
+}
+
+// Operation — один HTTP-маршрут
+type Operation struct {
+    Method      string
+    Path        string // в нотации gin: /chats/:id
+    Summary     string
+    Description string
+    Tag         string
+    Auth        bool // требуется Bearer JWT
+    Params      []Param
+    Request     any // нулевое значение типа тела запроса
+    RequestType string
+    Responses   map[int]Response
+}
+
+// Key — "METHOD /path" в нотации gin, для сверки с зарегистрированными маршрутами.
+func (o *Operation) Key() string {
+    return o.Method + " " + o.Path
+}
+
+// OpenAPI строит документ OpenAPI 3.0 по списку операций.
+func OpenAPI(info Info, ops []Operation) map[string]any {
+    g := NewGenerator()
+    paths := map[string]map[string]any{}
+    tags := map[string]bool{}
+
+    for i := range ops {
+        op := &ops[i]
+        path, pathParams := convertPath(op.Path)
+        if paths[path] == nil {
+            paths[path] = map[string]any{}
+        }
+        if op.Tag != "" {
+            tags[op.Tag] = true
+        }
+        paths[path][strings.ToLower(op.Method)] = g.operation(op, pathParams)
+    }
+
+    tagList := make([]map[string]any, 0, len(tags))
+    for _, name := range sortedKeys(tags) {
+        tagList = append(tagList, map[string]any{"name": name})
+    }
+
+    infoDoc := map[string]any{"title": info.Title, "version": info.Version}
+    if info.Description != "" {
+        infoDoc["description"] = info.Description
+    }
+
+    return map[string]any{
+        "openapi": "3.0.3",
+        "info":    infoDoc,
+        "tags":    tagList,
+        "paths":   paths,
+        "components": map[string]any{
+            "schemas": g.Schemas(),
+            "securitySchemes": map[string]any{
+                "bearerAuth": map[string]any{
+                    "type":         "http",
+                    "scheme":       "bearer",
+                    "bearerFormat": "JWT",
+                },
+            },
+        },
+    }
+}
+
+func (g *Generator) operation(op *Operation, pathParams []string) map[string]any {
+    doc := map[string]any{
+        "summary":     op.Summary,
+        "operationId": operationID(op),
+    }
+    if op.Description != "" {
+        doc["description"] = op.Description
+    }
+    if op.Tag != "" {
+        doc["tags"] = []string{op.Tag}
+    }
+    if op.Auth {
+        doc["security"] = []map[string][]string{{"bearerAuth": {}}}
+    }
+
+    var params []map[string]any
+    for _, name := range pathParams {
+        params = append(params, map[string]any{
+            "name":     name,
+            "in":       "path",
+            "required": true,
+            "schema":   Schema{"type": "string"},
+        })
+    }
+    for _, p := range op.Params {
+        s := Schema{"type": "string"}
+        if p.Example != nil {
+            s = g.SchemaOf(p.Example)
+        }
+        param := map[string]any{"name": p.Name, "in": p.In, "schema": s}
+        if p.Required {
+            param["required"] = true
+        }
+        if p.Description != "" {
+            param["description"] = p.Description
+        }
+        params = append(params, param)
+    }
+    if len(params) > 0 {
+        doc["parameters"] = params
+    }
+
+    if op.Request != nil {
+        ct := op.RequestType
+        if ct == "" {
+            ct = "application/json"
+        }
+        doc["requestBody"] = map[string]any{
+            "required": true,
+            "content":  map[string]any{ct: map[string]any{"schema": g.SchemaOf(op.Request)}},
+        }
+    }
+
+    responses := map[string]any{}
+    for code, r := range op.Responses {
+        resp := map[string]any{"description": r.Description}
+        if resp["description"] == "" {
+            resp["description"] = http.StatusText(code)
+        }
+        if r.Body != nil {
+            ct := r.ContentType
+            if ct == "" {
+                ct = "application/json"
+            }
+            resp["content"] = map[string]any{ct: map[string]any{"schema": g.SchemaOf(r.Body)}}
+        }
+        responses[strconv.Itoa(code)] = resp
+    }
+    doc["responses"] = responses
+    return doc
+}
+
+// convertPath переводит /chats/:id в /chats/{id} и возвращает имена параметров.
+func convertPath(path string) (string, []string) {
+    parts := strings.Split(path, "/")
+    var params []string
+    for i, p := range parts {
+        if strings.HasPrefix(p, ":") || strings.HasPrefix(p, "*") {
+            name := p[1:]
+            params = append(params, name)
+            parts[i] = "{" + name + "}"
+        }
+    }
+    return strings.Join(parts, "/"), params
+}
+
+// operationID строит стабильный идентификатор из метода и пути.
+func operationID(op *Operation) string {
+    var b strings.Builder
+    b.WriteString(strings.ToLower(op.Method))
+    for _, p := range strings.FieldsFunc(op.Path, func(r rune) bool { return r == '/' || r == '-' || r == '_' }) {
+        p = strings.TrimLeft(p, ":*")
+        if p == "api" {
+            continue
+        }
+        b.WriteString(upperFirst(p))
+    }
+    return b.String()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+    keys := make([]string, 0, len(m))
+    for k := range m {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    return keys
+}
@@ -0,0 +1,205 @@
+// Package apispec строит OpenAPI 3 и AsyncAPI 2 документы из Go-типов.
+//
+// Схемы выводятся рефлексией по json-тегам, поэтому изменение структуры
+// payload сразу меняет документ (см. cmd/specgen -check).
+package apispec
+
+import (
+    "encoding/json"
+    "reflect"
+    "strings"
+    "time"
+
+    "github.com/google/uuid"
+)
+
+var (
+    timeType       = reflect.TypeOf(time.Time{})
+    uuidType       = reflect.TypeOf(uuid.UUID{})
+    rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// Schema — JSON Schema объекта в виде, пригодном для json.Marshal
+type Schema = map[string]any
+
+// Generator собирает схемы именованных структур в components.schemas.
+type Generator struct {
+    schemas map[string]Schema
+    names   map[reflect.Type]string
+}
+
+// NewGenerator создаёт пустой генератор.
+func NewGenerator() *Generator {
+    return &Generator{
+        schemas: map[string]Schema{},
+        names:   map[reflect.Type]string{},
+    }
+}
+
+// Schemas возвращает накопленные схемы компонентов.
+func (g *Generator) Schemas() map[string]Schema {
+    return g.schemas
+}
+
+// SchemaOf возвращает схему значения v (обычно нулевого значения типа).
+// Именованные структуры выносятся в компоненты и подставляются через $ref.
+func (g *Generator) SchemaOf(v any) Schema {
+    if v == nil {
+        return Schema{}
+    }
+    return g.schema(reflect.TypeOf(v))
+}
+
+func (g *Generator) schema(t reflect.Type) Schema {
+    switch t {
+    case timeType:
+        return Schema{"type": "string", "format": "date-time"}
+    case uuidType:
+        return Schema{"type": "string", "format": "uuid"}
+    case rawMessageType:
+        return Schema{}
+    }
+
+    switch t.Kind() {
+    case reflect.Pointer:
+        s := g.schema(t.Elem())
+        if _, isRef := s["$ref"]; isRef {
+            return Schema{"allOf": []Schema{s}, "nullable": true}
+        }
+        s["nullable"] = true
+        return s
+    case reflect.String:
+        return Schema{"type": "string"}
+    case reflect.Bool:
+        return Schema{"type": "boolean"}
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+        reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+        return Schema{"type": "integer"}
+    case reflect.Float32, reflect.Float64:
+        return Schema{"type": "number"}
+    case reflect.Slice, reflect.Array:
+        if t.Elem().Kind() == reflect.Uint8 {
+            return Schema{"type": "string", "format": "byte"}
+        }
+        return Schema{"type": "array", "items": g.schema(t.Elem())}
+    case reflect.Map:
+        return Schema{"type": "object", "additionalProperties": g.schema(t.Elem())}
+    case reflect.Interface:
+        return Schema{}
+    case reflect.Struct:
+        return g.structSchema(t)
+    }
+    return Schema{}
+}
+
+// structSchema описывает структуру; именованные — через компонент и $ref.
+func (g *Generator) structSchema(t reflect.Type) Schema {
+    if t.Name() == "" {
+        return g.objectSchema(t)
+    }
+
+    name, ok := g.names[t]
+    if !ok {
+        name = g.componentName(t)
+        g.names[t] = name
+        // Заглушка до заполнения защищает от бесконечной рекурсии
+        g.schemas[name] = Schema{}
+        g.schemas[name] = g.objectSchema(t)
+    }
+    return Schema{"$ref": "#/components/schemas/" + name}
+}
+
+// componentName — имя компонента: Package.Type, с заглавной буквы.
+func (g *Generator) componentName(t reflect.Type) string {
+    pkg := t.PkgPath()
+    if i := strings.LastIndex(pkg, "/"); i >= 0 {
+        pkg = pkg[i+1:]
+    }
+    name := t.Name()
+    if pkg != "" {
+        name = upperFirst(pkg) + "." + upperFirst(name)
+    }
+    // Разные типы с одинаковым именем получают суффикс
+    base, n := name, 2
+    for g.taken(name, t) {
+        name = base + string(rune('0'+n))
+        n++
+    }
+    return name
+}
+
+func (g *Generator) taken(name string, t reflect.Type) bool {
+    for other, n := range g.names {
+        if n == name && other != t {
+            return true
+        }
+    }
+    return false
+}
+
+// objectSchema описывает поля структуры по json-тегам.
+func (g *Generator) objectSchema(t reflect.Type) Schema {
+    props := Schema{}
+    var required []string
+
+    for i := 0; i < t.NumField(); i++ {
+        f := t.Field(i)
+        if !f.IsExported() {
+            continue
+        }
+
+        name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+        if name == "-" && opts == "" {
+            continue
+        }
+
+        // Встроенные структуры без тега раскрываются в родителя
+        if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+            embedded := g.objectSchema(f.Type)
+            if p, ok := embedded["properties"].(Schema); ok {
+                for k, v := range p {
+                    props[k] = v
+                }
+            }
+            if r, ok := embedded["required"].([]string); ok {
+                required = append(required, r...)
+            }
+            continue
+        }
+
+        if name == "" {
+            name = f.Name
+        }
+        s := g.schema(f.Type)
+        if doc := f.Tag.Get("doc"); doc != "" {
+            s = withDescription(s, doc)
+        }
+        props[name] = s
+
+        if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Pointer {
+            required = append(required, name)
+        }
+    }
+
+    s := Schema{"type": "object", "properties": props}
+    if len(required) > 0 {
+        s["required"] = required
+    }
+    return s
+}
+
+// withDescription добавляет описание, не ломая $ref.
+func withDescription(s Schema, doc string) Schema {
+    if _, isRef := s["$ref"]; isRef {
+        return Schema{"allOf": []Schema{s}, "description": doc}
+    }
+    s["description"] = doc
+    return s
+}
+
+func upperFirst(s string) string {
+    if s == "" {
+        return s
+    }
+    return strings.ToUpper(s[:1]) + s[1:]
+}
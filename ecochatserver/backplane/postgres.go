@@ -0,0 +1,93 @@
+// Package backplane содержит реализации websocket.Backplane — шины, по
+// которой узлы кластера обмениваются событиями хаба.
+package backplane
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/egor/ecochatserver/database"
+)
+
+const (
+    // maxNotifyPayload — запас до лимита NOTIFY в 8000 байт
+    maxNotifyPayload = 7900
+    // payloadRefPrefix помечает уведомление-ссылку на hub_backplane_payloads
+    payloadRefPrefix = "@"
+
+    payloadTTL      = 5 * time.Minute
+    cleanupInterval = time.Minute
+)
+
+// Postgres — бэкплейн на LISTEN/NOTIFY. Крупные сообщения кладутся в
+// hub_backplane_payloads, а в канал уходит только их ID.
+type Postgres struct {
+    channel string
+}
+
+// NewPostgres создаёт бэкплейн на канале channel.
+func NewPostgres(channel string) *Postgres {
+    return &Postgres{channel: channel}
+}
+
+// Publish отправляет data всем узлам, подписанным на канал.
+func (p *Postgres) Publish(ctx context.Context, data []byte) error {
+    payload := string(data)
+    if len(data) > maxNotifyPayload {
+        id, err := database.SaveBackplanePayload(data)
+        if err != nil {
+            return err
+        }
+        payload = payloadRefPrefix + strconv.FormatInt(id, 10)
+    }
+    return database.Notify(ctx, p.channel, payload)
+}
+
+// Subscribe слушает канал до отмены ctx или обрыва соединения.
+func (p *Postgres) Subscribe(ctx context.Context, handle func(data []byte)) error {
+    ctx, cancel := context.WithCancel(ctx)
+    defer cancel()
+    go p.cleanup(ctx)
+
+    return database.Listen(ctx, p.channel, func(payload string) {
+        data, err := p.resolve(payload)
+        if err != nil {
+            log.Printf("backplane: %v", err)
+            return
+        }
+        handle(data)
+    })
+}
+
+// resolve возвращает сообщение по уведомлению, загружая крупные из таблицы.
+func (p *Postgres) resolve(payload string) ([]byte, error) {
+    if !strings.HasPrefix(payload, payloadRefPrefix) {
+        return []byte(payload), nil
+    }
+    id, err := strconv.ParseInt(strings.TrimPrefix(payload, payloadRefPrefix), 10, 64)
+    if err != nil {
+        return nil, fmt.Errorf("некорректная ссылка %q: %w", payload, err)
+    }
+    return database.GetBackplanePayload(id)
+}
+
+// cleanup периодически удаляет прочитанные крупные сообщения.
+func (p *Postgres) cleanup(ctx context.Context) {
+    ticker := time.NewTicker(cleanupInterval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+            if _, err := database.DeleteBackplanePayloads(payloadTTL); err != nil {
+                log.Printf("backplane: очистка: %v", err)
+            }
+        }
+    }
+}
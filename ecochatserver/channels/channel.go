@@ -0,0 +1,155 @@
+// Package channels — адаптеры источников сообщений (мессенджеры, виджет).
+// Каждый источник реализует Channel и регистрируется в реестре по имени,
+// совпадающему с models.Chat.Source.
+package channels
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "sort"
+    "sync"
+    "time"
+
+    "github.com/egor/ecochatserver/models"
+)
+
+// Ошибки, общие для всех адаптеров
+var (
+    ErrUnauthorized       = errors.New("вебхук не прошёл проверку подлинности")
+    ErrSendNotSupported   = errors.New("канал не поддерживает исходящую доставку")
+    ErrMissingCredentials = errors.New("для канала не настроены учётные данные бота")
+)
+
+// Capabilities описывает, что умеет канал.
+type Capabilities struct {
+    Attachments  bool `json:"attachments"`  // принимает/отправляет файлы и медиа
+    Typing       bool `json:"typing"`       // индикатор «печатает…»
+    ReadReceipts bool `json:"readReceipts"` // отметки о прочтении
+    Outbound     bool `json:"outbound"`     // ответы оператора доставляются через Send
+    InlineReply  bool `json:"inlineReply"`  // автоответ возвращается в HTTP-ответе вебхука
+}
+
+// InboundMessage — одно входящее сообщение, извлечённое из вебхука.
+type InboundMessage struct {
+    Message  models.IncomingMessage
+    DedupKey string // ключ дедупликации повторных доставок
+}
+
+// StatusUpdate — изменение статуса доставки исходящего сообщения у источника.
+type StatusUpdate struct {
+    ExternalID string // ID сообщения на стороне источника (metadata.delivery.externalId)
+    Status     string // "sent", "delivered", "read", "failed"
+    Error      string
+}
+
+// Inbound — результат разбора тела вебхука.
+type Inbound struct {
+    Messages []InboundMessage
+    Statuses []StatusUpdate
+    Rejected int // события, отброшенные как чужие для бота из URL
+}
+
+// Target — адресат исходящего сообщения.
+type Target struct {
+    Chat        *models.Chat
+    Bot         *models.Bot     // nil для каналов без учётных данных
+    LastInbound *models.Message // последнее сообщение пользователя (для цепочек писем), может быть nil
+}
+
+// SendResult — результат успешной отправки.
+type SendResult struct {
+    ExternalID string // ID сообщения на стороне источника
+}
+
+// Channel — адаптер источника сообщений.
+type Channel interface {
+    // Name — имя источника, совпадает с chats.source
+    Name() string
+    // Capabilities — возможности канала
+    Capabilities() Capabilities
+    // VerifyWebhook проверяет подлинность входящего запроса.
+    // bot — учётные данные из URL вебхука (nil, если бот в URL не указан).
+    VerifyWebhook(r *http.Request, body []byte, bot *models.Bot) error
+    // ParseInbound разбирает тело вебхука в нормализованные сообщения
+    ParseInbound(body []byte, bot *models.Bot) (*Inbound, error)
+    // Send доставляет сообщение оператора пользователю
+    Send(ctx context.Context, target Target, msg *models.Message) (*SendResult, error)
+}
+
+// Acknowledger — канал, которому нужно подтвердить источнику приём сообщения
+// (например, answerCallbackQuery в Telegram).
+type Acknowledger interface {
+    Acknowledge(ctx context.Context, bot *models.Bot, msg *models.IncomingMessage) error
+}
+
+// Challenger — канал, который подтверждает вебхук GET-запросом
+// (например, hub.challenge в WhatsApp Cloud API).
+type Challenger interface {
+    Challenge(r *http.Request, bot *models.Bot) (string, error)
+}
+
+// ─────────────────────────────── реестр
+
+var (
+    registryMu sync.RWMutex
+    registry   = make(map[string]Channel)
+)
+
+// Register добавляет канал в реестр (повторная регистрация заменяет прежний).
+func Register(ch Channel) {
+    registryMu.Lock()
+    defer registryMu.Unlock()
+    registry[ch.Name()] = ch
+}
+
+// Get возвращает канал по имени источника.
+func Get(name string) (Channel, bool) {
+    registryMu.RLock()
+    defer registryMu.RUnlock()
+    ch, ok := registry[name]
+    return ch, ok
+}
+
+// Names возвращает имена зарегистрированных каналов.
+func Names() []string {
+    registryMu.RLock()
+    defer registryMu.RUnlock()
+    names := make([]string, 0, len(registry))
+    for name := range registry {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// ─────────────────────────────── ошибки доставки
+
+// temporary реализуют ошибки, которые имеет смысл повторить.
+type temporary interface {
+    Temporary() bool
+}
+
+// retryDelayer реализуют ошибки с подсказкой источника о задержке.
+type retryDelayer interface {
+    RetryDelay() time.Duration
+}
+
+// IsTemporary сообщает, стоит ли повторять отправку после err.
+// Ошибки без признака Temporary считаются временными.
+func IsTemporary(err error) bool {
+    var t temporary
+    if errors.As(err, &t) {
+        return t.Temporary()
+    }
+    return !errors.Is(err, ErrSendNotSupported) && !errors.Is(err, ErrMissingCredentials)
+}
+
+// RetryDelay возвращает задержку, запрошенную источником (0 — не указана).
+func RetryDelay(err error) time.Duration {
+    var r retryDelayer
+    if errors.As(err, &r) {
+        return r.RetryDelay()
+    }
+    return 0
+}
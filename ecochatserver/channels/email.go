@@ -0,0 +1,198 @@
+package channels
+
+import (
+    "context"
+    "crypto/subtle"
+    "net/http"
+
+    "github.com/egor/ecochatserver/email"
+    "github.com/egor/ecochatserver/models"
+)
+
+// SourceEmail — имя источника электронной почты
+const SourceEmail = "email"
+
+// Ключи настроек бота для почтового ящика
+const (
+    emailSettingSMTPAddr     = "smtpAddr"     // свой релей вместо общего
+    emailSettingSMTPUsername = "smtpUsername" // пароль — в client_bots.token
+    emailSettingFromName     = "fromName"
+)
+
+// Email — адаптер почтового ящика поддержки.
+// Учётные данные в client_bots: bot_id — адрес ящика (support@…),
+// webhook_secret — секрет для HTTP-приёма писем (X-Webhook-Secret).
+type Email struct {
+    relay email.Relay
+}
+
+// NewEmail создаёт адаптер с общим SMTP-релеем для ответов.
+func NewEmail(relay email.Relay) *Email {
+    return &Email{relay: relay}
+}
+
+func (e *Email) Name() string { return SourceEmail }
+
+func (e *Email) Capabilities() Capabilities {
+    return Capabilities{
+        Attachments: true,
+        Outbound:    true,
+    }
+}
+
+// VerifyWebhook проверяет секрет при приёме писем по HTTP (сырой MIME в теле).
+// Письма со встроенного SMTP-приёмника проверку не проходят — ящик уже найден по RCPT TO.
+func (e *Email) VerifyWebhook(r *http.Request, body []byte, bot *models.Bot) error {
+    if bot == nil || bot.Secret == "" {
+        return ErrUnauthorized
+    }
+    if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Webhook-Secret")), []byte(bot.Secret)) != 1 {
+        return ErrUnauthorized
+    }
+    return nil
+}
+
+// ParseInbound разбирает письмо RFC 5322. Чат ключуется адресом отправителя
+// и веткой писем: корнем цепочки References/In-Reply-To, а для нового письма —
+// его собственным Message-ID. Заголовки цепочки и обе версии текста
+// сохраняются в metadata.
+func (e *Email) ParseInbound(body []byte, bot *models.Bot) (*Inbound, error) {
+    msg, err := email.Parse(body)
+    if err != nil {
+        return nil, err
+    }
+
+    meta := map[string]interface{}{
+        "emailSubject": msg.Subject,
+        "text":         msg.Text,
+    }
+    if msg.HTML != "" {
+        meta["html"] = msg.HTML
+    }
+    if msg.MessageID != "" {
+        meta["emailMessageId"] = msg.MessageID
+        meta["sourceMessageId"] = msg.MessageID
+    }
+    if msg.InReplyTo != "" {
+        meta["emailInReplyTo"] = msg.InReplyTo
+    }
+    if len(msg.References) > 0 {
+        meta["emailReferences"] = msg.References
+    }
+    if len(msg.Attachments) > 0 {
+        meta["attachments"] = msg.Attachments
+    }
+
+    name := msg.FromName
+    if name == "" {
+        name = msg.From
+    }
+
+    msgType := "text"
+    content := msg.Body()
+    if content == "" && len(msg.Attachments) > 0 {
+        msgType = "file"
+    }
+
+    // Одно письмо может прийти сразу в несколько ящиков — ключ на ящик
+    dedupKey := ""
+    if msg.MessageID != "" {
+        dedupKey = "email_" + bot.BotID + "_" + msg.MessageID
+    }
+
+    return &Inbound{Messages: []InboundMessage{{
+        Message: models.IncomingMessage{
+            UserID:      msg.From,
+            UserName:    name,
+            UserEmail:   msg.From,
+            SourceID:    msg.From,
+            Content:     content,
+            Source:      SourceEmail,
+            BotID:       bot.BotID,
+            ClientID:    bot.ClientID.String(),
+            MessageType: msgType,
+            Metadata:    meta,
+            ThreadID:    emailThreadID(msg),
+            ThreadRefs:  emailThreadRefs(msg),
+        },
+        DedupKey: dedupKey,
+    }}}, nil
+}
+
+// emailThreadID возвращает корень цепочки письма: первый References,
+// иначе In-Reply-To, иначе собственный Message-ID (новая ветка).
+func emailThreadID(msg *email.Message) string {
+    switch {
+    case len(msg.References) > 0:
+        return msg.References[0]
+    case msg.InReplyTo != "":
+        return msg.InReplyTo
+    case msg.MessageID != "":
+        return msg.MessageID
+    }
+    // Без Message-ID цепочку не восстановить — каждое такое письмо в своём чате
+    return email.NewMessageID("thread")
+}
+
+// emailThreadRefs — письма, на которые отвечает msg, от ближайшего к корню.
+func emailThreadRefs(msg *email.Message) []string {
+    var refs []string
+    if msg.InReplyTo != "" {
+        refs = append(refs, msg.InReplyTo)
+    }
+    for i := len(msg.References) - 1; i >= 0; i-- {
+        if msg.References[i] != msg.InReplyTo {
+            refs = append(refs, msg.References[i])
+        }
+    }
+    return refs
+}
+
+// Send отправляет ответ оператора письмом в ту же цепочку.
+func (e *Email) Send(ctx context.Context, target Target, msg *models.Message) (*SendResult, error) {
+    if target.Bot == nil {
+        return nil, ErrMissingCredentials
+    }
+
+    relay := e.relay
+    if addr := target.Bot.Settings[emailSettingSMTPAddr]; addr != "" {
+        relay = email.Relay{
+            Addr:     addr,
+            Username: target.Bot.Settings[emailSettingSMTPUsername],
+            Password: target.Bot.Token,
+        }
+    }
+
+    out := &email.Outgoing{
+        From:      target.Bot.BotID,
+        FromName:  target.Bot.Settings[emailSettingFromName],
+        To:        target.Chat.User.SourceID,
+        Subject:   email.ReplySubject(""),
+        MessageID: email.NewMessageID(target.Bot.BotID),
+        Text:      msg.Content,
+    }
+
+    // Цепочка: In-Reply-To — последнее письмо пользователя,
+    // References — его References + его Message-ID
+    if last := target.LastInbound; last != nil {
+        if subject, ok := last.Metadata["emailSubject"].(string); ok {
+            out.Subject = email.ReplySubject(subject)
+        }
+        if refs, ok := last.Metadata["emailReferences"].([]interface{}); ok {
+            for _, ref := range refs {
+                if s, ok := ref.(string); ok {
+                    out.References = append(out.References, s)
+                }
+            }
+        }
+        if id, ok := last.Metadata["emailMessageId"].(string); ok && id != "" {
+            out.InReplyTo = id
+            out.References = append(out.References, id)
+        }
+    }
+
+    if err := relay.Send(out); err != nil {
+        return nil, err
+    }
+    return &SendResult{ExternalID: out.MessageID}, nil
+}
@@ -0,0 +1,104 @@
+package channels
+
+import (
+    "reflect"
+    "strings"
+    "testing"
+
+    "github.com/google/uuid"
+
+    "github.com/egor/ecochatserver/email"
+    "github.com/egor/ecochatserver/models"
+)
+
+func rawEmail(headers ...string) []byte {
+    return []byte(strings.Join(append([]string{
+        "From: Ivan <ivan@example.com>",
+        "To: support@shop.example",
+        "Subject: Заказ",
+        "Content-Type: text/plain; charset=utf-8",
+    }, headers...), "\r\n") + "\r\n\r\nТекст письма\r\n")
+}
+
+func TestEmailParseInboundThread(t *testing.T) {
+    bot := &models.Bot{ClientID: uuid.New(), Source: SourceEmail, BotID: "support@shop.example"}
+
+    tests := []struct {
+        name       string
+        headers    []string
+        wantThread string
+        wantRefs   []string
+        wantDedup  string
+    }{
+        {
+            name:       "new thread",
+            headers:    []string{"Message-ID: <a@example.com>"},
+            wantThread: "<a@example.com>",
+            wantDedup:  "email_support@shop.example_<a@example.com>",
+        },
+        {
+            name:       "reply with in-reply-to only",
+            headers:    []string{"Message-ID: <b@example.com>", "In-Reply-To: <r1@shop.example>"},
+            wantThread: "<r1@shop.example>",
+            wantRefs:   []string{"<r1@shop.example>"},
+            wantDedup:  "email_support@shop.example_<b@example.com>",
+        },
+        {
+            name: "reply with references",
+            headers: []string{
+                "Message-ID: <c@example.com>",
+                "In-Reply-To: <r2@shop.example>",
+                "References: <a@example.com> <r1@shop.example> <r2@shop.example>",
+            },
+            wantThread: "<a@example.com>",
+            wantRefs:   []string{"<r2@shop.example>", "<r1@shop.example>", "<a@example.com>"},
+            wantDedup:  "email_support@shop.example_<c@example.com>",
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            inbound, err := NewEmail(email.Relay{}).ParseInbound(rawEmail(tt.headers...), bot)
+            if err != nil {
+                t.Fatalf("ParseInbound: %v", err)
+            }
+            if len(inbound.Messages) != 1 {
+                t.Fatalf("сообщений %d, ожидалось 1", len(inbound.Messages))
+            }
+            item := inbound.Messages[0]
+            if item.Message.ThreadID != tt.wantThread {
+                t.Errorf("ThreadID = %q, want %q", item.Message.ThreadID, tt.wantThread)
+            }
+            if !reflect.DeepEqual(item.Message.ThreadRefs, tt.wantRefs) {
+                t.Errorf("ThreadRefs = %q, want %q", item.Message.ThreadRefs, tt.wantRefs)
+            }
+            if item.DedupKey != tt.wantDedup {
+                t.Errorf("DedupKey = %q, want %q", item.DedupKey, tt.wantDedup)
+            }
+        })
+    }
+}
+
+func TestEmailParseInboundWithoutMessageID(t *testing.T) {
+    bot := &models.Bot{ClientID: uuid.New(), Source: SourceEmail, BotID: "support@shop.example"}
+    ch := NewEmail(email.Relay{})
+
+    first, err := ch.ParseInbound(rawEmail(), bot)
+    if err != nil {
+        t.Fatalf("ParseInbound: %v", err)
+    }
+    second, err := ch.ParseInbound(rawEmail(), bot)
+    if err != nil {
+        t.Fatalf("ParseInbound: %v", err)
+    }
+
+    a, b := first.Messages[0], second.Messages[0]
+    if a.Message.ThreadID == "" || a.Message.ThreadID == b.Message.ThreadID {
+        t.Errorf("письма без Message-ID должны открывать разные ветки: %q и %q", a.Message.ThreadID, b.Message.ThreadID)
+    }
+    if a.DedupKey != "" {
+        t.Errorf("DedupKey = %q, ожидался пустой", a.DedupKey)
+    }
+    if _, ok := a.Message.Metadata["sourceMessageId"]; ok {
+        t.Error("sourceMessageId не должен заполняться без Message-ID")
+    }
+}
@@ -0,0 +1,99 @@
+package channels
+
+import (
+    "context"
+    "crypto/subtle"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "strconv"
+
+    "github.com/egor/ecochatserver/models"
+    "github.com/egor/ecochatserver/telegram"
+)
+
+// SourceTelegram — имя источника Telegram
+const SourceTelegram = "telegram"
+
+// Telegram — адаптер Telegram Bot API.
+type Telegram struct {
+    api *telegram.Client
+}
+
+// NewTelegram создаёт адаптер поверх клиента Bot API.
+func NewTelegram(api *telegram.Client) *Telegram {
+    return &Telegram{api: api}
+}
+
+func (t *Telegram) Name() string { return SourceTelegram }
+
+func (t *Telegram) Capabilities() Capabilities {
+    return Capabilities{
+        Attachments: true,
+        Typing:      true,
+        Outbound:    true,
+    }
+}
+
+// VerifyWebhook сверяет X-Telegram-Bot-Api-Secret-Token с секретом бота.
+// Бот без секрета не принимается: иначе вебхук открыт всем.
+func (t *Telegram) VerifyWebhook(r *http.Request, body []byte, bot *models.Bot) error {
+    if bot == nil || bot.Secret == "" {
+        return ErrUnauthorized
+    }
+    provided := r.Header.Get("X-Telegram-Bot-Api-Secret-Token")
+    if subtle.ConstantTimeCompare([]byte(provided), []byte(bot.Secret)) != 1 {
+        return ErrUnauthorized
+    }
+    return nil
+}
+
+// ParseInbound разбирает нативный Update. Неподдерживаемые обновления
+// дают пустой результат — Telegram их нужно просто подтвердить.
+func (t *Telegram) ParseInbound(body []byte, bot *models.Bot) (*Inbound, error) {
+    var update telegram.Update
+    if err := json.Unmarshal(body, &update); err != nil {
+        return nil, fmt.Errorf("разбор Update: %w", err)
+    }
+
+    parsed, ok := telegram.ParseUpdate(&update)
+    if !ok {
+        return &Inbound{}, nil
+    }
+
+    return &Inbound{Messages: []InboundMessage{{
+        Message: models.IncomingMessage{
+            UserID:      parsed.UserID,
+            UserName:    parsed.UserName,
+            SourceID:    parsed.ChatID,
+            Content:     parsed.Content,
+            Source:      SourceTelegram,
+            BotID:       bot.BotID,
+            ClientID:    bot.ClientID.String(),
+            MessageType: parsed.MessageType,
+            Metadata:    parsed.Metadata,
+        },
+        DedupKey: fmt.Sprintf("tg_%s_%d", bot.BotID, update.UpdateID),
+    }}}, nil
+}
+
+// Send отправляет текст в чат Telegram пользователя (chat.id хранится в users.source_id).
+func (t *Telegram) Send(ctx context.Context, target Target, msg *models.Message) (*SendResult, error) {
+    if target.Bot == nil || target.Bot.Token == "" {
+        return nil, ErrMissingCredentials
+    }
+    sent, err := t.api.SendMessage(ctx, target.Bot.Token, target.Chat.User.SourceID, msg.Content)
+    if err != nil {
+        return nil, err
+    }
+    return &SendResult{ExternalID: strconv.FormatInt(sent.MessageID, 10)}, nil
+}
+
+// Acknowledge отвечает на callback_query, чтобы у пользователя пропали «часики».
+func (t *Telegram) Acknowledge(ctx context.Context, bot *models.Bot, msg *models.IncomingMessage) error {
+    queryID, ok := msg.Metadata["callbackQueryId"].(string)
+    if !ok || bot == nil {
+        return nil
+    }
+    return t.api.AnswerCallbackQuery(ctx, bot.Token, queryID)
+}
@@ -0,0 +1,228 @@
+package channels
+
+import (
+    "context"
+    "crypto/subtle"
+    "encoding/json"
+    "fmt"
+    "net/http"
+
+    "github.com/egor/ecochatserver/models"
+    "github.com/egor/ecochatserver/whatsapp"
+)
+
+// SourceWhatsApp — имя источника WhatsApp
+const SourceWhatsApp = "whatsapp"
+
+// Ключ настройки бота с verify token для GET-подтверждения вебхука
+const whatsappVerifyTokenSetting = "verifyToken"
+
+// WhatsApp — адаптер WhatsApp Cloud API.
+// Учётные данные в client_bots: bot_id — phone_number_id, token — access token,
+// webhook_secret — app secret, settings.verifyToken — verify token вебхука.
+type WhatsApp struct {
+    api *whatsapp.Client
+}
+
+// NewWhatsApp создаёт адаптер поверх клиента Graph API.
+func NewWhatsApp(api *whatsapp.Client) *WhatsApp {
+    return &WhatsApp{api: api}
+}
+
+func (w *WhatsApp) Name() string { return SourceWhatsApp }
+
+func (w *WhatsApp) Capabilities() Capabilities {
+    return Capabilities{
+        Attachments:  true,
+        ReadReceipts: true,
+        Outbound:     true,
+    }
+}
+
+// Challenge отвечает на GET-подтверждение подписки (hub.mode=subscribe).
+func (w *WhatsApp) Challenge(r *http.Request, bot *models.Bot) (string, error) {
+    q := r.URL.Query()
+    if bot == nil || q.Get("hub.mode") != "subscribe" {
+        return "", ErrUnauthorized
+    }
+    expected := bot.Settings[whatsappVerifyTokenSetting]
+    if expected == "" || subtle.ConstantTimeCompare([]byte(q.Get("hub.verify_token")), []byte(expected)) != 1 {
+        return "", ErrUnauthorized
+    }
+    return q.Get("hub.challenge"), nil
+}
+
+// VerifyWebhook проверяет подпись X-Hub-Signature-256 секретом приложения клиента.
+func (w *WhatsApp) VerifyWebhook(r *http.Request, body []byte, bot *models.Bot) error {
+    if bot == nil || !whatsapp.VerifySignature(body, r.Header.Get("X-Hub-Signature-256"), bot.Secret) {
+        return ErrUnauthorized
+    }
+    return nil
+}
+
+// ParseInbound разбирает уведомление Cloud API: сообщения и статусы доставки.
+// Один вебхук приложения получает события всех его номеров, но подпись
+// подтверждает только бота из URL, поэтому события других номеров
+// (metadata.phone_number_id ≠ bot_id) отбрасываются.
+func (w *WhatsApp) ParseInbound(body []byte, bot *models.Bot) (*Inbound, error) {
+    if bot == nil {
+        return nil, ErrUnauthorized
+    }
+    var n whatsapp.Notification
+    if err := json.Unmarshal(body, &n); err != nil {
+        return nil, fmt.Errorf("разбор уведомления WhatsApp: %w", err)
+    }
+
+    inbound := &Inbound{}
+    for _, entry := range n.Entry {
+        for _, change := range entry.Changes {
+            if change.Field != "messages" {
+                continue
+            }
+            v := change.Value
+            if v.Metadata.PhoneNumberID != bot.BotID {
+                inbound.Rejected++
+                continue
+            }
+
+            names := make(map[string]string, len(v.Contacts))
+            for _, contact := range v.Contacts {
+                names[contact.WaID] = contact.Profile.Name
+            }
+
+            for _, m := range v.Messages {
+                in, ok := whatsappIncoming(&m)
+                if !ok {
+                    continue
+                }
+                in.UserName = names[m.From]
+                if in.UserName == "" {
+                    in.UserName = "+" + m.From
+                }
+                in.Source = SourceWhatsApp
+                in.BotID = v.Metadata.PhoneNumberID
+                in.ClientID = bot.ClientID.String()
+                in.Metadata["whatsappPhoneNumberId"] = v.Metadata.PhoneNumberID
+
+                inbound.Messages = append(inbound.Messages, InboundMessage{
+                    Message:  *in,
+                    DedupKey: "wa_" + m.ID,
+                })
+            }
+
+            for _, st := range v.Statuses {
+                update := StatusUpdate{ExternalID: st.ID, Status: st.Status}
+                if len(st.Errors) > 0 {
+                    update.Error = fmt.Sprintf("%d: %s", st.Errors[0].Code, st.Errors[0].Title)
+                }
+                inbound.Statuses = append(inbound.Statuses, update)
+            }
+        }
+    }
+    return inbound, nil
+}
+
+// whatsappIncoming переводит сообщение Cloud API в IncomingMessage.
+func whatsappIncoming(m *whatsapp.Message) (*models.IncomingMessage, bool) {
+    in := &models.IncomingMessage{
+        UserID:   m.From,
+        SourceID: m.From,
+        Metadata: map[string]interface{}{
+            "whatsappMessageId": m.ID,
+            "whatsappTimestamp": m.Timestamp,
+        },
+    }
+    if m.Context != nil && m.Context.ID != "" {
+        in.Metadata["replyTo"] = m.Context.ID
+    }
+
+    media := func(msgType string, md *whatsapp.Media) {
+        in.MessageType = msgType
+        in.Content = md.Caption
+        in.Metadata["mediaId"] = md.ID
+        in.Metadata["mimeType"] = md.MimeType
+        if md.Filename != "" {
+            in.Metadata["fileName"] = md.Filename
+        }
+    }
+
+    switch m.Type {
+    case "text":
+        if m.Text == nil {
+            return nil, false
+        }
+        in.MessageType = "text"
+        in.Content = m.Text.Body
+    case "image":
+        if m.Image == nil {
+            return nil, false
+        }
+        media("image", m.Image)
+    case "video":
+        if m.Video == nil {
+            return nil, false
+        }
+        media("video", m.Video)
+    case "audio":
+        if m.Audio == nil {
+            return nil, false
+        }
+        media("voice", m.Audio)
+    case "document":
+        if m.Document == nil {
+            return nil, false
+        }
+        media("file", m.Document)
+    case "sticker":
+        if m.Sticker == nil {
+            return nil, false
+        }
+        media("sticker", m.Sticker)
+    case "location":
+        if m.Location == nil {
+            return nil, false
+        }
+        in.MessageType = "location"
+        in.Content = m.Location.Name
+        in.Metadata["latitude"] = m.Location.Latitude
+        in.Metadata["longitude"] = m.Location.Longitude
+        in.Metadata["address"] = m.Location.Address
+    case "button":
+        if m.Button == nil {
+            return nil, false
+        }
+        in.MessageType = "callback"
+        in.Content = m.Button.Text
+        in.Metadata["payload"] = m.Button.Payload
+    case "interactive":
+        if m.Interactive == nil {
+            return nil, false
+        }
+        in.MessageType = "callback"
+        switch {
+        case m.Interactive.ButtonReply != nil:
+            in.Content = m.Interactive.ButtonReply.Title
+            in.Metadata["payload"] = m.Interactive.ButtonReply.ID
+        case m.Interactive.ListReply != nil:
+            in.Content = m.Interactive.ListReply.Title
+            in.Metadata["payload"] = m.Interactive.ListReply.ID
+        default:
+            return nil, false
+        }
+    default:
+        return nil, false
+    }
+    return in, true
+}
+
+// Send отправляет текст пользователю (wa_id хранится в users.source_id).
+func (w *WhatsApp) Send(ctx context.Context, target Target, msg *models.Message) (*SendResult, error) {
+    if target.Bot == nil || target.Bot.Token == "" {
+        return nil, ErrMissingCredentials
+    }
+    wamid, err := w.api.SendText(ctx, target.Bot.Token, target.Bot.BotID, target.Chat.User.SourceID, msg.Content)
+    if err != nil {
+        return nil, err
+    }
+    return &SendResult{ExternalID: wamid}, nil
+}
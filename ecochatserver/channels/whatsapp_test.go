@@ -0,0 +1,176 @@
+package channels
+
+import (
+    "context"
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+
+    "github.com/google/uuid"
+
+    "github.com/egor/ecochatserver/models"
+    "github.com/egor/ecochatserver/whatsapp"
+)
+
+const waNotification = `{
+  "object": "whatsapp_business_account",
+  "entry": [{
+    "id": "WABA",
+    "changes": [
+      {
+        "field": "messages",
+        "value": {
+          "messaging_product": "whatsapp",
+          "metadata": {"display_phone_number": "15550001111", "phone_number_id": "1001"},
+          "contacts": [{"wa_id": "79990001122", "profile": {"name": "Иван"}}],
+          "messages": [
+            {"from": "79990001122", "id": "wamid.IN1", "timestamp": "1700000000", "type": "text", "text": {"body": "привет"}},
+            {"from": "79990001122", "id": "wamid.IN2", "timestamp": "1700000001", "type": "image", "image": {"id": "media1", "mime_type": "image/jpeg", "caption": "фото"}}
+          ],
+          "statuses": [
+            {"id": "wamid.OUT1", "status": "delivered", "timestamp": "1700000002", "recipient_id": "79990001122"},
+            {"id": "wamid.OUT2", "status": "failed", "timestamp": "1700000003", "recipient_id": "79990001122",
+             "errors": [{"code": 131047, "title": "Re-engagement message"}]}
+          ]
+        }
+      },
+      {
+        "field": "messages",
+        "value": {
+          "messaging_product": "whatsapp",
+          "metadata": {"display_phone_number": "15550002222", "phone_number_id": "2002"},
+          "messages": [{"from": "70000000000", "id": "wamid.FOREIGN", "timestamp": "1700000004", "type": "text", "text": {"body": "чужой"}}],
+          "statuses": [{"id": "wamid.FOREIGN_OUT", "status": "read", "timestamp": "1700000005", "recipient_id": "70000000000"}]
+        }
+      }
+    ]
+  }]
+}`
+
+func waBot() *models.Bot {
+    return &models.Bot{
+        ClientID: uuid.New(),
+        Source:   SourceWhatsApp,
+        BotID:    "1001",
+        Token:    "tok",
+        Secret:   "app-secret",
+        Settings: map[string]string{whatsappVerifyTokenSetting: "verify-me"},
+    }
+}
+
+func waSign(body []byte, secret string) string {
+    mac := hmac.New(sha256.New, []byte(secret))
+    mac.Write(body)
+    return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestWhatsAppVerifyWebhook(t *testing.T) {
+    body := []byte(waNotification)
+    ch := NewWhatsApp(whatsapp.NewClientWithURL("http://unused", "v19.0", nil))
+
+    tests := []struct {
+        name      string
+        signature string
+        bot       *models.Bot
+        wantErr   error
+    }{
+        {name: "valid", signature: waSign(body, "app-secret"), bot: waBot()},
+        {name: "wrong secret", signature: waSign(body, "other"), bot: waBot(), wantErr: ErrUnauthorized},
+        {name: "missing header", bot: waBot(), wantErr: ErrUnauthorized},
+        {name: "no bot", signature: waSign(body, "app-secret"), wantErr: ErrUnauthorized},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            r := httptest.NewRequest(http.MethodPost, "/api/whatsapp/webhook/1001", strings.NewReader(waNotification))
+            if tt.signature != "" {
+                r.Header.Set("X-Hub-Signature-256", tt.signature)
+            }
+            if err := ch.VerifyWebhook(r, body, tt.bot); !errors.Is(err, tt.wantErr) {
+                t.Fatalf("err = %v, want %v", err, tt.wantErr)
+            }
+        })
+    }
+}
+
+func TestWhatsAppChallenge(t *testing.T) {
+    ch := NewWhatsApp(nil)
+    r := httptest.NewRequest(http.MethodGet, "/?hub.mode=subscribe&hub.verify_token=verify-me&hub.challenge=42", nil)
+    if got, err := ch.Challenge(r, waBot()); err != nil || got != "42" {
+        t.Fatalf("Challenge = %q, %v", got, err)
+    }
+    r = httptest.NewRequest(http.MethodGet, "/?hub.mode=subscribe&hub.verify_token=wrong&hub.challenge=42", nil)
+    if _, err := ch.Challenge(r, waBot()); !errors.Is(err, ErrUnauthorized) {
+        t.Fatalf("неверный verify token принят: %v", err)
+    }
+}
+
+func TestWhatsAppParseInbound(t *testing.T) {
+    bot := waBot()
+    in, err := NewWhatsApp(nil).ParseInbound([]byte(waNotification), bot)
+    if err != nil {
+        t.Fatalf("ParseInbound: %v", err)
+    }
+
+    if len(in.Messages) != 2 {
+        t.Fatalf("сообщений %d, want 2 (события номера 2002 отбрасываются)", len(in.Messages))
+    }
+    text := in.Messages[0].Message
+    if text.Content != "привет" || text.UserName != "Иван" || text.BotID != "1001" ||
+        text.ClientID != bot.ClientID.String() || text.Source != SourceWhatsApp {
+        t.Errorf("текстовое сообщение = %+v", text)
+    }
+    if in.Messages[0].DedupKey != "wa_wamid.IN1" {
+        t.Errorf("DedupKey = %q", in.Messages[0].DedupKey)
+    }
+    image := in.Messages[1].Message
+    if image.MessageType != "image" || image.Content != "фото" || image.Metadata["mediaId"] != "media1" {
+        t.Errorf("фото = %+v", image)
+    }
+
+    want := []StatusUpdate{
+        {ExternalID: "wamid.OUT1", Status: "delivered"},
+        {ExternalID: "wamid.OUT2", Status: "failed", Error: "131047: Re-engagement message"},
+    }
+    if len(in.Statuses) != len(want) {
+        t.Fatalf("статусы = %+v, want %+v", in.Statuses, want)
+    }
+    for i := range want {
+        if in.Statuses[i] != want[i] {
+            t.Errorf("статус %d = %+v, want %+v", i, in.Statuses[i], want[i])
+        }
+    }
+    if in.Rejected != 1 {
+        t.Errorf("Rejected = %d, want 1", in.Rejected)
+    }
+}
+
+func TestWhatsAppSend(t *testing.T) {
+    var path string
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        path = r.URL.Path
+        w.Write([]byte(`{"messages":[{"id":"wamid.OUT1"}]}`))
+    }))
+    defer srv.Close()
+
+    ch := NewWhatsApp(whatsapp.NewClientWithURL(srv.URL, "v19.0", srv.Client()))
+    target := Target{
+        Chat: &models.Chat{User: models.User{SourceID: "79990001122"}},
+        Bot:  waBot(),
+    }
+    res, err := ch.Send(context.Background(), target, &models.Message{Content: "ответ"})
+    if err != nil {
+        t.Fatalf("Send: %v", err)
+    }
+    if res.ExternalID != "wamid.OUT1" || path != "/v19.0/1001/messages" {
+        t.Errorf("ExternalID %q, путь %q", res.ExternalID, path)
+    }
+
+    if _, err := ch.Send(context.Background(), Target{Chat: target.Chat}, &models.Message{}); !errors.Is(err, ErrMissingCredentials) {
+        t.Errorf("без бота: %v, want ErrMissingCredentials", err)
+    }
+}
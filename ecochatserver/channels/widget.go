@@ -0,0 +1,99 @@
+package channels
+
+import (
+    "context"
+    "crypto/subtle"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "strings"
+    "time"
+
+    "github.com/egor/ecochatserver/models"
+)
+
+// SourceWidget — имя источника веб-виджета
+const SourceWidget = "widget"
+
+// Widget — адаптер веб-виджета и ретрансляторов, присылающих
+// сообщения в собственном формате models.IncomingMessage.
+// Ответы оператора виджет получает по WebSocket, поэтому Send не поддерживается.
+//
+// Клиент определяется не телом запроса, а учётными данными: для
+// /api/webhook/incoming это API ключ клиента (bot.Secret), для
+// /api/channels/widget/webhook/:botId — секрет бота.
+type Widget struct{}
+
+// NewWidget создаёт адаптер виджета.
+func NewWidget() *Widget {
+    return &Widget{}
+}
+
+func (w *Widget) Name() string { return SourceWidget }
+
+func (w *Widget) Capabilities() Capabilities {
+    return Capabilities{
+        Typing:       true,
+        ReadReceipts: true,
+        InlineReply:  true,
+    }
+}
+
+// VerifyWebhook проверяет Content-Type и сверяет заголовок X-API-Key с секретом бота.
+func (w *Widget) VerifyWebhook(r *http.Request, body []byte, bot *models.Bot) error {
+    if bot == nil || bot.Secret == "" {
+        return ErrUnauthorized
+    }
+    provided := r.Header.Get("X-API-Key")
+    if subtle.ConstantTimeCompare([]byte(provided), []byte(bot.Secret)) != 1 {
+        return ErrUnauthorized
+    }
+    if !strings.Contains(r.Header.Get("Content-Type"), "application/json") {
+        return fmt.Errorf("Content-Type должен быть application/json")
+    }
+    return nil
+}
+
+// ParseInbound разбирает IncomingMessage. Клиент всегда берётся из bot:
+// clientId из тела игнорируется, иначе запрос мог бы писать в чужого клиента.
+//
+// source из тела принимается только с API ключом клиента (bot без BotID):
+// ретрансляторы старого формата присылают так сообщения мессенджеров, и
+// ответы оператора должны уходить обратно в мессенджер. Источник должен быть
+// зарегистрированным каналом. С секретом бота виджета допустим только "widget".
+func (w *Widget) ParseInbound(body []byte, bot *models.Bot) (*Inbound, error) {
+    if bot == nil {
+        return nil, ErrUnauthorized
+    }
+    var in models.IncomingMessage
+    if err := json.Unmarshal(body, &in); err != nil {
+        return nil, fmt.Errorf("разбор IncomingMessage: %w", err)
+    }
+    if in.UserID == "" {
+        return nil, fmt.Errorf("UserID обязателен")
+    }
+    in.ClientID = bot.ClientID.String()
+    switch {
+    case in.Source == "" || in.Source == SourceWidget:
+        in.Source = SourceWidget
+    case bot.BotID != "":
+        return nil, fmt.Errorf("source %q недоступен для бота виджета", in.Source)
+    default:
+        if _, ok := Get(in.Source); !ok {
+            return nil, fmt.Errorf("неизвестный source %q", in.Source)
+        }
+    }
+    if bot.BotID != "" {
+        in.BotID = bot.BotID
+    }
+
+    return &Inbound{Messages: []InboundMessage{{
+        Message: in,
+        // группируем по 10-секундным интервалам
+        DedupKey: fmt.Sprintf("%s_%s_%s_%d", in.ClientID, in.UserID, in.Content, time.Now().Unix()/10),
+    }}}, nil
+}
+
+func (w *Widget) Send(ctx context.Context, target Target, msg *models.Message) (*SendResult, error) {
+    return nil, ErrSendNotSupported
+}
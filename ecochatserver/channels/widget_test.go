@@ -0,0 +1,101 @@
+package channels
+
+import (
+    "errors"
+    "net/http/httptest"
+    "strings"
+    "testing"
+
+    "github.com/google/uuid"
+
+    "github.com/egor/ecochatserver/models"
+)
+
+func TestWidgetVerifyWebhook(t *testing.T) {
+    bot := &models.Bot{ClientID: uuid.New(), Source: SourceWidget, Secret: "key-1"}
+
+    tests := []struct {
+        name    string
+        bot     *models.Bot
+        apiKey  string
+        wantErr error
+    }{
+        {name: "valid key", bot: bot, apiKey: "key-1"},
+        {name: "wrong key", bot: bot, apiKey: "key-2", wantErr: ErrUnauthorized},
+        {name: "missing key", bot: bot, wantErr: ErrUnauthorized},
+        {name: "no credentials", apiKey: "key-1", wantErr: ErrUnauthorized},
+        {name: "empty secret", bot: &models.Bot{ClientID: bot.ClientID}, wantErr: ErrUnauthorized},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            r := httptest.NewRequest("POST", "/api/webhook/incoming", strings.NewReader("{}"))
+            r.Header.Set("Content-Type", "application/json")
+            if tt.apiKey != "" {
+                r.Header.Set("X-API-Key", tt.apiKey)
+            }
+            err := NewWidget().VerifyWebhook(r, nil, tt.bot)
+            if !errors.Is(err, tt.wantErr) {
+                t.Fatalf("err = %v, want %v", err, tt.wantErr)
+            }
+        })
+    }
+}
+
+func TestWidgetParseInboundIgnoresBodyTenant(t *testing.T) {
+    bot := &models.Bot{ClientID: uuid.New(), Source: SourceWidget, Secret: "key-1"}
+    body := `{"userId":"u1","content":"hi","clientId":"` + uuid.NewString() + `","botId":"b1"}`
+
+    in, err := NewWidget().ParseInbound([]byte(body), bot)
+    if err != nil {
+        t.Fatalf("ParseInbound: %v", err)
+    }
+    msg := in.Messages[0].Message
+    if msg.ClientID != bot.ClientID.String() {
+        t.Errorf("ClientID = %s, want клиента из учётных данных %s", msg.ClientID, bot.ClientID)
+    }
+    if msg.Source != SourceWidget {
+        t.Errorf("Source = %q, want %q", msg.Source, SourceWidget)
+    }
+}
+
+func TestWidgetParseInboundSource(t *testing.T) {
+    Register(NewTelegram(nil))
+    // API ключ клиента (/api/webhook/incoming) и секрет бота виджета
+    apiKey := &models.Bot{ClientID: uuid.New(), Source: SourceWidget, Secret: "key-1"}
+    widgetBot := &models.Bot{ClientID: apiKey.ClientID, Source: SourceWidget, BotID: "site", Secret: "secret"}
+
+    tests := []struct {
+        name    string
+        bot     *models.Bot
+        source  string
+        want    string
+        wantErr bool
+    }{
+        {name: "no source", bot: apiKey, want: SourceWidget},
+        {name: "widget", bot: apiKey, source: SourceWidget, want: SourceWidget},
+        // Старый формат ретранслятора Telegram: ответы должны уйти в Telegram
+        {name: "old format telegram", bot: apiKey, source: SourceTelegram, want: SourceTelegram},
+        {name: "unknown source", bot: apiKey, source: "icq", wantErr: true},
+        {name: "widget bot widget", bot: widgetBot, source: SourceWidget, want: SourceWidget},
+        {name: "widget bot telegram", bot: widgetBot, source: SourceTelegram, wantErr: true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            body := `{"userId":"42","sourceId":"42","botId":"tg_bot","content":"Привет","source":"` + tt.source + `","clientId":"` + uuid.NewString() + `"}`
+            in, err := NewWidget().ParseInbound([]byte(body), tt.bot)
+            if tt.wantErr {
+                if err == nil {
+                    t.Fatalf("source %q принят", tt.source)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("ParseInbound: %v", err)
+            }
+            msg := in.Messages[0].Message
+            if msg.Source != tt.want || msg.ClientID != tt.bot.ClientID.String() {
+                t.Errorf("Source = %q, ClientID = %s; ожидалось %q, %s", msg.Source, msg.ClientID, tt.want, tt.bot.ClientID)
+            }
+        })
+    }
+}
@@ -0,0 +1,71 @@
+// Command specgen генерирует документы API в docs/ из Go-типов обработчиков.
+//
+//	go run ./cmd/specgen          # перезаписать docs/openapi.json и docs/asyncapi.json
+//	go run ./cmd/specgen -check   # завершиться с ошибкой, если документы устарели
+//
+// То же сравнение выполняет go test ./handlers (api_spec_test.go): изменение
+// payload-типа без обновления документов ломает тесты.
+package main
+
+import (
+    "bytes"
+    "flag"
+    "fmt"
+    "os"
+    "path/filepath"
+
+    "github.com/egor/ecochatserver/handlers"
+)
+
+func main() {
+    check := flag.Bool("check", false, "сравнить с сохранёнными документами вместо записи")
+    dir := flag.String("dir", "docs", "каталог с документами")
+    flag.Parse()
+
+    specs := []struct {
+        file  string
+        build func() ([]byte, error)
+    }{
+        {"openapi.json", handlers.OpenAPISpec},
+        {"asyncapi.json", handlers.AsyncAPISpec},
+    }
+
+    stale := false
+    for _, s := range specs {
+        doc, err := s.build()
+        if err != nil {
+            fail("%s: %v", s.file, err)
+        }
+        doc = append(doc, '\n')
+        path := filepath.Join(*dir, s.file)
+
+        if *check {
+            saved, err := os.ReadFile(path)
+            if err != nil {
+                fail("%s: %v", path, err)
+            }
+            if !bytes.Equal(saved, doc) {
+                fmt.Fprintf(os.Stderr, "%s устарел: запустите go run ./cmd/specgen\n", path)
+                stale = true
+            }
+            continue
+        }
+
+        if err := os.MkdirAll(*dir, 0o755); err != nil {
+            fail("%v", err)
+        }
+        if err := os.WriteFile(path, doc, 0o644); err != nil {
+            fail("%v", err)
+        }
+        fmt.Println("записан", path)
+    }
+
+    if stale {
+        os.Exit(1)
+    }
+}
+
+func fail(format string, args ...any) {
+    fmt.Fprintf(os.Stderr, "specgen: "+format+"\n", args...)
+    os.Exit(1)
+}
@@ -40,6 +40,11 @@ func Init() error {
 	}
 
 	log.Println("[database] PostgreSQL connected ✓")
+
+	if err := ensureSchema(); err != nil {
+		_ = DB.Close()
+		return fmt.Errorf("ensure schema: %w", err)
+	}
 	
 	// Создаем партиции заранее
 	if err := initializePartitions(); err != nil {
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// Notify отправляет уведомление в канал LISTEN/NOTIFY.
+func Notify(ctx context.Context, channel, payload string) error {
+	if _, err := DB.ExecContext(ctx, `SELECT pg_notify($1, $2)`, channel, payload); err != nil {
+		return fmt.Errorf("notify %s: %w", channel, err)
+	}
+	return nil
+}
+
+// Listen подписывается на канал на выделенном соединении пула и вызывает
+// handle для каждого уведомления. Блокирует до отмены ctx или обрыва
+// соединения; соединение после этого закрывается, а не возвращается в пул
+// с активным LISTEN.
+func Listen(ctx context.Context, channel string, handle func(payload string)) error {
+	conn, err := DB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("listen %s: get conn: %w", channel, err)
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		pgc := driverConn.(*stdlib.Conn).Conn()
+
+		if _, err := pgc.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+			return fmt.Errorf("listen %s: %v: %w", channel, err, driver.ErrBadConn)
+		}
+		for {
+			n, err := pgc.WaitForNotification(ctx)
+			if err != nil {
+				return fmt.Errorf("listen %s: %v: %w", channel, err, driver.ErrBadConn)
+			}
+			handle(n.Payload)
+		}
+	})
+}
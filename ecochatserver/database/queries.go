@@ -1,6 +1,10 @@
 package database
 
 import (
+    "context"
+    "database/sql"
+    "time"
+
     "github.com/egor/ecochatserver/database/queries"
     "github.com/egor/ecochatserver/models"
     "github.com/google/uuid"
@@ -10,6 +14,21 @@ import (
 const (
     DefaultPageSize = queries.DefaultPageSize
     MaxPageSize     = queries.MaxPageSize
+
+    NodeTTL = queries.NodeTTL
+)
+
+// Экспортируем ошибки для errors.Is в обработчиках
+var (
+    ErrClientNotFound = queries.ErrClientNotFound
+    ErrBotNotFound    = queries.ErrBotNotFound
+
+    ErrWebhookNotFound         = queries.ErrWebhookNotFound
+    ErrWebhookDeliveryNotFound = queries.ErrWebhookDeliveryNotFound
+
+    ErrAssignmentConflict = queries.ErrAssignmentConflict
+    ErrAdminNotFound      = queries.ErrAdminNotFound
+    ErrStatusConflict     = queries.ErrStatusConflict
 )
 
 // Прокси-функции для внешнего использования
@@ -21,8 +40,8 @@ func VerifyPassword(pw, hash string) error {
     return queries.VerifyPassword(pw, hash)
 }
 
-func GetChats(clientID, adminID uuid.UUID, page, size int) ([]models.ChatResponse, int, error) {
-    return queries.GetChats(DB, clientID, adminID, page, size)
+func GetChats(clientID uuid.UUID, assignee *uuid.UUID, status string, page, size int) ([]models.ChatResponse, int, error) {
+    return queries.GetChats(DB, clientID, assignee, status, page, size)
 }
 
 func GetChatByID(chatID uuid.UUID, page, size int) (*models.Chat, int, error) {
@@ -39,14 +58,33 @@ func AddMessage(
     return queries.AddMessage(DB, chatID, content, sender, senderID, msgType, meta)
 }
 
+func AddMessageTx(
+    chatID uuid.UUID,
+    content, sender string,
+    senderID uuid.UUID,
+    msgType string,
+    meta map[string]any,
+    inTx func(ctx context.Context, tx *sql.Tx) error,
+) (*models.Message, error) {
+    return queries.AddMessageTx(DB, chatID, content, sender, senderID, msgType, meta, inTx)
+}
+
 func MarkMessagesAsRead(chatID uuid.UUID) error {
     return queries.MarkMessagesAsRead(DB, chatID)
 }
 
 func GetOrCreateChat(
-    userID, userName, userEmail, source, sourceID, botID, clientAPIKey string,
-) (*models.Chat, error) {
-    return queries.GetOrCreateChat(DB, userID, userName, userEmail, source, sourceID, botID, clientAPIKey)
+    userID, userName, userEmail, source, sourceID, botID, threadID, clientAPIKey string,
+) (*models.Chat, bool, error) {
+    return queries.GetOrCreateChat(DB, userID, userName, userEmail, source, sourceID, botID, threadID, clientAPIKey)
+}
+
+func FindThread(clientID uuid.UUID, source, botID string, refs []string) (string, bool, error) {
+    return queries.FindThread(DB, clientID, source, botID, refs)
+}
+
+func HasInboundMessage(clientID uuid.UUID, source, botID, sourceMessageID string) (bool, error) {
+    return queries.HasInboundMessage(DB, clientID, source, botID, sourceMessageID)
 }
 
 func EnsureClientWithAPIKey(apiKey, clientName string) (uuid.UUID, error) {
@@ -60,4 +98,201 @@ func GetChatLightweight(chatID uuid.UUID) (*models.Chat, error) {
 
 func UpdateChatTimestamp(chatID uuid.UUID) error {
     return queries.UpdateChatTimestamp(DB, chatID)
-}
\ No newline at end of file
+}
+
+func GetBot(clientID uuid.UUID, source, botID string) (*models.Bot, error) {
+    return queries.GetBot(DB, clientID, source, botID)
+}
+
+func UpdateMessageMetadata(messageID uuid.UUID, patch map[string]any) error {
+    return queries.UpdateMessageMetadata(DB, messageID, patch)
+}
+func FindBot(source, botID string) (*models.Bot, error) {
+    return queries.FindBot(DB, source, botID)
+}
+
+
+func EnqueueOutbound(chatID uuid.UUID, channel string, msg *models.Message) error {
+    return queries.EnqueueOutbound(DB, chatID, channel, msg)
+}
+
+func ClaimOutboundDeliveries(limit int, lease time.Duration) ([]models.OutboundDelivery, error) {
+    return queries.ClaimOutboundDeliveries(DB, limit, lease)
+}
+
+func CompleteOutboundDelivery(messageID uuid.UUID, status, lastErr string, nextAt time.Time) error {
+    return queries.CompleteOutboundDelivery(DB, messageID, status, lastErr, nextAt)
+}
+
+func UpdateDeliveryByExternalID(source string, clientID uuid.UUID, botID, externalID string, patch map[string]any) (uuid.UUID, uuid.UUID, error) {
+    return queries.UpdateDeliveryByExternalID(DB, source, clientID, botID, externalID, patch)
+}
+
+func GetLastUserMessage(chatID uuid.UUID) (*models.Message, error) {
+    return queries.GetLastUserMessage(DB, chatID)
+}
+
+func CreateWebhookSubscription(sub *models.WebhookSubscription) error {
+    return queries.CreateWebhookSubscription(DB, sub)
+}
+
+func ListWebhookSubscriptions(clientID uuid.UUID) ([]models.WebhookSubscription, error) {
+    return queries.ListWebhookSubscriptions(DB, clientID)
+}
+
+func GetWebhookSubscription(clientID, id uuid.UUID) (*models.WebhookSubscription, error) {
+    return queries.GetWebhookSubscription(DB, clientID, id)
+}
+
+func UpdateWebhookSubscription(sub *models.WebhookSubscription) error {
+    return queries.UpdateWebhookSubscription(DB, sub)
+}
+
+func DeleteWebhookSubscription(clientID, id uuid.UUID) error {
+    return queries.DeleteWebhookSubscription(DB, clientID, id)
+}
+
+func EnqueueWebhookEvent(clientID, eventID uuid.UUID, eventType string, payload []byte) (int, error) {
+    return queries.EnqueueWebhookEvent(DB, clientID, eventID, eventType, payload)
+}
+
+func ClaimWebhookDeliveries(limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+    return queries.ClaimWebhookDeliveries(DB, limit, lease)
+}
+
+func CompleteWebhookDelivery(id uuid.UUID, status string, statusCode int, lastErr string, nextAt time.Time) error {
+    return queries.CompleteWebhookDelivery(DB, id, status, statusCode, lastErr, nextAt)
+}
+
+func ListWebhookDeliveries(clientID uuid.UUID, subscriptionID *uuid.UUID, status string, page, size int) ([]models.WebhookDelivery, int, error) {
+    return queries.ListWebhookDeliveries(DB, clientID, subscriptionID, status, page, size)
+}
+
+func RetryWebhookDelivery(clientID, id uuid.UUID) error {
+    return queries.RetryWebhookDelivery(DB, clientID, id)
+}
+
+func ChangeChatAssignment(
+    chatID, clientID uuid.UUID,
+    expected, next *uuid.UUID,
+    byAdmin *uuid.UUID,
+    action, note string,
+) error {
+    return queries.ChangeChatAssignment(DB, chatID, clientID, expected, next, byAdmin, action, note)
+}
+
+func TryAdvisoryLock(key int64) (func(), bool, error) {
+    return queries.TryAdvisoryLock(DB, key)
+}
+
+func ListAvailableOperators() ([]models.RoutingOperator, error) {
+    return queries.ListAvailableOperators(DB)
+}
+
+func ListClientOperators(clientID uuid.UUID) ([]models.RoutingOperator, error) {
+    return queries.ListClientOperators(DB, clientID)
+}
+
+func UpdateOperatorRouting(clientID, adminID uuid.UUID, skills []string, maxChats int) error {
+    return queries.UpdateOperatorRouting(DB, clientID, adminID, skills, maxChats)
+}
+
+func GetRoutingStrategies(clientIDs []string) (map[uuid.UUID]string, error) {
+    return queries.GetRoutingStrategies(DB, clientIDs)
+}
+
+func SetRoutingStrategy(clientID uuid.UUID, strategy string) error {
+    return queries.SetRoutingStrategy(DB, clientID, strategy)
+}
+
+func ListQueuedChats(clientIDs []string, limit int) ([]models.QueuedChat, error) {
+    return queries.ListQueuedChats(DB, clientIDs, limit)
+}
+
+func CountQueuedChats(clientID uuid.UUID) (int, error) {
+    return queries.CountQueuedChats(DB, clientID)
+}
+
+func SetChatLanguage(chatID uuid.UUID, language string) error {
+    return queries.SetChatLanguage(DB, chatID, language)
+}
+
+func ChangeChatStatus(chatID uuid.UUID, from []string, to, resolution string) (*models.ChatStatusChange, error) {
+    return queries.ChangeChatStatus(DB, chatID, from, to, resolution)
+}
+
+func CloseIdleChats(defaultMinutes, limit int) ([]models.ChatStatusChange, error) {
+    return queries.CloseIdleChats(DB, defaultMinutes, limit)
+}
+
+func GetAutoCloseMinutes(clientID uuid.UUID) (*int, error) {
+    return queries.GetAutoCloseMinutes(DB, clientID)
+}
+
+func SetAutoCloseMinutes(clientID uuid.UUID, minutes *int) error {
+    return queries.SetAutoCloseMinutes(DB, clientID, minutes)
+}
+
+func GetAutoResponderSettings(clientID uuid.UUID) (*models.AutoResponderSettings, error) {
+    return queries.GetAutoResponderSettings(DB, clientID)
+}
+
+func SetAutoResponderSettings(clientID uuid.UUID, settings *models.AutoResponderSettings) error {
+    return queries.SetAutoResponderSettings(DB, clientID, settings)
+}
+
+func SetAdminPresence(adminID uuid.UUID, presence string) error {
+    return queries.SetAdminPresence(DB, adminID, presence)
+}
+
+func ResetPresence() error {
+    return queries.ResetPresence(DB)
+}
+
+func HeartbeatNode(nodeID string) error {
+    return queries.HeartbeatNode(DB, nodeID)
+}
+
+func AttachAdminNode(nodeID string, adminID uuid.UUID) (string, bool, error) {
+    return queries.AttachAdminNode(DB, nodeID, adminID)
+}
+
+func DetachAdminNode(nodeID string, adminID uuid.UUID, ttl time.Duration) (bool, error) {
+    return queries.DetachAdminNode(DB, nodeID, adminID, ttl)
+}
+
+func ExpirePresence(ttl time.Duration) ([]models.AdminPresence, error) {
+    return queries.ExpirePresence(DB, ttl)
+}
+
+func GetOperatorAvailability(clientID uuid.UUID) (map[string]int, error) {
+    return queries.GetOperatorAvailability(DB, clientID)
+}
+
+func FindClientID(key string) (uuid.UUID, error) {
+    return queries.FindClientID(DB, key)
+}
+
+func FindClientByAPIKey(apiKey string) (uuid.UUID, error) {
+    return queries.FindClientByAPIKey(DB, apiKey)
+}
+
+func SaveBackplanePayload(payload []byte) (int64, error) {
+    return queries.SaveBackplanePayload(DB, payload)
+}
+
+func GetBackplanePayload(id int64) ([]byte, error) {
+    return queries.GetBackplanePayload(DB, id)
+}
+
+func DeleteBackplanePayloads(olderThan time.Duration) (int64, error) {
+    return queries.DeleteBackplanePayloads(DB, olderThan)
+}
+
+func GetMessagesSince(clientID uuid.UUID, chatID, assignee *uuid.UUID, since time.Time, limit int) ([]models.Message, error) {
+    return queries.GetMessagesSince(DB, clientID, chatID, assignee, since, limit)
+}
+
+func GetRecentMessages(chatID uuid.UUID, limit int) ([]models.Message, error) {
+    return queries.GetRecentMessages(DB, chatID, limit)
+}
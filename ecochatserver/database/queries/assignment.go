@@ -0,0 +1,95 @@
+package queries
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+
+    "github.com/google/uuid"
+)
+
+// ErrAssignmentConflict — назначение чата изменилось с момента чтения
+// (например, чат уже забрал другой оператор)
+var ErrAssignmentConflict = errors.New("назначение чата уже изменено")
+
+// ErrAdminNotFound — оператор не существует, отключён или принадлежит другому клиенту
+var ErrAdminNotFound = errors.New("оператор не найден")
+
+// Действия в истории назначений chat_assignments
+const (
+    AssignmentClaim    = "claim"
+    AssignmentTransfer = "transfer"
+    AssignmentUnassign = "unassign"
+    AssignmentRoute    = "route"
+)
+
+// ChangeChatAssignment атомарно меняет assigned_to чата с expected на next
+// и пишет запись в chat_assignments. Обновление выполняется только если
+// текущее значение всё ещё равно expected, поэтому из нескольких
+// одновременных claim выигрывает ровно один, остальные получают
+// ErrAssignmentConflict. Новый оператор должен быть активным и
+// принадлежать тому же клиенту, что и чат. byAdmin равен nil, если чат
+// назначен автоматически.
+func ChangeChatAssignment(
+    db *sql.DB,
+    chatID, clientID uuid.UUID,
+    expected, next *uuid.UUID,
+    byAdmin *uuid.UUID,
+    action, note string,
+) error {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    tx, err := db.BeginTx(ctx, nil)
+    if err != nil {
+        return fmt.Errorf("ChangeChatAssignment begin: %w", err)
+    }
+    defer tx.Rollback()
+
+    if next != nil {
+        var ok bool
+        if err := tx.QueryRowContext(ctx,
+            `SELECT EXISTS(SELECT 1 FROM admins WHERE id=$1 AND client_id=$2 AND active)`,
+            *next, clientID,
+        ).Scan(&ok); err != nil {
+            return fmt.Errorf("ChangeChatAssignment admin: %w", err)
+        }
+        if !ok {
+            return ErrAdminNotFound
+        }
+    }
+
+    res, err := tx.ExecContext(ctx, `
+        UPDATE chats SET assigned_to=$3, updated_at=now()
+         WHERE id=$1 AND client_id=$2 AND assigned_to IS NOT DISTINCT FROM $4`,
+        chatID, clientID, next, expected)
+    if err != nil {
+        return fmt.Errorf("ChangeChatAssignment update: %w", err)
+    }
+    if n, _ := res.RowsAffected(); n == 0 {
+        return ErrAssignmentConflict
+    }
+
+    if _, err := tx.ExecContext(ctx, `
+        INSERT INTO chat_assignments (id, chat_id, action, from_admin, to_admin, by_admin, note)
+        VALUES ($1,$2,$3,$4,$5,$6,$7)`,
+        uuid.New(), chatID, action, expected, next, byAdmin, note,
+    ); err != nil {
+        return fmt.Errorf("ChangeChatAssignment history: %w", err)
+    }
+
+    // Курсор round-robin хранится в БД, чтобы узлы роутера его разделяли
+    if action == AssignmentRoute && next != nil {
+        if _, err := tx.ExecContext(ctx,
+            `UPDATE admins SET last_routed_at=now() WHERE id=$1`, *next,
+        ); err != nil {
+            return fmt.Errorf("ChangeChatAssignment cursor: %w", err)
+        }
+    }
+
+    if err := tx.Commit(); err != nil {
+        return fmt.Errorf("ChangeChatAssignment commit: %w", err)
+    }
+    return nil
+}
@@ -0,0 +1,55 @@
+package queries
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "errors"
+    "fmt"
+
+    "github.com/egor/ecochatserver/models"
+    "github.com/google/uuid"
+)
+
+// GetAutoResponderSettings возвращает настройки автоответчика клиента
+// (пустые, если клиент их не задавал).
+func GetAutoResponderSettings(db *sql.DB, clientID uuid.UUID) (*models.AutoResponderSettings, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    var raw []byte
+    err := db.QueryRowContext(ctx, `SELECT autoresponder_settings FROM clients WHERE id=$1`, clientID).Scan(&raw)
+    if errors.Is(err, sql.ErrNoRows) {
+        return nil, ErrClientNotFound
+    }
+    if err != nil {
+        return nil, fmt.Errorf("GetAutoResponderSettings: %w", err)
+    }
+
+    settings := &models.AutoResponderSettings{}
+    if len(raw) > 0 {
+        if err := json.Unmarshal(raw, settings); err != nil {
+            return nil, fmt.Errorf("GetAutoResponderSettings: %w", err)
+        }
+    }
+    return settings, nil
+}
+
+// SetAutoResponderSettings заменяет настройки автоответчика клиента.
+func SetAutoResponderSettings(db *sql.DB, clientID uuid.UUID, settings *models.AutoResponderSettings) error {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    raw, err := json.Marshal(settings)
+    if err != nil {
+        return fmt.Errorf("SetAutoResponderSettings: %w", err)
+    }
+    res, err := db.ExecContext(ctx, `UPDATE clients SET autoresponder_settings=$2 WHERE id=$1`, clientID, raw)
+    if err != nil {
+        return fmt.Errorf("SetAutoResponderSettings: %w", err)
+    }
+    if n, _ := res.RowsAffected(); n == 0 {
+        return ErrClientNotFound
+    }
+    return nil
+}
@@ -0,0 +1,53 @@
+package queries
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "time"
+)
+
+// SaveBackplanePayload сохраняет сообщение бэкплейна, не помещающееся
+// в NOTIFY (лимит Postgres — 8000 байт), и возвращает его ID.
+func SaveBackplanePayload(db *sql.DB, payload []byte) (int64, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    var id int64
+    if err := db.QueryRowContext(ctx,
+        `INSERT INTO hub_backplane_payloads (payload) VALUES ($1) RETURNING id`, string(payload),
+    ).Scan(&id); err != nil {
+        return 0, fmt.Errorf("SaveBackplanePayload: %w", err)
+    }
+    return id, nil
+}
+
+// GetBackplanePayload возвращает сохранённое сообщение бэкплейна.
+func GetBackplanePayload(db *sql.DB, id int64) ([]byte, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    var payload string
+    if err := db.QueryRowContext(ctx,
+        `SELECT payload FROM hub_backplane_payloads WHERE id=$1`, id,
+    ).Scan(&payload); err != nil {
+        return nil, fmt.Errorf("GetBackplanePayload: %w", err)
+    }
+    return []byte(payload), nil
+}
+
+// DeleteBackplanePayloads удаляет сообщения старше olderThan: к этому
+// времени все узлы их уже прочитали.
+func DeleteBackplanePayloads(db *sql.DB, olderThan time.Duration) (int64, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    res, err := db.ExecContext(ctx,
+        `DELETE FROM hub_backplane_payloads WHERE created_at < now() - make_interval(secs => $1)`,
+        olderThan.Seconds(),
+    )
+    if err != nil {
+        return 0, fmt.Errorf("DeleteBackplanePayloads: %w", err)
+    }
+    return res.RowsAffected()
+}
@@ -0,0 +1,71 @@
+package queries
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "errors"
+    "fmt"
+
+    "github.com/google/uuid"
+    "github.com/egor/ecochatserver/models"
+)
+
+// ErrBotNotFound — бот не зарегистрирован или отключён
+var ErrBotNotFound = errors.New("бот не найден")
+
+// GetBot возвращает активного бота клиента по источнику и ID бота.
+func GetBot(db *sql.DB, clientID uuid.UUID, source, botID string) (*models.Bot, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    var bot models.Bot
+    var settings []byte
+    err := db.QueryRowContext(ctx, `
+        SELECT id,client_id,source,bot_id,token,webhook_secret,settings,active,created_at
+          FROM client_bots
+         WHERE client_id=$1 AND source=$2 AND bot_id=$3 AND active=true`,
+        clientID, source, botID,
+    ).Scan(
+        &bot.ID, &bot.ClientID, &bot.Source, &bot.BotID,
+        &bot.Token, &bot.Secret, &settings, &bot.Active, &bot.CreatedAt,
+    )
+    if err == sql.ErrNoRows {
+        return nil, ErrBotNotFound
+    }
+    if err != nil {
+        return nil, fmt.Errorf("GetBot: %w", err)
+    }
+    if len(settings) > 0 {
+        _ = json.Unmarshal(settings, &bot.Settings)
+    }
+    return &bot, nil
+}
+
+// FindBot ищет активного бота по источнику и ID бота среди всех клиентов.
+func FindBot(db *sql.DB, source, botID string) (*models.Bot, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    var bot models.Bot
+    var settings []byte
+    err := db.QueryRowContext(ctx, `
+        SELECT id,client_id,source,bot_id,token,webhook_secret,settings,active,created_at
+          FROM client_bots
+         WHERE source=$1 AND bot_id=$2 AND active=true`,
+        source, botID,
+    ).Scan(
+        &bot.ID, &bot.ClientID, &bot.Source, &bot.BotID,
+        &bot.Token, &bot.Secret, &settings, &bot.Active, &bot.CreatedAt,
+    )
+    if err == sql.ErrNoRows {
+        return nil, ErrBotNotFound
+    }
+    if err != nil {
+        return nil, fmt.Errorf("FindBot: %w", err)
+    }
+    if len(settings) > 0 {
+        _ = json.Unmarshal(settings, &bot.Settings)
+    }
+    return &bot, nil
+}
@@ -12,9 +12,12 @@ import (
     "github.com/egor/ecochatserver/models"
 )
 
-func GetChats(db *sql.DB, clientID, adminID uuid.UUID, page, size int) ([]models.ChatResponse, int, error) {
-    log.Printf("GetChats: начало, clientID=%s, adminID=%s, page=%d, size=%d", 
-        clientID, adminID, page, size)
+// GetChats возвращает страницу чатов клиента. Если assignee не nil, только
+// чаты этого оператора и свободные. status ("" — любой) фильтрует по
+// статусу чата.
+func GetChats(db *sql.DB, clientID uuid.UUID, assignee *uuid.UUID, status string, page, size int) ([]models.ChatResponse, int, error) {
+    log.Printf("GetChats: начало, clientID=%s, assignee=%v, status=%q, page=%d, size=%d",
+        clientID, assignee, status, page, size)
     
     if page < 1 {
         page = 1
@@ -33,58 +36,17 @@ func GetChats(db *sql.DB, clientID, adminID uuid.UUID, page, size int) ([]models
     var total int
     countQuery := `
         SELECT COUNT(*) FROM chats
-        WHERE client_id=$1 AND (assigned_to=$2 OR assigned_to IS NULL)`
+        WHERE client_id=$1 AND ($2::uuid IS NULL OR assigned_to IS NULL OR assigned_to=$2)
+          AND ($3='' OR status=$3)`
     
     log.Printf("GetChats: выполняем запрос подсчета: %s", countQuery)
-    log.Printf("GetChats: параметры подсчета: clientID=%s, adminID=%s", clientID, adminID)
     
-    if err := db.QueryRowContext(ctx, countQuery, clientID, adminID).Scan(&total); err != nil {
+    if err := db.QueryRowContext(ctx, countQuery, clientID, assignee, status).Scan(&total); err != nil {
         log.Printf("GetChats: ошибка подсчета: %v", err)
         return nil, 0, fmt.Errorf("ошибка подсчета чатов: %w", err)
     }
     log.Printf("GetChats: найдено всего чатов с фильтром: %d", total)
 
-    // Для отладки - проверим ВСЕ чаты клиента без фильтра по assigned_to
-    var totalWithoutFilter int
-    debugQuery := "SELECT COUNT(*) FROM chats WHERE client_id=$1"
-    if err := db.QueryRowContext(ctx, debugQuery, clientID).Scan(&totalWithoutFilter); err == nil {
-        log.Printf("GetChats: всего чатов клиента без фильтра по assigned_to: %d", totalWithoutFilter)
-        
-        // Проверим, есть ли чаты с assigned_to не равным текущему админу
-        var assignedToOthers int
-        if err := db.QueryRowContext(ctx, 
-            "SELECT COUNT(*) FROM chats WHERE client_id=$1 AND assigned_to IS NOT NULL AND assigned_to != $2", 
-            clientID, adminID,
-        ).Scan(&assignedToOthers); err == nil {
-            log.Printf("GetChats: чатов назначенных другим админам: %d", assignedToOthers)
-        }
-    }
-
-    // Для отладки - выведем все чаты клиента
-    debugRows, err := db.QueryContext(ctx, 
-        "SELECT id, user_id, client_id, assigned_to, status, created_at FROM chats WHERE client_id=$1 ORDER BY created_at DESC LIMIT 10", 
-        clientID)
-    if err == nil {
-        defer debugRows.Close()
-        log.Printf("GetChats: последние 10 чатов клиента для отладки:")
-        i := 0
-        for debugRows.Next() {
-            var chatID, userID, clientID uuid.UUID
-            var assignedTo sql.NullString
-            var status string
-            var createdAt time.Time
-            if err := debugRows.Scan(&chatID, &userID, &clientID, &assignedTo, &status, &createdAt); err == nil {
-                assignedToStr := "NULL"
-                if assignedTo.Valid {
-                    assignedToStr = assignedTo.String
-                }
-                log.Printf("  чат %d: ID=%s, userID=%s, clientID=%s, assignedTo=%s, status=%s, created=%v", 
-                    i, chatID, userID, clientID, assignedToStr, status, createdAt)
-                i++
-            }
-        }
-    }
-
     // Основной запрос для получения чатов
     const q = `
       SELECT
@@ -102,7 +64,8 @@ func GetChats(db *sql.DB, clientID, adminID uuid.UUID, page, size int) ([]models
          ORDER BY timestamp DESC
          LIMIT 1
       ) l ON TRUE
-      WHERE c.client_id=$1 AND (c.assigned_to=$2 OR c.assigned_to IS NULL)
+      WHERE c.client_id=$1 AND ($2::uuid IS NULL OR c.assigned_to IS NULL OR c.assigned_to=$2)
+        AND ($5='' OR c.status=$5)
       GROUP BY c.id,u.id,l.id,l.content,l.sender,l.timestamp
       ORDER BY c.updated_at DESC
       LIMIT $3 OFFSET $4
@@ -111,7 +74,7 @@ func GetChats(db *sql.DB, clientID, adminID uuid.UUID, page, size int) ([]models
     offset := (page - 1) * size
     log.Printf("GetChats: выполняем основной запрос с LIMIT=%d OFFSET=%d", size, offset)
     
-    rows, err := db.QueryContext(ctx, q, clientID, adminID, size, offset)
+    rows, err := db.QueryContext(ctx, q, clientID, assignee, size, offset, status)
     if err != nil {
         log.Printf("GetChats: ошибка основного запроса: %v", err)
         return nil, 0, fmt.Errorf("ошибка получения чатов: %w", err)
@@ -198,7 +161,7 @@ func GetChatByID(db *sql.DB, chatID uuid.UUID, page, size int) (*models.Chat, in
     
     chatQuery := `
         SELECT id,created_at,updated_at,status,user_id,
-               source,bot_id,client_id,assigned_to
+               source,bot_id,client_id,assigned_to,closed_at,resolution
           FROM chats WHERE id=$1`
     
     log.Printf("GetChatByID: выполняем запрос чата: %s", chatQuery)
@@ -206,6 +169,7 @@ func GetChatByID(db *sql.DB, chatID uuid.UUID, page, size int) (*models.Chat, in
     if err := db.QueryRowContext(ctx, chatQuery, chatID).Scan(
         &chat.ID, &chat.CreatedAt, &chat.UpdatedAt, &chat.Status,
         &userID, &chat.Source, &chat.BotID, &chat.ClientID, &assignedNull,
+        &chat.ClosedAt, &chat.Resolution,
     ); err != nil {
         log.Printf("GetChatByID: ошибка получения чата: %v", err)
         if err == sql.ErrNoRows {
@@ -341,8 +305,8 @@ func GetChatByID(db *sql.DB, chatID uuid.UUID, page, size int) (*models.Chat, in
 
 func GetOrCreateChat(
     db *sql.DB,
-    userID, userName, userEmail, source, sourceID, botID, clientAPIKey string,
-) (*models.Chat, error) {
+    userID, userName, userEmail, source, sourceID, botID, threadID, clientAPIKey string,
+) (*models.Chat, bool, error) {
     log.Printf("GetOrCreateChat: начало, userID=%s, userName='%s', userEmail='%s', source=%s, sourceID=%s, botID=%s, clientAPIKey=%s", 
         userID, userName, userEmail, source, sourceID, botID, clientAPIKey)
     
@@ -352,7 +316,7 @@ func GetOrCreateChat(
     tx, err := db.BeginTx(ctx, nil)
     if err != nil {
         log.Printf("GetOrCreateChat: ошибка начала транзакции: %v", err)
-        return nil, fmt.Errorf("ошибка начала транзакции: %w", err)
+        return nil, false, fmt.Errorf("ошибка начала транзакции: %w", err)
     }
     defer tx.Rollback()
 
@@ -360,7 +324,7 @@ func GetOrCreateChat(
     user, err := getOrCreateUser(ctx, tx, userID, userName, userEmail, source, sourceID)
     if err != nil {
         log.Printf("GetOrCreateChat: ошибка getOrCreateUser: %v", err)
-        return nil, fmt.Errorf("ошибка получения/создания пользователя: %w", err)
+        return nil, false, fmt.Errorf("ошибка получения/создания пользователя: %w", err)
     }
     log.Printf("GetOrCreateChat: получен/создан пользователь ID=%s, name='%s', email='%s'", 
         user.ID, user.Name, user.Email)
@@ -369,21 +333,22 @@ func GetOrCreateChat(
     clientUUID, err := getClientUUIDByAPIKey(ctx, tx, clientAPIKey)
     if err != nil {
         log.Printf("GetOrCreateChat: ошибка getClientUUIDByAPIKey: %v", err)
-        return nil, fmt.Errorf("ошибка получения клиента: %w", err)
+        return nil, false, fmt.Errorf("ошибка получения клиента: %w", err)
     }
     log.Printf("GetOrCreateChat: получен clientUUID=%s для API key=%s", clientUUID, clientAPIKey)
 
     // Проверяем, существует ли чат
     var chatID uuid.UUID
-    checkQuery := "SELECT id FROM chats WHERE user_id=$1 AND source=$2 AND bot_id=$3 AND client_id=$4 LIMIT 1"
-    log.Printf("GetOrCreateChat: проверяем существование чата: user_id=%s, source=%s, bot_id=%s, client_id=%s", 
-        user.ID, source, botID, clientUUID)
+    created := false
+    checkQuery := "SELECT id FROM chats WHERE user_id=$1 AND source=$2 AND bot_id=$3 AND client_id=$4 AND thread_id=$5 LIMIT 1"
+    log.Printf("GetOrCreateChat: проверяем существование чата: user_id=%s, source=%s, bot_id=%s, client_id=%s, thread_id=%s", 
+        user.ID, source, botID, clientUUID, threadID)
     
-    err = tx.QueryRowContext(ctx, checkQuery, user.ID, source, botID, clientUUID).Scan(&chatID)
+    err = tx.QueryRowContext(ctx, checkQuery, user.ID, source, botID, clientUUID, threadID).Scan(&chatID)
     
     if err != nil && err != sql.ErrNoRows {
         log.Printf("GetOrCreateChat: ошибка поиска чата: %v", err)
-        return nil, fmt.Errorf("ошибка поиска чата: %w", err)
+        return nil, false, fmt.Errorf("ошибка поиска чата: %w", err)
     }
     
     if err == sql.ErrNoRows {
@@ -394,23 +359,24 @@ func GetOrCreateChat(
             chatID, user.ID, clientUUID)
         
         insertQuery := `
-            INSERT INTO chats(id,user_id,created_at,updated_at,status,source,bot_id,client_id) 
-            VALUES($1,$2,$3,$4,'active',$5,$6,$7)`
+            INSERT INTO chats(id,user_id,created_at,updated_at,status,source,bot_id,client_id,thread_id) 
+            VALUES($1,$2,$3,$4,'active',$5,$6,$7,$8)`
         
         if _, err := tx.ExecContext(ctx, insertQuery, 
-            chatID, user.ID, now, now, source, botID, clientUUID,
+            chatID, user.ID, now, now, source, botID, clientUUID, threadID,
         ); err != nil {
             log.Printf("GetOrCreateChat: ошибка создания чата: %v", err)
-            return nil, fmt.Errorf("ошибка создания чата: %w", err)
+            return nil, false, fmt.Errorf("ошибка создания чата: %w", err)
         }
         log.Printf("GetOrCreateChat: чат успешно создан")
+        created = true
     } else {
         log.Printf("GetOrCreateChat: найден существующий чат ID=%s", chatID)
     }
 
     if err := tx.Commit(); err != nil {
         log.Printf("GetOrCreateChat: ошибка коммита транзакции: %v", err)
-        return nil, fmt.Errorf("ошибка коммита транзакции: %w", err)
+        return nil, false, fmt.Errorf("ошибка коммита транзакции: %w", err)
     }
     
     log.Printf("GetOrCreateChat: транзакция успешно закоммичена")
@@ -419,10 +385,10 @@ func GetOrCreateChat(
     chat, _, err := GetChatByID(db, chatID, 1, DefaultPageSize)
     if err != nil {
         log.Printf("GetOrCreateChat: ошибка получения созданного чата: %v", err)
-        return nil, fmt.Errorf("ошибка получения чата: %w", err)
+        return nil, false, fmt.Errorf("ошибка получения чата: %w", err)
     }
     
     log.Printf("GetOrCreateChat: успешно, возвращаем чат ID=%s, clientID=%s, userID=%s", 
         chat.ID, chat.ClientID, chat.User.ID)
-    return chat, nil
+    return chat, created, nil
 }
\ No newline at end of file
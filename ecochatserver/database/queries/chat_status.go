@@ -0,0 +1,143 @@
+package queries
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+
+    "github.com/egor/ecochatserver/models"
+    "github.com/google/uuid"
+)
+
+// ErrStatusConflict — статус чата не совпал с ожидаемым (уже изменён или чата нет)
+var ErrStatusConflict = errors.New("статус чата уже изменён")
+
+// ResolutionIdle — причина автоматического закрытия неактивного чата
+const ResolutionIdle = "idle"
+
+func scanStatusChange(row interface{ Scan(...any) error }, to, resolution string) (*models.ChatStatusChange, error) {
+    ch := models.ChatStatusChange{Status: to, Resolution: resolution}
+    var assignedNull sql.NullString
+    if err := row.Scan(&ch.ChatID, &ch.ClientID, &assignedNull, &ch.PreviousStatus); err != nil {
+        return nil, err
+    }
+    var err error
+    if ch.AssignedTo, err = nullUUIDToPointer(assignedNull); err != nil {
+        return nil, err
+    }
+    return &ch, nil
+}
+
+// ChangeChatStatus переводит чат в статус to, если текущий статус входит
+// в from. Для closed запоминаются время и причина закрытия, для остальных
+// статусов они сбрасываются. Иначе возвращает ErrStatusConflict.
+func ChangeChatStatus(db *sql.DB, chatID uuid.UUID, from []string, to, resolution string) (*models.ChatStatusChange, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    if to != models.ChatStatusClosed {
+        resolution = ""
+    }
+
+    row := db.QueryRowContext(ctx, `
+        WITH old AS (
+            SELECT id, status FROM chats
+             WHERE id=$1 AND status = ANY($2::text[])
+             FOR UPDATE
+        )
+        UPDATE chats c
+           SET status=$3,
+               resolution=$4,
+               closed_at=CASE WHEN $3='closed' THEN now() END,
+               updated_at=now()
+          FROM old
+         WHERE c.id=old.id
+        RETURNING c.id, c.client_id, c.assigned_to, old.status`,
+        chatID, from, to, resolution)
+
+    ch, err := scanStatusChange(row, to, resolution)
+    if errors.Is(err, sql.ErrNoRows) {
+        return nil, ErrStatusConflict
+    }
+    if err != nil {
+        return nil, fmt.Errorf("ChangeChatStatus: %w", err)
+    }
+    return ch, nil
+}
+
+// CloseIdleChats закрывает не более limit открытых чатов, в которых ничего
+// не происходило дольше порога клиента (clients.auto_close_minutes, для
+// NULL — defaultMinutes; 0 отключает автозакрытие).
+func CloseIdleChats(db *sql.DB, defaultMinutes, limit int) ([]models.ChatStatusChange, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    rows, err := db.QueryContext(ctx, `
+        WITH idle AS (
+            SELECT c.id, c.status
+              FROM chats c
+              JOIN clients cl ON cl.id=c.client_id
+             WHERE c.status <> 'closed'
+               AND COALESCE(cl.auto_close_minutes, $1) > 0
+               AND c.updated_at < now() - make_interval(mins => COALESCE(cl.auto_close_minutes, $1))
+             ORDER BY c.updated_at
+             LIMIT $2
+             FOR UPDATE OF c SKIP LOCKED
+        )
+        UPDATE chats c
+           SET status='closed', resolution=$3, closed_at=now()
+          FROM idle
+         WHERE c.id=idle.id
+        RETURNING c.id, c.client_id, c.assigned_to, idle.status`,
+        defaultMinutes, limit, ResolutionIdle)
+    if err != nil {
+        return nil, fmt.Errorf("CloseIdleChats: %w", err)
+    }
+    defer rows.Close()
+
+    var list []models.ChatStatusChange
+    for rows.Next() {
+        ch, err := scanStatusChange(rows, models.ChatStatusClosed, ResolutionIdle)
+        if err != nil {
+            return nil, fmt.Errorf("CloseIdleChats scan: %w", err)
+        }
+        list = append(list, *ch)
+    }
+    return list, rows.Err()
+}
+
+// GetAutoCloseMinutes возвращает порог автозакрытия клиента (nil — по умолчанию сервера).
+func GetAutoCloseMinutes(db *sql.DB, clientID uuid.UUID) (*int, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    var minutes sql.NullInt64
+    err := db.QueryRowContext(ctx, `SELECT auto_close_minutes FROM clients WHERE id=$1`, clientID).Scan(&minutes)
+    if errors.Is(err, sql.ErrNoRows) {
+        return nil, ErrClientNotFound
+    }
+    if err != nil {
+        return nil, fmt.Errorf("GetAutoCloseMinutes: %w", err)
+    }
+    if !minutes.Valid {
+        return nil, nil
+    }
+    m := int(minutes.Int64)
+    return &m, nil
+}
+
+// SetAutoCloseMinutes сохраняет порог автозакрытия клиента (nil — по умолчанию сервера).
+func SetAutoCloseMinutes(db *sql.DB, clientID uuid.UUID, minutes *int) error {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    res, err := db.ExecContext(ctx, `UPDATE clients SET auto_close_minutes=$2 WHERE id=$1`, clientID, minutes)
+    if err != nil {
+        return fmt.Errorf("SetAutoCloseMinutes: %w", err)
+    }
+    if n, _ := res.RowsAffected(); n == 0 {
+        return ErrClientNotFound
+    }
+    return nil
+}
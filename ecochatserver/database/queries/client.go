@@ -3,49 +3,73 @@ package queries
 import (
     "context"
     "database/sql"
+    "errors"
+    "fmt"
     "log"
     "time"
 
     "github.com/google/uuid"
 )
 
+// ErrClientNotFound — клиент с таким API ключом/ID не существует или отключён
+var ErrClientNotFound = errors.New("клиент не найден")
+
+// getClientUUIDByAPIKey находит активного клиента по API ключу или по его UUID.
+// Новых клиентов не создаёт — для этого есть EnsureClientWithAPIKey.
 func getClientUUIDByAPIKey(ctx context.Context, tx *sql.Tx, apiKey string) (uuid.UUID, error) {
     log.Printf("getClientUUIDByAPIKey: начало, apiKey=%s", apiKey)
-    
-    if u, err := uuid.Parse(apiKey); err == nil {
-        log.Printf("getClientUUIDByAPIKey: apiKey является UUID: %s", u)
-        return u, nil
+
+    if apiKey == "" {
+        return uuid.Nil, ErrClientNotFound
     }
     
     var clientID uuid.UUID
-    err := tx.QueryRowContext(ctx,
-        "SELECT id FROM clients WHERE api_key=$1", apiKey,
-    ).Scan(&clientID)
+    var err error
+    if u, parseErr := uuid.Parse(apiKey); parseErr == nil {
+        err = tx.QueryRowContext(ctx,
+            "SELECT id FROM clients WHERE id=$1 AND active=true", u,
+        ).Scan(&clientID)
+    } else {
+        err = tx.QueryRowContext(ctx,
+            "SELECT id FROM clients WHERE api_key=$1 AND active=true", apiKey,
+        ).Scan(&clientID)
+    }
     
     if err == sql.ErrNoRows {
-        clientID = uuid.New()
-        log.Printf("getClientUUIDByAPIKey: клиент не найден, создаем новый ID=%s для apiKey=%s", 
-            clientID, apiKey)
-        
-        if _, err := tx.ExecContext(ctx,
-            "INSERT INTO clients(id,name,api_key,subscription,active,created_at) VALUES($1,$2,$3,'free',true,$4)",
-            clientID, "Клиент "+apiKey, apiKey, time.Now(),
-        ); err != nil {
-            log.Printf("getClientUUIDByAPIKey: ошибка создания клиента: %v", err)
-            return uuid.Nil, err
-        }
-        log.Printf("getClientUUIDByAPIKey: клиент создан ID=%s для apiKey=%s", clientID, apiKey)
+        log.Printf("getClientUUIDByAPIKey: клиент не найден для apiKey=%s", apiKey)
+        return uuid.Nil, ErrClientNotFound
     } else if err != nil {
         log.Printf("getClientUUIDByAPIKey: ошибка поиска клиента: %v", err)
         return uuid.Nil, err
-    } else {
-        log.Printf("getClientUUIDByAPIKey: найден существующий клиент ID=%s для apiKey=%s", 
-            clientID, apiKey)
     }
-    
+
+    log.Printf("getClientUUIDByAPIKey: найден существующий клиент ID=%s для apiKey=%s", 
+        clientID, apiKey)
     return clientID, nil
 }
 
+// FindClientByAPIKey находит активного клиента строго по API ключу
+// (в отличие от FindClientID, публичный UUID клиента не подходит).
+func FindClientByAPIKey(db *sql.DB, apiKey string) (uuid.UUID, error) {
+    if apiKey == "" {
+        return uuid.Nil, ErrClientNotFound
+    }
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    var id uuid.UUID
+    err := db.QueryRowContext(ctx,
+        "SELECT id FROM clients WHERE api_key=$1 AND active=true", apiKey,
+    ).Scan(&id)
+    if err == sql.ErrNoRows {
+        return uuid.Nil, ErrClientNotFound
+    }
+    if err != nil {
+        return uuid.Nil, fmt.Errorf("FindClientByAPIKey: %w", err)
+    }
+    return id, nil
+}
+
 func EnsureClientWithAPIKey(db *sql.DB, apiKey, clientName string) (uuid.UUID, error) {
     log.Printf("EnsureClientWithAPIKey: начало, apiKey=%s, clientName=%s", apiKey, clientName)
     
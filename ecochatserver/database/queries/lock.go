@@ -0,0 +1,48 @@
+package queries
+
+import (
+    "context"
+    "database/sql"
+    "database/sql/driver"
+    "fmt"
+    "log"
+)
+
+// Ключи advisory-блокировок Postgres для задач, которые в кластере
+// должен выполнять один узел
+const (
+    LockRouting int64 = 0x65636f726f757465 // "ecoroute"
+)
+
+// TryAdvisoryLock пытается взять сессионную advisory-блокировку key на
+// выделенном соединении. ok=false — блокировку держит другой узел. Вызывающий
+// обязан вызвать release; при обрыве соединения Postgres снимает её сам.
+func TryAdvisoryLock(db *sql.DB, key int64) (release func(), ok bool, err error) {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    conn, err := db.Conn(ctx)
+    if err != nil {
+        return nil, false, fmt.Errorf("TryAdvisoryLock conn: %w", err)
+    }
+    if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, key).Scan(&ok); err != nil {
+        conn.Close()
+        return nil, false, fmt.Errorf("TryAdvisoryLock: %w", err)
+    }
+    if !ok {
+        conn.Close()
+        return nil, false, nil
+    }
+
+    release = func() {
+        ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+        defer cancel()
+        if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, key); err != nil {
+            log.Printf("TryAdvisoryLock: снятие блокировки %d: %v", key, err)
+            // Соединение с неснятой блокировкой не должно вернуться в пул
+            _ = conn.Raw(func(any) error { return driver.ErrBadConn })
+        }
+        conn.Close()
+    }
+    return release, true, nil
+}
@@ -19,6 +19,20 @@ func AddMessage(
     senderID uuid.UUID,
     msgType string,
     meta map[string]any,
+) (*models.Message, error) {
+    return AddMessageTx(db, chatID, content, sender, senderID, msgType, meta, nil)
+}
+
+// AddMessageTx — AddMessage, который перед коммитом вызывает inTx в той же
+// транзакции (nil — не вызывает). Ошибка inTx откатывает вставку сообщения.
+func AddMessageTx(
+    db *sql.DB,
+    chatID uuid.UUID,
+    content, sender string,
+    senderID uuid.UUID,
+    msgType string,
+    meta map[string]any,
+    inTx func(ctx context.Context, tx *sql.Tx) error,
 ) (*models.Message, error) {
     ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
     defer cancel()
@@ -65,6 +79,12 @@ func AddMessage(
         return nil, fmt.Errorf("обновление чата: %w", err)
     }
 
+    if inTx != nil {
+        if err := inTx(ctx, tx); err != nil {
+            return nil, err
+        }
+    }
+
     if err := tx.Commit(); err != nil {
         return nil, fmt.Errorf("commit tx: %w", err)
     }
@@ -91,4 +111,169 @@ func MarkMessagesAsRead(db *sql.DB, chatID uuid.UUID) error {
         chatID,
     )
     return err
-}
\ No newline at end of file
+}
+
+// UpdateMessageMetadata дописывает ключи patch в metadata сообщения (jsonb merge).
+func UpdateMessageMetadata(db *sql.DB, messageID uuid.UUID, patch map[string]any) error {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    raw, err := json.Marshal(patch)
+    if err != nil {
+        return fmt.Errorf("marshal metadata: %w", err)
+    }
+
+    _, err = db.ExecContext(ctx,
+        "UPDATE messages SET metadata = coalesce(metadata, '{}'::jsonb) || $1::jsonb WHERE id=$2",
+        raw, messageID,
+    )
+    return err
+}
+
+// deliveryLookupWindow — сколько назад искать сообщение по ID у источника
+// (ограничивает сканирование партиций messages)
+const deliveryLookupWindow = 30 * 24 * time.Hour
+
+// UpdateDeliveryByExternalID дописывает patch в metadata.delivery сообщения,
+// отправленного через канал source с ID externalID у источника. Ищется только
+// в чатах бота botID клиента clientID: ID источника уникальны лишь в пределах бота.
+// Статус "read" не перезаписывается более ранними статусами.
+// Возвращает ID сообщения и чата; uuid.Nil, если сообщение не найдено.
+func UpdateDeliveryByExternalID(
+    db *sql.DB,
+    source string,
+    clientID uuid.UUID,
+    botID, externalID string,
+    patch map[string]any,
+) (messageID, chatID uuid.UUID, err error) {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    raw, err := json.Marshal(patch)
+    if err != nil {
+        return uuid.Nil, uuid.Nil, fmt.Errorf("marshal delivery: %w", err)
+    }
+
+    err = db.QueryRowContext(ctx, `
+        UPDATE messages
+           SET metadata = jsonb_set(metadata, '{delivery}', (metadata->'delivery') || $1::jsonb)
+         WHERE metadata->'delivery'->>'externalId' = $2
+           AND metadata->'delivery'->>'channel' = $3
+           AND coalesce(metadata->'delivery'->>'status', '') <> 'read'
+           AND timestamp > $4
+           AND chat_id IN (SELECT id FROM chats WHERE client_id=$5 AND source=$3 AND bot_id=$6)
+        RETURNING id, chat_id`,
+        raw, externalID, source, time.Now().Add(-deliveryLookupWindow), clientID, botID,
+    ).Scan(&messageID, &chatID)
+    if err == sql.ErrNoRows {
+        return uuid.Nil, uuid.Nil, nil
+    }
+    if err != nil {
+        return uuid.Nil, uuid.Nil, fmt.Errorf("UpdateDeliveryByExternalID: %w", err)
+    }
+    return messageID, chatID, nil
+}
+
+// GetLastUserMessage возвращает последнее сообщение пользователя в чате (nil, если его нет).
+func GetLastUserMessage(db *sql.DB, chatID uuid.UUID) (*models.Message, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    var m models.Message
+    var raw []byte
+    err := db.QueryRowContext(ctx, `
+        SELECT id,content,sender,sender_id,timestamp,read,type,metadata
+          FROM messages
+         WHERE chat_id=$1 AND sender='user'
+         ORDER BY timestamp DESC LIMIT 1`,
+        chatID,
+    ).Scan(&m.ID, &m.Content, &m.Sender, &m.SenderID, &m.Timestamp, &m.Read, &m.Type, &raw)
+    if err == sql.ErrNoRows {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("GetLastUserMessage: %w", err)
+    }
+    m.ChatID = chatID
+    if len(raw) > 0 {
+        _ = json.Unmarshal(raw, &m.Metadata)
+    }
+    return &m, nil
+}
+
+// GetRecentMessages возвращает последние limit сообщений чата в порядке создания.
+func GetRecentMessages(db *sql.DB, chatID uuid.UUID, limit int) ([]models.Message, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    rows, err := db.QueryContext(ctx, `
+        SELECT id,content,sender,sender_id,timestamp,read,type,metadata
+          FROM (SELECT * FROM messages
+                 WHERE chat_id=$1
+                 ORDER BY timestamp DESC
+                 LIMIT $2) recent
+         ORDER BY timestamp ASC`,
+        chatID, limit,
+    )
+    if err != nil {
+        return nil, fmt.Errorf("GetRecentMessages: %w", err)
+    }
+    defer rows.Close()
+
+    var list []models.Message
+    for rows.Next() {
+        var m models.Message
+        var raw []byte
+        if err := rows.Scan(
+            &m.ID, &m.Content, &m.Sender, &m.SenderID,
+            &m.Timestamp, &m.Read, &m.Type, &raw,
+        ); err != nil {
+            return nil, fmt.Errorf("GetRecentMessages: %w", err)
+        }
+        m.ChatID = chatID
+        if len(raw) > 0 {
+            _ = json.Unmarshal(raw, &m.Metadata)
+        }
+        list = append(list, m)
+    }
+    return list, rows.Err()
+}
+// GetMessagesSince возвращает сообщения клиента (или одного чата, если
+// chatID не nil), созданные после since, в порядке создания — не больше limit.
+// Если assignee не nil, только из чатов этого оператора и свободных.
+func GetMessagesSince(db *sql.DB, clientID uuid.UUID, chatID, assignee *uuid.UUID, since time.Time, limit int) ([]models.Message, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    rows, err := db.QueryContext(ctx, `
+        SELECT m.id,m.chat_id,m.content,m.sender,m.sender_id,m.timestamp,m.read,m.type,m.metadata
+          FROM messages m
+          JOIN chats c ON c.id = m.chat_id
+         WHERE c.client_id=$1 AND ($2::uuid IS NULL OR m.chat_id=$2) AND m.timestamp > $3
+           AND ($5::uuid IS NULL OR c.assigned_to IS NULL OR c.assigned_to=$5)
+         ORDER BY m.timestamp ASC
+         LIMIT $4`,
+        clientID, chatID, since, limit, assignee,
+    )
+    if err != nil {
+        return nil, fmt.Errorf("GetMessagesSince: %w", err)
+    }
+    defer rows.Close()
+
+    var list []models.Message
+    for rows.Next() {
+        var m models.Message
+        var raw []byte
+        if err := rows.Scan(
+            &m.ID, &m.ChatID, &m.Content, &m.Sender, &m.SenderID,
+            &m.Timestamp, &m.Read, &m.Type, &raw,
+        ); err != nil {
+            return nil, fmt.Errorf("GetMessagesSince: %w", err)
+        }
+        if len(raw) > 0 {
+            _ = json.Unmarshal(raw, &m.Metadata)
+        }
+        list = append(list, m)
+    }
+    return list, rows.Err()
+}
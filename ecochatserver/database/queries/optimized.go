@@ -16,24 +16,31 @@ func GetChatLightweight(db *sql.DB, chatID uuid.UUID) (*models.Chat, error) {
 
     var chat models.Chat
     var userID uuid.UUID
+    var assignedNull sql.NullString
     
     // Получаем только базовую информацию
     err := db.QueryRowContext(ctx, `
         SELECT c.id, c.created_at, c.updated_at, c.status,
-               c.user_id, c.source, c.client_id,
-               u.id, u.name, u.email, u.source
+               c.user_id, c.source, c.bot_id, c.client_id, c.assigned_to,
+               c.closed_at, c.resolution,
+               u.id, u.name, u.email, u.source, u.source_id
         FROM chats c
         JOIN users u ON c.user_id = u.id
         WHERE c.id = $1
     `, chatID).Scan(
         &chat.ID, &chat.CreatedAt, &chat.UpdatedAt, &chat.Status,
-        &userID, &chat.Source, &chat.ClientID,
-        &chat.User.ID, &chat.User.Name, &chat.User.Email, &chat.User.Source,
+        &userID, &chat.Source, &chat.BotID, &chat.ClientID, &assignedNull,
+        &chat.ClosedAt, &chat.Resolution,
+        &chat.User.ID, &chat.User.Name, &chat.User.Email, &chat.User.Source, &chat.User.SourceID,
     )
     
     if err != nil {
         return nil, err
     }
+
+    if chat.AssignedTo, err = nullUUIDToPointer(assignedNull); err != nil {
+        return nil, err
+    }
     
     return &chat, nil
 }
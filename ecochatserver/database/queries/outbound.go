@@ -0,0 +1,106 @@
+package queries
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "github.com/egor/ecochatserver/models"
+    "github.com/google/uuid"
+)
+
+// Статусы строк очереди outbound_deliveries
+const (
+    OutboundDeliveryPending = "pending"
+    OutboundDeliverySent    = "sent"
+    OutboundDeliveryFailed  = "failed"
+)
+
+// EnqueueOutbound ставит сообщение чата chatID в очередь доставки в канал channel.
+// Повторная постановка того же сообщения ничего не меняет.
+func EnqueueOutbound(db *sql.DB, chatID uuid.UUID, channel string, msg *models.Message) error {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    payload, err := json.Marshal(msg)
+    if err != nil {
+        return fmt.Errorf("marshal message: %w", err)
+    }
+    if _, err := db.ExecContext(ctx, `
+        INSERT INTO outbound_deliveries(message_id,chat_id,channel,payload)
+        VALUES($1,$2,$3,$4)
+        ON CONFLICT (message_id) DO NOTHING`,
+        msg.ID, chatID, channel, payload,
+    ); err != nil {
+        return fmt.Errorf("EnqueueOutbound: %w", err)
+    }
+    return nil
+}
+
+// ClaimOutboundDeliveries забирает до limit доставок, срок которых наступил,
+// так же, как ClaimWebhookDeliveries: next_attempt_at сдвигается на lease,
+// и после падения процесса доставка вернётся в очередь.
+func ClaimOutboundDeliveries(db *sql.DB, limit int, lease time.Duration) ([]models.OutboundDelivery, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    rows, err := db.QueryContext(ctx, `
+        UPDATE outbound_deliveries
+           SET attempts = attempts + 1,
+               next_attempt_at = now() + make_interval(secs => $2)
+         WHERE message_id IN (
+                SELECT message_id
+                  FROM outbound_deliveries
+                 WHERE status = 'pending' AND next_attempt_at <= now()
+                 ORDER BY next_attempt_at
+                 LIMIT $1
+                   FOR UPDATE SKIP LOCKED)
+        RETURNING chat_id, channel, payload, attempts`,
+        limit, lease.Seconds(),
+    )
+    if err != nil {
+        return nil, fmt.Errorf("ClaimOutboundDeliveries: %w", err)
+    }
+    defer rows.Close()
+
+    var list []models.OutboundDelivery
+    for rows.Next() {
+        var d models.OutboundDelivery
+        var payload []byte
+        if err := rows.Scan(&d.ChatID, &d.Channel, &payload, &d.Attempts); err != nil {
+            return nil, fmt.Errorf("ClaimOutboundDeliveries scan: %w", err)
+        }
+        if err := json.Unmarshal(payload, &d.Message); err != nil {
+            return nil, fmt.Errorf("ClaimOutboundDeliveries payload: %w", err)
+        }
+        list = append(list, d)
+    }
+    return list, rows.Err()
+}
+
+// CompleteOutboundDelivery фиксирует результат попытки. Отправленная
+// доставка удаляется из очереди; OutboundDeliveryPending переносит повтор
+// на nextAt, OutboundDeliveryFailed оставляет строку для разбора.
+func CompleteOutboundDelivery(db *sql.DB, messageID uuid.UUID, status, lastErr string, nextAt time.Time) error {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    var err error
+    if status == OutboundDeliverySent {
+        _, err = db.ExecContext(ctx, "DELETE FROM outbound_deliveries WHERE message_id=$1", messageID)
+    } else {
+        _, err = db.ExecContext(ctx, `
+            UPDATE outbound_deliveries
+               SET status=$2, last_error=$3,
+                   next_attempt_at=CASE WHEN $2='pending' THEN $4 ELSE next_attempt_at END
+             WHERE message_id=$1`,
+            messageID, status, lastErr, nextAt,
+        )
+    }
+    if err != nil {
+        return fmt.Errorf("CompleteOutboundDelivery: %w", err)
+    }
+    return nil
+}
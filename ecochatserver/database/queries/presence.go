@@ -0,0 +1,255 @@
+package queries
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+
+    "github.com/egor/ecochatserver/models"
+)
+
+// SetAdminPresence сохраняет статус присутствия оператора.
+func SetAdminPresence(db *sql.DB, adminID uuid.UUID, presence string) error {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    if _, err := db.ExecContext(ctx,
+        `UPDATE admins SET presence=$2, presence_updated_at=now() WHERE id=$1`, adminID, presence,
+    ); err != nil {
+        return fmt.Errorf("SetAdminPresence: %w", err)
+    }
+    return nil
+}
+
+// ResetPresence переводит всех операторов в offline и забывает узлы хаба.
+// Вызывается при старте одиночного сервера: соединения, открытые до
+// перезапуска, уже закрыты.
+func ResetPresence(db *sql.DB) error {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    if _, err := db.ExecContext(ctx, `DELETE FROM hub_nodes`); err != nil {
+        return fmt.Errorf("ResetPresence: %w", err)
+    }
+    if _, err := db.ExecContext(ctx,
+        `UPDATE admins SET presence='offline', presence_updated_at=now() WHERE presence <> 'offline'`,
+    ); err != nil {
+        return fmt.Errorf("ResetPresence: %w", err)
+    }
+    return nil
+}
+
+// NodeTTL — через сколько без heartbeat узел хаба считается упавшим, а
+// сессии операторов на нём — закрытыми
+const NodeTTL = 45 * time.Second
+
+// HeartbeatNode отмечает, что узел хаба жив.
+func HeartbeatNode(db *sql.DB, nodeID string) error {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    if _, err := db.ExecContext(ctx, `
+        INSERT INTO hub_nodes (node_id) VALUES ($1)
+        ON CONFLICT (node_id) DO UPDATE SET heartbeat_at=now()`, nodeID,
+    ); err != nil {
+        return fmt.Errorf("HeartbeatNode: %w", err)
+    }
+    return nil
+}
+
+// AttachAdminNode отмечает, что у оператора есть сессия на узле nodeID.
+// Оператор, бывший offline, становится online (changed=true); иначе
+// возвращается его текущий статус, выставленный, возможно, на другом узле.
+func AttachAdminNode(db *sql.DB, nodeID string, adminID uuid.UUID) (status string, changed bool, err error) {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    tx, err := db.BeginTx(ctx, nil)
+    if err != nil {
+        return "", false, fmt.Errorf("AttachAdminNode: %w", err)
+    }
+    defer tx.Rollback()
+
+    // Строка оператора блокируется: подключение и отключение на разных
+    // узлах не должны разминуться
+    if err := tx.QueryRowContext(ctx,
+        `SELECT presence FROM admins WHERE id=$1 FOR UPDATE`, adminID,
+    ).Scan(&status); err != nil {
+        return "", false, fmt.Errorf("AttachAdminNode: %w", err)
+    }
+    if _, err := tx.ExecContext(ctx, `
+        INSERT INTO hub_nodes (node_id) VALUES ($1)
+        ON CONFLICT (node_id) DO UPDATE SET heartbeat_at=now()`, nodeID,
+    ); err != nil {
+        return "", false, fmt.Errorf("AttachAdminNode: %w", err)
+    }
+    if _, err := tx.ExecContext(ctx,
+        `INSERT INTO admin_nodes (admin_id, node_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+        adminID, nodeID,
+    ); err != nil {
+        return "", false, fmt.Errorf("AttachAdminNode: %w", err)
+    }
+    if status == models.PresenceOffline {
+        status, changed = models.PresenceOnline, true
+        if _, err := tx.ExecContext(ctx,
+            `UPDATE admins SET presence=$2, presence_updated_at=now() WHERE id=$1`, adminID, status,
+        ); err != nil {
+            return "", false, fmt.Errorf("AttachAdminNode: %w", err)
+        }
+    }
+    if err := tx.Commit(); err != nil {
+        return "", false, fmt.Errorf("AttachAdminNode: %w", err)
+    }
+    return status, changed, nil
+}
+
+// DetachAdminNode убирает отметку о сессиях оператора на узле nodeID.
+// Если живых узлов (heartbeat моложе ttl) с его сессиями не осталось,
+// оператор становится offline (offline=true).
+func DetachAdminNode(db *sql.DB, nodeID string, adminID uuid.UUID, ttl time.Duration) (offline bool, err error) {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    tx, err := db.BeginTx(ctx, nil)
+    if err != nil {
+        return false, fmt.Errorf("DetachAdminNode: %w", err)
+    }
+    defer tx.Rollback()
+
+    var status string
+    if err := tx.QueryRowContext(ctx,
+        `SELECT presence FROM admins WHERE id=$1 FOR UPDATE`, adminID,
+    ).Scan(&status); err != nil {
+        return false, fmt.Errorf("DetachAdminNode: %w", err)
+    }
+    if _, err := tx.ExecContext(ctx,
+        `DELETE FROM admin_nodes WHERE admin_id=$1 AND node_id=$2`, adminID, nodeID,
+    ); err != nil {
+        return false, fmt.Errorf("DetachAdminNode: %w", err)
+    }
+    var connected bool
+    if err := tx.QueryRowContext(ctx, `
+        SELECT EXISTS (
+            SELECT 1 FROM admin_nodes an
+              JOIN hub_nodes n ON n.node_id=an.node_id
+             WHERE an.admin_id=$1 AND n.heartbeat_at > now() - make_interval(secs => $2))`,
+        adminID, ttl.Seconds(),
+    ).Scan(&connected); err != nil {
+        return false, fmt.Errorf("DetachAdminNode: %w", err)
+    }
+    if !connected && status != models.PresenceOffline {
+        offline = true
+        if _, err := tx.ExecContext(ctx,
+            `UPDATE admins SET presence='offline', presence_updated_at=now() WHERE id=$1`, adminID,
+        ); err != nil {
+            return false, fmt.Errorf("DetachAdminNode: %w", err)
+        }
+    }
+    if err := tx.Commit(); err != nil {
+        return false, fmt.Errorf("DetachAdminNode: %w", err)
+    }
+    return offline, nil
+}
+
+// ExpirePresence удаляет узлы хаба без heartbeat дольше ttl (упавшие) и
+// переводит в offline операторов, у которых не осталось сессий на живых
+// узлах. Возвращает операторов, ставших offline.
+func ExpirePresence(db *sql.DB, ttl time.Duration) ([]models.AdminPresence, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    if _, err := db.ExecContext(ctx,
+        `DELETE FROM hub_nodes WHERE heartbeat_at < now() - make_interval(secs => $1)`, ttl.Seconds(),
+    ); err != nil {
+        return nil, fmt.Errorf("ExpirePresence: %w", err)
+    }
+
+    tx, err := db.BeginTx(ctx, nil)
+    if err != nil {
+        return nil, fmt.Errorf("ExpirePresence: %w", err)
+    }
+    defer tx.Rollback()
+
+    // Сначала блокируем кандидатов, затем проверяем их заново: следующий
+    // запрос видит подключения, закоммиченные, пока мы ждали блокировку
+    if _, err := tx.ExecContext(ctx, `
+        SELECT a.id FROM admins a
+         WHERE a.presence <> 'offline'
+           AND NOT EXISTS (SELECT 1 FROM admin_nodes an WHERE an.admin_id=a.id)
+         FOR UPDATE`,
+    ); err != nil {
+        return nil, fmt.Errorf("ExpirePresence: %w", err)
+    }
+    rows, err := tx.QueryContext(ctx, `
+        UPDATE admins a SET presence='offline', presence_updated_at=now()
+         WHERE a.presence <> 'offline'
+           AND NOT EXISTS (SELECT 1 FROM admin_nodes an WHERE an.admin_id=a.id)
+        RETURNING a.id, a.client_id`)
+    if err != nil {
+        return nil, fmt.Errorf("ExpirePresence: %w", err)
+    }
+    var list []models.AdminPresence
+    for rows.Next() {
+        op := models.AdminPresence{Status: models.PresenceOffline}
+        if err := rows.Scan(&op.AdminID, &op.ClientID); err != nil {
+            rows.Close()
+            return nil, fmt.Errorf("ExpirePresence scan: %w", err)
+        }
+        list = append(list, op)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return nil, fmt.Errorf("ExpirePresence: %w", err)
+    }
+    if err := tx.Commit(); err != nil {
+        return nil, fmt.Errorf("ExpirePresence: %w", err)
+    }
+    return list, nil
+}
+
+// GetOperatorAvailability возвращает число активных операторов клиента
+// по статусам присутствия.
+func GetOperatorAvailability(db *sql.DB, clientID uuid.UUID) (map[string]int, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    rows, err := db.QueryContext(ctx,
+        `SELECT presence, COUNT(*) FROM admins WHERE client_id=$1 AND active GROUP BY presence`, clientID)
+    if err != nil {
+        return nil, fmt.Errorf("GetOperatorAvailability: %w", err)
+    }
+    defer rows.Close()
+
+    counts := make(map[string]int)
+    for rows.Next() {
+        var presence string
+        var n int
+        if err := rows.Scan(&presence, &n); err != nil {
+            return nil, fmt.Errorf("GetOperatorAvailability scan: %w", err)
+        }
+        counts[presence] = n
+    }
+    return counts, rows.Err()
+}
+
+// FindClientID находит активного клиента по API-ключу или ID.
+func FindClientID(db *sql.DB, key string) (uuid.UUID, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    var id uuid.UUID
+    err := db.QueryRowContext(ctx,
+        `SELECT id FROM clients WHERE (api_key=$1 OR id::text=$1) AND active`, key,
+    ).Scan(&id)
+    if errors.Is(err, sql.ErrNoRows) {
+        return uuid.Nil, ErrClientNotFound
+    }
+    if err != nil {
+        return uuid.Nil, fmt.Errorf("FindClientID: %w", err)
+    }
+    return id, nil
+}
@@ -0,0 +1,199 @@
+package queries
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "fmt"
+
+    "github.com/egor/ecochatserver/models"
+    "github.com/google/uuid"
+)
+
+// routingOperatorQuery выбирает активных операторов с числом открытых
+// назначенных им чатов. Условие WHERE дописывается вызывающим.
+const routingOperatorQuery = `
+    SELECT a.id, a.client_id, a.name, a.role, array_to_json(a.skills), a.max_chats,
+           (SELECT COUNT(*) FROM chats c WHERE c.assigned_to=a.id AND c.status <> 'closed'),
+           a.presence, a.last_routed_at
+      FROM admins a
+     WHERE a.active AND `
+
+func scanRoutingOperators(rows *sql.Rows) ([]models.RoutingOperator, error) {
+    defer rows.Close()
+
+    var list []models.RoutingOperator
+    for rows.Next() {
+        var op models.RoutingOperator
+        var skills []byte
+        var lastRouted sql.NullTime
+        if err := rows.Scan(
+            &op.ID, &op.ClientID, &op.Name, &op.Role, &skills, &op.MaxChats, &op.ActiveChats,
+            &op.Presence, &lastRouted,
+        ); err != nil {
+            return nil, err
+        }
+        op.LastRoutedAt = lastRouted.Time
+        if err := json.Unmarshal(skills, &op.Skills); err != nil || op.Skills == nil {
+            op.Skills = []string{}
+        }
+        list = append(list, op)
+    }
+    return list, rows.Err()
+}
+
+// ListAvailableOperators возвращает активных операторов в статусе online
+// с их нагрузкой — кандидатов для маршрутизации. Берутся только операторы
+// с сессией на живом узле хаба (heartbeat моложе NodeTTL): статус online
+// упавшего узла ещё не успел истечь.
+func ListAvailableOperators(db *sql.DB) ([]models.RoutingOperator, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    rows, err := db.QueryContext(ctx, routingOperatorQuery+`a.presence='online'
+       AND EXISTS (
+           SELECT 1 FROM admin_nodes an
+             JOIN hub_nodes n ON n.node_id=an.node_id
+            WHERE an.admin_id=a.id AND n.heartbeat_at > now() - make_interval(secs => $1))
+     ORDER BY a.name`, NodeTTL.Seconds())
+    if err != nil {
+        return nil, fmt.Errorf("ListAvailableOperators: %w", err)
+    }
+    list, err := scanRoutingOperators(rows)
+    if err != nil {
+        return nil, fmt.Errorf("ListAvailableOperators scan: %w", err)
+    }
+    return list, nil
+}
+
+// ListClientOperators возвращает всех активных операторов клиента.
+func ListClientOperators(db *sql.DB, clientID uuid.UUID) ([]models.RoutingOperator, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    rows, err := db.QueryContext(ctx, routingOperatorQuery+`a.client_id=$1 ORDER BY a.name`, clientID)
+    if err != nil {
+        return nil, fmt.Errorf("ListClientOperators: %w", err)
+    }
+    list, err := scanRoutingOperators(rows)
+    if err != nil {
+        return nil, fmt.Errorf("ListClientOperators scan: %w", err)
+    }
+    return list, nil
+}
+
+// UpdateOperatorRouting меняет навыки и лимит чатов оператора клиента.
+func UpdateOperatorRouting(db *sql.DB, clientID, adminID uuid.UUID, skills []string, maxChats int) error {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    if skills == nil {
+        skills = []string{}
+    }
+    res, err := db.ExecContext(ctx,
+        `UPDATE admins SET skills=$3, max_chats=$4 WHERE id=$1 AND client_id=$2`,
+        adminID, clientID, skills, maxChats)
+    if err != nil {
+        return fmt.Errorf("UpdateOperatorRouting: %w", err)
+    }
+    if n, _ := res.RowsAffected(); n == 0 {
+        return ErrAdminNotFound
+    }
+    return nil
+}
+
+// GetRoutingStrategies возвращает стратегии маршрутизации клиентов
+// (пустая строка — стратегия сервера по умолчанию).
+func GetRoutingStrategies(db *sql.DB, clientIDs []string) (map[uuid.UUID]string, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    rows, err := db.QueryContext(ctx,
+        `SELECT id, routing_strategy FROM clients WHERE id = ANY($1::text[]::uuid[])`, clientIDs)
+    if err != nil {
+        return nil, fmt.Errorf("GetRoutingStrategies: %w", err)
+    }
+    defer rows.Close()
+
+    strategies := make(map[uuid.UUID]string, len(clientIDs))
+    for rows.Next() {
+        var id uuid.UUID
+        var strategy string
+        if err := rows.Scan(&id, &strategy); err != nil {
+            return nil, fmt.Errorf("GetRoutingStrategies scan: %w", err)
+        }
+        strategies[id] = strategy
+    }
+    return strategies, rows.Err()
+}
+
+// SetRoutingStrategy сохраняет стратегию маршрутизации клиента.
+func SetRoutingStrategy(db *sql.DB, clientID uuid.UUID, strategy string) error {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    res, err := db.ExecContext(ctx, `UPDATE clients SET routing_strategy=$2 WHERE id=$1`, clientID, strategy)
+    if err != nil {
+        return fmt.Errorf("SetRoutingStrategy: %w", err)
+    }
+    if n, _ := res.RowsAffected(); n == 0 {
+        return ErrClientNotFound
+    }
+    return nil
+}
+
+// ListQueuedChats возвращает самые старые неназначенные открытые чаты клиентов.
+func ListQueuedChats(db *sql.DB, clientIDs []string, limit int) ([]models.QueuedChat, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    rows, err := db.QueryContext(ctx, `
+        SELECT id, client_id, source, language, created_at
+          FROM chats
+         WHERE client_id = ANY($1::text[]::uuid[])
+           AND assigned_to IS NULL AND status <> 'closed'
+         ORDER BY created_at
+         LIMIT $2`, clientIDs, limit)
+    if err != nil {
+        return nil, fmt.Errorf("ListQueuedChats: %w", err)
+    }
+    defer rows.Close()
+
+    var list []models.QueuedChat
+    for rows.Next() {
+        var ch models.QueuedChat
+        if err := rows.Scan(&ch.ID, &ch.ClientID, &ch.Source, &ch.Language, &ch.CreatedAt); err != nil {
+            return nil, fmt.Errorf("ListQueuedChats scan: %w", err)
+        }
+        list = append(list, ch)
+    }
+    return list, rows.Err()
+}
+
+// CountQueuedChats возвращает размер очереди клиента.
+func CountQueuedChats(db *sql.DB, clientID uuid.UUID) (int, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    var n int
+    err := db.QueryRowContext(ctx, `
+        SELECT COUNT(*) FROM chats
+         WHERE client_id=$1 AND assigned_to IS NULL AND status <> 'closed'`, clientID).Scan(&n)
+    if err != nil {
+        return 0, fmt.Errorf("CountQueuedChats: %w", err)
+    }
+    return n, nil
+}
+
+// SetChatLanguage запоминает язык чата, если он ещё не определён.
+func SetChatLanguage(db *sql.DB, chatID uuid.UUID, language string) error {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    if _, err := db.ExecContext(ctx,
+        `UPDATE chats SET language=$2 WHERE id=$1 AND language=''`, chatID, language,
+    ); err != nil {
+        return fmt.Errorf("SetChatLanguage: %w", err)
+    }
+    return nil
+}
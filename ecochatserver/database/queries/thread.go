@@ -0,0 +1,69 @@
+package queries
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+)
+
+// threadLookupWindow — сколько назад искать сообщения цепочки
+// (ограничивает сканирование партиций messages)
+const threadLookupWindow = 180 * 24 * time.Hour
+
+// FindThread возвращает thread_id чата бота, в котором есть сообщение с одним
+// из ID источника refs: входящее (metadata.sourceMessageId) или исходящее
+// (metadata.delivery.externalId). ok=false — цепочка не найдена.
+func FindThread(db *sql.DB, clientID uuid.UUID, source, botID string, refs []string) (threadID string, ok bool, err error) {
+    if len(refs) == 0 {
+        return "", false, nil
+    }
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    err = db.QueryRowContext(ctx, `
+        SELECT c.thread_id
+          FROM messages m
+          JOIN chats c ON c.id = m.chat_id
+         WHERE c.client_id=$1 AND c.source=$2 AND c.bot_id=$3
+           AND (m.metadata->>'sourceMessageId' = ANY($4::text[])
+                OR m.metadata->'delivery'->>'externalId' = ANY($4::text[]))
+           AND m.timestamp > $5
+         ORDER BY m.timestamp DESC
+         LIMIT 1`,
+        clientID, source, botID, refs, time.Now().Add(-threadLookupWindow),
+    ).Scan(&threadID)
+    if err == sql.ErrNoRows {
+        return "", false, nil
+    }
+    if err != nil {
+        return "", false, fmt.Errorf("FindThread: %w", err)
+    }
+    return threadID, true, nil
+}
+
+// HasInboundMessage сообщает, сохранено ли уже входящее сообщение с ID
+// источника sourceMessageID в чатах бота (повторная доставка).
+func HasInboundMessage(db *sql.DB, clientID uuid.UUID, source, botID, sourceMessageID string) (bool, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    var exists bool
+    err := db.QueryRowContext(ctx, `
+        SELECT EXISTS(
+            SELECT 1
+              FROM messages m
+              JOIN chats c ON c.id = m.chat_id
+             WHERE c.client_id=$1 AND c.source=$2 AND c.bot_id=$3
+               AND m.sender='user'
+               AND m.metadata->>'sourceMessageId' = $4
+               AND m.timestamp > $5)`,
+        clientID, source, botID, sourceMessageID, time.Now().Add(-threadLookupWindow),
+    ).Scan(&exists)
+    if err != nil {
+        return false, fmt.Errorf("HasInboundMessage: %w", err)
+    }
+    return exists, nil
+}
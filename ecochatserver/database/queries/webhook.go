@@ -0,0 +1,328 @@
+package queries
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "time"
+
+    "github.com/egor/ecochatserver/models"
+    "github.com/google/uuid"
+)
+
+// ErrWebhookNotFound — подписка не существует или принадлежит другому клиенту
+var ErrWebhookNotFound = errors.New("подписка на вебхук не найдена")
+
+// ErrWebhookDeliveryNotFound — доставка не существует или принадлежит другому клиенту
+var ErrWebhookDeliveryNotFound = errors.New("доставка вебхука не найдена")
+
+// Статусы строк журнала доставок webhook_deliveries
+const (
+    WebhookDeliveryPending   = "pending"
+    WebhookDeliveryDelivered = "delivered"
+    WebhookDeliveryFailed    = "failed"
+)
+
+const webhookSubscriptionColumns = `id,client_id,url,secret,array_to_json(events),active,created_at,updated_at`
+
+func scanWebhookSubscription(row interface{ Scan(...any) error }) (*models.WebhookSubscription, error) {
+    var sub models.WebhookSubscription
+    var events []byte
+    if err := row.Scan(
+        &sub.ID, &sub.ClientID, &sub.URL, &sub.Secret, &events,
+        &sub.Active, &sub.CreatedAt, &sub.UpdatedAt,
+    ); err != nil {
+        return nil, err
+    }
+    if err := json.Unmarshal(events, &sub.Events); err != nil || sub.Events == nil {
+        sub.Events = []string{}
+    }
+    return &sub, nil
+}
+
+// CreateWebhookSubscription сохраняет новую подписку клиента.
+func CreateWebhookSubscription(db *sql.DB, sub *models.WebhookSubscription) error {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    if sub.ID == uuid.Nil {
+        sub.ID = uuid.New()
+    }
+    if sub.Events == nil {
+        sub.Events = []string{}
+    }
+    now := time.Now()
+    sub.CreatedAt, sub.UpdatedAt = now, now
+
+    if _, err := db.ExecContext(ctx, `
+        INSERT INTO webhook_subscriptions(id,client_id,url,secret,events,active,created_at,updated_at)
+        VALUES($1,$2,$3,$4,$5,$6,$7,$7)`,
+        sub.ID, sub.ClientID, sub.URL, sub.Secret, sub.Events, sub.Active, now,
+    ); err != nil {
+        return fmt.Errorf("CreateWebhookSubscription: %w", err)
+    }
+    return nil
+}
+
+// ListWebhookSubscriptions возвращает все подписки клиента.
+func ListWebhookSubscriptions(db *sql.DB, clientID uuid.UUID) ([]models.WebhookSubscription, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    rows, err := db.QueryContext(ctx, `
+        SELECT `+webhookSubscriptionColumns+`
+          FROM webhook_subscriptions
+         WHERE client_id=$1
+         ORDER BY created_at`, clientID)
+    if err != nil {
+        return nil, fmt.Errorf("ListWebhookSubscriptions: %w", err)
+    }
+    defer rows.Close()
+
+    list := []models.WebhookSubscription{}
+    for rows.Next() {
+        sub, err := scanWebhookSubscription(rows)
+        if err != nil {
+            return nil, fmt.Errorf("ListWebhookSubscriptions scan: %w", err)
+        }
+        list = append(list, *sub)
+    }
+    return list, rows.Err()
+}
+
+// GetWebhookSubscription возвращает подписку клиента по ID.
+func GetWebhookSubscription(db *sql.DB, clientID, id uuid.UUID) (*models.WebhookSubscription, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    sub, err := scanWebhookSubscription(db.QueryRowContext(ctx, `
+        SELECT `+webhookSubscriptionColumns+`
+          FROM webhook_subscriptions
+         WHERE id=$1 AND client_id=$2`, id, clientID))
+    if err == sql.ErrNoRows {
+        return nil, ErrWebhookNotFound
+    }
+    if err != nil {
+        return nil, fmt.Errorf("GetWebhookSubscription: %w", err)
+    }
+    return sub, nil
+}
+
+// UpdateWebhookSubscription обновляет URL, события и активность подписки.
+// Секрет меняется, только если передан непустой.
+func UpdateWebhookSubscription(db *sql.DB, sub *models.WebhookSubscription) error {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    if sub.Events == nil {
+        sub.Events = []string{}
+    }
+    res, err := db.ExecContext(ctx, `
+        UPDATE webhook_subscriptions
+           SET url=$3, events=$4, active=$5,
+               secret=COALESCE(NULLIF($6,''), secret),
+               updated_at=now()
+         WHERE id=$1 AND client_id=$2`,
+        sub.ID, sub.ClientID, sub.URL, sub.Events, sub.Active, sub.Secret,
+    )
+    if err != nil {
+        return fmt.Errorf("UpdateWebhookSubscription: %w", err)
+    }
+    if n, _ := res.RowsAffected(); n == 0 {
+        return ErrWebhookNotFound
+    }
+    return nil
+}
+
+// DeleteWebhookSubscription удаляет подписку вместе с её журналом доставок.
+func DeleteWebhookSubscription(db *sql.DB, clientID, id uuid.UUID) error {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    res, err := db.ExecContext(ctx,
+        "DELETE FROM webhook_subscriptions WHERE id=$1 AND client_id=$2", id, clientID)
+    if err != nil {
+        return fmt.Errorf("DeleteWebhookSubscription: %w", err)
+    }
+    if n, _ := res.RowsAffected(); n == 0 {
+        return ErrWebhookNotFound
+    }
+    return nil
+}
+
+// EnqueueWebhookEvent ставит событие в очередь доставки для всех активных
+// подписок клиента, подписанных на этот тип (пустой список событий — все).
+// Возвращает число созданных доставок.
+func EnqueueWebhookEvent(db *sql.DB, clientID, eventID uuid.UUID, eventType string, payload []byte) (int, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    res, err := db.ExecContext(ctx, `
+        INSERT INTO webhook_deliveries(id,subscription_id,client_id,event_id,event_type,payload)
+        SELECT gen_random_uuid(), s.id, s.client_id, $2, $3, $4
+          FROM webhook_subscriptions s
+         WHERE s.client_id=$1 AND s.active=true
+           AND (cardinality(s.events)=0 OR $3=ANY(s.events))`,
+        clientID, eventID, eventType, payload,
+    )
+    if err != nil {
+        return 0, fmt.Errorf("EnqueueWebhookEvent: %w", err)
+    }
+    n, _ := res.RowsAffected()
+    return int(n), nil
+}
+
+// ClaimWebhookDeliveries забирает до limit доставок, срок которых наступил,
+// и сдвигает их next_attempt_at на lease вперёд. Если процесс упадёт во время
+// отправки, доставка вернётся в очередь по истечении lease. Несколько
+// экземпляров сервера не заберут одну и ту же строку (SKIP LOCKED).
+func ClaimWebhookDeliveries(db *sql.DB, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    rows, err := db.QueryContext(ctx, `
+        UPDATE webhook_deliveries d
+           SET attempts = d.attempts + 1,
+               next_attempt_at = now() + make_interval(secs => $2)
+          FROM webhook_subscriptions s
+         WHERE s.id = d.subscription_id
+           AND d.id IN (
+                SELECT dd.id
+                  FROM webhook_deliveries dd
+                  JOIN webhook_subscriptions ss ON ss.id = dd.subscription_id
+                 WHERE dd.status = 'pending' AND dd.next_attempt_at <= now() AND ss.active = true
+                 ORDER BY dd.next_attempt_at
+                 LIMIT $1
+                   FOR UPDATE OF dd SKIP LOCKED)
+        RETURNING d.id, d.subscription_id, d.client_id, d.event_id, d.event_type,
+                  d.payload, d.attempts, d.created_at, s.url, s.secret`,
+        limit, lease.Seconds(),
+    )
+    if err != nil {
+        return nil, fmt.Errorf("ClaimWebhookDeliveries: %w", err)
+    }
+    defer rows.Close()
+
+    var list []models.WebhookDelivery
+    for rows.Next() {
+        var d models.WebhookDelivery
+        var payload []byte
+        if err := rows.Scan(
+            &d.ID, &d.SubscriptionID, &d.ClientID, &d.EventID, &d.EventType,
+            &payload, &d.Attempts, &d.CreatedAt, &d.URL, &d.Secret,
+        ); err != nil {
+            return nil, fmt.Errorf("ClaimWebhookDeliveries scan: %w", err)
+        }
+        d.Payload = payload
+        d.Status = WebhookDeliveryPending
+        list = append(list, d)
+    }
+    return list, rows.Err()
+}
+
+// CompleteWebhookDelivery фиксирует результат попытки доставки.
+// status — WebhookDeliveryDelivered, WebhookDeliveryFailed (попытки исчерпаны)
+// или WebhookDeliveryPending с новым временем повтора nextAt.
+func CompleteWebhookDelivery(db *sql.DB, id uuid.UUID, status string, statusCode int, lastErr string, nextAt time.Time) error {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    if _, err := db.ExecContext(ctx, `
+        UPDATE webhook_deliveries
+           SET status=$2, last_status_code=$3, last_error=$4,
+               next_attempt_at=CASE WHEN $2='pending' THEN $5 ELSE next_attempt_at END,
+               delivered_at=CASE WHEN $2='delivered' THEN now() ELSE delivered_at END
+         WHERE id=$1`,
+        id, status, statusCode, lastErr, nextAt,
+    ); err != nil {
+        return fmt.Errorf("CompleteWebhookDelivery: %w", err)
+    }
+    return nil
+}
+
+// ListWebhookDeliveries возвращает журнал доставок клиента (новые сверху).
+// subscriptionID и status необязательны.
+func ListWebhookDeliveries(
+    db *sql.DB,
+    clientID uuid.UUID,
+    subscriptionID *uuid.UUID,
+    status string,
+    page, size int,
+) ([]models.WebhookDelivery, int, error) {
+    if page < 1 {
+        page = 1
+    }
+    if size < 1 || size > MaxPageSize {
+        size = DefaultPageSize
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    const filter = `
+         WHERE client_id=$1
+           AND ($2::uuid IS NULL OR subscription_id=$2)
+           AND ($3='' OR status=$3)`
+
+    var total int
+    if err := db.QueryRowContext(ctx,
+        "SELECT COUNT(*) FROM webhook_deliveries"+filter,
+        clientID, subscriptionID, status,
+    ).Scan(&total); err != nil {
+        return nil, 0, fmt.Errorf("ListWebhookDeliveries count: %w", err)
+    }
+
+    rows, err := db.QueryContext(ctx, `
+        SELECT id,subscription_id,client_id,event_id,event_type,payload,status,attempts,
+               next_attempt_at,last_status_code,last_error,created_at,delivered_at
+          FROM webhook_deliveries`+filter+`
+         ORDER BY created_at DESC
+         LIMIT $4 OFFSET $5`,
+        clientID, subscriptionID, status, size, (page-1)*size,
+    )
+    if err != nil {
+        return nil, 0, fmt.Errorf("ListWebhookDeliveries: %w", err)
+    }
+    defer rows.Close()
+
+    list := []models.WebhookDelivery{}
+    for rows.Next() {
+        var d models.WebhookDelivery
+        var payload []byte
+        var deliveredAt sql.NullTime
+        if err := rows.Scan(
+            &d.ID, &d.SubscriptionID, &d.ClientID, &d.EventID, &d.EventType, &payload,
+            &d.Status, &d.Attempts, &d.NextAttemptAt, &d.LastStatusCode, &d.LastError,
+            &d.CreatedAt, &deliveredAt,
+        ); err != nil {
+            return nil, 0, fmt.Errorf("ListWebhookDeliveries scan: %w", err)
+        }
+        d.Payload = payload
+        if deliveredAt.Valid {
+            t := deliveredAt.Time
+            d.DeliveredAt = &t
+        }
+        list = append(list, d)
+    }
+    return list, total, rows.Err()
+}
+
+// RetryWebhookDelivery возвращает доставку в очередь для немедленной повторной отправки.
+func RetryWebhookDelivery(db *sql.DB, clientID, id uuid.UUID) error {
+    ctx, cancel := context.WithTimeout(context.Background(), dbQueryTimeout)
+    defer cancel()
+
+    res, err := db.ExecContext(ctx, `
+        UPDATE webhook_deliveries
+           SET status='pending', attempts=0, next_attempt_at=now()
+         WHERE id=$1 AND client_id=$2`, id, clientID)
+    if err != nil {
+        return fmt.Errorf("RetryWebhookDelivery: %w", err)
+    }
+    if n, _ := res.RowsAffected(); n == 0 {
+        return ErrWebhookDeliveryNotFound
+    }
+    return nil
+}
@@ -0,0 +1,151 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// schemaStatements — идемпотентные DDL для таблиц, которые сервер
+// создаёт сам поверх основной схемы (chats, messages, users, clients, admins).
+var schemaStatements = []string{
+	// Учётные данные ботов/каналов клиента (токен для исходящих сообщений)
+	`CREATE TABLE IF NOT EXISTS client_bots (
+		id         UUID PRIMARY KEY,
+		client_id  UUID NOT NULL REFERENCES clients(id) ON DELETE CASCADE,
+		source     TEXT NOT NULL,
+		bot_id     TEXT NOT NULL,
+		token      TEXT NOT NULL DEFAULT '',
+		active     BOOLEAN NOT NULL DEFAULT true,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		UNIQUE (source, bot_id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS client_bots_client_idx ON client_bots (client_id)`,
+	// Секрет вебхука: сверяется с заголовком X-Telegram-Bot-Api-Secret-Token
+	`ALTER TABLE client_bots ADD COLUMN IF NOT EXISTS webhook_secret TEXT NOT NULL DEFAULT ''`,
+	// Настройки, специфичные для канала (verifyToken для WhatsApp и т.п.)
+	`ALTER TABLE client_bots ADD COLUMN IF NOT EXISTS settings JSONB NOT NULL DEFAULT '{}'::jsonb`,
+	// Поиск сообщения по ID у источника для обновления статуса доставки
+	`CREATE INDEX IF NOT EXISTS messages_delivery_external_idx
+		ON messages ((metadata->'delivery'->>'externalId'))`,
+	// Подписки клиента на исходящие вебхуки событий (CRM и т.п.)
+	`CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+		id         UUID PRIMARY KEY,
+		client_id  UUID NOT NULL REFERENCES clients(id) ON DELETE CASCADE,
+		url        TEXT NOT NULL,
+		secret     TEXT NOT NULL,
+		events     TEXT[] NOT NULL DEFAULT '{}',
+		active     BOOLEAN NOT NULL DEFAULT true,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`,
+	`CREATE INDEX IF NOT EXISTS webhook_subscriptions_client_idx ON webhook_subscriptions (client_id)`,
+	// Журнал доставок: одна строка на пару (событие, подписка), она же очередь повторов
+	`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id               UUID PRIMARY KEY,
+		subscription_id  UUID NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+		client_id        UUID NOT NULL,
+		event_id         UUID NOT NULL,
+		event_type       TEXT NOT NULL,
+		payload          JSONB NOT NULL,
+		status           TEXT NOT NULL DEFAULT 'pending',
+		attempts         INT NOT NULL DEFAULT 0,
+		next_attempt_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+		last_status_code INT NOT NULL DEFAULT 0,
+		last_error       TEXT NOT NULL DEFAULT '',
+		created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+		delivered_at     TIMESTAMPTZ
+	)`,
+	`CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx
+		ON webhook_deliveries (next_attempt_at) WHERE status = 'pending'`,
+	`CREATE INDEX IF NOT EXISTS webhook_deliveries_subscription_idx
+		ON webhook_deliveries (subscription_id, created_at DESC)`,
+	// История назначений чатов операторам (claim / transfer / unassign)
+	`CREATE TABLE IF NOT EXISTS chat_assignments (
+		id         UUID PRIMARY KEY,
+		chat_id    UUID NOT NULL,
+		action     TEXT NOT NULL,
+		from_admin UUID,
+		to_admin   UUID,
+		by_admin   UUID NOT NULL,
+		note       TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`,
+	`CREATE INDEX IF NOT EXISTS chat_assignments_chat_idx ON chat_assignments (chat_id, created_at DESC)`,
+	// Автоматическая маршрутизация: by_admin пуст, если чат назначил роутер
+	`ALTER TABLE chat_assignments ALTER COLUMN by_admin DROP NOT NULL`,
+	// Навыки и лимит открытых чатов оператора
+	`ALTER TABLE admins ADD COLUMN IF NOT EXISTS skills TEXT[] NOT NULL DEFAULT '{}'`,
+	`ALTER TABLE admins ADD COLUMN IF NOT EXISTS max_chats INT NOT NULL DEFAULT 5`,
+	// Стратегия маршрутизации клиента ('' — по умолчанию сервера)
+	`ALTER TABLE clients ADD COLUMN IF NOT EXISTS routing_strategy TEXT NOT NULL DEFAULT ''`,
+	// Язык чата, определённый по сообщениям пользователя
+	`ALTER TABLE chats ADD COLUMN IF NOT EXISTS language TEXT NOT NULL DEFAULT ''`,
+	`CREATE INDEX IF NOT EXISTS chats_queue_idx ON chats (client_id, created_at) WHERE assigned_to IS NULL`,
+	// Жизненный цикл чата: когда и почему закрыт
+	`ALTER TABLE chats ADD COLUMN IF NOT EXISTS closed_at TIMESTAMPTZ`,
+	`ALTER TABLE chats ADD COLUMN IF NOT EXISTS resolution TEXT NOT NULL DEFAULT ''`,
+	// Порог автозакрытия неактивных чатов клиента в минутах (NULL — по умолчанию сервера, 0 — не закрывать)
+	`ALTER TABLE clients ADD COLUMN IF NOT EXISTS auto_close_minutes INT`,
+	`CREATE INDEX IF NOT EXISTS chats_open_updated_idx ON chats (updated_at) WHERE status <> 'closed'`,
+	// Присутствие оператора (online / away / busy / offline)
+	`ALTER TABLE admins ADD COLUMN IF NOT EXISTS presence TEXT NOT NULL DEFAULT 'offline'`,
+	`ALTER TABLE admins ADD COLUMN IF NOT EXISTS presence_updated_at TIMESTAMPTZ`,
+	// Настройки автоответчика клиента (NULL — по умолчанию сервера)
+	`ALTER TABLE clients ADD COLUMN IF NOT EXISTS autoresponder_settings JSONB`,
+	// Бэкплейн хаба: сообщения, не помещающиеся в NOTIFY
+	`CREATE TABLE IF NOT EXISTS hub_backplane_payloads (
+		id         BIGSERIAL PRIMARY KEY,
+		payload    TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`,
+	// Курсор round-robin: когда роутер последний раз назначил оператору чат (общий для всех узлов)
+	`ALTER TABLE admins ADD COLUMN IF NOT EXISTS last_routed_at TIMESTAMPTZ`,
+	// Цепочка внутри источника: отдельный чат на каждую ветку писем ('' — один чат на пользователя)
+	`ALTER TABLE chats ADD COLUMN IF NOT EXISTS thread_id TEXT NOT NULL DEFAULT ''`,
+	// Поиск цепочки и повторных доставок по ID входящего сообщения у источника
+	`CREATE INDEX IF NOT EXISTS messages_source_message_idx
+		ON messages ((metadata->>'sourceMessageId'))`,
+	// Присутствие в кластере: узлы хаба с heartbeat и операторы, подключённые к ним.
+	// Оператор offline, когда у него не осталось строк на живых узлах.
+	`CREATE TABLE IF NOT EXISTS hub_nodes (
+		node_id      TEXT PRIMARY KEY,
+		heartbeat_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`,
+	`CREATE TABLE IF NOT EXISTS admin_nodes (
+		admin_id UUID NOT NULL REFERENCES admins(id) ON DELETE CASCADE,
+		node_id  TEXT NOT NULL REFERENCES hub_nodes(node_id) ON DELETE CASCADE,
+		PRIMARY KEY (admin_id, node_id)
+	)`,
+	// Очередь доставки сообщений операторов во внешние каналы. Переживает
+	// перезапуск; отправленные строки удаляются, неудачные остаются failed.
+	`CREATE TABLE IF NOT EXISTS outbound_deliveries (
+		message_id      UUID PRIMARY KEY,
+		chat_id         UUID NOT NULL,
+		channel         TEXT NOT NULL,
+		payload         JSONB NOT NULL,
+		status          TEXT NOT NULL DEFAULT 'pending',
+		attempts        INT NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		last_error      TEXT NOT NULL DEFAULT '',
+		created_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`,
+	`CREATE INDEX IF NOT EXISTS outbound_deliveries_due_idx
+		ON outbound_deliveries (next_attempt_at) WHERE status = 'pending'`,
+}
+
+// ensureSchema применяет schemaStatements.
+func ensureSchema() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for i, stmt := range schemaStatements {
+		if _, err := DB.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("schema statement %d: %w", i, err)
+		}
+	}
+
+	log.Println("[database] Схема проверена ✓")
+	return nil
+}
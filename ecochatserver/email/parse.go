@@ -0,0 +1,223 @@
+// Package email — приём писем (встроенный SMTP-приёмник), разбор MIME
+// и отправка ответов через SMTP-релей.
+package email
+
+import (
+    "bytes"
+    "encoding/base64"
+    "fmt"
+    "io"
+    "mime"
+    "mime/multipart"
+    "mime/quotedprintable"
+    "net/mail"
+    "regexp"
+    "strings"
+    "time"
+)
+
+// Ограничения разбора
+const (
+    maxPartDepth = 10
+    maxTextSize  = 1 << 20
+)
+
+// Message — разобранное входящее письмо.
+type Message struct {
+    From        string // адрес отправителя в нижнем регистре
+    FromName    string
+    To          []string
+    Subject     string
+    MessageID   string   // в угловых скобках, как в заголовке
+    InReplyTo   string
+    References  []string
+    Date        time.Time
+    Text        string
+    HTML        string
+    Attachments []Attachment
+}
+
+// Attachment — описание вложения (содержимое не сохраняется).
+type Attachment struct {
+    Filename    string `json:"filename"`
+    ContentType string `json:"contentType"`
+    Size        int    `json:"size"`
+}
+
+var headerDecoder = &mime.WordDecoder{}
+
+// Parse разбирает письмо в формате RFC 5322.
+// Поддерживаются кодировки utf-8/us-ascii; тексты в иных кодировках
+// сохраняются как есть.
+func Parse(raw []byte) (*Message, error) {
+    m, err := mail.ReadMessage(bytes.NewReader(raw))
+    if err != nil {
+        return nil, fmt.Errorf("разбор письма: %w", err)
+    }
+
+    from, err := mail.ParseAddress(m.Header.Get("From"))
+    if err != nil {
+        return nil, fmt.Errorf("заголовок From: %w", err)
+    }
+
+    msg := &Message{
+        From:       strings.ToLower(from.Address),
+        FromName:   from.Name,
+        Subject:    decodeHeader(m.Header.Get("Subject")),
+        MessageID:  strings.TrimSpace(m.Header.Get("Message-Id")),
+        InReplyTo:  firstMsgID(m.Header.Get("In-Reply-To")),
+        References: parseMsgIDs(m.Header.Get("References")),
+    }
+    if to, err := m.Header.AddressList("To"); err == nil {
+        for _, a := range to {
+            msg.To = append(msg.To, strings.ToLower(a.Address))
+        }
+    }
+    if date, err := m.Header.Date(); err == nil {
+        msg.Date = date
+    }
+
+    if err := msg.walk(
+        m.Header.Get("Content-Type"),
+        m.Header.Get("Content-Transfer-Encoding"),
+        m.Header.Get("Content-Disposition"),
+        m.Body, 0,
+    ); err != nil {
+        return nil, err
+    }
+    return msg, nil
+}
+
+// walk рекурсивно обходит MIME-части, собирая text/plain, text/html и вложения.
+func (msg *Message) walk(contentType, encoding, disposition string, body io.Reader, depth int) error {
+    if depth > maxPartDepth {
+        return fmt.Errorf("слишком глубокая вложенность MIME")
+    }
+
+    mediaType, params, err := mime.ParseMediaType(contentType)
+    if err != nil || contentType == "" {
+        mediaType = "text/plain"
+    }
+
+    if strings.HasPrefix(mediaType, "multipart/") {
+        mr := multipart.NewReader(body, params["boundary"])
+        for {
+            part, err := mr.NextRawPart()
+            if err == io.EOF {
+                return nil
+            }
+            if err != nil {
+                return fmt.Errorf("разбор multipart: %w", err)
+            }
+            if err := msg.walk(
+                part.Header.Get("Content-Type"),
+                part.Header.Get("Content-Transfer-Encoding"),
+                part.Header.Get("Content-Disposition"),
+                part, depth+1,
+            ); err != nil {
+                return err
+            }
+        }
+    }
+
+    data, err := io.ReadAll(io.LimitReader(decodeTransfer(encoding, body), maxTextSize))
+    if err != nil {
+        return fmt.Errorf("декодирование части %s: %w", mediaType, err)
+    }
+
+    dispType, dispParams, _ := mime.ParseMediaType(disposition)
+    filename := dispParams["filename"]
+    if filename == "" {
+        filename = params["name"]
+    }
+    if dispType == "attachment" || filename != "" || !strings.HasPrefix(mediaType, "text/") {
+        msg.Attachments = append(msg.Attachments, Attachment{
+            Filename:    decodeHeader(filename),
+            ContentType: mediaType,
+            Size:        len(data),
+        })
+        return nil
+    }
+
+    switch mediaType {
+    case "text/html":
+        if msg.HTML == "" {
+            msg.HTML = string(data)
+        }
+    default:
+        if msg.Text == "" {
+            msg.Text = string(data)
+        }
+    }
+    return nil
+}
+
+// decodeTransfer снимает Content-Transfer-Encoding.
+func decodeTransfer(encoding string, r io.Reader) io.Reader {
+    switch strings.ToLower(strings.TrimSpace(encoding)) {
+    case "base64":
+        return base64.NewDecoder(base64.StdEncoding, r)
+    case "quoted-printable":
+        return quotedprintable.NewReader(r)
+    }
+    return r
+}
+
+func decodeHeader(s string) string {
+    decoded, err := headerDecoder.DecodeHeader(s)
+    if err != nil {
+        return s
+    }
+    return decoded
+}
+
+var msgIDPattern = regexp.MustCompile(`<[^<>\s]+>`)
+
+// parseMsgIDs извлекает список <message-id> из References/In-Reply-To.
+func parseMsgIDs(s string) []string {
+    return msgIDPattern.FindAllString(s, -1)
+}
+
+func firstMsgID(s string) string {
+    if ids := parseMsgIDs(s); len(ids) > 0 {
+        return ids[0]
+    }
+    return strings.TrimSpace(s)
+}
+
+var (
+    htmlTagPattern   = regexp.MustCompile(`(?s)<(script|style)[^>]*>.*?</(script|style)>|<[^>]+>`)
+    blankLinePattern = regexp.MustCompile(`\n{3,}`)
+    quoteHeaderLine  = regexp.MustCompile(`(?i)^(on .+ wrote:|.+ пишет:|.+ написал\(а\):)$`)
+)
+
+// Body возвращает текст письма для чата: text/plain без процитированной
+// переписки, либо текст, извлечённый из HTML.
+func (msg *Message) Body() string {
+    text := msg.Text
+    if strings.TrimSpace(text) == "" && msg.HTML != "" {
+        text = htmlTagPattern.ReplaceAllString(msg.HTML, "")
+        text = strings.NewReplacer("&nbsp;", " ", "&amp;", "&", "&lt;", "<", "&gt;", ">", "&quot;", `"`).Replace(text)
+    }
+    return stripQuoted(text)
+}
+
+// stripQuoted отрезает процитированный ответ («> …» и строку «On … wrote:»).
+func stripQuoted(text string) string {
+    text = strings.ReplaceAll(text, "\r\n", "\n")
+    lines := strings.Split(text, "\n")
+    end := len(lines)
+    for i, line := range lines {
+        trimmed := strings.TrimSpace(line)
+        if strings.HasPrefix(trimmed, ">") || quoteHeaderLine.MatchString(trimmed) {
+            end = i
+            break
+        }
+    }
+    out := strings.TrimSpace(strings.Join(lines[:end], "\n"))
+    if out == "" {
+        // письмо состоит только из цитаты — оставляем как есть
+        out = strings.TrimSpace(text)
+    }
+    return blankLinePattern.ReplaceAllString(out, "\n\n")
+}
@@ -0,0 +1,132 @@
+package email
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "mime"
+    "mime/quotedprintable"
+    "net"
+    "net/smtp"
+    "net/textproto"
+    "strings"
+    "time"
+
+    "github.com/google/uuid"
+)
+
+// Relay — SMTP-релей для исходящих ответов операторов.
+type Relay struct {
+    Addr     string // host:port
+    Username string
+    Password string
+}
+
+// Outgoing — исходящее письмо с заголовками цепочки.
+type Outgoing struct {
+    From       string
+    FromName   string
+    To         string
+    Subject    string
+    MessageID  string
+    InReplyTo  string
+    References []string
+    Text       string
+}
+
+// SendError — ошибка релея с признаком временности (коды 4xx и сетевые сбои).
+type SendError struct {
+    Err       error
+    temporary bool
+}
+
+func (e *SendError) Error() string   { return "smtp relay: " + e.Err.Error() }
+func (e *SendError) Unwrap() error   { return e.Err }
+func (e *SendError) Temporary() bool { return e.temporary }
+
+// NewMessageID генерирует Message-ID в домене отправителя.
+func NewMessageID(from string) string {
+    domain := "localhost"
+    if i := strings.LastIndexByte(from, '@'); i >= 0 && i < len(from)-1 {
+        domain = from[i+1:]
+    }
+    return fmt.Sprintf("<%s@%s>", uuid.New().String(), domain)
+}
+
+// ReplySubject добавляет «Re: », если его ещё нет.
+func ReplySubject(subject string) string {
+    s := strings.TrimSpace(subject)
+    if s == "" {
+        return "Re: обращение в поддержку"
+    }
+    if strings.HasPrefix(strings.ToLower(s), "re:") {
+        return s
+    }
+    return "Re: " + s
+}
+
+// Send отправляет письмо через релей.
+func (r *Relay) Send(out *Outgoing) error {
+    if r.Addr == "" {
+        return &SendError{Err: errors.New("SMTP-релей не настроен")}
+    }
+
+    var auth smtp.Auth
+    if r.Username != "" {
+        host, _, _ := net.SplitHostPort(r.Addr)
+        auth = smtp.PlainAuth("", r.Username, r.Password, host)
+    }
+
+    raw, err := out.Bytes()
+    if err != nil {
+        return &SendError{Err: err}
+    }
+
+    if err := smtp.SendMail(r.Addr, auth, out.From, []string{out.To}, raw); err != nil {
+        var tpErr *textproto.Error
+        if errors.As(err, &tpErr) {
+            return &SendError{Err: err, temporary: tpErr.Code >= 400 && tpErr.Code < 500}
+        }
+        return &SendError{Err: err, temporary: true}
+    }
+    return nil
+}
+
+// Bytes собирает письмо в формате RFC 5322 (text/plain, quoted-printable).
+func (out *Outgoing) Bytes() ([]byte, error) {
+    var buf bytes.Buffer
+
+    from := out.From
+    if out.FromName != "" {
+        from = fmt.Sprintf("%s <%s>", mime.QEncoding.Encode("utf-8", out.FromName), out.From)
+    }
+
+    header := func(k, v string) {
+        fmt.Fprintf(&buf, "%s: %s\r\n", k, v)
+    }
+    header("From", from)
+    header("To", out.To)
+    header("Subject", mime.QEncoding.Encode("utf-8", out.Subject))
+    header("Date", time.Now().Format(time.RFC1123Z))
+    header("Message-ID", out.MessageID)
+    if out.InReplyTo != "" {
+        header("In-Reply-To", out.InReplyTo)
+    }
+    if len(out.References) > 0 {
+        header("References", strings.Join(out.References, " "))
+    }
+    header("MIME-Version", "1.0")
+    header("Content-Type", `text/plain; charset="utf-8"`)
+    header("Content-Transfer-Encoding", "quoted-printable")
+    buf.WriteString("\r\n")
+
+    qp := quotedprintable.NewWriter(&buf)
+    text := strings.ReplaceAll(out.Text, "\r\n", "\n")
+    if _, err := qp.Write([]byte(strings.ReplaceAll(text, "\n", "\r\n"))); err != nil {
+        return nil, err
+    }
+    if err := qp.Close(); err != nil {
+        return nil, err
+    }
+    return buf.Bytes(), nil
+}
@@ -0,0 +1,248 @@
+package email
+
+import (
+    "errors"
+    "fmt"
+    "io"
+    "log"
+    "net"
+    "net/textproto"
+    "strings"
+    "sync"
+    "time"
+)
+
+// Ограничения SMTP-сессии
+const (
+    smtpCommandTimeout = 5 * time.Minute
+    smtpMaxRecipients  = 50
+    defaultMaxSize     = 10 << 20
+)
+
+// Handler получает письмо, принятое для одного из адресатов.
+type Handler func(from string, to []string, data []byte) error
+
+// Server — минимальный SMTP-приёмник (RFC 5321) для входящей почты поддержки.
+// Рассчитан на работу за основным MTA во внутренней сети: без AUTH и TLS.
+type Server struct {
+    Addr    string
+    Domain  string
+    MaxSize int64
+
+    // Recipient решает, принимаем ли письма для адреса (RCPT TO)
+    Recipient func(addr string) bool
+    // Handler вызывается после успешного DATA
+    Handler Handler
+
+    mu       sync.Mutex
+    listener net.Listener
+    closed   bool
+}
+
+// ErrServerClosed возвращается Serve после Close.
+var ErrServerClosed = errors.New("email: сервер остановлен")
+
+// ListenAndServe слушает s.Addr и обслуживает соединения.
+func (s *Server) ListenAndServe() error {
+    l, err := net.Listen("tcp", s.Addr)
+    if err != nil {
+        return err
+    }
+    return s.Serve(l)
+}
+
+// Serve принимает соединения на l до вызова Close.
+func (s *Server) Serve(l net.Listener) error {
+    s.mu.Lock()
+    s.listener = l
+    s.mu.Unlock()
+
+    log.Printf("[email] SMTP-приёмник слушает %s", l.Addr())
+    for {
+        conn, err := l.Accept()
+        if err != nil {
+            s.mu.Lock()
+            closed := s.closed
+            s.mu.Unlock()
+            if closed {
+                return ErrServerClosed
+            }
+            return err
+        }
+        go s.serveConn(conn)
+    }
+}
+
+// Close останавливает приём новых соединений.
+func (s *Server) Close() error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.closed = true
+    if s.listener != nil {
+        return s.listener.Close()
+    }
+    return nil
+}
+
+// session — состояние одной транзакции SMTP.
+type session struct {
+    started bool // был MAIL FROM (отправитель может быть пустым: <>)
+    from    string
+    to      []string
+}
+
+func (ss *session) reset() {
+    ss.started = false
+    ss.from = ""
+    ss.to = nil
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+    defer conn.Close()
+    tp := textproto.NewConn(conn)
+    domain := s.domain()
+    maxSize := s.MaxSize
+    if maxSize <= 0 {
+        maxSize = defaultMaxSize
+    }
+
+    reply := func(format string, args ...interface{}) bool {
+        conn.SetWriteDeadline(time.Now().Add(smtpCommandTimeout))
+        return tp.PrintfLine(format, args...) == nil
+    }
+
+    if !reply("220 %s ESMTP ecochat", domain) {
+        return
+    }
+
+    var ss session
+    for {
+        conn.SetReadDeadline(time.Now().Add(smtpCommandTimeout))
+        line, err := tp.ReadLine()
+        if err != nil {
+            return
+        }
+
+        verb, arg := splitCommand(line)
+        switch verb {
+        case "HELO":
+            ss.reset()
+            reply("250 %s", domain)
+        case "EHLO":
+            ss.reset()
+            reply("250-%s", domain)
+            reply("250-SIZE %d", maxSize)
+            reply("250 8BITMIME")
+        case "MAIL":
+            addr, ok := parsePath(arg, "FROM:")
+            if !ok {
+                reply("501 Синтаксис: MAIL FROM:<address>")
+                continue
+            }
+            ss.reset()
+            ss.started = true
+            ss.from = strings.ToLower(addr)
+            reply("250 OK")
+        case "RCPT":
+            if !ss.started {
+                reply("503 Сначала MAIL FROM")
+                continue
+            }
+            addr, ok := parsePath(arg, "TO:")
+            if !ok || addr == "" {
+                reply("501 Синтаксис: RCPT TO:<address>")
+                continue
+            }
+            addr = strings.ToLower(addr)
+            if len(ss.to) >= smtpMaxRecipients {
+                reply("452 Слишком много получателей")
+                continue
+            }
+            if s.Recipient != nil && !s.Recipient(addr) {
+                reply("550 Нет такого ящика: %s", addr)
+                continue
+            }
+            ss.to = append(ss.to, addr)
+            reply("250 OK")
+        case "DATA":
+            if len(ss.to) == 0 {
+                reply("503 Нет получателей")
+                continue
+            }
+            if !reply("354 Завершите письмо строкой с точкой") {
+                return
+            }
+            conn.SetReadDeadline(time.Now().Add(smtpCommandTimeout))
+            data, err := io.ReadAll(io.LimitReader(tp.DotReader(), maxSize+1))
+            if err != nil {
+                return
+            }
+            if int64(len(data)) > maxSize {
+                // Дочитываем остаток, чтобы сессия осталась синхронной
+                io.Copy(io.Discard, tp.DotReader())
+                reply("552 Письмо превышает %d байт", maxSize)
+                ss.reset()
+                continue
+            }
+            if s.Handler != nil {
+                if err := s.Handler(ss.from, ss.to, data); err != nil {
+                    log.Printf("[email] ошибка обработки письма от %s: %v", ss.from, err)
+                    reply("451 Временная ошибка обработки")
+                    ss.reset()
+                    continue
+                }
+            }
+            reply("250 OK: принято")
+            ss.reset()
+        case "RSET":
+            ss.reset()
+            reply("250 OK")
+        case "NOOP":
+            reply("250 OK")
+        case "VRFY":
+            reply("252 Не проверяем, но попробуем доставить")
+        case "QUIT":
+            reply("221 %s закрывает соединение", domain)
+            return
+        default:
+            reply("502 Команда не поддерживается")
+        }
+    }
+}
+
+func (s *Server) domain() string {
+    if s.Domain != "" {
+        return s.Domain
+    }
+    return "localhost"
+}
+
+// splitCommand делит строку SMTP на глагол (в верхнем регистре) и аргумент.
+func splitCommand(line string) (string, string) {
+    line = strings.TrimSpace(line)
+    if i := strings.IndexByte(line, ' '); i >= 0 {
+        return strings.ToUpper(line[:i]), strings.TrimSpace(line[i+1:])
+    }
+    return strings.ToUpper(line), ""
+}
+
+// parsePath извлекает адрес из "FROM:<addr> [параметры]".
+func parsePath(arg, prefix string) (string, bool) {
+    if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+        return "", false
+    }
+    rest := strings.TrimSpace(arg[len(prefix):])
+    if !strings.HasPrefix(rest, "<") {
+        return "", false
+    }
+    end := strings.IndexByte(rest, '>')
+    if end < 0 {
+        return "", false
+    }
+    return rest[1:end], true
+}
+
+// String для логов.
+func (s *Server) String() string {
+    return fmt.Sprintf("smtp://%s", s.Addr)
+}
@@ -0,0 +1,549 @@
+package handlers
+
+import (
+    "encoding/json"
+    "net/http"
+    "sort"
+
+    "github.com/gin-gonic/gin"
+
+    "github.com/egor/ecochatserver/apispec"
+    "github.com/egor/ecochatserver/models"
+    "github.com/egor/ecochatserver/telegram"
+    websocketpkg "github.com/egor/ecochatserver/websocket"
+    "github.com/egor/ecochatserver/whatsapp"
+)
+
+// Описание HTTP API и WebSocket-протокола. Схемы строятся из типов,
+// которыми отвечают обработчики (payloads.go, models.*), поэтому при
+// изменении payload меняется и документ. Сгенерированные документы лежат
+// в docs/ и сверяются командой `go run ./cmd/specgen -check` и тестами
+// (api_spec_test.go, main_test.go).
+
+// APIVersion — версия документов API
+const APIVersion = "1.17.2"
+
+var specInfo = apispec.Info{
+    Title:       "EcoChat Server API",
+    Version:     APIVersion,
+    Description: "REST API сервера EcoChat. Ошибки возвращаются как {\"error\": \"...\"}.",
+}
+
+// Общие ответы
+var (
+    respBadRequest   = apispec.Response{Description: "Некорректный запрос", Body: errorResponse{}}
+    respUnauthorized = apispec.Response{Description: "Требуется авторизация", Body: errorResponse{}}
+    respForbidden    = apispec.Response{Description: "Доступ запрещён", Body: errorResponse{}}
+    // Чат другого клиента или, при CHAT_ACCESS_MODE=assigned, назначенный другому оператору
+    respChatForbidden = apispec.Response{Description: "Нет доступа к чату", Body: errorResponse{}}
+    respNotFound      = apispec.Response{Description: "Не найдено", Body: errorResponse{}}
+    respServerError   = apispec.Response{Description: "Внутренняя ошибка", Body: errorResponse{}}
+)
+
+var pageParamsSpec = []apispec.Param{
+    {Name: "page", In: "query", Description: "Номер страницы (с 1)", Example: 0},
+    {Name: "pageSize", In: "query", Description: "Размер страницы (1–100, по умолчанию 20)", Example: 0},
+}
+
+// freeForm — произвольный JSON-объект (ответы, собираемые в main.go через gin.H)
+var freeForm = map[string]any{}
+
+// apiOperations — все REST-маршруты, регистрируемые в setupAPIRoutes.
+func apiOperations() []apispec.Operation {
+    ops := []apispec.Operation{
+        {
+            Method: http.MethodGet, Path: "/api/health", Tag: "system",
+            Summary:   "Проверка работоспособности",
+            Responses: map[int]apispec.Response{200: {Body: freeForm}},
+        },
+        {
+            Method: http.MethodPost, Path: "/api/auth/login", Tag: "auth",
+            Summary: "Вход администратора, выдаёт JWT",
+            Request: loginRequest{},
+            Responses: map[int]apispec.Response{
+                200: {Body: loginResponse{}},
+                400: respBadRequest,
+                401: respUnauthorized,
+            },
+        },
+        {
+            Method: http.MethodPost, Path: "/api/telegram/webhook/:botId", Tag: "channels",
+            Summary:     "Вебхук Telegram Bot API",
+            Description: "Секрет сверяется с заголовком X-Telegram-Bot-Api-Secret-Token.",
+            Params:      []apispec.Param{{Name: "X-Telegram-Bot-Api-Secret-Token", In: "header", Required: true}},
+            Request:     telegram.Update{},
+            Responses:   channelWebhookResponses(),
+        },
+        {
+            Method: http.MethodGet, Path: "/api/whatsapp/webhook/:botId", Tag: "channels",
+            Summary: "Подтверждение подписки WhatsApp Cloud API",
+            Params: []apispec.Param{
+                {Name: "hub.mode", In: "query", Required: true},
+                {Name: "hub.verify_token", In: "query", Required: true},
+                {Name: "hub.challenge", In: "query", Required: true},
+            },
+            Responses: challengeResponses(),
+        },
+        {
+            Method: http.MethodPost, Path: "/api/whatsapp/webhook/:botId", Tag: "channels",
+            Summary:     "Вебхук WhatsApp Cloud API",
+            Description: "Подпись проверяется по заголовку X-Hub-Signature-256.",
+            Params:      []apispec.Param{{Name: "X-Hub-Signature-256", In: "header", Required: true}},
+            Request:     whatsapp.Notification{},
+            Responses:   channelWebhookResponses(),
+        },
+        {
+            Method: http.MethodGet, Path: "/api/channels/:source/webhook/:botId", Tag: "channels",
+            Summary:   "Подтверждение вебхука произвольного канала",
+            Responses: challengeResponses(),
+        },
+        {
+            Method: http.MethodPost, Path: "/api/channels/:source/webhook/:botId", Tag: "channels",
+            Summary:     "Вебхук произвольного зарегистрированного канала",
+            Description: "Формат тела определяется каналом (для email — исходное письмо message/rfc822).",
+            Request:     freeForm,
+            Responses:   channelWebhookResponses(),
+        },
+        {
+            Method: http.MethodPost, Path: "/api/webhook/incoming", Tag: "channels",
+            Summary:     "Входящее сообщение в собственном формате (виджет, ретрансляторы)",
+            Description: "Клиент определяется по X-API-Key, clientId из тела игнорируется. source — пусто или widget либо зарегистрированный канал (ретрансляторы старого формата, ответы оператора уходят в этот канал); неизвестный source — 400. Ответ автоответчика возвращается в bot_response.",
+            Params:      []apispec.Param{{Name: "X-API-Key", In: "header", Required: true, Description: "API ключ клиента"}},
+            Request:     models.IncomingMessage{},
+            Responses:   channelWebhookResponses(),
+        },
+        {
+            Method: http.MethodGet, Path: "/api/widget/chat/:id/messages", Tag: "widget",
+            Summary:     "Устарело: параметры WebSocket-подключения виджета",
+            Description: "Сообщения виджет получает командой getWidgetMessages.",
+            Params: []apispec.Param{
+                {Name: "X-Widget-User-ID", In: "header", Required: true},
+                {Name: "X-API-Key", In: "header", Required: true},
+            },
+            Responses: map[int]apispec.Response{200: {Body: widgetInfoResponse{}}, 400: respBadRequest},
+        },
+        {
+            Method: http.MethodGet, Path: "/api/widget/availability", Tag: "widget",
+            Summary:     "Есть ли операторы на связи (индикатор виджета)",
+            Description: "Учитываются операторы в статусе online.",
+            Params: []apispec.Param{
+                {Name: "chat_id", In: "query", Description: "ID чата виджета", Example: ""},
+                {Name: "client_id", In: "query", Description: "API-ключ или ID клиента, если чата ещё нет", Example: ""},
+            },
+            Responses: map[int]apispec.Response{
+                200: {Body: widgetAvailabilityResponse{}},
+                400: respBadRequest,
+                404: respNotFound,
+            },
+        },
+        {
+            Method: http.MethodGet, Path: "/api/widget/info", Tag: "widget",
+            Summary:   "Параметры WebSocket-подключения виджета",
+            Responses: map[int]apispec.Response{200: {Body: freeForm}},
+        },
+        {
+            Method: http.MethodGet, Path: "/api/admin/stats", Tag: "system", Auth: true,
+            Summary:   "Статистика WebSocket-хаба",
+            Responses: map[int]apispec.Response{200: {Body: freeForm}, 401: respUnauthorized},
+        },
+
+        // ─── Чаты (аналоги WebSocket-команд) ────────────────────────────────
+        {
+            Method: http.MethodGet, Path: "/api/v1/chats", Tag: "chats", Auth: true,
+            Summary: "Список чатов оператора (getChats)",
+            Params: append([]apispec.Param{
+                {Name: "status", In: "query", Description: "Фильтр по статусу: active, pending или closed", Example: ""},
+            }, pageParamsSpec...),
+            Responses: map[int]apispec.Response{
+                200: {Body: models.ChatPaginationResponse{}},
+                400: respBadRequest,
+                401: respUnauthorized,
+                500: respServerError,
+            },
+        },
+        {
+            Method: http.MethodGet, Path: "/api/v1/chats/:id", Tag: "chats", Auth: true,
+            Summary:     "Чат со страницей сообщений (getChatByID)",
+            Description: "Сообщения пользователя помечаются прочитанными.",
+            Params:      pageParamsSpec,
+            Responses: map[int]apispec.Response{
+                200: {Body: chatDetails{}},
+                400: respBadRequest,
+                401: respUnauthorized,
+                403: respChatForbidden,
+                404: respNotFound,
+            },
+        },
+        {
+            Method: http.MethodPost, Path: "/api/v1/chats/:id/messages", Tag: "chats", Auth: true,
+            Summary:     "Отправить сообщение оператора (sendMessage)",
+            Description: "Поле chatID тела игнорируется — чат берётся из пути.",
+            Request:     sendMessageRequest{},
+            Responses: map[int]apispec.Response{
+                201: {Body: sendMessageResult{}},
+                400: respBadRequest,
+                401: respUnauthorized,
+                403: respChatForbidden,
+                404: respNotFound,
+                409: {Description: "Повтор того же сообщения", Body: errorResponse{}},
+            },
+        },
+        {
+            Method: http.MethodPost, Path: "/api/v1/chats/:id/read", Tag: "chats", Auth: true,
+            Summary: "Пометить сообщения прочитанными (markAsRead)",
+            Responses: map[int]apispec.Response{
+                200: {Body: chatStatusResult{}},
+                401: respUnauthorized,
+                403: respChatForbidden,
+                404: respNotFound,
+            },
+        },
+        {
+            Method: http.MethodPost, Path: "/api/v1/chats/:id/claim", Tag: "chats", Auth: true,
+            Summary:     "Взять свободный чат (claimChat)",
+            Description: "Из одновременных запросов выигрывает один, остальные получают 409.",
+            Responses: map[int]apispec.Response{
+                200: {Body: chatAssignmentEvent{}},
+                401: respUnauthorized,
+                403: respChatForbidden,
+                404: respNotFound,
+                409: {Description: "Чат уже назначен", Body: errorResponse{}},
+            },
+        },
+        {
+            Method: http.MethodPost, Path: "/api/v1/chats/:id/transfer", Tag: "chats", Auth: true,
+            Summary:     "Передать чат другому оператору (transferChat)",
+            Description: "Передать может текущий исполнитель или администратор. Поле chatID тела игнорируется.",
+            Request:     transferChatRequest{},
+            Responses: map[int]apispec.Response{
+                200: {Body: chatAssignmentEvent{}},
+                400: respBadRequest,
+                401: respUnauthorized,
+                403: respChatForbidden,
+                404: {Description: "Чат или оператор не найден", Body: errorResponse{}},
+                409: {Description: "Назначение изменилось", Body: errorResponse{}},
+            },
+        },
+        {
+            Method: http.MethodPost, Path: "/api/v1/chats/:id/status", Tag: "chats", Auth: true,
+            Summary:     "Сменить статус чата (setChatStatus)",
+            Description: "closed — закрыть с причиной, pending — ждём ответа пользователя, active — вернуть в работу. Новое сообщение пользователя возвращает чат в active автоматически. Поле chatID тела игнорируется.",
+            Request:     chatStatusRequest{},
+            Responses: map[int]apispec.Response{
+                200: {Body: chatStatusEvent{}},
+                400: respBadRequest,
+                401: respUnauthorized,
+                403: respChatForbidden,
+                404: respNotFound,
+                409: {Description: "Статус изменился", Body: errorResponse{}},
+            },
+        },
+        {
+            Method: http.MethodPost, Path: "/api/v1/chats/:id/unassign", Tag: "chats", Auth: true,
+            Summary: "Вернуть чат в общую очередь (unassignChat)",
+            Responses: map[int]apispec.Response{
+                200: {Body: chatAssignmentEvent{}},
+                400: respBadRequest,
+                401: respUnauthorized,
+                403: respChatForbidden,
+                404: respNotFound,
+                409: {Description: "Назначение изменилось", Body: errorResponse{}},
+            },
+        },
+
+        // ─── Исходящие вебхуки ──────────────────────────────────────────────
+        {
+            Method: http.MethodGet, Path: "/api/webhooks", Tag: "webhooks", Auth: true,
+            Summary:   "Подписки клиента на исходящие вебхуки",
+            Responses: map[int]apispec.Response{200: {Body: webhookListResponse{}}, 401: respUnauthorized, 403: respForbidden},
+        },
+        {
+            Method: http.MethodPost, Path: "/api/webhooks", Tag: "webhooks", Auth: true,
+            Summary:     "Создать подписку",
+            Description: "Секрет подписи возвращается только в этом ответе. URL на внутренние адреса (localhost, RFC 1918, link-local) отклоняется с 400.",
+            Request:     webhookRequest{},
+            Responses: map[int]apispec.Response{
+                201: {Body: models.WebhookSubscription{}},
+                400: respBadRequest,
+                401: respUnauthorized,
+                403: respForbidden,
+            },
+        },
+        {
+            Method: http.MethodPut, Path: "/api/webhooks/:id", Tag: "webhooks", Auth: true,
+            Summary: "Изменить подписку",
+            Request: webhookRequest{},
+            Responses: map[int]apispec.Response{
+                200: {Body: models.WebhookSubscription{}},
+                400: respBadRequest,
+                404: respNotFound,
+            },
+        },
+        {
+            Method: http.MethodDelete, Path: "/api/webhooks/:id", Tag: "webhooks", Auth: true,
+            Summary:   "Удалить подписку вместе с журналом доставок",
+            Responses: map[int]apispec.Response{204: {Description: "Удалено"}, 404: respNotFound},
+        },
+        {
+            Method: http.MethodGet, Path: "/api/webhooks/:id/deliveries", Tag: "webhooks", Auth: true,
+            Summary: "Журнал доставок подписки",
+            Params: append([]apispec.Param{
+                {Name: "status", In: "query", Description: "pending | delivered | failed"},
+            }, pageParamsSpec...),
+            Responses: map[int]apispec.Response{200: {Body: webhookDeliveriesResponse{}}, 400: respBadRequest},
+        },
+        {
+            Method: http.MethodPost, Path: "/api/webhooks/:id/deliveries/:deliveryId/retry", Tag: "webhooks", Auth: true,
+            Summary:   "Повторить доставку",
+            Responses: map[int]apispec.Response{202: {Body: webhookRetryResponse{}}, 404: respNotFound},
+        },
+
+        // ─── Маршрутизация ──────────────────────────────────────────────────
+        {
+            Method: http.MethodGet, Path: "/api/routing", Tag: "routing", Auth: true,
+            Summary:   "Стратегия маршрутизации, операторы с нагрузкой и размер очереди",
+            Responses: map[int]apispec.Response{200: {Body: routingResponse{}}, 401: respUnauthorized},
+        },
+        {
+            Method: http.MethodPut, Path: "/api/routing", Tag: "routing", Auth: true,
+            Summary:     "Изменить стратегию маршрутизации клиента",
+            Description: "Только роль admin. Пустая стратегия — стратегия сервера по умолчанию (ROUTING_STRATEGY).",
+            Request:     routingStrategyRequest{},
+            Responses:   map[int]apispec.Response{200: {Body: routingResponse{}}, 400: respBadRequest, 401: respUnauthorized, 403: respForbidden},
+        },
+        {
+            Method: http.MethodPut, Path: "/api/routing/operators/:id", Tag: "routing", Auth: true,
+            Summary:     "Изменить навыки и лимит открытых чатов оператора",
+            Description: "Только роль admin. maxChats=0 — оператору не назначаются чаты автоматически.",
+            Request:     operatorRoutingRequest{},
+            Responses: map[int]apispec.Response{
+                200: {Body: routingResponse{}},
+                400: respBadRequest,
+                401: respUnauthorized,
+                403: respForbidden,
+                404: respNotFound,
+            },
+        },
+
+        // ─── Автозакрытие ───────────────────────────────────────────────────
+        {
+            Method: http.MethodGet, Path: "/api/settings/auto-close", Tag: "settings", Auth: true,
+            Summary:   "Порог автозакрытия неактивных чатов",
+            Responses: map[int]apispec.Response{200: {Body: autoCloseSettings{}}, 401: respUnauthorized},
+        },
+        {
+            Method: http.MethodPut, Path: "/api/settings/auto-close", Tag: "settings", Auth: true,
+            Summary:     "Изменить порог автозакрытия",
+            Description: "Только роль admin. defaultMinutes в теле игнорируется.",
+            Request:     autoCloseSettings{},
+            Responses:   map[int]apispec.Response{200: {Body: autoCloseSettings{}}, 400: respBadRequest, 401: respUnauthorized, 403: respForbidden},
+        },
+
+        // ─── Автоответчик ───────────────────────────────────────────────────
+        {
+            Method: http.MethodGet, Path: "/api/settings/autoresponder", Tag: "settings", Auth: true,
+            Summary:   "Настройки автоответчика клиента",
+            Responses: map[int]apispec.Response{200: {Body: autoResponderSettingsResponse{}}, 401: respUnauthorized, 404: respNotFound},
+        },
+        {
+            Method: http.MethodPut, Path: "/api/settings/autoresponder", Tag: "settings", Auth: true,
+            Summary:     "Изменить настройки автоответчика",
+            Description: "Только роль admin. Тело заменяет настройки целиком; незаданные поля берутся из настроек сервера.",
+            Request:     models.AutoResponderSettings{},
+            Responses:   map[int]apispec.Response{200: {Body: autoResponderSettingsResponse{}}, 400: respBadRequest, 401: respUnauthorized, 403: respForbidden, 404: respNotFound},
+        },
+
+        // ─── Документация и WebSocket ───────────────────────────────────────
+        {
+            Method: http.MethodGet, Path: "/api/docs", Tag: "system",
+            Summary:   "Ссылки на документы OpenAPI и AsyncAPI",
+            Responses: map[int]apispec.Response{200: {Body: docsIndex{}}},
+        },
+        {
+            Method: http.MethodGet, Path: "/api/docs/openapi.json", Tag: "system",
+            Summary:   "Этот документ (OpenAPI 3)",
+            Responses: map[int]apispec.Response{200: {Body: freeForm}},
+        },
+        {
+            Method: http.MethodGet, Path: "/api/docs/asyncapi.json", Tag: "system",
+            Summary:   "Протокол WebSocket (AsyncAPI 2)",
+            Responses: map[int]apispec.Response{200: {Body: freeForm}},
+        },
+        wsOperation("/ws"),
+        wsOperation("/api/ws"),
+        {
+            Method: http.MethodGet, Path: "/", Tag: "system",
+            Summary:   "Информация о сервисе",
+            Responses: map[int]apispec.Response{200: {Body: freeForm}},
+        },
+    }
+    return ops
+}
+
+func channelWebhookResponses() map[int]apispec.Response {
+    return map[int]apispec.Response{
+        200: {Body: webhookResponse{}},
+        400: respBadRequest,
+        401: {Description: "Неверная подпись или секрет", Body: errorResponse{}},
+        403: {Description: "Неизвестный клиент", Body: errorResponse{}},
+        404: {Description: "Бот или канал не найден", Body: errorResponse{}},
+    }
+}
+
+func challengeResponses() map[int]apispec.Response {
+    return map[int]apispec.Response{
+        200: {Description: "Значение challenge", Body: "", ContentType: "text/plain"},
+        403: {Description: "Неверный verify token", Body: errorResponse{}},
+        404: respNotFound,
+    }
+}
+
+func wsOperation(path string) apispec.Operation {
+    return apispec.Operation{
+        Method: http.MethodGet, Path: path, Tag: "websocket",
+        Summary:     "WebSocket-подключение (протокол описан в /api/docs/asyncapi.json)",
+        Description: "Оператор подключается с type=admin и JWT в token, виджет — с type=widget и chat_id.",
+        Params: []apispec.Param{
+            {Name: "type", In: "query", Description: "admin | widget"},
+            {Name: "token", In: "query", Description: "JWT оператора"},
+            {Name: "chat_id", In: "query", Description: "Чат виджета"},
+            {Name: "device", In: "query", Description: "Тип устройства сессии (по умолчанию определяется по User-Agent)"},
+            {Name: "session_id", In: "query", Description: "Возобновить сессию из сообщения session"},
+            {Name: "resume_from", In: "query", Description: "Последний полученный seq: события после него будут повторены"},
+            {Name: "since", In: "query", Description: "Время последнего события (RFC3339): если сессию не возобновить, придут missedMessages"},
+        },
+        Responses: map[int]apispec.Response{
+            101: {Description: "Switching Protocols"},
+            400: respBadRequest,
+            401: respUnauthorized,
+        },
+    }
+}
+
+// wsEnvelope — необязательные поля конверта WebSocket-сообщений
+var wsEnvelope = map[string]apispec.Schema{
+    apispec.Publish: {
+        "requestId": apispec.Schema{"type": "string", "description": "Произвольный ID команды: возвращается в ответе и ошибке"},
+    },
+    apispec.Subscribe: {
+        "requestId": apispec.Schema{"type": "string", "description": "requestId команды, на которую это ответ"},
+        "status":    apispec.Schema{"type": "string", "enum": []string{websocketpkg.StatusOK, websocketpkg.StatusError}, "description": "Есть только у ответов на команды"},
+        "seq":       apispec.Schema{"type": "integer", "description": "Номер события сессии (только у событий)"},
+    },
+}
+
+// wsMessages — все типы сообщений WebSocket-протокола.
+func wsMessages() []apispec.Message {
+    return []apispec.Message{
+        // Команды клиента
+        {Name: "getChats", Direction: apispec.Publish, Clients: "admin", Summary: "Список чатов", Payload: pageRequest{}},
+        {Name: "getChatByID", Direction: apispec.Publish, Clients: "admin", Summary: "Чат со страницей сообщений", Payload: chatPageRequest{}},
+        {Name: "sendMessage", Direction: apispec.Publish, Clients: "admin, widget", Summary: "Отправить сообщение", Payload: sendMessageRequest{}},
+        {Name: "markAsRead", Direction: apispec.Publish, Clients: "admin, widget", Summary: "Пометить сообщения прочитанными", Payload: chatRequest{}},
+        {Name: "typing", Direction: apispec.Publish, Clients: "admin, widget", Summary: "Индикатор набора текста", Payload: typingRequest{}},
+        {Name: "getWidgetMessages", Direction: apispec.Publish, Clients: "widget", Summary: "Сообщения чата виджета", Payload: chatPageRequest{}},
+        {Name: "claimChat", Direction: apispec.Publish, Clients: "admin", Summary: "Взять свободный чат", Payload: chatRequest{}},
+        {Name: "transferChat", Direction: apispec.Publish, Clients: "admin", Summary: "Передать чат другому оператору", Payload: transferChatRequest{}},
+        {Name: "setChatStatus", Direction: apispec.Publish, Clients: "admin", Summary: "Сменить статус чата", Payload: chatStatusRequest{}},
+        {Name: "setPresence", Direction: apispec.Publish, Clients: "admin", Summary: "Выставить свой статус присутствия", Payload: presenceRequest{}},
+        {Name: "activity", Direction: apispec.Publish, Clients: "admin", Summary: "Отметка активности оператора (сбрасывает таймер автоматического away)", Payload: struct{}{}},
+        {Name: "unassignChat", Direction: apispec.Publish, Clients: "admin", Summary: "Вернуть чат в общую очередь", Payload: chatRequest{}},
+        {Name: "subscribe", Direction: apispec.Publish, Clients: "admin", Summary: "Подписаться на события чатов своего клиента (all — на все; по умолчанию админ подписан на все, если ему доступны все чаты клиента)", Payload: subscriptionRequest{}},
+        {Name: "unsubscribe", Direction: apispec.Publish, Clients: "admin", Summary: "Отписаться от событий чатов (all — от «всех чатов», отдельные подписки остаются)", Payload: subscriptionRequest{}},
+        {Name: "ack", Direction: apispec.Publish, Clients: "admin, widget", Summary: "Подтвердить получение событий до seq включительно", Payload: ackRequest{}},
+
+        // Ответы на команды
+        {Name: "chatsList", Direction: apispec.Subscribe, Clients: "admin", Summary: "Ответ на getChats", Payload: models.ChatPaginationResponse{}},
+        {Name: "chatDetails", Direction: apispec.Subscribe, Clients: "admin", Summary: "Ответ на getChatByID", Payload: chatDetails{}},
+        {Name: "messageSent", Direction: apispec.Subscribe, Clients: "admin, widget", Summary: "Ответ на sendMessage", Payload: sendMessageResult{}},
+        {Name: "messageDuplicate", Direction: apispec.Subscribe, Clients: "admin, widget", Summary: "sendMessage отклонён как повтор", Payload: chatStatusResult{}},
+        {Name: "markAsReadConfirmed", Direction: apispec.Subscribe, Clients: "admin, widget", Summary: "Ответ на markAsRead", Payload: chatStatusResult{}},
+        {Name: "widgetMessages", Direction: apispec.Subscribe, Clients: "widget", Summary: "Ответ на getWidgetMessages", Payload: widgetMessagesResult{}},
+        {Name: "error", Direction: apispec.Subscribe, Clients: "admin, widget", Summary: "Ошибка команды", Payload: websocketpkg.ErrorPayload{}},
+
+        // События
+        {Name: "chat_update", Direction: apispec.Subscribe, Clients: "admin, widget", Summary: "Новое сообщение в чате и автоответ", Payload: chatUpdateEvent{}},
+        {Name: "bot_delta", Direction: apispec.Subscribe, Clients: "admin, widget", Summary: "Фрагмент автоответа по мере генерации; итог приходит в chat_update с тем же metadata.streamId", Payload: botDeltaEvent{}},
+        {Name: "messagesRead", Direction: apispec.Subscribe, Clients: "admin, widget", Summary: "Сообщения чата прочитаны", Payload: messagesReadEvent{}},
+        {Name: "chatAssigned", Direction: apispec.Subscribe, Clients: "admin", Summary: "Назначение чата изменилось (ответ на claimChat/transferChat/unassignChat, уведомление затронутым операторам и автоматическое назначение)", Payload: chatAssignmentEvent{}},
+        {Name: "chatStatusChanged", Direction: apispec.Subscribe, Clients: "admin, widget", Summary: "Статус чата изменился (ответ на setChatStatus, повторное обращение пользователя, автозакрытие)", Payload: chatStatusEvent{}},
+        {Name: "presence", Direction: apispec.Subscribe, Clients: "admin", Summary: "Статус присутствия оператора своего клиента (в том числе ответ на setPresence)", Payload: presenceEvent{}},
+        {Name: "deliveryStatus", Direction: apispec.Subscribe, Clients: "admin", Summary: "Статус доставки исходящего сообщения во внешний канал", Payload: deliveryStatusEvent{}},
+        {Name: "typing", Direction: apispec.Subscribe, Clients: "admin, widget", Summary: "Собеседник печатает", Payload: websocketpkg.TypingPayload{}},
+        {Name: "subscriptions", Direction: apispec.Subscribe, Clients: "admin", Summary: "Ответ на subscribe/unsubscribe: текущие подписки", Payload: subscriptionsResult{}},
+        {Name: "session", Direction: apispec.Subscribe, Clients: "admin, widget", Summary: "Первое сообщение соединения: ID сессии и результат возобновления. События хаба несут seq", Payload: websocketpkg.SessionPayload{}},
+        {Name: "missedMessages", Direction: apispec.Subscribe, Clients: "admin, widget", Summary: "Сообщения после since, если сессию не удалось возобновить из буфера", Payload: missedMessagesEvent{}},
+        {Name: "connection_status", Direction: apispec.Subscribe, Clients: "admin, widget", Summary: "Подключение виджета", Payload: websocketpkg.ConnectionStatusPayload{}},
+    }
+}
+
+// OpenAPISpec возвращает документ OpenAPI 3 в JSON.
+func OpenAPISpec() ([]byte, error) {
+    return json.MarshalIndent(apispec.OpenAPI(specInfo, apiOperations()), "", "  ")
+}
+
+// AsyncAPISpec возвращает документ AsyncAPI WebSocket-протокола в JSON.
+func AsyncAPISpec() ([]byte, error) {
+    info := specInfo
+    info.Title = "EcoChat WebSocket API"
+    info.Description = "Все сообщения — JSON вида {\"type\": \"...\", \"payload\": {...}}."
+    return json.MarshalIndent(apispec.AsyncAPI(info, "/ws", "Основной канал операторов и виджетов", wsMessages(), wsEnvelope), "", "  ")
+}
+
+// docsIndex — ответ GET /api/docs
+type docsIndex struct {
+    OpenAPI  string `json:"openapi"`
+    AsyncAPI string `json:"asyncapi"`
+    Version  string `json:"version"`
+}
+
+// APIDocs отдаёт ссылки на документы.
+func APIDocs(c *gin.Context) {
+    c.JSON(http.StatusOK, docsIndex{
+        OpenAPI:  "/api/docs/openapi.json",
+        AsyncAPI: "/api/docs/asyncapi.json",
+        Version:  APIVersion,
+    })
+}
+
+// OpenAPIDocs отдаёт документ OpenAPI.
+func OpenAPIDocs(c *gin.Context) {
+    serveSpec(c, OpenAPISpec)
+}
+
+// AsyncAPIDocs отдаёт документ AsyncAPI.
+func AsyncAPIDocs(c *gin.Context) {
+    serveSpec(c, AsyncAPISpec)
+}
+
+func serveSpec(c *gin.Context, build func() ([]byte, error)) {
+    doc, err := build()
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, errorResponse{Error: err.Error()})
+        return
+    }
+    c.Data(http.StatusOK, "application/json; charset=utf-8", doc)
+}
+
+// UndocumentedRoutes сверяет зарегистрированные маршруты с описанием API и
+// возвращает расхождения в обе стороны ("+" — нет в документе, "-" — нет в роутере).
+func UndocumentedRoutes(routes gin.RoutesInfo) []string {
+    documented := map[string]bool{}
+    for _, op := range apiOperations() {
+        documented[op.Key()] = true
+    }
+
+    var diff []string
+    for _, r := range routes {
+        key := r.Method + " " + r.Path
+        if !documented[key] {
+            diff = append(diff, "+ "+key)
+        }
+        delete(documented, key)
+    }
+    for key := range documented {
+        diff = append(diff, "- "+key)
+    }
+    sort.Strings(diff)
+    return diff
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+    "bytes"
+    "os"
+    "path/filepath"
+    "reflect"
+    "testing"
+
+    "github.com/gin-gonic/gin"
+)
+
+// Сохранённые документы в docs/ должны совпадать с генерируемыми:
+// изменение payload-типа без `go run ./cmd/specgen` ломает тест.
+func TestSpecsUpToDate(t *testing.T) {
+    specs := []struct {
+        file  string
+        build func() ([]byte, error)
+    }{
+        {"openapi.json", OpenAPISpec},
+        {"asyncapi.json", AsyncAPISpec},
+    }
+    for _, s := range specs {
+        t.Run(s.file, func(t *testing.T) {
+            doc, err := s.build()
+            if err != nil {
+                t.Fatalf("генерация: %v", err)
+            }
+            saved, err := os.ReadFile(filepath.Join("..", "docs", s.file))
+            if err != nil {
+                t.Fatalf("чтение: %v", err)
+            }
+            if !bytes.Equal(saved, append(doc, '\n')) {
+                t.Errorf("docs/%s устарел: запустите go run ./cmd/specgen", s.file)
+            }
+        })
+    }
+}
+
+// Каждая описанная операция находится в роутере, построенном по описанию.
+// Полный роутер сервера сверяется в main_test.go.
+func TestUndocumentedRoutes(t *testing.T) {
+    r := gin.New()
+    for _, op := range apiOperations() {
+        r.Handle(op.Method, op.Path, func(*gin.Context) {})
+    }
+    if diff := UndocumentedRoutes(r.Routes()); len(diff) > 0 {
+        t.Fatalf("маршруты расходятся с описанием API: %v", diff)
+    }
+
+    r.GET("/api/undocumented", func(*gin.Context) {})
+    want := []string{"+ GET /api/undocumented"}
+    if diff := UndocumentedRoutes(r.Routes()); !reflect.DeepEqual(diff, want) {
+        t.Fatalf("diff = %v, want %v", diff, want)
+    }
+}
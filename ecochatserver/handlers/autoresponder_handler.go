@@ -0,0 +1,128 @@
+package handlers
+
+import (
+    "errors"
+    "log"
+    "net/http"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+
+    "github.com/egor/ecochatserver/database"
+    "github.com/egor/ecochatserver/llm"
+    "github.com/egor/ecochatserver/models"
+)
+
+// Ограничения настроек автоответчика клиента
+const (
+    maxSystemPromptLen    = 20000
+    maxBotNameLen         = 100
+    maxAutoResponseDelay  = 60
+    maxAutoResponseTokens = 8000
+    minHistoryTokens      = 500
+    maxHistoryTokens      = 100000
+)
+
+// GetAutoResponderSettings возвращает настройки автоответчика клиента
+// и действующий с ними конфиг.
+func GetAutoResponderSettings(c *gin.Context) {
+    clientID, ok := requestClientID(c)
+    if !ok {
+        return
+    }
+
+    settings, err := database.GetAutoResponderSettings(clientID)
+    if errors.Is(err, database.ErrClientNotFound) {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Клиент не найден"})
+        return
+    }
+    if err != nil {
+        log.Printf("GetAutoResponderSettings: %v", err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения настроек автоответчика"})
+        return
+    }
+
+    resp := autoResponderSettingsResponse{
+        Settings:      settings,
+        ServerEnabled: AutoResponder != nil,
+    }
+    if AutoResponder != nil {
+        resp.Effective = AutoResponder.ConfigFor(clientID)
+        resp.Providers = AutoResponder.Providers()
+    } else {
+        resp.Effective = llm.GetDefaultConfig().Apply(settings)
+    }
+    c.JSON(http.StatusOK, resp)
+}
+
+// UpdateAutoResponderSettings заменяет настройки автоответчика клиента.
+// Незаданные поля берутся из настроек сервера.
+func UpdateAutoResponderSettings(c *gin.Context) {
+    clientID, ok := requestClientID(c)
+    if !ok {
+        return
+    }
+
+    var req models.AutoResponderSettings
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    if msg := validateAutoResponderSettings(&req); msg != "" {
+        c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+        return
+    }
+
+    err := database.SetAutoResponderSettings(clientID, &req)
+    if errors.Is(err, database.ErrClientNotFound) {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Клиент не найден"})
+        return
+    }
+    if err != nil {
+        log.Printf("UpdateAutoResponderSettings: %v", err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка сохранения настроек автоответчика"})
+        return
+    }
+    if AutoResponder != nil {
+        AutoResponder.InvalidateConfig(clientID)
+    }
+    log.Printf("UpdateAutoResponderSettings: клиент %s обновил настройки автоответчика", clientID)
+
+    GetAutoResponderSettings(c)
+}
+
+// validateAutoResponderSettings возвращает текст ошибки или "".
+func validateAutoResponderSettings(s *models.AutoResponderSettings) string {
+    switch {
+    case s.BotName != nil && (strings.TrimSpace(*s.BotName) == "" || len([]rune(*s.BotName)) > maxBotNameLen):
+        return "botName должен быть непустым и не длиннее 100 символов"
+    case s.SystemPrompt != nil && (strings.TrimSpace(*s.SystemPrompt) == "" || len([]rune(*s.SystemPrompt)) > maxSystemPromptLen):
+        return "systemPrompt должен быть непустым и не длиннее 20000 символов"
+    case s.DelaySeconds != nil && (*s.DelaySeconds < 0 || *s.DelaySeconds > maxAutoResponseDelay):
+        return "delaySeconds должен быть от 0 до 60"
+    case s.Provider != nil && !knownProvider(*s.Provider):
+        return "Неизвестный провайдер LLM: " + *s.Provider
+    case s.Model != nil && strings.TrimSpace(*s.Model) == "":
+        return "model не может быть пустым"
+    case s.Temperature != nil && (*s.Temperature < 0 || *s.Temperature > 2):
+        return "temperature должна быть от 0 до 2"
+    case s.MaxTokens != nil && (*s.MaxTokens < 1 || *s.MaxTokens > maxAutoResponseTokens):
+        return "maxTokens должен быть от 1 до 8000"
+    case s.HistoryTokens != nil && (*s.HistoryTokens < minHistoryTokens || *s.HistoryTokens > maxHistoryTokens):
+        return "historyTokens должен быть от 500 до 100000"
+    }
+    return ""
+}
+
+// knownProvider сообщает, настроен ли на сервере провайдер с таким именем.
+func knownProvider(name string) bool {
+    if AutoResponder == nil {
+        return false
+    }
+    for _, p := range AutoResponder.Providers() {
+        if p == name {
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,285 @@
+package handlers
+
+import (
+    "context"
+    "errors"
+    "io"
+    "log"
+    "net/http"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+
+    "github.com/egor/ecochatserver/channels"
+    "github.com/egor/ecochatserver/database"
+    "github.com/egor/ecochatserver/models"
+)
+
+// maxWebhookBody — ограничение размера тела входящего вебхука
+const maxWebhookBody = 1 << 20
+
+// ChannelWebhook возвращает обработчик вебхука для источника source.
+// Пустой source означает, что имя берётся из параметра маршрута :source.
+// Если в маршруте есть :botId, учётные данные бота загружаются из client_bots.
+func ChannelWebhook(source string) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        name := source
+        if name == "" {
+            name = c.Param("source")
+        }
+        log.Printf("ChannelWebhook[%s]: %s %s from %s", name, c.Request.Method, c.FullPath(), c.ClientIP())
+
+        ch, ok := channels.Get(name)
+        if !ok {
+            c.JSON(http.StatusNotFound, gin.H{"error": "Неизвестный источник: " + name})
+            return
+        }
+
+        var bot *models.Bot
+        if botID := c.Param("botId"); botID != "" {
+            var err error
+            bot, err = database.FindBot(name, botID)
+            if errors.Is(err, database.ErrBotNotFound) {
+                log.Printf("ChannelWebhook[%s]: неизвестный бот %s", name, botID)
+                c.JSON(http.StatusNotFound, gin.H{"error": "Бот не найден"})
+                return
+            }
+            if err != nil {
+                log.Printf("ChannelWebhook[%s]: ошибка поиска бота %s: %v", name, botID, err)
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка поиска бота"})
+                return
+            }
+        }
+
+        serveChannelWebhook(c, ch, bot)
+    }
+}
+
+// serveChannelWebhook проверяет, разбирает и принимает вебхук канала ch.
+// bot — учётные данные отправителя (nil, если их нет).
+func serveChannelWebhook(c *gin.Context, ch channels.Channel, bot *models.Bot) {
+    name := ch.Name()
+
+    body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBody))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Ошибка чтения тела запроса"})
+        return
+    }
+
+    if err := ch.VerifyWebhook(c.Request, body, bot); err != nil {
+        log.Printf("ChannelWebhook[%s]: проверка вебхука не пройдена: %v", name, err)
+        status := http.StatusBadRequest
+        if errors.Is(err, channels.ErrUnauthorized) {
+            status = http.StatusUnauthorized
+        }
+        c.JSON(status, gin.H{"error": err.Error()})
+        return
+    }
+
+    inbound, err := ch.ParseInbound(body, bot)
+    if err != nil {
+        log.Printf("ChannelWebhook[%s]: ошибка разбора: %v", name, err)
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    if inbound.Rejected > 0 {
+        log.Printf("ChannelWebhook[%s]: отброшено %d событий, не относящихся к боту %s", name, inbound.Rejected, bot.BotID)
+    }
+    if len(inbound.Statuses) > 0 {
+        applyStatusUpdates(name, bot, inbound.Statuses)
+    }
+
+    // Источники повторяют доставку при не-2xx ответе, поэтому пустые
+    // и повторные обновления подтверждаем статусом 200.
+    if len(inbound.Messages) == 0 {
+        status := "ignored"
+        if len(inbound.Statuses) > 0 {
+            status = "status processed"
+        }
+        c.JSON(http.StatusOK, webhookResponse{Status: status})
+        return
+    }
+
+    inline := ch.Capabilities().InlineReply
+    response := webhookResponse{Status: "message processed"}
+    processed := 0
+
+    for i := range inbound.Messages {
+        chat, userMsg, err := acceptInbound(ch, bot, &inbound.Messages[i])
+        if errors.Is(err, errDuplicateInbound) {
+            continue
+        }
+        if errors.Is(err, database.ErrClientNotFound) {
+            c.JSON(http.StatusForbidden, gin.H{"error": "Неизвестный клиент"})
+            return
+        }
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        processed++
+
+        response.MessageID = userMsg.ID.String()
+        response.ChatID = chat.ID.String()
+
+        if inline {
+            // Автоответ генерируем синхронно: отправитель забирает его из ответа
+            botMsg := generateAutoResponse(c.Request.Context(), chat, userMsg)
+            notifyChatUpdate(chat, userMsg, botMsg)
+            if botMsg != nil {
+                response.BotResponse = botMsg.Content
+                response.BotMessageID = botMsg.ID.String()
+            }
+            continue
+        }
+
+        respondAsync(chat, userMsg)
+    }
+
+    if processed == 0 {
+        c.JSON(http.StatusOK, webhookResponse{
+            Status:  "duplicate_ignored",
+            Message: "Сообщение уже обработано",
+        })
+        return
+    }
+
+    response.Timestamp = time.Now().Format(time.RFC3339)
+    c.JSON(http.StatusOK, response)
+}
+
+// errDuplicateInbound — сообщение уже принималось (повторная доставка источником)
+var errDuplicateInbound = errors.New("повторное входящее сообщение")
+
+// acceptInbound дедуплицирует и сохраняет одно входящее сообщение канала,
+// затем подтверждает его источнику, если канал это требует.
+func acceptInbound(ch channels.Channel, bot *models.Bot, item *channels.InboundMessage) (*models.Chat, *models.Message, error) {
+    if item.DedupKey != "" {
+        if isRecentMessage(item.DedupKey) {
+            log.Printf("acceptInbound[%s]: дублирующее сообщение пропущено (%s)", ch.Name(), item.DedupKey)
+            return nil, nil, errDuplicateInbound
+        }
+        registerMessage(item.DedupKey)
+    }
+
+    chat, userMsg, err := ingestIncoming(&item.Message)
+    if err != nil {
+        // Повторная доставка после ошибки должна пройти
+        if item.DedupKey != "" {
+            recentMessages.Delete(item.DedupKey)
+        }
+        return nil, nil, err
+    }
+
+    if ack, ok := ch.(channels.Acknowledger); ok {
+        go func(in models.IncomingMessage) {
+            ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+            defer cancel()
+            if err := ack.Acknowledge(ctx, bot, &in); err != nil {
+                log.Printf("acceptInbound[%s]: ошибка подтверждения: %v", ch.Name(), err)
+            }
+        }(item.Message)
+    }
+
+    return chat, userMsg, nil
+}
+
+// ChannelChallenge возвращает обработчик GET-подтверждения вебхука
+// для каналов, реализующих channels.Challenger.
+func ChannelChallenge(source string) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        name := source
+        if name == "" {
+            name = c.Param("source")
+        }
+
+        ch, ok := channels.Get(name)
+        if !ok {
+            c.JSON(http.StatusNotFound, gin.H{"error": "Неизвестный источник: " + name})
+            return
+        }
+        challenger, ok := ch.(channels.Challenger)
+        if !ok {
+            c.JSON(http.StatusMethodNotAllowed, gin.H{"error": "Канал не поддерживает подтверждение вебхука"})
+            return
+        }
+
+        bot, err := database.FindBot(name, c.Param("botId"))
+        if err != nil {
+            log.Printf("ChannelChallenge[%s]: бот %s не найден: %v", name, c.Param("botId"), err)
+            c.JSON(http.StatusNotFound, gin.H{"error": "Бот не найден"})
+            return
+        }
+
+        challenge, err := challenger.Challenge(c.Request, bot)
+        if err != nil {
+            log.Printf("ChannelChallenge[%s]: подтверждение отклонено: %v", name, err)
+            c.JSON(http.StatusForbidden, gin.H{"error": "Неверный verify token"})
+            return
+        }
+
+        log.Printf("ChannelChallenge[%s]: вебхук бота %s подтверждён", name, bot.BotID)
+        c.String(http.StatusOK, challenge)
+    }
+}
+
+// applyStatusUpdates записывает статусы доставки от источника в metadata
+// исходящих сообщений и уведомляет клиентов чата. Обновляются только
+// сообщения чатов бота, подтвердившего вебхук.
+func applyStatusUpdates(source string, bot *models.Bot, updates []channels.StatusUpdate) {
+    if bot == nil {
+        log.Printf("applyStatusUpdates[%s]: статусы без учётных данных бота пропущены", source)
+        return
+    }
+    for _, st := range updates {
+        delivery := map[string]any{
+            "status":    st.Status,
+            "updatedAt": time.Now().Format(time.RFC3339),
+        }
+        if st.Error != "" {
+            delivery["error"] = st.Error
+        }
+
+        messageID, chatID, err := database.UpdateDeliveryByExternalID(source, bot.ClientID, bot.BotID, st.ExternalID, delivery)
+        if err != nil {
+            log.Printf("applyStatusUpdates[%s]: ошибка обновления статуса %s: %v", source, st.ExternalID, err)
+            continue
+        }
+        if messageID == uuid.Nil {
+            log.Printf("applyStatusUpdates[%s]: сообщение %s не найдено или статус не изменён", source, st.ExternalID)
+            continue
+        }
+
+        notifyDeliveryStatus(chatID, messageID, delivery)
+    }
+}
+
+// respondAsync генерирует автоответ в фоне и доставляет его через канал чата,
+// чтобы не держать запрос источника на время генерации.
+func respondAsync(chat *models.Chat, userMsg *models.Message) {
+    if AutoResponder == nil {
+        notifyChatUpdate(chat, userMsg, nil)
+        return
+    }
+
+    go func() {
+        ctx, cancel := context.WithTimeout(context.Background(), outboundTimeout)
+        defer cancel()
+
+        botMsg := generateAutoResponse(ctx, chat, userMsg)
+        notifyChatUpdate(chat, userMsg, botMsg)
+        if botMsg != nil {
+            deliverOutbound(chat.ID, botMsg)
+        }
+    }()
+}
+
+// notifyChatUpdate отправляет ОДНО комплексное WebSocket уведомление по чату.
+func notifyChatUpdate(chat *models.Chat, userMsg, botMsg *models.Message) {
+    notification := createChatNotification(chat.ID, userMsg, botMsg)
+    sendToChat(chat, notification)
+
+    emitMessagesCreated(chat, userMsg, botMsg)
+}
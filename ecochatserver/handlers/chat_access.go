@@ -0,0 +1,122 @@
+package handlers
+
+import (
+    "database/sql"
+    "errors"
+    "log"
+
+    "github.com/google/uuid"
+
+    "github.com/egor/ecochatserver/database"
+    "github.com/egor/ecochatserver/models"
+)
+
+// Единая проверка доступа к чатам. Все команды над конкретным чатом (WS и
+// REST) получают его через authorizeChat, поэтому правила ниже действуют
+// одинаково для обоих транспортов.
+
+// Режимы доступа операторов к чатам своего клиента (CHAT_ACCESS_MODE)
+const (
+    ChatAccessClient   = "client"   // все чаты клиента
+    ChatAccessAssigned = "assigned" // свои и свободные чаты, кроме ролей ChatAccessFullRoles
+)
+
+// ChatAccessMode — текущий режим; задаётся в main до приёма соединений.
+var ChatAccessMode = ChatAccessClient
+
+// ChatAccessFullRoles — роли, которым в режиме assigned доступны все чаты клиента.
+var ChatAccessFullRoles = []string{"admin"}
+
+// seesAllChats сообщает, доступны ли оператору все чаты его клиента.
+func seesAllChats(a *actor) bool {
+    if a.Kind != actorAdmin {
+        return false
+    }
+    if ChatAccessMode != ChatAccessAssigned {
+        return true
+    }
+    for _, role := range ChatAccessFullRoles {
+        if a.Role == role {
+            return true
+        }
+    }
+    return false
+}
+
+// canAccessChat проверяет доступ участника к загруженному чату:
+// виджет — только свой чат, оператор — чаты своего клиента, а в режиме
+// assigned без полной роли — только назначенные ему и свободные.
+func canAccessChat(a *actor, chat *models.Chat) error {
+    switch a.Kind {
+    case actorWidget:
+        if chat.ID != a.ChatID {
+            return errChatAccessDenied
+        }
+        return nil
+    case actorAdmin:
+        if chat.ClientID != a.ClientID {
+            return errChatAccessDenied
+        }
+        if !seesAllChats(a) && chat.AssignedTo != nil && *chat.AssignedTo != a.ID {
+            return errNotAssignee
+        }
+        return nil
+    }
+    return errChatAccessDenied
+}
+
+// authorizeChat загружает чат и проверяет доступ к нему (см. canAccessChat).
+func authorizeChat(a *actor, chatID uuid.UUID) (*models.Chat, error) {
+    // Чужой чат виджета отклоняем без запроса к БД
+    if a.Kind == actorWidget && a.ChatID != chatID {
+        return nil, errChatAccessDenied
+    }
+
+    chat, err := database.GetChatLightweight(chatID)
+    if errors.Is(err, sql.ErrNoRows) {
+        return nil, errChatNotFound
+    }
+    if err != nil {
+        return nil, errDB("Ошибка получения чата", err)
+    }
+    if err := canAccessChat(a, chat); err != nil {
+        return nil, err
+    }
+    return chat, nil
+}
+
+// visibleAssignee возвращает оператора, чьими чатами (и свободными)
+// ограничена выборка, или nil, если участнику доступны все чаты клиента.
+func visibleAssignee(a *actor) *uuid.UUID {
+    if a.Kind != actorAdmin || seesAllChats(a) {
+        return nil
+    }
+    return &a.ID
+}
+
+// revokeChatAccess отписывает прежнего исполнителя от событий чата,
+// переданного другому оператору, если в режиме assigned чат ему больше
+// не доступен. Роль прежнего исполнителя не найдена — отписываем.
+func revokeChatAccess(chat *models.Chat, prev *uuid.UUID) {
+    if ChatAccessMode != ChatAccessAssigned || prev == nil {
+        return
+    }
+    if chat.AssignedTo != nil && *chat.AssignedTo == *prev {
+        return
+    }
+
+    ops, err := database.ListClientOperators(chat.ClientID)
+    if err != nil {
+        log.Printf("revokeChatAccess: ошибка загрузки операторов клиента %s: %v", chat.ClientID, err)
+    }
+    for _, op := range ops {
+        if op.ID == *prev {
+            former := &actor{Kind: actorAdmin, ID: op.ID, ClientID: op.ClientID, Role: op.Role}
+            if canAccessChat(former, chat) == nil {
+                return
+            }
+            break
+        }
+    }
+    WebSocketHub.UnsubscribeAdmin(prev.String(), chat.ID.String())
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+    "errors"
+    "testing"
+
+    "github.com/google/uuid"
+
+    "github.com/egor/ecochatserver/models"
+)
+
+// withAccessMode задаёт CHAT_ACCESS_MODE на время теста.
+func withAccessMode(t *testing.T, mode string) {
+    t.Helper()
+    prevMode, prevRoles := ChatAccessMode, ChatAccessFullRoles
+    ChatAccessMode, ChatAccessFullRoles = mode, []string{"admin"}
+    t.Cleanup(func() { ChatAccessMode, ChatAccessFullRoles = prevMode, prevRoles })
+}
+
+func TestChatAccess(t *testing.T) {
+    clientID, foreignClientID := uuid.New(), uuid.New()
+    operatorID, otherOperatorID := uuid.New(), uuid.New()
+    chatID := uuid.New()
+
+    chat := func(client uuid.UUID, assignee *uuid.UUID) *models.Chat {
+        return &models.Chat{ID: chatID, ClientID: client, AssignedTo: assignee}
+    }
+    admin := func(role string) *actor {
+        return &actor{Kind: actorAdmin, ID: operatorID, ClientID: clientID, Role: role}
+    }
+    widget := func(chat uuid.UUID) *actor {
+        return &actor{Kind: actorWidget, ID: uuid.New(), ChatID: chat}
+    }
+
+    tests := []struct {
+        name     string
+        mode     string
+        actor    *actor
+        chat     *models.Chat
+        wantErr  error
+        seesAll  bool
+        assignee bool // visibleAssignee ограничивает выборку оператором
+    }{
+        // Виджет: только свой чат, в любом режиме
+        {name: "widget own chat", mode: ChatAccessClient, actor: widget(chatID), chat: chat(clientID, nil)},
+        {name: "widget foreign chat", mode: ChatAccessClient, actor: widget(uuid.New()), chat: chat(clientID, nil), wantErr: errChatAccessDenied},
+        {name: "widget own chat assigned mode", mode: ChatAccessAssigned, actor: widget(chatID), chat: chat(clientID, &otherOperatorID)},
+        {name: "unknown actor kind", mode: ChatAccessClient, actor: &actor{Kind: "bot", ClientID: clientID}, chat: chat(clientID, nil), wantErr: errChatAccessDenied},
+
+        // Режим client: все чаты своего клиента для любой роли
+        {name: "client mode operator unassigned", mode: ChatAccessClient, actor: admin("operator"), chat: chat(clientID, nil), seesAll: true},
+        {name: "client mode operator foreign assignee", mode: ChatAccessClient, actor: admin("operator"), chat: chat(clientID, &otherOperatorID), seesAll: true},
+        {name: "client mode admin", mode: ChatAccessClient, actor: admin("admin"), chat: chat(clientID, &otherOperatorID), seesAll: true},
+        {name: "client mode foreign client", mode: ChatAccessClient, actor: admin("admin"), chat: chat(foreignClientID, nil), wantErr: errChatAccessDenied, seesAll: true},
+
+        // Режим assigned: оператор — свои и свободные, полная роль — все
+        {name: "assigned mode operator unassigned", mode: ChatAccessAssigned, actor: admin("operator"), chat: chat(clientID, nil), assignee: true},
+        {name: "assigned mode operator own", mode: ChatAccessAssigned, actor: admin("operator"), chat: chat(clientID, &operatorID), assignee: true},
+        {name: "assigned mode operator foreign assignee", mode: ChatAccessAssigned, actor: admin("operator"), chat: chat(clientID, &otherOperatorID), wantErr: errNotAssignee, assignee: true},
+        {name: "assigned mode operator foreign client", mode: ChatAccessAssigned, actor: admin("operator"), chat: chat(foreignClientID, nil), wantErr: errChatAccessDenied, assignee: true},
+        {name: "assigned mode empty role", mode: ChatAccessAssigned, actor: admin(""), chat: chat(clientID, &otherOperatorID), wantErr: errNotAssignee, assignee: true},
+        {name: "assigned mode admin foreign assignee", mode: ChatAccessAssigned, actor: admin("admin"), chat: chat(clientID, &otherOperatorID), seesAll: true},
+        {name: "assigned mode admin foreign client", mode: ChatAccessAssigned, actor: admin("admin"), chat: chat(foreignClientID, &operatorID), wantErr: errChatAccessDenied, seesAll: true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            withAccessMode(t, tt.mode)
+
+            if err := canAccessChat(tt.actor, tt.chat); !errors.Is(err, tt.wantErr) {
+                t.Errorf("canAccessChat: err = %v, want %v", err, tt.wantErr)
+            }
+            if got := seesAllChats(tt.actor); got != tt.seesAll {
+                t.Errorf("seesAllChats = %v, want %v", got, tt.seesAll)
+            }
+            got := visibleAssignee(tt.actor)
+            switch {
+            case tt.assignee && (got == nil || *got != tt.actor.ID):
+                t.Errorf("visibleAssignee = %v, ожидался %s", got, tt.actor.ID)
+            case !tt.assignee && got != nil:
+                t.Errorf("visibleAssignee = %s, ожидался nil", got)
+            }
+        })
+    }
+}
+
+func TestChatAccessFullRoles(t *testing.T) {
+    withAccessMode(t, ChatAccessAssigned)
+    ChatAccessFullRoles = []string{"admin", "supervisor"}
+
+    other := uuid.New()
+    a := &actor{Kind: actorAdmin, ID: uuid.New(), ClientID: uuid.New(), Role: "supervisor"}
+    chat := &models.Chat{ID: uuid.New(), ClientID: a.ClientID, AssignedTo: &other}
+
+    if !seesAllChats(a) {
+        t.Error("роль из ChatAccessFullRoles должна видеть все чаты")
+    }
+    if err := canAccessChat(a, chat); err != nil {
+        t.Errorf("canAccessChat: %v", err)
+    }
+    if got := visibleAssignee(a); got != nil {
+        t.Errorf("visibleAssignee = %s, ожидался nil", got)
+    }
+}
+
+// listed повторяет условие выборки database.GetChats для оператора.
+func listed(a *actor, chat *models.Chat) bool {
+    assignee := visibleAssignee(a)
+    return chat.ClientID == a.ClientID &&
+        (assignee == nil || chat.AssignedTo == nil || *chat.AssignedTo == *assignee)
+}
+
+// Список чатов оператора совпадает с чатами, к которым у него есть доступ:
+// getChats не прячет чаты, которые открываются по ID, и наоборот.
+func TestChatListMatchesAccess(t *testing.T) {
+    clientID, operatorID, otherOperatorID := uuid.New(), uuid.New(), uuid.New()
+    assignees := map[string]*uuid.UUID{"free": nil, "own": &operatorID, "foreign": &otherOperatorID}
+
+    for _, mode := range []string{ChatAccessClient, ChatAccessAssigned} {
+        for _, role := range []string{"operator", "admin"} {
+            for name, assignee := range assignees {
+                t.Run(mode+"/"+role+"/"+name, func(t *testing.T) {
+                    withAccessMode(t, mode)
+                    a := &actor{Kind: actorAdmin, ID: operatorID, ClientID: clientID, Role: role}
+                    chat := &models.Chat{ID: uuid.New(), ClientID: clientID, AssignedTo: assignee}
+
+                    allowed := canAccessChat(a, chat) == nil
+                    if got := listed(a, chat); got != allowed {
+                        t.Errorf("чат в списке: %v, доступ по ID: %v", got, allowed)
+                    }
+                })
+            }
+        }
+    }
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+    "net/http"
+    "strconv"
+
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+)
+
+// REST-аналоги WebSocket-команд (/api/v1/chats). Тела ответов совпадают
+// с payload соответствующих WebSocket-сообщений.
+
+// respondError отдаёт ошибку команды в формате {"error", "code"}.
+func respondError(c *gin.Context, err error) {
+    se := asServiceError(err)
+    c.JSON(se.Status, errorResponse{Error: se.Message, Code: se.Code})
+}
+
+// pageParams читает page и pageSize из query-параметров.
+func pageParams(c *gin.Context) (int, int) {
+    page, _ := strconv.Atoi(c.Query("page"))
+    size, _ := strconv.Atoi(c.Query("pageSize"))
+    return page, size
+}
+
+// restActor возвращает участника запроса или отвечает 401.
+func restActor(c *gin.Context) (*actor, bool) {
+    a, err := actorFromContext(c)
+    if err != nil {
+        respondError(c, err)
+        return nil, false
+    }
+    return a, true
+}
+
+// chatIDParam разбирает :id из пути или отвечает 400.
+func chatIDParam(c *gin.Context) (uuid.UUID, bool) {
+    chatID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        respondError(c, errBadRequest("invalid_uuid", "Некорректный формат chatID"))
+        return uuid.Nil, false
+    }
+    return chatID, true
+}
+
+// ListChatsREST — GET /api/v1/chats?status= (аналог getChats)
+func ListChatsREST(c *gin.Context) {
+    a, ok := restActor(c)
+    if !ok {
+        return
+    }
+
+    page, size := pageParams(c)
+    list, err := listChats(a, c.Query("status"), page, size)
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+    c.JSON(http.StatusOK, list)
+}
+
+// GetChatREST — GET /api/v1/chats/:id (аналог getChatByID)
+func GetChatREST(c *gin.Context) {
+    a, ok := restActor(c)
+    if !ok {
+        return
+    }
+    chatID, ok := chatIDParam(c)
+    if !ok {
+        return
+    }
+
+    page, size := pageParams(c)
+    details, err := getChat(a, chatID, page, size)
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+    c.JSON(http.StatusOK, details)
+}
+
+// SendMessageREST — POST /api/v1/chats/:id/messages (аналог sendMessage)
+func SendMessageREST(c *gin.Context) {
+    a, ok := restActor(c)
+    if !ok {
+        return
+    }
+    chatID, ok := chatIDParam(c)
+    if !ok {
+        return
+    }
+
+    var req sendMessageRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondError(c, errBadRequest("invalid_payload", "Некорректный формат данных для sendMessage"))
+        return
+    }
+    req.ChatID = chatID.String()
+
+    message, err := sendMessage(a, &req)
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+    c.JSON(http.StatusCreated, sendMessageResult{
+        MessageID: message.ID.String(),
+        Timestamp: message.Timestamp,
+        Status:    "delivered",
+    })
+}
+
+// MarkAsReadREST — POST /api/v1/chats/:id/read (аналог markAsRead)
+func MarkAsReadREST(c *gin.Context) {
+    a, ok := restActor(c)
+    if !ok {
+        return
+    }
+    chatID, ok := chatIDParam(c)
+    if !ok {
+        return
+    }
+
+    if err := markAsRead(a, chatID); err != nil {
+        respondError(c, err)
+        return
+    }
+    c.JSON(http.StatusOK, chatStatusResult{ChatID: chatID.String(), Status: "success"})
+}
+
+// ClaimChatREST — POST /api/v1/chats/:id/claim (аналог claimChat)
+func ClaimChatREST(c *gin.Context) {
+    a, ok := restActor(c)
+    if !ok {
+        return
+    }
+    chatID, ok := chatIDParam(c)
+    if !ok {
+        return
+    }
+
+    event, err := claimChat(a, chatID)
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+    c.JSON(http.StatusOK, event)
+}
+
+// TransferChatREST — POST /api/v1/chats/:id/transfer (аналог transferChat)
+func TransferChatREST(c *gin.Context) {
+    a, ok := restActor(c)
+    if !ok {
+        return
+    }
+    chatID, ok := chatIDParam(c)
+    if !ok {
+        return
+    }
+
+    var req transferChatRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondError(c, errBadRequest("invalid_payload", "Некорректный формат данных для transferChat"))
+        return
+    }
+    req.ChatID = chatID.String()
+
+    event, err := transferChat(a, &req)
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+    c.JSON(http.StatusOK, event)
+}
+
+// UnassignChatREST — POST /api/v1/chats/:id/unassign (аналог unassignChat)
+func UnassignChatREST(c *gin.Context) {
+    a, ok := restActor(c)
+    if !ok {
+        return
+    }
+    chatID, ok := chatIDParam(c)
+    if !ok {
+        return
+    }
+
+    event, err := unassignChat(a, chatID)
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+    c.JSON(http.StatusOK, event)
+}
+
+// SetChatStatusREST — POST /api/v1/chats/:id/status (аналог setChatStatus)
+func SetChatStatusREST(c *gin.Context) {
+    a, ok := restActor(c)
+    if !ok {
+        return
+    }
+    chatID, ok := chatIDParam(c)
+    if !ok {
+        return
+    }
+
+    var req chatStatusRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondError(c, errBadRequest("invalid_payload", "Некорректный формат данных для setChatStatus"))
+        return
+    }
+    req.ChatID = chatID.String()
+
+    event, err := setChatStatus(a, &req)
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+    c.JSON(http.StatusOK, event)
+}
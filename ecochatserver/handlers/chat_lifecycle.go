@@ -0,0 +1,182 @@
+package handlers
+
+import (
+    "context"
+    "errors"
+    "log"
+    "net/http"
+    "time"
+
+    "github.com/gin-gonic/gin"
+
+    "github.com/egor/ecochatserver/database"
+    "github.com/egor/ecochatserver/models"
+    websocketpkg "github.com/egor/ecochatserver/websocket"
+    "github.com/egor/ecochatserver/webhooks"
+)
+
+const (
+    autoCloseInterval  = time.Minute
+    autoCloseBatchSize = 100
+)
+
+// AutoCloseAfter — порог автозакрытия для клиентов без своего (0 — не закрывать)
+var AutoCloseAfter = 24 * time.Hour
+
+// applyStatusChange рассылает chatStatusChanged и публикует вебхук.
+// by — оператор, сменивший статус (nil — пользователь или автозакрытие).
+func applyStatusChange(change *models.ChatStatusChange, by *actor) *chatStatusEvent {
+    event := &chatStatusEvent{
+        ChatID:         change.ChatID.String(),
+        Status:         change.Status,
+        PreviousStatus: change.PreviousStatus,
+        Resolution:     change.Resolution,
+        Timestamp:      time.Now(),
+    }
+    if by != nil {
+        s := by.ID.String()
+        event.ChangedBy = &s
+    }
+
+    msg, err := websocketpkg.NewMessage("chatStatusChanged", event)
+    if err != nil {
+        log.Printf("applyStatusChange: %v", err)
+    } else {
+        // Сессия-инициатор получает событие в ответе на свою команду
+        var except []string
+        if by != nil {
+            except = append(except, by.ConnID.String())
+        }
+        sendToChat(&models.Chat{ID: change.ChatID, ClientID: change.ClientID}, msg, except...)
+    }
+
+    if change.Status == models.ChatStatusClosed {
+        // Закрытый чат освобождает место у оператора
+        wakeRouter()
+    }
+
+    var eventType string
+    switch {
+    case change.Status == models.ChatStatusClosed:
+        eventType = webhooks.EventChatClosed
+    case change.PreviousStatus == models.ChatStatusClosed:
+        eventType = webhooks.EventChatReopened
+    default:
+        return event
+    }
+    chat, err := database.GetChatLightweight(change.ChatID)
+    if err != nil {
+        log.Printf("applyStatusChange: ошибка загрузки чата %s: %v", change.ChatID, err)
+        return event
+    }
+    extra := map[string]interface{}{"previousStatus": change.PreviousStatus}
+    if change.Resolution != "" {
+        extra["resolution"] = change.Resolution
+    }
+    if event.ChangedBy != nil {
+        extra["changedBy"] = *event.ChangedBy
+    }
+    emitChatEvent(eventType, chat, extra)
+    return event
+}
+
+// reopenOnUserMessage возвращает закрытый или ожидающий чат в работу,
+// когда пользователь пишет снова.
+func reopenOnUserMessage(chat *models.Chat) {
+    if chat.Status == models.ChatStatusActive {
+        return
+    }
+    change, err := database.ChangeChatStatus(chat.ID,
+        []string{models.ChatStatusClosed, models.ChatStatusPending}, models.ChatStatusActive, "")
+    if errors.Is(err, database.ErrStatusConflict) {
+        return // уже активен
+    }
+    if err != nil {
+        log.Printf("reopenOnUserMessage: чат %s: %v", chat.ID, err)
+        return
+    }
+
+    log.Printf("reopenOnUserMessage: чат %s %s → active", chat.ID, change.PreviousStatus)
+    chat.Status = models.ChatStatusActive
+    applyStatusChange(change, nil)
+}
+
+// RunAutoClose периодически закрывает неактивные чаты до отмены ctx.
+func RunAutoClose(ctx context.Context) {
+    ticker := time.NewTicker(autoCloseInterval)
+    defer ticker.Stop()
+
+    defaultMinutes := int(AutoCloseAfter / time.Minute)
+    log.Printf("[autoclose] Автозакрытие неактивных чатов запущено (по умолчанию %d мин)", defaultMinutes)
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+
+        // Закрываем пачками, пока находятся неактивные чаты
+        for ctx.Err() == nil {
+            closed, err := database.CloseIdleChats(defaultMinutes, autoCloseBatchSize)
+            if err != nil {
+                log.Printf("[autoclose] ошибка: %v", err)
+                break
+            }
+            for i := range closed {
+                applyStatusChange(&closed[i], nil)
+            }
+            if len(closed) > 0 {
+                log.Printf("[autoclose] закрыто неактивных чатов: %d", len(closed))
+            }
+            if len(closed) < autoCloseBatchSize {
+                break
+            }
+        }
+    }
+}
+
+// GetAutoCloseSettings возвращает порог автозакрытия клиента.
+func GetAutoCloseSettings(c *gin.Context) {
+    clientID, ok := requestClientID(c)
+    if !ok {
+        return
+    }
+
+    minutes, err := database.GetAutoCloseMinutes(clientID)
+    if err != nil {
+        log.Printf("GetAutoCloseSettings: %v", err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения настроек автозакрытия"})
+        return
+    }
+    c.JSON(http.StatusOK, autoCloseSettings{
+        AutoCloseMinutes: minutes,
+        DefaultMinutes:   int(AutoCloseAfter / time.Minute),
+    })
+}
+
+// UpdateAutoCloseSettings меняет порог автозакрытия клиента
+// (null — по умолчанию сервера, 0 — не закрывать).
+func UpdateAutoCloseSettings(c *gin.Context) {
+    clientID, ok := requestClientID(c)
+    if !ok {
+        return
+    }
+
+    var req autoCloseSettings
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    if req.AutoCloseMinutes != nil && *req.AutoCloseMinutes < 0 {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "autoCloseMinutes не может быть отрицательным"})
+        return
+    }
+
+    if err := database.SetAutoCloseMinutes(clientID, req.AutoCloseMinutes); err != nil {
+        log.Printf("UpdateAutoCloseSettings: %v", err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка сохранения настроек автозакрытия"})
+        return
+    }
+
+    GetAutoCloseSettings(c)
+}
@@ -0,0 +1,541 @@
+package handlers
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log"
+    "net/http"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+
+    "github.com/egor/ecochatserver/database"
+    "github.com/egor/ecochatserver/database/queries"
+    "github.com/egor/ecochatserver/models"
+    "github.com/egor/ecochatserver/webhooks"
+    websocketpkg "github.com/egor/ecochatserver/websocket"
+)
+
+// Общая логика команд над чатами. WebSocket (processWebSocketMessage) и
+// REST (/api/v1/chats) только разбирают запрос, вызывают эти функции и
+// упаковывают результат, поэтому поведение двух транспортов не расходится.
+
+// Типы участников
+const (
+    actorAdmin  = "admin"
+    actorWidget = "widget"
+)
+
+// actor — кто выполняет команду: оператор (из JWT) или виджет пользователя
+type actor struct {
+    Kind     string    // actorAdmin | actorWidget
+    ID       uuid.UUID // adminID или ID пользователя виджета
+    ClientID uuid.UUID // клиент оператора (для виджета не заполнен)
+    Role     string
+    ChatID   uuid.UUID // единственный доступный виджету чат
+    ConnID   uuid.UUID // сессия WebSocket админа (для REST — adminID); дедупликация и исключение инициатора из рассылок
+}
+
+// sender возвращает отправителя сообщений от имени участника.
+func (a *actor) sender() string {
+    if a.Kind == actorAdmin {
+        return "admin"
+    }
+    return "user"
+}
+
+// actorFromContext собирает участника из данных, которые AuthMiddleware
+// (или ServeWs) положил в gin.Context.
+func actorFromContext(c *gin.Context) (*actor, error) {
+    adminID, err := uuid.Parse(c.GetString("adminID"))
+    if err != nil {
+        return nil, errInvalidActor("Некорректный adminID")
+    }
+    clientID, err := uuid.Parse(c.GetString("clientID"))
+    if err != nil {
+        return nil, errInvalidActor("Некорректный clientID")
+    }
+    return &actor{
+        Kind:     actorAdmin,
+        ID:       adminID,
+        ClientID: clientID,
+        Role:     c.GetString("role"),
+        ConnID:   adminID,
+    }, nil
+}
+
+// actorFromClient собирает участника WebSocket-соединения.
+func actorFromClient(client *websocketpkg.Client) (*actor, error) {
+    if client.ClientType == actorAdmin {
+        a, err := actorFromContext(client.Context)
+        if err != nil {
+            return nil, err
+        }
+        a.ConnID = client.SessionID
+        return a, nil
+    }
+    return &actor{
+        Kind:   actorWidget,
+        ID:     client.ID,
+        ChatID: client.ChatID,
+        ConnID: client.ID,
+    }, nil
+}
+
+// serviceError — ошибка команды с кодом для WebSocket и HTTP-статусом для REST
+type serviceError struct {
+    Code    string
+    Message string
+    Status  int
+}
+
+func (e *serviceError) Error() string { return e.Message }
+
+func errInvalidActor(msg string) *serviceError {
+    return &serviceError{Code: "invalid_uuid", Message: msg, Status: http.StatusUnauthorized}
+}
+
+func errBadRequest(code, msg string) *serviceError {
+    return &serviceError{Code: code, Message: msg, Status: http.StatusBadRequest}
+}
+
+func errDB(msg string, err error) *serviceError {
+    return &serviceError{Code: "db_error", Message: msg + ": " + err.Error(), Status: http.StatusInternalServerError}
+}
+
+var (
+    errChatNotFound     = &serviceError{Code: "not_found", Message: "Чат не найден", Status: http.StatusNotFound}
+    errChatAccessDenied = &serviceError{Code: "access_denied", Message: "Доступ к чату запрещен", Status: http.StatusForbidden}
+    errDuplicateMessage = &serviceError{Code: "duplicate", Message: "Сообщение уже отправлено", Status: http.StatusConflict}
+
+    errAlreadyAssigned    = &serviceError{Code: "already_assigned", Message: "Чат уже назначен другому оператору", Status: http.StatusConflict}
+    errAssignmentConflict = &serviceError{Code: "assignment_conflict", Message: "Назначение чата изменилось, обновите данные", Status: http.StatusConflict}
+    errNotAssignee        = &serviceError{Code: "not_assignee", Message: "Чат назначен другому оператору", Status: http.StatusForbidden}
+    errAdminNotFound      = &serviceError{Code: "admin_not_found", Message: "Оператор не найден", Status: http.StatusNotFound}
+
+    errStatusConflict = &serviceError{Code: "status_conflict", Message: "Статус чата изменился, обновите данные", Status: http.StatusConflict}
+)
+
+// asServiceError приводит произвольную ошибку к serviceError.
+func asServiceError(err error) *serviceError {
+    var se *serviceError
+    if errors.As(err, &se) {
+        return se
+    }
+    return &serviceError{Code: "internal_error", Message: err.Error(), Status: http.StatusInternalServerError}
+}
+
+// normalizePage приводит параметры пагинации к допустимым значениям
+// (так же, как это делают database.GetChats и GetChatByID).
+func normalizePage(page, size int) (int, int) {
+    if page < 1 {
+        page = 1
+    }
+    if size < 1 || size > database.MaxPageSize {
+        size = database.DefaultPageSize
+    }
+    return page, size
+}
+
+// totalPages считает число страниц (минимум одна).
+func totalPages(total, size int) int {
+    pages := (total + size - 1) / size
+    if pages < 1 {
+        pages = 1
+    }
+    return pages
+}
+
+// sendToChat рассылает событие чата виджетам этого чата и админам его
+// клиента, подписанным на чат (кроме сессий exceptSessions). Другим
+// клиентам события не уходят.
+func sendToChat(chat *models.Chat, msg []byte, exceptSessions ...string) {
+    WebSocketHub.SendChatEvent(chat.ClientID.String(), chat.ID.String(), msg, exceptSessions...)
+}
+
+// sendToChatID — sendToChat, когда известен только ID чата.
+func sendToChatID(chatID uuid.UUID, msg []byte) {
+    chat, err := database.GetChatLightweight(chatID)
+    if err != nil {
+        // Без клиента чата адресатов не проверить — событие не отправляем
+        log.Printf("sendToChatID: ошибка загрузки чата %s, событие не отправлено: %v", chatID, err)
+        return
+    }
+    sendToChat(chat, msg)
+}
+
+// chatDetails — ответ getChatByID / GET /api/v1/chats/:id
+type chatDetails struct {
+    Chat       *models.Chat `json:"chat"`
+    Page       int          `json:"page"`
+    PageSize   int          `json:"pageSize"`
+    TotalItems int          `json:"totalItems"`
+    TotalPages int          `json:"totalPages"`
+}
+
+// sendMessageRequest — тело sendMessage / POST /api/v1/chats/:id/messages
+type sendMessageRequest struct {
+    ChatID   string                 `json:"chatID"`
+    Content  string                 `json:"content"`
+    Type     string                 `json:"type"`
+    Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// sendMessageResult — подтверждение отправки
+type sendMessageResult struct {
+    MessageID string    `json:"messageID"`
+    Timestamp time.Time `json:"timestamp"`
+    Status    string    `json:"status"`
+}
+
+// listChats возвращает страницу чатов, доступных оператору (status "" —
+// любой). Список ограничен так же, как доступ к отдельному чату: см.
+// visibleAssignee.
+func listChats(a *actor, status string, page, size int) (*models.ChatPaginationResponse, error) {
+    if a.Kind != actorAdmin {
+        return nil, errChatAccessDenied
+    }
+    if status != "" && !models.IsValidChatStatus(status) {
+        return nil, errBadRequest("invalid_status", "Неизвестный статус: "+status)
+    }
+    page, size = normalizePage(page, size)
+
+    log.Printf("listChats: запрос чатов для admin=%s, client=%s, status=%q, page=%d, size=%d",
+        a.ID, a.ClientID, status, page, size)
+
+    chats, total, err := database.GetChats(a.ClientID, visibleAssignee(a), status, page, size)
+    if err != nil {
+        log.Printf("listChats: ошибка получения чатов: %v", err)
+        return nil, errDB("Ошибка получения чатов", err)
+    }
+
+    log.Printf("listChats: найдено %d чатов из %d всего", len(chats), total)
+    return &models.ChatPaginationResponse{
+        Chats:      chats,
+        Page:       page,
+        PageSize:   size,
+        TotalItems: total,
+        TotalPages: totalPages(total, size),
+    }, nil
+}
+
+// getChat возвращает чат со страницей сообщений. Для оператора
+// сообщения пользователя помечаются прочитанными.
+func getChat(a *actor, chatID uuid.UUID, page, size int) (*chatDetails, error) {
+    if _, err := authorizeChat(a, chatID); err != nil {
+        return nil, err
+    }
+    page, size = normalizePage(page, size)
+
+    log.Printf("getChat: запрос чата ID=%s, page=%d, size=%d", chatID, page, size)
+
+    chat, total, err := database.GetChatByID(chatID, page, size)
+    if err != nil {
+        log.Printf("getChat: ошибка получения чата: %v", err)
+        return nil, errDB("Ошибка получения чата", err)
+    }
+
+    if a.Kind == actorAdmin {
+        if err := database.MarkMessagesAsRead(chatID); err != nil {
+            log.Printf("getChat: ошибка маркировки сообщений: %v", err)
+        }
+    }
+
+    log.Printf("getChat: найден чат с %d сообщениями", len(chat.Messages))
+    return &chatDetails{
+        Chat:       chat,
+        Page:       page,
+        PageSize:   size,
+        TotalItems: total,
+        TotalPages: totalPages(total, size),
+    }, nil
+}
+
+// sendMessage сохраняет сообщение участника, рассылает его по WebSocket,
+// доставляет ответ оператора во внешний канал и запускает автоответчик.
+func sendMessage(a *actor, req *sendMessageRequest) (*models.Message, error) {
+    if req.ChatID == "" || req.Content == "" {
+        return nil, errBadRequest("missing_fields", "Необходимы поля chatID и content")
+    }
+    if req.Type == "" {
+        req.Type = "text"
+    }
+
+    chatID, err := uuid.Parse(req.ChatID)
+    if err != nil {
+        return nil, errBadRequest("invalid_uuid", "Некорректный формат chatID")
+    }
+    chat, err := authorizeChat(a, chatID)
+    if err != nil {
+        return nil, err
+    }
+
+    // Простая дедупликация одинаковых сообщений в пределах секунды
+    messageHash := fmt.Sprintf("%s_%s_%s_%d", a.ConnID, req.ChatID, req.Content, time.Now().Unix())
+    if isRecentMessage(messageHash) {
+        log.Printf("sendMessage: дублирующее сообщение от %s", a.ConnID)
+        return nil, errDuplicateMessage
+    }
+    registerMessage(messageHash)
+
+    sender := a.sender()
+    log.Printf("sendMessage: добавление сообщения в чат %s от %s (%s): %s",
+        chatID, sender, a.ID, req.Content)
+
+    message, err := database.AddMessage(chatID, req.Content, sender, a.ID, req.Type, req.Metadata)
+    if err != nil {
+        log.Printf("sendMessage: ошибка добавления сообщения: %v", err)
+        return nil, errDB("Ошибка при отправке сообщения", err)
+    }
+
+    if err := queries.UpdateChatTimestamp(database.DB, chatID); err != nil {
+        log.Printf("sendMessage: ошибка обновления времени: %v", err)
+    }
+
+    // Ответ оператора доставляем во внешний источник (Telegram и т.п.)
+    if sender == "admin" {
+        deliverOutbound(chatID, message)
+    } else {
+        reopenOnUserMessage(chat)
+    }
+
+    if sender == "user" && AutoResponder != nil {
+        go func() {
+            ctx, cancel := context.WithTimeout(context.Background(), outboundTimeout)
+            defer cancel()
+
+            if botMsg := generateAutoResponse(ctx, chat, message); botMsg != nil {
+                // Отправляем ОДНО комплексное сообщение
+                notification := createChatNotification(chatID, message, botMsg)
+                sendToChat(chat, notification)
+                emitMessagesCreated(chat, message, botMsg)
+            } else {
+                emitMessagesCreated(chat, message)
+            }
+        }()
+    } else {
+        notification := createChatNotification(chatID, message, nil)
+        sendToChat(chat, notification)
+        emitMessagesCreated(chat, message)
+    }
+
+    log.Printf("sendMessage: сообщение успешно отправлено (ID=%s)", message.ID)
+    return message, nil
+}
+
+// markAsRead помечает сообщения чата прочитанными и уведомляет клиентов чата.
+func markAsRead(a *actor, chatID uuid.UUID) error {
+    chat, err := authorizeChat(a, chatID)
+    if err != nil {
+        return err
+    }
+
+    log.Printf("markAsRead: отметка сообщений как прочитанных в чате %s", chatID)
+
+    if err := database.MarkMessagesAsRead(chatID); err != nil {
+        log.Printf("markAsRead: ошибка: %v", err)
+        return errDB("Ошибка при обновлении статуса сообщений", err)
+    }
+
+    statusMsg, _ := websocketpkg.NewMessage("messagesRead", messagesReadEvent{
+        ChatID: chatID.String(),
+        ReadBy: a.ID.String(),
+    })
+    sendToChat(chat, statusMsg)
+
+    log.Printf("markAsRead: успешно обновлен статус сообщений в чате %s", chatID)
+    return nil
+}
+
+// claimChat назначает свободный чат на оператора. Из нескольких
+// одновременных claim выигрывает один (см. database.ChangeChatAssignment).
+func claimChat(a *actor, chatID uuid.UUID) (*chatAssignmentEvent, error) {
+    return changeAssignment(a, chatID, queries.AssignmentClaim, nil, "")
+}
+
+// transferChat передаёт чат другому оператору того же клиента.
+func transferChat(a *actor, req *transferChatRequest) (*chatAssignmentEvent, error) {
+    chatID, err := uuid.Parse(req.ChatID)
+    if err != nil {
+        return nil, errBadRequest("invalid_uuid", "Некорректный формат chatID")
+    }
+    toAdminID, err := uuid.Parse(req.ToAdminID)
+    if err != nil {
+        return nil, errBadRequest("invalid_uuid", "Некорректный формат toAdminID")
+    }
+    return changeAssignment(a, chatID, queries.AssignmentTransfer, &toAdminID, req.Note)
+}
+
+// unassignChat возвращает чат в общую очередь.
+func unassignChat(a *actor, chatID uuid.UUID) (*chatAssignmentEvent, error) {
+    return changeAssignment(a, chatID, queries.AssignmentUnassign, nil, "")
+}
+
+// changeAssignment проверяет права, меняет assigned_to и уведомляет
+// затронутых операторов. Передать или снять чат может его текущий
+// исполнитель или администратор (role=admin); свободный чат — любой оператор.
+func changeAssignment(a *actor, chatID uuid.UUID, action string, target *uuid.UUID, note string) (*chatAssignmentEvent, error) {
+    if a.Kind != actorAdmin {
+        return nil, errChatAccessDenied
+    }
+    chat, err := authorizeChat(a, chatID)
+    if err != nil {
+        return nil, err
+    }
+    prev := chat.AssignedTo
+
+    switch action {
+    case queries.AssignmentClaim:
+        if prev != nil && *prev == a.ID {
+            // Повторный claim своего чата — ничего не меняем
+            return newAssignmentEvent(chatID, action, prev, prev, &a.ID, ""), nil
+        }
+        if prev != nil {
+            return nil, errAlreadyAssigned
+        }
+        target = &a.ID
+    case queries.AssignmentTransfer:
+        if prev != nil && *prev == *target {
+            return nil, errBadRequest("already_assigned", "Чат уже назначен этому оператору")
+        }
+    case queries.AssignmentUnassign:
+        if prev == nil {
+            return nil, errBadRequest("not_assigned", "Чат не назначен")
+        }
+    }
+    if action != queries.AssignmentClaim && prev != nil && *prev != a.ID && a.Role != "admin" {
+        return nil, errNotAssignee
+    }
+
+    err = database.ChangeChatAssignment(chatID, a.ClientID, prev, target, &a.ID, action, note)
+    switch {
+    case errors.Is(err, database.ErrAssignmentConflict):
+        if action == queries.AssignmentClaim {
+            return nil, errAlreadyAssigned
+        }
+        return nil, errAssignmentConflict
+    case errors.Is(err, database.ErrAdminNotFound):
+        return nil, errAdminNotFound
+    case err != nil:
+        log.Printf("changeAssignment: ошибка %s чата %s: %v", action, chatID, err)
+        return nil, errDB("Ошибка изменения назначения чата", err)
+    }
+
+    log.Printf("changeAssignment: %s чата %s оператором %s (%v → %v)", action, chatID, a.ID, prev, target)
+
+    event := newAssignmentEvent(chatID, action, target, prev, &a.ID, note)
+    notifyAssignment(event, a.ConnID.String(), prev, target, &a.ID)
+
+    chat.AssignedTo = target
+    if target != nil {
+        // Передача: прежний исполнитель мог потерять доступ к чату
+        revokeChatAccess(chat, prev)
+    }
+    extra := map[string]interface{}{
+        "action":     action,
+        "assignedBy": a.ID.String(),
+    }
+    if prev != nil {
+        extra["previousAssignee"] = prev.String()
+    }
+    if note != "" {
+        extra["note"] = note
+    }
+    emitChatEvent(webhooks.EventChatAssigned, chat, extra)
+
+    if target == nil {
+        // Чат вернулся в очередь — пусть роутер назначит его заново
+        wakeRouter()
+    }
+
+    return event, nil
+}
+
+// newAssignmentEvent собирает payload chatAssigned; by равен nil, если чат
+// назначил роутер.
+func newAssignmentEvent(chatID uuid.UUID, action string, to, from, by *uuid.UUID, note string) *chatAssignmentEvent {
+    uuidString := func(id *uuid.UUID) *string {
+        if id == nil {
+            return nil
+        }
+        s := id.String()
+        return &s
+    }
+    return &chatAssignmentEvent{
+        ChatID:           chatID.String(),
+        Action:           action,
+        AssignedTo:       uuidString(to),
+        PreviousAssignee: uuidString(from),
+        AssignedBy:       uuidString(by),
+        Note:             note,
+        Timestamp:        time.Now(),
+    }
+}
+
+// notifyAssignment рассылает chatAssigned во все сессии перечисленных админов.
+// Сессия-инициатор (exceptSession) получает результат в ответе на свою команду.
+func notifyAssignment(event *chatAssignmentEvent, exceptSession string, admins ...*uuid.UUID) {
+    msg, err := websocketpkg.NewMessage("chatAssigned", event)
+    if err != nil {
+        log.Printf("notifyAssignment: %v", err)
+        return
+    }
+    sent := make(map[uuid.UUID]bool)
+    for _, id := range admins {
+        if id == nil || sent[*id] {
+            continue
+        }
+        sent[*id] = true
+        WebSocketHub.SendToAdmin(id.String(), msg, exceptSession)
+    }
+}
+
+// statusTransitions — из каких статусов оператор может перевести чат в данный
+var statusTransitions = map[string][]string{
+    models.ChatStatusActive:  {models.ChatStatusPending, models.ChatStatusClosed},
+    models.ChatStatusPending: {models.ChatStatusActive},
+    models.ChatStatusClosed:  {models.ChatStatusActive, models.ChatStatusPending},
+}
+
+// setChatStatus меняет статус чата: закрыть с причиной, ждать ответа
+// пользователя (pending) или вернуть в работу.
+func setChatStatus(a *actor, req *chatStatusRequest) (*chatStatusEvent, error) {
+    if a.Kind != actorAdmin {
+        return nil, errChatAccessDenied
+    }
+    chatID, err := uuid.Parse(req.ChatID)
+    if err != nil {
+        return nil, errBadRequest("invalid_uuid", "Некорректный формат chatID")
+    }
+    from, ok := statusTransitions[req.Status]
+    if !ok {
+        return nil, errBadRequest("invalid_status", "Неизвестный статус: "+req.Status)
+    }
+    chat, err := authorizeChat(a, chatID)
+    if err != nil {
+        return nil, err
+    }
+    if chat.Status == req.Status {
+        return nil, errBadRequest("same_status", "Чат уже в статусе "+req.Status)
+    }
+
+    resolution := req.Resolution
+    if req.Status == models.ChatStatusClosed && resolution == "" {
+        resolution = "resolved"
+    }
+
+    change, err := database.ChangeChatStatus(chatID, from, req.Status, resolution)
+    if errors.Is(err, database.ErrStatusConflict) {
+        return nil, errStatusConflict
+    }
+    if err != nil {
+        log.Printf("setChatStatus: ошибка смены статуса чата %s: %v", chatID, err)
+        return nil, errDB("Ошибка смены статуса чата", err)
+    }
+
+    log.Printf("setChatStatus: чат %s %s → %s (%s) оператором %s",
+        chatID, change.PreviousStatus, change.Status, change.Resolution, a.ID)
+    return applyStatusChange(change, a), nil
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+    "errors"
+    "fmt"
+    "log"
+
+    "github.com/egor/ecochatserver/channels"
+    "github.com/egor/ecochatserver/database"
+    "github.com/google/uuid"
+)
+
+// IsEmailMailbox сообщает, зарегистрирован ли адрес как почтовый ящик
+// поддержки (используется SMTP-приёмником на этапе RCPT TO).
+func IsEmailMailbox(addr string) bool {
+    _, err := database.FindBot(channels.SourceEmail, addr)
+    if err != nil && !errors.Is(err, database.ErrBotNotFound) {
+        log.Printf("IsEmailMailbox: ошибка поиска ящика %s: %v", addr, err)
+    }
+    return err == nil
+}
+
+// HandleInboundEmail принимает письмо от SMTP-приёмника и передаёт его
+// адаптеру email для каждого ящика-получателя. Приём идемпотентен по
+// Message-ID на ящик: при повторе после временной ошибки ящики, которые
+// уже сохранили письмо, его пропускают.
+func HandleInboundEmail(from string, to []string, data []byte) error {
+    ch, ok := channels.Get(channels.SourceEmail)
+    if !ok {
+        return fmt.Errorf("канал %s не зарегистрирован", channels.SourceEmail)
+    }
+
+    for _, rcpt := range to {
+        bot, err := database.FindBot(channels.SourceEmail, rcpt)
+        if err != nil {
+            log.Printf("HandleInboundEmail: ящик %s недоступен: %v", rcpt, err)
+            continue
+        }
+
+        inbound, err := ch.ParseInbound(data, bot)
+        if err != nil {
+            // Неразборчивое письмо повторять бессмысленно — принимаем и пишем в лог
+            log.Printf("HandleInboundEmail: ошибка разбора письма от %s: %v", from, err)
+            return nil
+        }
+
+        for i := range inbound.Messages {
+            if seen, err := emailAlreadyStored(bot.ClientID, bot.BotID, &inbound.Messages[i]); err != nil {
+                return fmt.Errorf("проверка повтора письма для %s: %w", rcpt, err)
+            } else if seen {
+                log.Printf("HandleInboundEmail: письмо от %s уже принято ящиком %s", from, rcpt)
+                continue
+            }
+
+            chat, userMsg, err := acceptInbound(ch, bot, &inbound.Messages[i])
+            if errors.Is(err, errDuplicateInbound) {
+                continue
+            }
+            if err != nil {
+                return fmt.Errorf("сохранение письма для %s: %w", rcpt, err)
+            }
+            log.Printf("HandleInboundEmail: письмо от %s принято в чат %s", from, chat.ID)
+            respondAsync(chat, userMsg)
+        }
+    }
+    return nil
+}
+
+// emailAlreadyStored сообщает, сохранён ли уже входящий Message-ID в ящике.
+// Письма без Message-ID проверить нельзя — они принимаются всегда.
+func emailAlreadyStored(clientID uuid.UUID, botID string, item *channels.InboundMessage) (bool, error) {
+    msgID, _ := item.Message.Metadata["sourceMessageId"].(string)
+    if msgID == "" {
+        return false, nil
+    }
+    return database.HasInboundMessage(clientID, channels.SourceEmail, botID, msgID)
+}
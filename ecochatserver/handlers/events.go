@@ -0,0 +1,64 @@
+package handlers
+
+import (
+    "log"
+
+    "github.com/google/uuid"
+
+    "github.com/egor/ecochatserver/database"
+    "github.com/egor/ecochatserver/models"
+    "github.com/egor/ecochatserver/webhooks"
+)
+
+// webhookChat — представление чата в теле исходящего вебхука
+func webhookChat(chat *models.Chat) map[string]interface{} {
+    data := map[string]interface{}{
+        "id":        chat.ID.String(),
+        "clientId":  chat.ClientID.String(),
+        "status":    chat.Status,
+        "source":    chat.Source,
+        "botId":     chat.BotID,
+        "createdAt": chat.CreatedAt,
+        "user": map[string]interface{}{
+            "id":       chat.User.ID.String(),
+            "name":     chat.User.Name,
+            "email":    chat.User.Email,
+            "sourceId": chat.User.SourceID,
+        },
+    }
+    if chat.AssignedTo != nil {
+        data["assignedTo"] = chat.AssignedTo.String()
+    }
+    return data
+}
+
+// emitChatEvent ставит событие по чату в очередь исходящих вебхуков.
+// extra дополняет data (например, причина закрытия или кто назначил чат).
+func emitChatEvent(eventType string, chat *models.Chat, extra map[string]interface{}) {
+    data := map[string]interface{}{"chat": webhookChat(chat)}
+    for k, v := range extra {
+        data[k] = v
+    }
+    webhooks.Emit(chat.ClientID, eventType, data)
+}
+
+// emitMessagesCreated публикует message.created для каждого сообщения.
+// Вызывается там же, где рассылается chat_update по WebSocket.
+func emitMessagesCreated(chat *models.Chat, msgs ...*models.Message) {
+    // Чат из WebSocket-команды приходит только с ID — догружаем клиента и пользователя
+    if chat.ClientID == uuid.Nil {
+        full, err := database.GetChatLightweight(chat.ID)
+        if err != nil {
+            log.Printf("emitMessagesCreated: ошибка загрузки чата %s: %v", chat.ID, err)
+            return
+        }
+        chat = full
+    }
+
+    for _, msg := range msgs {
+        if msg == nil {
+            continue
+        }
+        emitChatEvent(webhooks.EventMessageCreated, chat, map[string]interface{}{"message": msg})
+    }
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "log"
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+
+    "github.com/egor/ecochatserver/channels"
+    "github.com/egor/ecochatserver/database"
+    "github.com/egor/ecochatserver/database/queries"
+    "github.com/egor/ecochatserver/models"
+    "github.com/egor/ecochatserver/routing"
+    "github.com/egor/ecochatserver/webhooks"
+)
+
+// IncomingWebhook принимает сообщения в собственном формате models.IncomingMessage
+// (веб-виджет и ретрансляторы ботов) через адаптер channels.Widget.
+// Клиент определяется по заголовку X-API-Key, а не по clientId из тела.
+func IncomingWebhook(c *gin.Context) {
+    // OPTIONS для CORS
+    if c.Request.Method == http.MethodOptions {
+        handleCORS(c)
+        c.Status(http.StatusOK)
+        return
+    }
+    handleCORS(c)
+
+    apiKey := c.GetHeader("X-API-Key")
+    clientID, err := database.FindClientByAPIKey(apiKey)
+    if errors.Is(err, database.ErrClientNotFound) {
+        log.Printf("IncomingWebhook: неверный API ключ от %s", c.ClientIP())
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверный или отсутствующий X-API-Key"})
+        return
+    }
+    if err != nil {
+        log.Printf("IncomingWebhook: ошибка поиска клиента: %v", err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка поиска клиента"})
+        return
+    }
+
+    ch, ok := channels.Get(channels.SourceWidget)
+    if !ok {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Неизвестный источник: " + channels.SourceWidget})
+        return
+    }
+    // API ключ клиента выступает секретом вебхука виджета
+    serveChannelWebhook(c, ch, &models.Bot{
+        ClientID: clientID,
+        Source:   channels.SourceWidget,
+        Secret:   apiKey,
+        Active:   true,
+    })
+}
+
+// ingestIncoming создаёт/находит чат и сохраняет сообщение пользователя.
+// Общий путь для всех входящих источников.
+func ingestIncoming(in *models.IncomingMessage) (*models.Chat, *models.Message, error) {
+    sourceID := in.SourceID
+    if sourceID == "" {
+        sourceID = in.UserID
+    }
+
+    // Ответ в известной цепочке попадает в её чат, даже если отправитель
+    // сохранил не всю цепочку (например, только In-Reply-To на наш ответ)
+    threadID := in.ThreadID
+    if len(in.ThreadRefs) > 0 {
+        if clientID, err := uuid.Parse(in.ClientID); err == nil {
+            found, ok, err := database.FindThread(clientID, in.Source, in.BotID, in.ThreadRefs)
+            if err != nil {
+                log.Printf("ingestIncoming: ошибка поиска цепочки: %v", err)
+            } else if ok {
+                threadID = found
+            }
+        }
+    }
+
+    // Создаём или получаем чат
+    log.Printf("ingestIncoming: создаем/получаем чат для user=%s, source=%s, sourceID=%s, botID=%s, threadID=%s, clientID=%s", 
+        in.UserID, in.Source, sourceID, in.BotID, threadID, in.ClientID)
+    
+    chat, created, err := database.GetOrCreateChat(
+        in.UserID, in.UserName, in.UserEmail,
+        in.Source, sourceID, in.BotID, threadID, in.ClientID,
+    )
+    if err != nil {
+        log.Printf("ingestIncoming: GetOrCreateChat error: %v", err)
+        return nil, nil, err
+    }
+    
+    log.Printf("ingestIncoming: получен чат: ID=%s, ClientID=%s, UserID=%s", 
+        chat.ID, chat.ClientID, chat.User.ID)
+
+    if created {
+        emitChatEvent(webhooks.EventChatCreated, chat, nil)
+    }
+    
+    // Создаем детерминированный UUID для отправителя
+    var userUUID uuid.UUID
+    if parsedUUID, err := uuid.Parse(in.UserID); err == nil {
+        userUUID = parsedUUID
+    } else {
+        userUUID = uuid.NewSHA1(uuid.NameSpaceOID, []byte(in.UserID))
+        log.Printf("ingestIncoming: создан детерминированный UUID для userID %s: %s", in.UserID, userUUID.String())
+    }
+
+    // Добавляем сообщение пользователя
+    msgType := "text"
+    if in.MessageType != "" {
+        msgType = in.MessageType
+    }
+    
+    userMsg, err := database.AddMessage(
+        chat.ID,
+        in.Content,
+        "user",
+        userUUID,
+        msgType,
+        in.Metadata,
+    )
+    if err != nil {
+        log.Printf("ingestIncoming: AddMessage error: %v", err)
+        return nil, nil, err
+    }
+    
+    log.Printf("ingestIncoming: сообщение добавлено: ID=%s", userMsg.ID)
+
+    // Быстро обновляем время чата
+    if err := queries.UpdateChatTimestamp(database.DB, chat.ID); err != nil {
+        log.Printf("ingestIncoming: ошибка обновления времени: %v", err)
+    }
+
+    // Пользователь написал в закрытый или ожидающий чат — возвращаем в работу
+    reopenOnUserMessage(chat)
+
+    // Язык нужен маршрутизации по навыкам
+    if lang := routing.DetectLanguage(in.Content); lang != "" {
+        if err := database.SetChatLanguage(chat.ID, lang); err != nil {
+            log.Printf("ingestIncoming: %v", err)
+        }
+    }
+    if chat.AssignedTo == nil {
+        wakeRouter()
+    }
+
+    return chat, userMsg, nil
+}
+
+// generateAutoResponse запускает автоответчик и сохраняет ответ в БД.
+// Возвращает nil, если автоответ не нужен или не удался.
+func generateAutoResponse(ctx context.Context, chat *models.Chat, userMsg *models.Message) *models.Message {
+    if AutoResponder == nil {
+        return nil
+    }
+
+    // Загружаем минимальную информацию о чате для автоответчика
+    lightChat, err := queries.GetChatLightweight(database.DB, chat.ID)
+    if err != nil {
+        log.Printf("generateAutoResponse: ошибка загрузки чата: %v", err)
+        lightChat = chat // Используем уже загруженный чат
+    }
+    
+    botMsg, err := AutoResponder.ProcessMessage(ctx, lightChat, userMsg)
+    if err != nil {
+        log.Printf("generateAutoResponse: AutoResponder.ProcessMessage error: %v", err)
+        return nil
+    }
+    if botMsg == nil {
+        return nil
+    }
+
+    // История автоответчика сохраняется в той же транзакции, что и ответ
+    saved, err := database.AddMessageTx(
+        chat.ID,
+        botMsg.Content,
+        botMsg.Sender,
+        botMsg.SenderID,
+        botMsg.Type,
+        botMsg.Metadata,
+        func(ctx context.Context, tx *sql.Tx) error {
+            return AutoResponder.SaveReply(ctx, chat.ID.String(), botMsg, tx)
+        },
+    )
+    if err != nil {
+        log.Printf("generateAutoResponse: ошибка сохранения автоответа: %v", err)
+        return nil
+    }
+    AutoResponder.CommitReply(lightChat, saved)
+    log.Printf("generateAutoResponse: автоответ сохранен: ID=%s", saved.ID)
+
+    // Обновляем время чата
+    if err := queries.UpdateChatTimestamp(database.DB, chat.ID); err != nil {
+        log.Printf("generateAutoResponse: ошибка обновления времени: %v", err)
+    }
+    return saved
+}
@@ -13,10 +13,7 @@ import (
 
 // Login обрабатывает авторизацию админов
 func Login(c *gin.Context) {
-	var credentials struct {
-		Email    string `json:"email" binding:"required"`
-		Password string `json:"password" binding:"required"`
-	}
+	var credentials loginRequest
 	
 	if err := c.ShouldBindJSON(&credentials); err != nil {
 		log.Printf("Ошибка парсинга данных для авторизации: %v", err)
@@ -45,8 +42,8 @@ func Login(c *gin.Context) {
 	admin.PasswordHash = ""
 	
 	log.Printf("Успешная авторизация администратора: %s (ID: %s)", admin.Email, admin.ID)
-	c.JSON(http.StatusOK, gin.H{
-		"token": token,
-		"admin": admin,
+	c.JSON(http.StatusOK, loginResponse{
+		Token: token,
+		Admin: admin,
 	})
 }
\ No newline at end of file
@@ -0,0 +1,244 @@
+package handlers
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log"
+    "sync"
+    "time"
+
+    "github.com/google/uuid"
+
+    "github.com/egor/ecochatserver/channels"
+    "github.com/egor/ecochatserver/database"
+    "github.com/egor/ecochatserver/database/queries"
+    "github.com/egor/ecochatserver/models"
+    "github.com/egor/ecochatserver/websocket"
+)
+
+// Параметры очереди исходящей доставки
+const (
+    outboundMaxAttempts = 4
+    outboundBaseDelay   = 1 * time.Second
+    outboundMaxDelay    = 30 * time.Second
+
+    outboundPollInterval = 5 * time.Second
+    outboundBatchSize    = 20
+    outboundSendTimeout  = 30 * time.Second
+    // outboundClaimLease больше outboundSendTimeout, чтобы не отправить
+    // сообщение дважды
+    outboundClaimLease = time.Minute
+)
+
+// outboundTimeout ограничивает генерацию автоответа перед отправкой
+const outboundTimeout = 2 * time.Minute
+
+// Статусы доставки, записываемые в metadata.delivery
+const (
+    deliveryStatusPending = "pending"
+    deliveryStatusSent    = "sent"
+    deliveryStatusFailed  = "failed"
+)
+
+var outboundWake = make(chan struct{}, 1)
+
+// wakeOutbound будит RunOutbound, не дожидаясь очередного тика.
+func wakeOutbound() {
+    select {
+    case outboundWake <- struct{}{}:
+    default:
+    }
+}
+
+// deliverOutbound ставит сообщение оператора в очередь доставки во внешний
+// источник чата. Статус pending пишется в metadata до постановки в очередь,
+// чтобы не затереть результат уже выполненной попытки.
+func deliverOutbound(chatID uuid.UUID, msg *models.Message) {
+    chat, err := database.GetChatLightweight(chatID)
+    if err != nil {
+        log.Printf("deliverOutbound: ошибка загрузки чата %s: %v", chatID, err)
+        return
+    }
+
+    ch, ok := channels.Get(chat.Source)
+    if !ok || !ch.Capabilities().Outbound {
+        // Источник без исходящей доставки (виджет получает ответ по WebSocket)
+        return
+    }
+
+    saveDeliveryStatus(chatID, msg.ID, map[string]any{
+        "channel":  chat.Source,
+        "status":   deliveryStatusPending,
+        "attempts": 0,
+    })
+    if err := database.EnqueueOutbound(chatID, chat.Source, msg); err != nil {
+        log.Printf("deliverOutbound: ошибка постановки сообщения %s в очередь: %v", msg.ID, err)
+        saveDeliveryStatus(chatID, msg.ID, map[string]any{
+            "channel":  chat.Source,
+            "status":   deliveryStatusFailed,
+            "attempts": 0,
+            "error":    err.Error(),
+        })
+        return
+    }
+    wakeOutbound()
+}
+
+// RunOutbound отправляет сообщения из очереди outbound_deliveries до отмены
+// ctx. Очередь общая для узлов кластера и переживает перезапуск: доставка,
+// прерванная падением процесса, вернётся в очередь по истечении lease.
+func RunOutbound(ctx context.Context) {
+    ticker := time.NewTicker(outboundPollInterval)
+    defer ticker.Stop()
+
+    log.Println("[outbound] Доставка сообщений во внешние каналы запущена")
+    for {
+        // Забираем пачки, пока очередь не опустеет
+        for ctx.Err() == nil && processOutboundBatch(ctx) == outboundBatchSize {
+        }
+
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        case <-outboundWake:
+        }
+    }
+}
+
+// processOutboundBatch отправляет одну пачку доставок и возвращает её размер.
+func processOutboundBatch(ctx context.Context) int {
+    batch, err := database.ClaimOutboundDeliveries(outboundBatchSize, outboundClaimLease)
+    if err != nil {
+        log.Printf("[outbound] ошибка выборки очереди: %v", err)
+        return 0
+    }
+
+    var wg sync.WaitGroup
+    for i := range batch {
+        wg.Add(1)
+        go func(d *models.OutboundDelivery) {
+            defer wg.Done()
+            sendOutbound(ctx, d)
+        }(&batch[i])
+    }
+    wg.Wait()
+    return len(batch)
+}
+
+// sendOutbound выполняет одну попытку доставки и сохраняет её результат
+// в очередь и в metadata.delivery сообщения.
+func sendOutbound(ctx context.Context, d *models.OutboundDelivery) {
+    ctx, cancel := context.WithTimeout(ctx, outboundSendTimeout)
+    defer cancel()
+
+    msg := &d.Message
+    delivery := map[string]any{
+        "channel":  d.Channel,
+        "attempts": d.Attempts,
+    }
+
+    result, err := sendOutboundOnce(ctx, d)
+    status, lastErr := queries.OutboundDeliverySent, ""
+    var nextAt time.Time
+    if err == nil {
+        log.Printf("[outbound] сообщение %s доставлено в %s (externalId=%s)",
+            msg.ID, d.Channel, result.ExternalID)
+        delivery["status"] = deliveryStatusSent
+        delivery["externalId"] = result.ExternalID
+    } else {
+        lastErr = err.Error()
+        delivery["error"] = lastErr
+        if delay, retry := outboundRetryDelay(d.Attempts, err); retry {
+            log.Printf("[outbound] сообщение %s (%s), попытка %d/%d, повтор через %s: %v",
+                msg.ID, d.Channel, d.Attempts, outboundMaxAttempts, delay, err)
+            status, nextAt = queries.OutboundDeliveryPending, time.Now().Add(delay)
+            delivery["status"] = deliveryStatusPending
+            time.AfterFunc(delay, wakeOutbound)
+        } else {
+            log.Printf("[outbound] сообщение %s не доставлено в %s после %d попыток: %v",
+                msg.ID, d.Channel, d.Attempts, err)
+            status = queries.OutboundDeliveryFailed
+            delivery["status"] = deliveryStatusFailed
+        }
+    }
+
+    if err := database.CompleteOutboundDelivery(msg.ID, status, lastErr, nextAt); err != nil {
+        log.Printf("[outbound] сообщение %s: ошибка сохранения результата: %v", msg.ID, err)
+    }
+    saveDeliveryStatus(d.ChatID, msg.ID, delivery)
+}
+
+// sendOutboundOnce отправляет сообщение через адаптер канала чата.
+func sendOutboundOnce(ctx context.Context, d *models.OutboundDelivery) (*channels.SendResult, error) {
+    ch, ok := channels.Get(d.Channel)
+    if !ok {
+        return nil, channels.ErrSendNotSupported
+    }
+
+    chat, err := database.GetChatLightweight(d.ChatID)
+    if err != nil {
+        return nil, fmt.Errorf("загрузка чата: %w", err)
+    }
+
+    bot, err := database.GetBot(chat.ClientID, chat.Source, chat.BotID)
+    if err != nil && !errors.Is(err, database.ErrBotNotFound) {
+        return nil, fmt.Errorf("загрузка бота: %w", err)
+    }
+    if bot == nil {
+        log.Printf("[outbound] бот %s/%s клиента %s недоступен", chat.Source, chat.BotID, chat.ClientID)
+        return nil, channels.ErrMissingCredentials
+    }
+
+    target := channels.Target{Chat: chat, Bot: bot}
+    if target.LastInbound, err = database.GetLastUserMessage(d.ChatID); err != nil {
+        log.Printf("[outbound] ошибка загрузки последнего сообщения пользователя: %v", err)
+    }
+    return ch.Send(ctx, target, &d.Message)
+}
+
+// outboundRetryDelay решает, повторять ли отправку после неудачной попытки
+// attempt, и возвращает задержку: запрошенную источником или
+// экспоненциальную. Повторяются только временные ошибки.
+func outboundRetryDelay(attempt int, err error) (time.Duration, bool) {
+    if !channels.IsTemporary(err) || attempt >= outboundMaxAttempts {
+        return 0, false
+    }
+    if d := channels.RetryDelay(err); d > 0 {
+        return d, true
+    }
+
+    delay := outboundBaseDelay
+    for i := 1; i < attempt && delay < outboundMaxDelay; i++ {
+        delay *= 2
+    }
+    if delay > outboundMaxDelay {
+        delay = outboundMaxDelay
+    }
+    return delay, true
+}
+
+// saveDeliveryStatus пишет статус доставки в metadata и уведомляет клиентов чата.
+func saveDeliveryStatus(chatID, messageID uuid.UUID, delivery map[string]any) {
+    delivery["updatedAt"] = time.Now().Format(time.RFC3339)
+
+    if err := database.UpdateMessageMetadata(messageID, map[string]any{"delivery": delivery}); err != nil {
+        log.Printf("saveDeliveryStatus: ошибка записи статуса для %s: %v", messageID, err)
+    }
+    notifyDeliveryStatus(chatID, messageID, delivery)
+}
+
+// notifyDeliveryStatus отправляет событие deliveryStatus виджетам чата и админам его клиента.
+func notifyDeliveryStatus(chatID, messageID uuid.UUID, delivery map[string]any) {
+    statusMsg, err := websocket.NewMessage("deliveryStatus", deliveryStatusEvent{
+        ChatID:    chatID.String(),
+        MessageID: messageID.String(),
+        Delivery:  delivery,
+    })
+    if err != nil {
+        log.Printf("notifyDeliveryStatus: ошибка формирования уведомления: %v", err)
+        return
+    }
+    sendToChatID(chatID, statusMsg)
+}
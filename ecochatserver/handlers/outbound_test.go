@@ -0,0 +1,96 @@
+package handlers
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+
+    "github.com/egor/ecochatserver/channels"
+    "github.com/egor/ecochatserver/telegram"
+)
+
+// telegramStub отвечает на каждый запрос ответом reply.
+func telegramStub(t *testing.T, reply func(w http.ResponseWriter)) *telegram.Client {
+    t.Helper()
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        reply(w)
+    }))
+    t.Cleanup(srv.Close)
+    return telegram.NewClientWithURL(srv.URL, srv.Client())
+}
+
+func reply(status int, body string) func(w http.ResponseWriter) {
+    return func(w http.ResponseWriter) {
+        w.WriteHeader(status)
+        w.Write([]byte(body))
+    }
+}
+
+func TestOutboundRetryDelay(t *testing.T) {
+    tests := []struct {
+        name    string
+        reply   func(w http.ResponseWriter)
+        attempt int
+        delay   time.Duration
+        retry   bool
+    }{
+        {
+            name:    "permanent 4xx",
+            reply:   reply(http.StatusBadRequest, `{"ok":false,"error_code":400,"description":"chat not found"}`),
+            attempt: 1,
+        },
+        {
+            name:    "429 retry_after",
+            reply:   reply(http.StatusTooManyRequests, `{"ok":false,"error_code":429,"description":"Too Many Requests","parameters":{"retry_after":7}}`),
+            attempt: 1,
+            delay:   7 * time.Second,
+            retry:   true,
+        },
+        {
+            name:    "5xx first",
+            reply:   reply(http.StatusInternalServerError, `{"ok":false,"error_code":500,"description":"internal"}`),
+            attempt: 1,
+            delay:   outboundBaseDelay,
+            retry:   true,
+        },
+        {
+            name:    "5xx backoff",
+            reply:   reply(http.StatusInternalServerError, `{"ok":false,"error_code":500,"description":"internal"}`),
+            attempt: 3,
+            delay:   4 * outboundBaseDelay,
+            retry:   true,
+        },
+        {
+            name:    "exhausted",
+            reply:   reply(http.StatusInternalServerError, `{"ok":false,"error_code":500,"description":"internal"}`),
+            attempt: outboundMaxAttempts,
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            api := telegramStub(t, tt.reply)
+            _, err := api.SendMessage(context.Background(), "123:abc", "777", "привет")
+            if err == nil {
+                t.Fatal("ожидалась ошибка отправки")
+            }
+
+            delay, retry := outboundRetryDelay(tt.attempt, err)
+            if retry != tt.retry || delay != tt.delay {
+                t.Errorf("outboundRetryDelay = %v, %v; want %v, %v", delay, retry, tt.delay, tt.retry)
+            }
+        })
+    }
+}
+
+func TestOutboundRetryDelayCapped(t *testing.T) {
+    delay, retry := outboundRetryDelay(outboundMaxAttempts-1, errors.New("timeout"))
+    if !retry || delay > outboundMaxDelay {
+        t.Errorf("outboundRetryDelay = %v, %v; want повтор не позже %v", delay, retry, outboundMaxDelay)
+    }
+    if _, retry := outboundRetryDelay(1, channels.ErrMissingCredentials); retry {
+        t.Error("повтор без учётных данных бота")
+    }
+}
@@ -0,0 +1,280 @@
+package handlers
+
+import (
+    "time"
+
+    "github.com/egor/ecochatserver/llm"
+    "github.com/egor/ecochatserver/models"
+)
+
+// Типы тел запросов и ответов HTTP API и payload WebSocket-сообщений.
+// По ним генерируются OpenAPI/AsyncAPI документы (см. api_spec.go), поэтому
+// обработчики отвечают этими структурами, а не gin.H/map.
+
+// errorResponse — тело ответа с ошибкой
+type errorResponse struct {
+    Error string `json:"error"`
+    Code  string `json:"code,omitempty"`
+}
+
+// loginRequest — тело POST /api/auth/login
+type loginRequest struct {
+    Email    string `json:"email" binding:"required"`
+    Password string `json:"password" binding:"required"`
+}
+
+// loginResponse — JWT и профиль администратора
+type loginResponse struct {
+    Token string        `json:"token"`
+    Admin *models.Admin `json:"admin"`
+}
+
+// webhookResponse — ответ вебхука входящих сообщений канала
+type webhookResponse struct {
+    Status       string `json:"status"`
+    Message      string `json:"message,omitempty"`
+    MessageID    string `json:"message_id,omitempty"`
+    ChatID       string `json:"chat_id,omitempty"`
+    BotResponse  string `json:"bot_response,omitempty"`
+    BotMessageID string `json:"bot_message_id,omitempty"`
+    Timestamp    string `json:"timestamp,omitempty"`
+}
+
+// pageRequest — payload getChats
+type pageRequest struct {
+    Page     int    `json:"page"`
+    PageSize int    `json:"pageSize"`
+    Status   string `json:"status,omitempty" doc:"active | pending | closed; пусто — все"`
+}
+
+// chatPageRequest — payload getChatByID и getWidgetMessages
+type chatPageRequest struct {
+    ChatID   string `json:"chatID"`
+    Page     int    `json:"page"`
+    PageSize int    `json:"pageSize"`
+}
+
+// chatRequest — payload команд над одним чатом (markAsRead, claimChat, unassignChat)
+type chatRequest struct {
+    ChatID string `json:"chatID"`
+}
+
+// typingRequest — payload typing
+type typingRequest struct {
+    ChatID   string `json:"chatID"`
+    IsTyping bool   `json:"isTyping"`
+}
+
+// transferChatRequest — payload transferChat / тело POST /api/v1/chats/:id/transfer
+type transferChatRequest struct {
+    ChatID    string `json:"chatID"`
+    ToAdminID string `json:"toAdminID"`
+    Note      string `json:"note,omitempty"`
+}
+
+// chatAssignmentEvent — payload chatAssigned: кто и кому назначил чат
+type chatAssignmentEvent struct {
+    ChatID           string    `json:"chatID"`
+    Action           string    `json:"action" doc:"claim | transfer | unassign | route"`
+    AssignedTo       *string   `json:"assignedTo"`
+    PreviousAssignee *string   `json:"previousAssignee"`
+    AssignedBy       *string   `json:"assignedBy" doc:"null, если чат назначен автоматически"`
+    Note             string    `json:"note,omitempty"`
+    Timestamp        time.Time `json:"timestamp"`
+}
+
+// chatStatusRequest — payload setChatStatus / тело POST /api/v1/chats/:id/status
+type chatStatusRequest struct {
+    ChatID     string `json:"chatID"`
+    Status     string `json:"status" doc:"active | pending | closed"`
+    Resolution string `json:"resolution,omitempty" doc:"причина закрытия, по умолчанию resolved"`
+}
+
+// chatStatusEvent — payload chatStatusChanged
+type chatStatusEvent struct {
+    ChatID         string    `json:"chatID"`
+    Status         string    `json:"status"`
+    PreviousStatus string    `json:"previousStatus"`
+    Resolution     string    `json:"resolution,omitempty"`
+    ChangedBy      *string   `json:"changedBy" doc:"null — пользователь написал снова или автозакрытие"`
+    Timestamp      time.Time `json:"timestamp"`
+}
+
+// chatStatusResult — payload markAsReadConfirmed и messageDuplicate
+type chatStatusResult struct {
+    ChatID string `json:"chatID"`
+    Status string `json:"status"`
+}
+
+// messagesReadEvent — payload messagesRead
+type messagesReadEvent struct {
+    ChatID string `json:"chatID"`
+    ReadBy string `json:"readBy"`
+}
+
+// deliveryStatusEvent — payload deliveryStatus
+type deliveryStatusEvent struct {
+    ChatID    string         `json:"chatId"`
+    MessageID string         `json:"messageId"`
+    Delivery  map[string]any `json:"delivery"`
+}
+
+// chatUpdateMessage — сообщение внутри chat_update
+type chatUpdateMessage struct {
+    ID        string         `json:"id"`
+    Content   string         `json:"content"`
+    Sender    string         `json:"sender"`
+    Timestamp string         `json:"timestamp"`
+    Type      string         `json:"type"`
+    Metadata  map[string]any `json:"metadata,omitempty"`
+}
+
+// chatUpdateEvent — payload chat_update: сообщение пользователя и автоответ
+type chatUpdateEvent struct {
+    Type        string             `json:"type"`
+    ChatID      string             `json:"chatId"`
+    UserMessage *chatUpdateMessage `json:"userMessage"`
+    BotMessage  *chatUpdateMessage `json:"botMessage,omitempty"`
+    Timestamp   string             `json:"timestamp"`
+}
+
+// botDeltaEvent — payload bot_delta: фрагмент генерируемого автоответа.
+// Итоговое сообщение приходит в chat_update с тем же metadata.streamId.
+type botDeltaEvent struct {
+    ChatID   string `json:"chatId"`
+    StreamID string `json:"streamId"`
+    Delta    string `json:"delta"`
+    Done     bool   `json:"done,omitempty" doc:"генерация завершена"`
+    Aborted  bool   `json:"aborted,omitempty" doc:"ответ отменён, показанный текст нужно убрать"`
+}
+
+// widgetMessage — упрощённое сообщение для виджета
+type widgetMessage struct {
+    ID        string `json:"id"`
+    Content   string `json:"content"`
+    Sender    string `json:"sender"`
+    Timestamp string `json:"timestamp"`
+    Type      string `json:"type"`
+}
+
+// widgetMessagesResult — payload widgetMessages
+type widgetMessagesResult struct {
+    Messages   []widgetMessage `json:"messages"`
+    Page       int             `json:"page"`
+    PageSize   int             `json:"pageSize"`
+    TotalItems int             `json:"totalItems"`
+    TotalPages int             `json:"totalPages"`
+    ChatID     string          `json:"chatId"`
+    UserID     string          `json:"userId"`
+}
+
+// widgetInfoResponse — ответ устаревшего GET /api/widget/chat/:id/messages
+type widgetInfoResponse struct {
+    WebSocket struct {
+        URL    string `json:"url"`
+        ChatID string `json:"chatId"`
+        UserID string `json:"userId"`
+        Type   string `json:"type"`
+    } `json:"websocket"`
+    Message    string `json:"message"`
+    Deprecated string `json:"deprecated"`
+}
+
+// webhookListResponse — ответ GET /api/webhooks
+type webhookListResponse struct {
+    Webhooks []models.WebhookSubscription `json:"webhooks"`
+    Events   []string                     `json:"events"`
+}
+
+// webhookDeliveriesResponse — страница журнала доставок
+type webhookDeliveriesResponse struct {
+    Deliveries []models.WebhookDelivery `json:"deliveries"`
+    Page       int                      `json:"page"`
+    PageSize   int                      `json:"pageSize"`
+    TotalItems int                      `json:"totalItems"`
+    TotalPages int                      `json:"totalPages"`
+}
+
+// webhookRetryResponse — ответ повтора доставки
+type webhookRetryResponse struct {
+    ID     string `json:"id"`
+    Status string `json:"status"`
+}
+
+// routingResponse — настройки маршрутизации клиента и операторы
+type routingResponse struct {
+    Strategy        string                   `json:"strategy" doc:"пусто — стратегия сервера по умолчанию"`
+    DefaultStrategy string                   `json:"defaultStrategy"`
+    Strategies      []string                 `json:"strategies"`
+    Queued          int                      `json:"queued" doc:"неназначенные открытые чаты"`
+    Operators       []models.RoutingOperator `json:"operators"`
+}
+
+// routingStrategyRequest — тело PUT /api/routing
+type routingStrategyRequest struct {
+    Strategy string `json:"strategy"`
+}
+
+// operatorRoutingRequest — тело PUT /api/routing/operators/:id
+type operatorRoutingRequest struct {
+    Skills   []string `json:"skills"`
+    MaxChats *int     `json:"maxChats"`
+}
+
+// autoCloseSettings — порог автозакрытия неактивных чатов клиента
+type autoCloseSettings struct {
+    AutoCloseMinutes *int `json:"autoCloseMinutes" doc:"null — по умолчанию сервера, 0 — не закрывать"`
+    DefaultMinutes   int  `json:"defaultMinutes"`
+}
+
+// autoResponderSettingsResponse — настройки автоответчика клиента
+type autoResponderSettingsResponse struct {
+    Settings      *models.AutoResponderSettings `json:"settings" doc:"переопределения клиента; незаданные поля — по умолчанию сервера"`
+    Effective     llm.AutoResponderConfig       `json:"effective" doc:"действующий конфиг"`
+    ServerEnabled bool                          `json:"serverEnabled" doc:"false — автоответчик выключен на сервере (ENABLE_AUTO_RESPONDER)"`
+    Providers     []string                      `json:"providers" doc:"провайдеры LLM, доступные для settings.provider"`
+}
+
+// presenceRequest — payload setPresence
+type presenceRequest struct {
+    Status string `json:"status" doc:"online | away | busy"`
+}
+
+// presenceEvent — payload presence: статус оператора своего клиента
+type presenceEvent struct {
+    AdminID   string    `json:"adminId"`
+    Status    string    `json:"status" doc:"online | away | busy | offline"`
+    Auto      bool      `json:"auto" doc:"away выставлен по неактивности"`
+    Timestamp time.Time `json:"timestamp"`
+}
+
+// widgetAvailabilityResponse — ответ GET /api/widget/availability
+type widgetAvailabilityResponse struct {
+    Available bool `json:"available"`
+    Online    int  `json:"online"`
+}
+
+// ackRequest — payload ack: подтверждение событий до seq включительно
+type ackRequest struct {
+    Seq uint64 `json:"seq"`
+}
+
+// missedMessagesEvent — payload missedMessages: сообщения, пропущенные
+// за время обрыва, если сессию не удалось возобновить из буфера
+type missedMessagesEvent struct {
+    Since     time.Time        `json:"since"`
+    Messages  []models.Message `json:"messages"`
+    Truncated bool             `json:"truncated" doc:"сообщений больше лимита — загрузите чаты заново"`
+}
+
+// subscriptionRequest — payload subscribe / unsubscribe
+type subscriptionRequest struct {
+    ChatIDs []string `json:"chatIds"`
+    All     bool     `json:"all" doc:"все чаты своего клиента"`
+}
+
+// subscriptionsResult — ответ subscribe / unsubscribe: подписки после изменения
+type subscriptionsResult struct {
+    All     bool     `json:"all"`
+    ChatIDs []string `json:"chatIds"`
+}
@@ -0,0 +1,278 @@
+package handlers
+
+import (
+    "context"
+    "errors"
+    "log"
+    "net/http"
+    "sync"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+
+    "github.com/egor/ecochatserver/database"
+    "github.com/egor/ecochatserver/models"
+    websocketpkg "github.com/egor/ecochatserver/websocket"
+)
+
+const presenceCheckInterval = 30 * time.Second
+
+// Присутствие в кластере: узел раз в presenceHeartbeatInterval отмечается
+// в hub_nodes. Узел без heartbeat дольше database.NodeTTL считается
+// упавшим, его операторы — отключёнными.
+const presenceHeartbeatInterval = database.NodeTTL / 3
+
+// PresenceAwayAfter — через сколько без активности online-оператор
+// автоматически становится away (0 — не переводить)
+var PresenceAwayAfter = 5 * time.Minute
+
+// presenceEntry — присутствие подключённого оператора
+type presenceEntry struct {
+    clientID     uuid.UUID
+    status       string
+    auto         bool // away выставлен по неактивности
+    lastActivity time.Time
+}
+
+var presence = struct {
+    sync.Mutex
+    admins map[uuid.UUID]*presenceEntry
+}{admins: make(map[uuid.UUID]*presenceEntry)}
+
+// PresenceConnected отмечает сессию оператора на этом узле; оператор,
+// бывший offline, становится online. Если он уже подключён к другому узлу,
+// его статус сохраняется. Передаётся хабу как OnAdminConnect.
+func PresenceConnected(client *websocketpkg.Client) {
+    status, changed, err := database.AttachAdminNode(WebSocketHub.NodeID(), client.ID)
+    if err != nil {
+        log.Printf("PresenceConnected: %v", err)
+        status, changed = models.PresenceOnline, true
+    }
+
+    presence.Lock()
+    entry, ok := presence.admins[client.ID]
+    if !ok {
+        entry = &presenceEntry{clientID: client.ClientID, status: status}
+        presence.admins[client.ID] = entry
+    }
+    entry.lastActivity = time.Now()
+    if changed {
+        entry.status = models.PresenceOnline
+        entry.auto = false
+    }
+    presence.Unlock()
+
+    if changed {
+        broadcastPresence(client.ID, client.ClientID, models.PresenceOnline, false)
+    }
+}
+
+// PresenceDisconnected снимает отметку о сессиях оператора на этом узле,
+// когда у него здесь не осталось соединений. offline выставляется, только
+// если оператор не подключён к другим узлам кластера. Передаётся хабу как
+// OnAdminDisconnect.
+func PresenceDisconnected(client *websocketpkg.Client) {
+    // Оператор мог переподключиться, пока вызов ждал своей горутины
+    if WebSocketHub.AdminConnected(client.ID.String()) {
+        return
+    }
+    presence.Lock()
+    delete(presence.admins, client.ID)
+    presence.Unlock()
+
+    offline, err := database.DetachAdminNode(WebSocketHub.NodeID(), client.ID, database.NodeTTL)
+    if err != nil {
+        // Статус снимет истечение heartbeat, если узел упадёт
+        log.Printf("PresenceDisconnected: %v", err)
+        return
+    }
+    if offline {
+        broadcastPresence(client.ID, client.ClientID, models.PresenceOffline, false)
+    }
+}
+
+// RunPresenceHeartbeat отмечает узел живым и переводит в offline
+// операторов, оставшихся online только на упавших узлах, до отмены ctx.
+func RunPresenceHeartbeat(ctx context.Context) {
+    ticker := time.NewTicker(presenceHeartbeatInterval)
+    defer ticker.Stop()
+
+    for {
+        if err := database.HeartbeatNode(WebSocketHub.NodeID()); err != nil {
+            log.Printf("RunPresenceHeartbeat: %v", err)
+        }
+        expired, err := database.ExpirePresence(database.NodeTTL)
+        if err != nil {
+            log.Printf("RunPresenceHeartbeat: %v", err)
+        }
+        for _, p := range expired {
+            broadcastPresence(p.AdminID, p.ClientID, p.Status, false)
+        }
+
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+    }
+}
+
+// touchPresence отмечает активность оператора; away, выставленный по
+// неактивности, снимается.
+func touchPresence(client *websocketpkg.Client) {
+    if client.ClientType != actorAdmin {
+        return
+    }
+
+    presence.Lock()
+    entry, ok := presence.admins[client.ID]
+    if !ok {
+        presence.Unlock()
+        return
+    }
+    entry.lastActivity = time.Now()
+    back := entry.auto && entry.status == models.PresenceAway
+    if back {
+        entry.status = models.PresenceOnline
+        entry.auto = false
+    }
+    presence.Unlock()
+
+    if back {
+        publishPresence(client.ID, client.ClientID, models.PresenceOnline, false)
+    }
+}
+
+// setPresence выставляет статус, выбранный оператором.
+func setPresence(a *actor, status string) (*presenceEvent, error) {
+    if a.Kind != actorAdmin {
+        return nil, errChatAccessDenied
+    }
+    if !models.IsSettablePresence(status) {
+        return nil, errBadRequest("invalid_presence", "Неизвестный статус: "+status)
+    }
+
+    presence.Lock()
+    entry, ok := presence.admins[a.ID]
+    if ok {
+        entry.status = status
+        entry.auto = false
+        entry.lastActivity = time.Now()
+    }
+    presence.Unlock()
+    if !ok {
+        return nil, errBadRequest("not_connected", "Оператор не подключён")
+    }
+
+    // Сессия-инициатор получает presence в ответе на свою команду
+    return publishPresence(a.ID, a.ClientID, status, false, a.ConnID.String()), nil
+}
+
+// publishPresence сохраняет статус и рассылает presence админам того же
+// клиента, кроме сессий exceptSessions.
+func publishPresence(adminID, clientID uuid.UUID, status string, auto bool, exceptSessions ...string) *presenceEvent {
+    if err := database.SetAdminPresence(adminID, status); err != nil {
+        log.Printf("publishPresence: %v", err)
+    }
+    return broadcastPresence(adminID, clientID, status, auto, exceptSessions...)
+}
+
+// broadcastPresence рассылает уже сохранённый статус (см. publishPresence).
+func broadcastPresence(adminID, clientID uuid.UUID, status string, auto bool, exceptSessions ...string) *presenceEvent {
+    log.Printf("publishPresence: оператор %s → %s (auto=%v)", adminID, status, auto)
+
+    event := &presenceEvent{
+        AdminID:   adminID.String(),
+        Status:    status,
+        Auto:      auto,
+        Timestamp: time.Now(),
+    }
+    if msg, err := websocketpkg.NewMessage("presence", event); err == nil {
+        WebSocketHub.SendToAdminsOfClient(clientID.String(), msg, exceptSessions...)
+    }
+
+    if status == models.PresenceOnline {
+        wakeRouter()
+    }
+    return event
+}
+
+// RunPresenceIdle переводит неактивных online-операторов в away до отмены ctx.
+func RunPresenceIdle(ctx context.Context) {
+    if PresenceAwayAfter <= 0 {
+        return
+    }
+    ticker := time.NewTicker(presenceCheckInterval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+
+        type idle struct{ adminID, clientID uuid.UUID }
+        var away []idle
+
+        presence.Lock()
+        for id, entry := range presence.admins {
+            if entry.status == models.PresenceOnline && time.Since(entry.lastActivity) > PresenceAwayAfter {
+                entry.status = models.PresenceAway
+                entry.auto = true
+                away = append(away, idle{id, entry.clientID})
+            }
+        }
+        presence.Unlock()
+
+        for _, a := range away {
+            publishPresence(a.adminID, a.clientID, models.PresenceAway, true)
+        }
+    }
+}
+
+// WidgetAvailability — GET /api/widget/availability?chat_id=|client_id=
+// для индикатора «операторы на связи» в виджете. client_id — API-ключ или ID клиента.
+func WidgetAvailability(c *gin.Context) {
+    var clientID uuid.UUID
+    if chatIDStr := c.Query("chat_id"); chatIDStr != "" {
+        chatID, err := uuid.Parse(chatIDStr)
+        if err != nil {
+            c.JSON(http.StatusBadRequest, errorResponse{Error: "Некорректный формат chat_id"})
+            return
+        }
+        chat, err := database.GetChatLightweight(chatID)
+        if err != nil {
+            c.JSON(http.StatusNotFound, errorResponse{Error: "Чат не найден"})
+            return
+        }
+        clientID = chat.ClientID
+    } else if key := c.Query("client_id"); key != "" {
+        id, err := database.FindClientID(key)
+        if errors.Is(err, database.ErrClientNotFound) {
+            c.JSON(http.StatusNotFound, errorResponse{Error: "Клиент не найден"})
+            return
+        }
+        if err != nil {
+            log.Printf("WidgetAvailability: %v", err)
+            c.JSON(http.StatusInternalServerError, errorResponse{Error: "Ошибка получения клиента"})
+            return
+        }
+        clientID = id
+    } else {
+        c.JSON(http.StatusBadRequest, errorResponse{Error: "Необходим параметр chat_id или client_id"})
+        return
+    }
+
+    counts, err := database.GetOperatorAvailability(clientID)
+    if err != nil {
+        log.Printf("WidgetAvailability: %v", err)
+        c.JSON(http.StatusInternalServerError, errorResponse{Error: "Ошибка получения статусов операторов"})
+        return
+    }
+    c.JSON(http.StatusOK, widgetAvailabilityResponse{
+        Available: counts[models.PresenceOnline] > 0,
+        Online:    counts[models.PresenceOnline],
+    })
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+    "errors"
+    "log"
+    "net/http"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+
+    "github.com/egor/ecochatserver/database"
+    "github.com/egor/ecochatserver/database/queries"
+    "github.com/egor/ecochatserver/models"
+    "github.com/egor/ecochatserver/routing"
+    "github.com/egor/ecochatserver/webhooks"
+)
+
+// ChatRouter — движок автоматической маршрутизации (nil — отключена)
+var ChatRouter *routing.Router
+
+// wakeRouter просит роутер пересмотреть очередь.
+func wakeRouter() {
+    if ChatRouter != nil {
+        ChatRouter.Wake()
+    }
+}
+
+// OnChatRouted уведомляет оператора о назначенном роутером чате и
+// публикует chat.assigned. Передаётся в routing.NewRouter.
+func OnChatRouted(queued *models.QueuedChat, adminID uuid.UUID) {
+    event := newAssignmentEvent(queued.ID, queries.AssignmentRoute, &adminID, nil, nil, "")
+    notifyAssignment(event, "", &adminID)
+
+    chat, err := database.GetChatLightweight(queued.ID)
+    if err != nil {
+        log.Printf("OnChatRouted: ошибка загрузки чата %s: %v", queued.ID, err)
+        return
+    }
+    emitChatEvent(webhooks.EventChatAssigned, chat, map[string]interface{}{
+        "action": queries.AssignmentRoute,
+    })
+}
+
+// routingState собирает настройки маршрутизации клиента.
+func routingState(clientID uuid.UUID) (*routingResponse, error) {
+    strategies, err := database.GetRoutingStrategies([]string{clientID.String()})
+    if err != nil {
+        return nil, err
+    }
+    ops, err := database.ListClientOperators(clientID)
+    if err != nil {
+        return nil, err
+    }
+    queued, err := database.CountQueuedChats(clientID)
+    if err != nil {
+        return nil, err
+    }
+
+    if ops == nil {
+        ops = []models.RoutingOperator{}
+    }
+
+    resp := &routingResponse{
+        Strategy:   strategies[clientID],
+        Strategies: routing.Strategies,
+        Queued:     queued,
+        Operators:  ops,
+    }
+    if ChatRouter != nil {
+        resp.DefaultStrategy = ChatRouter.DefaultStrategy()
+    }
+    return resp, nil
+}
+
+// GetRouting возвращает стратегию маршрутизации, операторов с нагрузкой и размер очереди.
+func GetRouting(c *gin.Context) {
+    clientID, ok := requestClientID(c)
+    if !ok {
+        return
+    }
+
+    resp, err := routingState(clientID)
+    if err != nil {
+        log.Printf("GetRouting: %v", err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения настроек маршрутизации"})
+        return
+    }
+    c.JSON(http.StatusOK, resp)
+}
+
+// UpdateRouting меняет стратегию маршрутизации клиента
+// (пустая строка — стратегия сервера по умолчанию).
+func UpdateRouting(c *gin.Context) {
+    clientID, ok := requestClientID(c)
+    if !ok {
+        return
+    }
+
+    var req routingStrategyRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    if req.Strategy != "" && !routing.IsValidStrategy(req.Strategy) {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Неизвестная стратегия: " + req.Strategy})
+        return
+    }
+
+    if err := database.SetRoutingStrategy(clientID, req.Strategy); err != nil {
+        log.Printf("UpdateRouting: %v", err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка сохранения стратегии"})
+        return
+    }
+    log.Printf("UpdateRouting: клиент %s, стратегия %q", clientID, req.Strategy)
+    wakeRouter()
+
+    GetRouting(c)
+}
+
+// UpdateOperatorRouting меняет навыки и лимит открытых чатов оператора.
+func UpdateOperatorRouting(c *gin.Context) {
+    clientID, ok := requestClientID(c)
+    if !ok {
+        return
+    }
+    adminID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID оператора"})
+        return
+    }
+
+    var req operatorRoutingRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    if req.MaxChats == nil || *req.MaxChats < 0 {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Необходимо неотрицательное поле maxChats"})
+        return
+    }
+
+    skills := make([]string, 0, len(req.Skills))
+    for _, s := range req.Skills {
+        if s = strings.ToLower(strings.TrimSpace(s)); s != "" {
+            skills = append(skills, s)
+        }
+    }
+
+    err = database.UpdateOperatorRouting(clientID, adminID, skills, *req.MaxChats)
+    if errors.Is(err, database.ErrAdminNotFound) {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Оператор не найден"})
+        return
+    }
+    if err != nil {
+        log.Printf("UpdateOperatorRouting: %v", err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка сохранения настроек оператора"})
+        return
+    }
+    wakeRouter()
+
+    GetRouting(c)
+}
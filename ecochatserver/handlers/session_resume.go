@@ -0,0 +1,85 @@
+package handlers
+
+import (
+    "errors"
+    "log"
+    "strconv"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+
+    "github.com/egor/ecochatserver/database"
+    websocketpkg "github.com/egor/ecochatserver/websocket"
+)
+
+// missedMessagesLimit — сколько сообщений отдаётся при восстановлении из БД
+const missedMessagesLimit = 200
+
+// resumeParams разбирает ?session_id=&resume_from=&since=. Без session_id
+// соединение начинает новую сессию.
+func resumeParams(c *gin.Context) (*websocketpkg.ResumeRequest, error) {
+    raw := c.Query("session_id")
+    if raw == "" {
+        return nil, nil
+    }
+    sessionID, err := uuid.Parse(raw)
+    if err != nil {
+        return nil, errors.New("Некорректный session_id")
+    }
+    resume := &websocketpkg.ResumeRequest{SessionID: sessionID}
+
+    if v := c.Query("resume_from"); v != "" {
+        if resume.From, err = strconv.ParseUint(v, 10, 64); err != nil {
+            return nil, errors.New("Некорректный resume_from")
+        }
+    }
+    if v := c.Query("since"); v != "" {
+        if resume.Since, err = time.Parse(time.RFC3339, v); err != nil {
+            return nil, errors.New("Некорректный since (ожидается RFC3339)")
+        }
+    }
+    return resume, nil
+}
+
+// ReplayMissedMessages отправляет сообщения, созданные после Resume.Since,
+// если сессию не удалось возобновить из буфера хаба (например, клиент
+// переподключился к другому узлу). Передаётся хабу как OnResumeFailed.
+func ReplayMissedMessages(client *websocketpkg.Client) {
+    if client.Resume == nil || client.Resume.Since.IsZero() {
+        // Клиент получил session с resumed=false и перезагрузит данные сам
+        return
+    }
+
+    a, err := actorFromClient(client)
+    if err != nil {
+        log.Printf("ReplayMissedMessages: %v", err)
+        return
+    }
+    var chatID *uuid.UUID
+    if a.Kind == actorWidget {
+        chatID = &a.ChatID
+    }
+
+    list, err := database.GetMessagesSince(client.ClientID, chatID, visibleAssignee(a), client.Resume.Since, missedMessagesLimit+1)
+    if err != nil {
+        log.Printf("ReplayMissedMessages: %v", err)
+        return
+    }
+
+    event := missedMessagesEvent{Since: client.Resume.Since, Messages: list}
+    if len(list) > missedMessagesLimit {
+        event.Messages = list[:missedMessagesLimit]
+        event.Truncated = true
+    }
+
+    msg, err := websocketpkg.NewMessage("missedMessages", event)
+    if err != nil {
+        log.Printf("ReplayMissedMessages: %v", err)
+        return
+    }
+    client.Deliver(msg)
+
+    log.Printf("ReplayMissedMessages: сессия %s, %d сообщений с %s",
+        client.SessionID, len(event.Messages), client.Resume.Since.Format(time.RFC3339))
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+    "encoding/json"
+    "log"
+
+    "github.com/google/uuid"
+)
+
+// maxSubscribeChats — сколько чатов можно передать в одной команде subscribe
+const maxSubscribeChats = 100
+
+var errTooManyChats = errBadRequest("too_many_chats", "Слишком много чатов в одной подписке")
+
+// processSubscription обрабатывает subscribe и unsubscribe. Админ по
+// умолчанию подписан на все чаты своего клиента; отписавшись от них
+// (all=true), он получает события только выбранных чатов.
+func processSubscription(r *wsRequest, payload json.RawMessage) {
+    var p subscriptionRequest
+    if err := json.Unmarshal(payload, &p); err != nil {
+        r.fail("invalid_payload", "Некорректный формат данных для "+r.command)
+        return
+    }
+
+    a, err := actorFromClient(r.client)
+    if err != nil {
+        r.failErr(err)
+        return
+    }
+    if a.Kind != actorAdmin {
+        r.failErr(errChatAccessDenied)
+        return
+    }
+    if p.All && r.command == "subscribe" && !seesAllChats(a) {
+        // Оператору доступны не все чаты клиента (CHAT_ACCESS_MODE=assigned)
+        r.failErr(errChatAccessDenied)
+        return
+    }
+    if len(p.ChatIDs) > maxSubscribeChats {
+        r.failErr(errTooManyChats)
+        return
+    }
+
+    chatIDs := make([]string, 0, len(p.ChatIDs))
+    for _, raw := range p.ChatIDs {
+        chatID, err := uuid.Parse(raw)
+        if err != nil {
+            r.fail("invalid_uuid", "Некорректный формат chatID: "+raw)
+            return
+        }
+        // Подписаться можно только на чаты своего клиента; отписка от
+        // чужого или удалённого чата безвредна
+        if r.command == "subscribe" {
+            if _, err := authorizeChat(a, chatID); err != nil {
+                r.failErr(err)
+                return
+            }
+        }
+        chatIDs = append(chatIDs, chatID.String())
+    }
+
+    if r.command == "subscribe" {
+        WebSocketHub.Subscribe(r.client, chatIDs, p.All)
+    } else {
+        WebSocketHub.Unsubscribe(r.client, chatIDs, p.All)
+    }
+
+    all, current := WebSocketHub.Subscriptions(r.client)
+    log.Printf("processSubscription: %s оператора %s (сессия %s): all=%v, чатов %d",
+        r.command, a.ID, r.client.SessionID, all, len(current))
+    r.reply("subscriptions", subscriptionsResult{All: all, ChatIDs: current})
+}
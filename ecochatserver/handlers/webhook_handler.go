@@ -0,0 +1,259 @@
+package handlers
+
+import (
+    "context"
+    "errors"
+    "log"
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+
+    "github.com/egor/ecochatserver/database"
+    "github.com/egor/ecochatserver/database/queries"
+    "github.com/egor/ecochatserver/models"
+    "github.com/egor/ecochatserver/webhooks"
+)
+
+// webhookRequest — тело создания/изменения подписки
+type webhookRequest struct {
+    URL    string   `json:"url"`
+    Secret string   `json:"secret"`
+    Events []string `json:"events"`
+    Active *bool    `json:"active"`
+}
+
+// validate проверяет URL и типы событий. Адреса внутренней сети
+// (loopback, RFC 1918, link-local, метаданные облака) отклоняются.
+func (r *webhookRequest) validate(ctx context.Context) string {
+    if err := webhooks.CheckURL(ctx, r.URL); err != nil {
+        if errors.Is(err, webhooks.ErrForbiddenTarget) {
+            return "URL вебхука указывает на внутренний адрес"
+        }
+        return "Некорректный URL вебхука"
+    }
+    for _, e := range r.Events {
+        if !webhooks.IsValidEvent(e) {
+            return "Неизвестный тип события: " + e
+        }
+    }
+    return ""
+}
+
+// requestClientID возвращает ID клиента из JWT (см. middleware.AuthMiddleware).
+func requestClientID(c *gin.Context) (uuid.UUID, bool) {
+    clientID, err := uuid.Parse(c.GetString("clientID"))
+    if err != nil {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "Некорректный clientID в токене"})
+        return uuid.Nil, false
+    }
+    return clientID, true
+}
+
+// ListWebhooks возвращает подписки клиента на исходящие вебхуки.
+func ListWebhooks(c *gin.Context) {
+    clientID, ok := requestClientID(c)
+    if !ok {
+        return
+    }
+
+    subs, err := database.ListWebhookSubscriptions(clientID)
+    if err != nil {
+        log.Printf("ListWebhooks: %v", err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения подписок"})
+        return
+    }
+    // Секрет показываем только при создании
+    for i := range subs {
+        subs[i].Secret = ""
+    }
+
+    c.JSON(http.StatusOK, webhookListResponse{Webhooks: subs, Events: webhooks.Events})
+}
+
+// CreateWebhook создаёт подписку. Если секрет не передан, он генерируется
+// и возвращается в ответе один раз.
+func CreateWebhook(c *gin.Context) {
+    clientID, ok := requestClientID(c)
+    if !ok {
+        return
+    }
+
+    var req webhookRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    if msg := req.validate(c.Request.Context()); msg != "" {
+        c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+        return
+    }
+
+    sub := &models.WebhookSubscription{
+        ClientID: clientID,
+        URL:      req.URL,
+        Secret:   req.Secret,
+        Events:   req.Events,
+        Active:   req.Active == nil || *req.Active,
+    }
+    if sub.Secret == "" {
+        secret, err := webhooks.GenerateSecret()
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка генерации секрета"})
+            return
+        }
+        sub.Secret = secret
+    }
+
+    if err := database.CreateWebhookSubscription(sub); err != nil {
+        log.Printf("CreateWebhook: %v", err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка создания подписки"})
+        return
+    }
+
+    log.Printf("CreateWebhook: клиент %s подписался на %v → %s", clientID, sub.Events, sub.URL)
+    c.JSON(http.StatusCreated, sub)
+}
+
+// UpdateWebhook меняет URL, события, активность и (опционально) секрет подписки.
+func UpdateWebhook(c *gin.Context) {
+    clientID, ok := requestClientID(c)
+    if !ok {
+        return
+    }
+    id, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID подписки"})
+        return
+    }
+
+    sub, err := database.GetWebhookSubscription(clientID, id)
+    if errors.Is(err, database.ErrWebhookNotFound) {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Подписка не найдена"})
+        return
+    }
+    if err != nil {
+        log.Printf("UpdateWebhook: %v", err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения подписки"})
+        return
+    }
+
+    // Незаданные поля оставляем как есть
+    req := webhookRequest{URL: sub.URL, Events: sub.Events}
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    if msg := req.validate(c.Request.Context()); msg != "" {
+        c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+        return
+    }
+
+    sub.URL = req.URL
+    sub.Events = req.Events
+    sub.Secret = req.Secret
+    if req.Active != nil {
+        sub.Active = *req.Active
+    }
+
+    if err := database.UpdateWebhookSubscription(sub); err != nil {
+        log.Printf("UpdateWebhook: %v", err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка обновления подписки"})
+        return
+    }
+
+    sub.Secret = ""
+    c.JSON(http.StatusOK, sub)
+}
+
+// DeleteWebhook удаляет подписку и её журнал доставок.
+func DeleteWebhook(c *gin.Context) {
+    clientID, ok := requestClientID(c)
+    if !ok {
+        return
+    }
+    id, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID подписки"})
+        return
+    }
+
+    err = database.DeleteWebhookSubscription(clientID, id)
+    if errors.Is(err, database.ErrWebhookNotFound) {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Подписка не найдена"})
+        return
+    }
+    if err != nil {
+        log.Printf("DeleteWebhook: %v", err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка удаления подписки"})
+        return
+    }
+
+    c.Status(http.StatusNoContent)
+}
+
+// ListWebhookDeliveries возвращает журнал доставок подписки.
+// Параметры: status (pending|delivered|failed), page, pageSize.
+func ListWebhookDeliveries(c *gin.Context) {
+    clientID, ok := requestClientID(c)
+    if !ok {
+        return
+    }
+    id, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID подписки"})
+        return
+    }
+
+    status := c.Query("status")
+    switch status {
+    case "", queries.WebhookDeliveryPending, queries.WebhookDeliveryDelivered, queries.WebhookDeliveryFailed:
+    default:
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный статус: " + status})
+        return
+    }
+
+    page, size := normalizePage(pageParams(c))
+
+    list, total, err := database.ListWebhookDeliveries(clientID, &id, status, page, size)
+    if err != nil {
+        log.Printf("ListWebhookDeliveries: %v", err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения журнала доставок"})
+        return
+    }
+
+    c.JSON(http.StatusOK, webhookDeliveriesResponse{
+        Deliveries: list,
+        Page:       page,
+        PageSize:   size,
+        TotalItems: total,
+        TotalPages: totalPages(total, size),
+    })
+}
+
+// RetryWebhookDelivery ставит доставку в очередь повторно (например, после
+// исправления URL или когда попытки исчерпаны).
+func RetryWebhookDelivery(c *gin.Context) {
+    clientID, ok := requestClientID(c)
+    if !ok {
+        return
+    }
+    deliveryID, err := uuid.Parse(c.Param("deliveryId"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID доставки"})
+        return
+    }
+
+    err = database.RetryWebhookDelivery(clientID, deliveryID)
+    if errors.Is(err, database.ErrWebhookDeliveryNotFound) {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Доставка не найдена"})
+        return
+    }
+    if err != nil {
+        log.Printf("RetryWebhookDelivery: %v", err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка повтора доставки"})
+        return
+    }
+
+    c.JSON(http.StatusAccepted, webhookRetryResponse{ID: deliveryID.String(), Status: queries.WebhookDeliveryPending})
+}
@@ -1,23 +1,20 @@
 package handlers
 
 import (
-    "context"
+    "database/sql"
     "encoding/json"
-    "fmt"
+    "errors"
     "log"
     "net/http"
     "os"
     "strings"
-    "time"
 
     "github.com/gin-gonic/gin"
     "github.com/google/uuid"
     "github.com/gorilla/websocket"
 
     "github.com/egor/ecochatserver/database"
-    "github.com/egor/ecochatserver/database/queries"
     "github.com/egor/ecochatserver/middleware"
-    "github.com/egor/ecochatserver/models"
     websocketpkg "github.com/egor/ecochatserver/websocket"
 )
 
@@ -92,9 +89,15 @@ func ServeWs(c *gin.Context) {
         return
     }
 
+    resume, err := resumeParams(c)
+    if err != nil {
+        log.Printf("ServeWs: некорректные параметры возобновления: %v", err)
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
     // Проверяем токен для админа
     var adminID, clientID, chatID uuid.UUID
-    var err error
 
     if clientType == "admin" && token != "" {
         // Валидируем JWT токен
@@ -140,7 +143,21 @@ func ServeWs(c *gin.Context) {
             adminID, _ = uuid.Parse(userIDStr)
         }
         
-        log.Printf("ServeWs: подключение виджета, chatID: %s, userID: %s", chatID, adminID)
+        // Клиент чата нужен хабу, чтобы события не выходили за его пределы
+        chat, err := database.GetChatLightweight(chatID)
+        if errors.Is(err, sql.ErrNoRows) {
+            log.Printf("ServeWs: чат %s не найден", chatID)
+            c.JSON(http.StatusNotFound, gin.H{"error": "Чат не найден"})
+            return
+        }
+        if err != nil {
+            log.Printf("ServeWs: ошибка получения чата: %v", err)
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения чата"})
+            return
+        }
+        clientID = chat.ClientID
+        
+        log.Printf("ServeWs: подключение виджета, chatID: %s, userID: %s, client: %s", chatID, adminID, clientID)
     } else {
         log.Printf("ServeWs: неверный тип клиента или отсутствует токен")
         c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный тип клиента или отсутствует токен"})
@@ -157,6 +174,17 @@ func ServeWs(c *gin.Context) {
     // Создаем нового клиента
     client := websocketpkg.NewClient(WebSocketHub, conn, clientType, adminID, chatID)
     client.Context = c
+    client.ClientID = clientID
+    client.UserAgent = c.Request.UserAgent()
+    client.Device = c.Query("device")
+    if client.Device == "" {
+        client.Device = websocketpkg.DetectDevice(client.UserAgent)
+    }
+    client.Resume = resume
+    if clientType == "admin" {
+        a, err := actorFromContext(c)
+        client.FollowAllChats = err == nil && seesAllChats(a)
+    }
 
     // Регистрируем клиента в хабе
     WebSocketHub.Register <- client
@@ -165,12 +193,41 @@ func ServeWs(c *gin.Context) {
     go client.WritePump()
     go client.ReadPump(processWebSocketMessage)
 
-    // Отправляем статус подключения
-    WebSocketHub.SendConnectionStatus(client, true)
+    // Отправляем статус подключения виджета (присутствие операторов
+    // рассылается через presence, см. PresenceConnected)
+    if clientType == "widget" {
+        WebSocketHub.SendConnectionStatus(client, true)
+    }
     
     log.Printf("ServeWs: клиент %s успешно подключен", client.ID)
 }
 
+// wsRequest — входящая команда WebSocket. Ответы и ошибки, отправленные
+// через неё, несут requestId команды (если клиент его передал) и status.
+type wsRequest struct {
+    client  *websocketpkg.Client
+    id      string
+    command string
+}
+
+// reply отправляет успешный ответ на команду.
+func (r *wsRequest) reply(msgType string, payload interface{}) {
+    if err := r.client.Reply(r.id, msgType, payload); err != nil {
+        log.Printf("%s: ошибка отправки ответа %s: %v", r.command, msgType, err)
+    }
+}
+
+// fail отправляет ошибку команды.
+func (r *wsRequest) fail(code, message string) {
+    r.client.ReplyError(r.id, r.command, code, message)
+}
+
+// failErr отправляет ошибку сервисного слоя (см. serviceError).
+func (r *wsRequest) failErr(err error) {
+    se := asServiceError(err)
+    r.fail(se.Code, se.Message)
+}
+
 // processWebSocketMessage обрабатывает входящие WebSocket сообщения
 func processWebSocketMessage(client *websocketpkg.Client, raw []byte) {
     var msg websocketpkg.WebSocketMessage
@@ -178,406 +235,297 @@ func processWebSocketMessage(client *websocketpkg.Client, raw []byte) {
         client.SendError("invalid_json", "Некорректный формат JSON")
         return
     }
+    r := &wsRequest{client: client, id: msg.RequestID, command: msg.Type}
 
-    // Получаем данные из контекста Gin
-    ginCtx := client.Context
+    // Любая команда оператора — признак активности (для автоматического away)
+    touchPresence(client)
 
     switch msg.Type {
     case "getChats":
-        processGetChats(client, msg.Payload, ginCtx)
+        processGetChats(r, msg.Payload)
     case "getChatByID":
-        processGetChatByID(client, msg.Payload, ginCtx)
+        processGetChatByID(r, msg.Payload)
     case "sendMessage":
-        processSendMessage(client, msg.Payload, ginCtx)
+        processSendMessage(r, msg.Payload)
     case "markAsRead":
-        processMarkAsRead(client, msg.Payload, ginCtx)
+        processMarkAsRead(r, msg.Payload)
     case "typing":
-        processTypingStatus(client, msg.Payload, ginCtx)
+        processTypingStatus(r, msg.Payload)
     case "getWidgetMessages":
-        processGetWidgetMessages(client, msg.Payload, ginCtx)
+        processGetWidgetMessages(r, msg.Payload)
+    case "claimChat", "unassignChat":
+        processChatAssignment(r, msg.Payload)
+    case "transferChat":
+        processTransferChat(r, msg.Payload)
+    case "setChatStatus":
+        processSetChatStatus(r, msg.Payload)
+    case "setPresence":
+        processSetPresence(r, msg.Payload)
+    case "activity":
+        // Только отметка активности оператора (см. touchPresence)
+    case "ack":
+        processAck(r, msg.Payload)
+    case "subscribe", "unsubscribe":
+        processSubscription(r, msg.Payload)
     default:
-        client.SendError("unknown_type", "Неизвестный тип сообщения: "+msg.Type)
+        r.fail("unknown_type", "Неизвестный тип сообщения: "+msg.Type)
     }
 }
 
 // processSendMessage обрабатывает отправку сообщений с автоответчиком
-func processSendMessage(client *websocketpkg.Client, payload json.RawMessage, ginCtx *gin.Context) {
-    var p struct {
-        ChatID  string                 `json:"chatID"`
-        Content string                 `json:"content"`
-        Type    string                 `json:"type"`
-        Metadata map[string]interface{} `json:"metadata,omitempty"`
-    }
+func processSendMessage(r *wsRequest, payload json.RawMessage) {
+    var p sendMessageRequest
     if err := json.Unmarshal(payload, &p); err != nil {
-        client.SendError("invalid_payload", "Некорректный формат данных для sendMessage")
+        r.fail("invalid_payload", "Некорректный формат данных для sendMessage")
         return
     }
 
-    // Проверяем обязательные поля
-    if p.ChatID == "" || p.Content == "" {
-        client.SendError("missing_fields", "Необходимы поля chatID и content")
+    a, err := actorFromClient(r.client)
+    if err != nil {
+        r.failErr(err)
         return
     }
-    
-    // Устанавливаем тип сообщения по умолчанию
-    if p.Type == "" {
-        p.Type = "text"
-    }
 
-    // Парсим chatID
-    chatID, err := uuid.Parse(p.ChatID)
+    message, err := sendMessage(a, &p)
+    if errors.Is(err, errDuplicateMessage) {
+        // Отправляем подтверждение, но не обрабатываем повторно
+        r.reply("messageDuplicate", chatStatusResult{
+            ChatID: p.ChatID,
+            Status: "ignored",
+        })
+        return
+    }
     if err != nil {
-        client.SendError("invalid_uuid", "Некорректный формат chatID")
+        r.failErr(err)
         return
     }
 
-    // Определяем отправителя в зависимости от типа клиента
-    var senderID uuid.UUID
-    var sender string
-    
-    if client.ClientType == "admin" {
-        // Для админа берем ID из контекста аутентификации
-        adminIDStr, exists := ginCtx.Get("adminID")
-        if !exists {
-            client.SendError("auth_error", "Не удалось получить ID администратора")
-            return
-        }
-        adminID, err := uuid.Parse(adminIDStr.(string))
-        if err != nil {
-            client.SendError("invalid_uuid", "Некорректный adminID")
-            return
-        }
-        senderID = adminID
-        sender = "admin"
-    } else {
-        // Для виджета используем ID пользователя
-        senderID = client.ID
-        sender = "user"
-    }
+    // Отправляем подтверждение отправителю
+    r.reply("messageSent", sendMessageResult{
+        MessageID: message.ID.String(),
+        Timestamp: message.Timestamp,
+        Status:    "delivered",
+    })
+}
 
-    // Простая дедупликация для WebSocket
-    messageHash := fmt.Sprintf("%s_%s_%s_%d", 
-        client.ID, 
-        p.ChatID, 
-        p.Content,
-        time.Now().Unix())
-    
-    if isRecentMessage(messageHash) {
-        log.Printf("processSendMessage: дублирующее сообщение от клиента %s", client.ID)
-        // Отправляем подтверждение, но не обрабатываем повторно
-        response := map[string]interface{}{
-            "type": "messageDuplicate",
-            "payload": map[string]interface{}{
-                "chatID": p.ChatID,
-                "status": "ignored",
-            },
-        }
-        client.SendJSON(response)
+func processGetChats(r *wsRequest, payload json.RawMessage) {
+    var p pageRequest
+    if err := json.Unmarshal(payload, &p); err != nil {
+        r.fail("invalid_payload", "Некорректный формат данных для getChats")
         return
     }
-    registerMessage(messageHash)
 
-    // Добавляем сообщение в базу
-    log.Printf("processSendMessage: добавление сообщения в чат %s от %s (%s): %s", 
-        chatID, sender, senderID, p.Content)
-        
-    message, err := database.AddMessage(
-        chatID, 
-        p.Content, 
-        sender, 
-        senderID, 
-        p.Type, 
-        p.Metadata,
-    )
+    a, err := actorFromClient(r.client)
     if err != nil {
-        log.Printf("processSendMessage: ошибка добавления сообщения: %v", err)
-        client.SendError("db_error", "Ошибка при отправке сообщения: "+err.Error())
+        r.failErr(err)
         return
     }
 
-    // Быстро обновляем время чата
-    if err := queries.UpdateChatTimestamp(database.DB, chatID); err != nil {
-        log.Printf("processSendMessage: ошибка обновления времени: %v", err)
-    }
-    
-    // ОБРАБОТКА АВТООТВЕТЧИКА
-    var botMsg *models.Message
-    if sender == "user" && AutoResponder != nil {
-        go func() {
-            // Асинхронная обработка автоответчика
-            lightChat, err := queries.GetChatLightweight(database.DB, chatID)
-            if err != nil {
-                log.Printf("processSendMessage: ошибка загрузки чата для автоответчика: %v", err)
-                return
-            }
-            
-            botMsg, err := AutoResponder.ProcessMessage(ginCtx.Request.Context(), lightChat, message)
-            if err != nil {
-                log.Printf("processSendMessage: ошибка автоответчика: %v", err)
-                return
-            }
-            
-            if botMsg != nil {
-                // Сохраняем автоответ
-                saved, err := database.AddMessage(
-                    chatID,
-                    botMsg.Content,
-                    botMsg.Sender,
-                    botMsg.SenderID,
-                    botMsg.Type,
-                    botMsg.Metadata,
-                )
-                if err != nil {
-                    log.Printf("processSendMessage: ошибка сохранения автоответа: %v", err)
-                } else {
-                    botMsg = saved
-                    
-                    // Обновляем время чата
-                    if err := queries.UpdateChatTimestamp(database.DB, chatID); err != nil {
-                        log.Printf("processSendMessage: ошибка обновления времени: %v", err)
-                    }
-                    
-                    // Отправляем ОДНО комплексное сообщение
-                    notification := createChatNotification(chatID, message, botMsg)
-                    WebSocketHub.SendToChat(chatID.String(), notification)
-                }
-            }
-        }()
-    } else {
-        // Отправляем только сообщение пользователя
-        notification := createChatNotification(chatID, message, nil)
-        WebSocketHub.SendToChat(chatID.String(), notification)
-    }
-    
-    log.Printf("processSendMessage: сообщение успешно отправлено (ID=%s)", message.ID)
-    
-    // Отправляем подтверждение отправителю
-    response := map[string]interface{}{
-        "type": "messageSent",
-        "payload": map[string]interface{}{
-            "messageID": message.ID.String(),
-            "timestamp": message.Timestamp,
-            "status":    "delivered",
-        },
-    }
-    
-    if err := client.SendJSON(response); err != nil {
-        log.Printf("processSendMessage: ошибка отправки подтверждения: %v", err)
+    list, err := listChats(a, p.Status, p.Page, p.PageSize)
+    if err != nil {
+        r.failErr(err)
+        return
     }
+
+    r.reply("chatsList", list)
 }
 
-// Остальные обработчики остаются без изменений
-func processGetChats(client *websocketpkg.Client, payload json.RawMessage, ginCtx *gin.Context) {
-    var p struct {
-        Page     int `json:"page"`
-        PageSize int `json:"pageSize"`
-    }
+func processGetChatByID(r *wsRequest, payload json.RawMessage) {
+    var p chatPageRequest
     if err := json.Unmarshal(payload, &p); err != nil {
-        client.SendError("invalid_payload", "Некорректный формат данных для getChats")
+        r.fail("invalid_payload", "Некорректный формат данных для getChatByID")
         return
     }
 
-    // Устанавливаем дефолтные значения
-    if p.Page < 1 {
-        p.Page = 1
-    }
-    if p.PageSize < 1 || p.PageSize > database.MaxPageSize {
-        p.PageSize = database.DefaultPageSize
+    chatID, err := uuid.Parse(p.ChatID)
+    if err != nil {
+        r.fail("invalid_uuid", "Некорректный формат chatID")
+        return
     }
 
-    // Получаем ID из контекста
-    adminIDStr, _ := ginCtx.Get("adminID")
-    clientIDStr, _ := ginCtx.Get("clientID")
-    
-    adminID, err := uuid.Parse(adminIDStr.(string))
+    a, err := actorFromClient(r.client)
     if err != nil {
-        client.SendError("invalid_uuid", "Некорректный adminID")
+        r.failErr(err)
         return
     }
-    
-    clientID, err := uuid.Parse(clientIDStr.(string))
+
+    details, err := getChat(a, chatID, p.Page, p.PageSize)
     if err != nil {
-        client.SendError("invalid_uuid", "Некорректный clientID")
+        r.failErr(err)
         return
     }
 
-    // Получаем чаты
-    log.Printf("processGetChats: запрос чатов для admin=%s, client=%s, page=%d, size=%d", 
-        adminID, clientID, p.Page, p.PageSize)
-        
-    chats, total, err := database.GetChats(clientID, adminID, p.Page, p.PageSize)
-    if err != nil {
-        log.Printf("processGetChats: ошибка получения чатов: %v", err)
-        client.SendError("db_error", "Ошибка получения чатов: "+err.Error())
+    r.reply("chatDetails", details)
+}
+
+func processMarkAsRead(r *wsRequest, payload json.RawMessage) {
+    var p chatRequest
+    if err := json.Unmarshal(payload, &p); err != nil {
+        r.fail("invalid_payload", "Некорректный формат данных для markAsRead")
         return
     }
 
-    // Рассчитываем общее количество страниц
-    totalPages := (total + p.PageSize - 1) / p.PageSize
-    if totalPages < 1 {
-        totalPages = 1
+    chatID, err := uuid.Parse(p.ChatID)
+    if err != nil {
+        r.fail("invalid_uuid", "Некорректный формат chatID")
+        return
     }
 
-    // Формируем ответ
-    response := map[string]interface{}{
-        "type": "chatsList",
-        "payload": models.ChatPaginationResponse{
-            Chats:      chats,
-            Page:       p.Page,
-            PageSize:   p.PageSize,
-            TotalItems: total,
-            TotalPages: totalPages,
-        },
+    a, err := actorFromClient(r.client)
+    if err != nil {
+        r.failErr(err)
+        return
     }
-    
-    log.Printf("processGetChats: найдено %d чатов из %d всего", len(chats), total)
-    
-    // Отправляем ответ
-    if err := client.SendJSON(response); err != nil {
-        log.Printf("processGetChats: ошибка отправки ответа: %v", err)
+
+    if err := markAsRead(a, chatID); err != nil {
+        r.failErr(err)
+        return
     }
+
+    // Отправляем подтверждение отправителю запроса
+    r.reply("markAsReadConfirmed", chatStatusResult{
+        ChatID: chatID.String(),
+        Status: "success",
+    })
 }
 
-func processGetChatByID(client *websocketpkg.Client, payload json.RawMessage, ginCtx *gin.Context) {
-    var p struct {
-        ChatID   string `json:"chatID"`
-        Page     int    `json:"page"`
-        PageSize int    `json:"pageSize"`
-    }
+// processChatAssignment обрабатывает claimChat и unassignChat. Инициатор
+// получает то же сообщение chatAssigned, что и остальные затронутые операторы.
+func processChatAssignment(r *wsRequest, payload json.RawMessage) {
+    var p chatRequest
     if err := json.Unmarshal(payload, &p); err != nil {
-        client.SendError("invalid_payload", "Некорректный формат данных для getChatByID")
+        r.fail("invalid_payload", "Некорректный формат данных для "+r.command)
         return
     }
 
-    // Устанавливаем дефолтные значения
-    if p.Page < 1 {
-        p.Page = 1
-    }
-    if p.PageSize < 1 || p.PageSize > database.MaxPageSize {
-        p.PageSize = database.DefaultPageSize
-    }
-
-    // Парсим ID чата
     chatID, err := uuid.Parse(p.ChatID)
     if err != nil {
-        client.SendError("invalid_uuid", "Некорректный формат chatID")
+        r.fail("invalid_uuid", "Некорректный формат chatID")
         return
     }
 
-    // Получаем чат и его сообщения
-    log.Printf("processGetChatByID: запрос чата ID=%s, page=%d, size=%d", 
-        chatID, p.Page, p.PageSize)
-        
-    chat, total, err := database.GetChatByID(chatID, p.Page, p.PageSize)
+    a, err := actorFromClient(r.client)
     if err != nil {
-        log.Printf("processGetChatByID: ошибка получения чата: %v", err)
-        client.SendError("db_error", "Ошибка получения чата: "+err.Error())
+        r.failErr(err)
         return
     }
 
-    // Отмечаем сообщения как прочитанные
-    if client.ClientType == "admin" {
-        if err := database.MarkMessagesAsRead(chatID); err != nil {
-            log.Printf("processGetChatByID: ошибка маркировки сообщений: %v", err)
-        }
+    var event *chatAssignmentEvent
+    if r.command == "claimChat" {
+        event, err = claimChat(a, chatID)
+    } else {
+        event, err = unassignChat(a, chatID)
     }
+    if err != nil {
+        r.failErr(err)
+        return
+    }
+    r.reply("chatAssigned", event)
+}
 
-    // Рассчитываем общее количество страниц
-    totalPages := (total + p.PageSize - 1) / p.PageSize
-    if totalPages < 1 {
-        totalPages = 1
+func processTransferChat(r *wsRequest, payload json.RawMessage) {
+    var p transferChatRequest
+    if err := json.Unmarshal(payload, &p); err != nil {
+        r.fail("invalid_payload", "Некорректный формат данных для transferChat")
+        return
     }
 
-    // Формируем ответ
-    response := map[string]interface{}{
-        "type": "chatDetails",
-        "payload": map[string]interface{}{
-            "chat":       chat,
-            "page":       p.Page,
-            "pageSize":   p.PageSize,
-            "totalItems": total,
-            "totalPages": totalPages,
-        },
+    a, err := actorFromClient(r.client)
+    if err != nil {
+        r.failErr(err)
+        return
     }
-    
-    log.Printf("processGetChatByID: найден чат с %d сообщениями", len(chat.Messages))
-    
-    // Отправляем ответ
-    if err := client.SendJSON(response); err != nil {
-        log.Printf("processGetChatByID: ошибка отправки ответа: %v", err)
+
+    event, err := transferChat(a, &p)
+    if err != nil {
+        r.failErr(err)
+        return
     }
+    r.reply("chatAssigned", event)
 }
 
-func processMarkAsRead(client *websocketpkg.Client, payload json.RawMessage, ginCtx *gin.Context) {
-    var p struct {
-        ChatID string `json:"chatID"`
-    }
+func processSetChatStatus(r *wsRequest, payload json.RawMessage) {
+    var p chatStatusRequest
     if err := json.Unmarshal(payload, &p); err != nil {
-        client.SendError("invalid_payload", "Некорректный формат данных для markAsRead")
+        r.fail("invalid_payload", "Некорректный формат данных для setChatStatus")
         return
     }
 
-    chatID, err := uuid.Parse(p.ChatID)
+    a, err := actorFromClient(r.client)
     if err != nil {
-        client.SendError("invalid_uuid", "Некорректный формат chatID")
+        r.failErr(err)
         return
     }
 
-    log.Printf("processMarkAsRead: отметка сообщений как прочитанных в чате %s", chatID)
-    
-    if err := database.MarkMessagesAsRead(chatID); err != nil {
-        log.Printf("processMarkAsRead: ошибка: %v", err)
-        client.SendError("db_error", "Ошибка при обновлении статуса сообщений: "+err.Error())
+    event, err := setChatStatus(a, &p)
+    if err != nil {
+        r.failErr(err)
         return
     }
 
-    // Отправляем обновление всем клиентам чата о прочтении сообщений
-    statusMsg, _ := websocketpkg.NewMessage("messagesRead", map[string]interface{}{
-        "chatID": chatID.String(),
-        "readBy": client.ID.String(),
-    })
-    
-    // Отправляем статус другим клиентам этого чата
-    WebSocketHub.SendToChat(chatID.String(), statusMsg)
-    
-    log.Printf("processMarkAsRead: успешно обновлен статус сообщений в чате %s", chatID)
-    
-    // Отправляем подтверждение отправителю запроса
-    response := map[string]interface{}{
-        "type": "markAsReadConfirmed",
-        "payload": map[string]interface{}{
-            "chatID": chatID.String(),
-            "status": "success",
-        },
+    r.reply("chatStatusChanged", event)
+}
+
+func processSetPresence(r *wsRequest, payload json.RawMessage) {
+    var p presenceRequest
+    if err := json.Unmarshal(payload, &p); err != nil {
+        r.fail("invalid_payload", "Некорректный формат данных для setPresence")
+        return
     }
-    
-    if err := client.SendJSON(response); err != nil {
-        log.Printf("processMarkAsRead: ошибка отправки подтверждения: %v", err)
+
+    a, err := actorFromClient(r.client)
+    if err != nil {
+        r.failErr(err)
+        return
     }
+
+    event, err := setPresence(a, p.Status)
+    if err != nil {
+        r.failErr(err)
+        return
+    }
+
+    log.Printf("processSetPresence: оператор %s выставил статус %s", a.ID, event.Status)
+    r.reply("presence", event)
 }
 
-func processTypingStatus(client *websocketpkg.Client, payload json.RawMessage, ginCtx *gin.Context) {
-    var p struct {
-        ChatID   string `json:"chatID"`
-        IsTyping bool   `json:"isTyping"`
+// processAck подтверждает получение событий сессии, освобождая буфер.
+func processAck(r *wsRequest, payload json.RawMessage) {
+    var p ackRequest
+    if err := json.Unmarshal(payload, &p); err != nil {
+        r.fail("invalid_payload", "Некорректный формат данных для ack")
+        return
     }
+    r.client.Ack(p.Seq)
+}
+
+func processTypingStatus(r *wsRequest, payload json.RawMessage) {
+    var p typingRequest
     if err := json.Unmarshal(payload, &p); err != nil {
-        client.SendError("invalid_payload", "Некорректный формат данных для typing")
+        r.fail("invalid_payload", "Некорректный формат данных для typing")
         return
     }
 
     chatID, err := uuid.Parse(p.ChatID)
     if err != nil {
-        client.SendError("invalid_uuid", "Некорректный формат chatID")
+        r.fail("invalid_uuid", "Некорректный формат chatID")
         return
     }
 
-    // Определяем тип отправителя
-    sender := "admin"
-    if client.ClientType == "widget" {
-        sender = "user"
+    a, err := actorFromClient(r.client)
+    if err != nil {
+        r.failErr(err)
+        return
+    }
+    chat, err := authorizeChat(a, chatID)
+    if err != nil {
+        r.failErr(err)
+        return
     }
 
+    // Определяем тип отправителя
+    sender := a.sender()
+
     // Создаем и отправляем сообщение о наборе текста
     typingMsg, err := websocketpkg.NewTypingMessage(chatID, p.IsTyping, sender)
     if err != nil {
@@ -585,90 +533,63 @@ func processTypingStatus(client *websocketpkg.Client, payload json.RawMessage, g
         return
     }
     
-    // Отправляем только клиентам этого чата
-    WebSocketHub.SendToChat(chatID.String(), typingMsg)
+    // Отправляем только участникам этого чата, своей сессии не отправляем
+    sendToChat(chat, typingMsg, a.ConnID.String())
     
     log.Printf("processTypingStatus: отправлен статус typing=%v для чата %s от %s", 
         p.IsTyping, chatID, sender)
 }
 
 // processGetWidgetMessages - новый метод для получения сообщений виджета через WebSocket
-func processGetWidgetMessages(client *websocketpkg.Client, payload json.RawMessage, ginCtx *gin.Context) {
-    var p struct {
-        ChatID   string `json:"chatID"`
-        Page     int    `json:"page"`
-        PageSize int    `json:"pageSize"`
-    }
+func processGetWidgetMessages(r *wsRequest, payload json.RawMessage) {
+    var p chatPageRequest
     if err := json.Unmarshal(payload, &p); err != nil {
-        client.SendError("invalid_payload", "Некорректный формат данных для getWidgetMessages")
+        r.fail("invalid_payload", "Некорректный формат данных для getWidgetMessages")
         return
     }
 
-    // Устанавливаем дефолтные значения
-    if p.Page < 1 {
-        p.Page = 1
-    }
-    if p.PageSize < 1 || p.PageSize > database.MaxPageSize {
-        p.PageSize = database.DefaultPageSize
-    }
-
     // Парсим ID чата
     chatID, err := uuid.Parse(p.ChatID)
     if err != nil {
-        client.SendError("invalid_uuid", "Некорректный формат chatID")
+        r.fail("invalid_uuid", "Некорректный формат chatID")
         return
     }
 
-    // Проверяем, принадлежит ли чат этому пользователю
-    if client.ClientType == "widget" && client.ChatID != chatID {
-        client.SendError("access_denied", "Доступ к чату запрещен")
+    a, err := actorFromClient(r.client)
+    if err != nil {
+        r.failErr(err)
         return
     }
 
-    // Получаем сообщения
-    chat, total, err := database.GetChatByID(chatID, p.Page, p.PageSize)
+    // Доступ проверяется в getChat: виджет видит только свой чат
+    details, err := getChat(a, chatID, p.Page, p.PageSize)
     if err != nil {
-        log.Printf("processGetWidgetMessages: ошибка получения сообщений: %v", err)
-        client.SendError("db_error", "Ошибка получения сообщений: "+err.Error())
+        r.failErr(err)
         return
     }
-
-    // Рассчитываем общее количество страниц
-    totalPages := (total + p.PageSize - 1) / p.PageSize
-    if totalPages < 1 {
-        totalPages = 1
-    }
+    chat := details.Chat
 
     // Преобразуем сообщения в формат для виджета
-    simplifiedMessages := make([]map[string]interface{}, 0, len(chat.Messages))
+    simplifiedMessages := make([]widgetMessage, 0, len(chat.Messages))
     for _, msg := range chat.Messages {
-        simplifiedMessages = append(simplifiedMessages, map[string]interface{}{
-            "id":        msg.ID.String(),
-            "content":   msg.Content,
-            "sender":    msg.Sender,
-            "timestamp": msg.Timestamp.Format("2006-01-02T15:04:05Z07:00"),
-            "type":      msg.Type,
+        simplifiedMessages = append(simplifiedMessages, widgetMessage{
+            ID:        msg.ID.String(),
+            Content:   msg.Content,
+            Sender:    msg.Sender,
+            Timestamp: msg.Timestamp.Format("2006-01-02T15:04:05Z07:00"),
+            Type:      msg.Type,
         })
     }
 
-    // Формируем ответ
-    response := map[string]interface{}{
-        "type": "widgetMessages",
-        "payload": map[string]interface{}{
-            "messages":    simplifiedMessages,
-            "page":        p.Page,
-            "pageSize":    p.PageSize,
-            "totalItems":  total,
-            "totalPages":  totalPages,
-            "chatId":      chat.ID.String(),
-            "userId":      chat.User.ID.String(),
-        },
-    }
-    
     log.Printf("processGetWidgetMessages: найдено %d сообщений", len(simplifiedMessages))
     
-    // Отправляем ответ
-    if err := client.SendJSON(response); err != nil {
-        log.Printf("processGetWidgetMessages: ошибка отправки ответа: %v", err)
-    }
-}
\ No newline at end of file
+    r.reply("widgetMessages", widgetMessagesResult{
+        Messages:   simplifiedMessages,
+        Page:       details.Page,
+        PageSize:   details.PageSize,
+        TotalItems: details.TotalItems,
+        TotalPages: details.TotalPages,
+        ChatID:     chat.ID.String(),
+        UserID:     chat.User.ID.String(),
+    })
+}
@@ -35,16 +35,14 @@ func GetWidgetChatMessages(c *gin.Context) {
     log.Printf("GetWidgetChatMessages: перенаправление на WebSocket для чата %s", chatIDStr)
     
     // Возвращаем информацию для подключения к WebSocket
-    response := gin.H{
-        "websocket": gin.H{
-            "url":     "/ws",
-            "chatId":  chatIDStr,
-            "userId":  userIDStr,
-            "type":    "widget",
-        },
-        "message": "Используйте WebSocket для получения сообщений",
-        "deprecated": "Этот REST endpoint устарел, используйте WebSocket подключение",
+    response := widgetInfoResponse{
+        Message:    "Используйте WebSocket для получения сообщений",
+        Deprecated: "Этот REST endpoint устарел, используйте WebSocket подключение",
     }
+    response.WebSocket.URL = "/ws"
+    response.WebSocket.ChatID = chatIDStr
+    response.WebSocket.UserID = userIDStr
+    response.WebSocket.Type = "widget"
 
     c.JSON(http.StatusOK, response)
 }
\ No newline at end of file
@@ -0,0 +1,190 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// anthropicVersion — версия Messages API в заголовке anthropic-version
+const anthropicVersion = "2023-06-01"
+
+// AnthropicClient — клиент Messages API ({BaseURL}/v1/messages).
+// Системные сообщения истории передаются отдельным полем system.
+type AnthropicClient struct {
+	baseURL string
+	apiKey  string
+	model   string
+	client  *http.Client
+	stream  *http.Client
+}
+
+type anthropicRequest struct {
+	Model       string    `json:"model"`
+	System      string    `json:"system,omitempty"`
+	Messages    []Message `json:"messages"`
+	MaxTokens   int       `json:"max_tokens"`
+	Temperature *float64  `json:"temperature,omitempty"`
+	Stream      bool      `json:"stream,omitempty"`
+}
+
+type anthropicResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+}
+
+// anthropicEvent — событие потока: content_block_delta несёт текст, error — ошибку
+type anthropicEvent struct {
+	Type  string `json:"type"`
+	Delta struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// NewAnthropicClient создаёт клиента Messages API. Нужны ключ и модель.
+func NewAnthropicClient(cfg ProviderConfig) (*AnthropicClient, error) {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "https://api.anthropic.com"
+	}
+	if cfg.APIKey == "" {
+		return nil, errors.New("anthropic: apiKey is required")
+	}
+	if cfg.Model == "" {
+		return nil, errors.New("anthropic: model is required")
+	}
+	client, stream, err := httpClients(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &AnthropicClient{
+		baseURL: strings.TrimRight(cfg.BaseURL, "/"),
+		apiKey:  cfg.APIKey,
+		model:   cfg.Model,
+		client:  client,
+		stream:  stream,
+	}, nil
+}
+
+// GenerateResponse возвращает текст ответа целиком.
+func (c *AnthropicClient) GenerateResponse(ctx context.Context, input string, history []Message, opts GenerateOptions) (string, error) {
+	req, err := c.newRequest(ctx, input, history, opts, false)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("LLM API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", statusError(resp)
+	}
+	var out anthropicResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("decode response: %w", err)
+	}
+
+	var text strings.Builder
+	for _, block := range out.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	return text.String(), nil
+}
+
+// GenerateStream вызывает onDelta для каждого текстового фрагмента.
+func (c *AnthropicClient) GenerateStream(ctx context.Context, input string, history []Message, opts GenerateOptions, onDelta func(delta string) error) (string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	req, err := c.newRequest(ctx, input, history, opts, true)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := c.stream.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("LLM API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", statusError(resp)
+	}
+
+	var full strings.Builder
+	err = readSSE(resp.Body, func(_, data string) error {
+		var ev anthropicEvent
+		if err := json.Unmarshal([]byte(data), &ev); err != nil {
+			return fmt.Errorf("decode stream event: %w", err)
+		}
+		switch ev.Type {
+		case "content_block_delta":
+			if ev.Delta.Type != "text_delta" || ev.Delta.Text == "" {
+				return nil
+			}
+			full.WriteString(ev.Delta.Text)
+			return onDelta(ev.Delta.Text)
+		case "error":
+			if ev.Error != nil {
+				return fmt.Errorf("LLM API stream error: %s", ev.Error.Message)
+			}
+			return errors.New("LLM API stream error")
+		case "message_stop":
+			return errStreamDone
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStreamDone) {
+		return full.String(), err
+	}
+	return full.String(), nil
+}
+
+func (c *AnthropicClient) newRequest(ctx context.Context, input string, history []Message, opts GenerateOptions, stream bool) (*http.Request, error) {
+	opts = opts.withDefaults(c.model)
+
+	body := anthropicRequest{
+		Model:       opts.Model,
+		MaxTokens:   opts.MaxTokens,
+		Temperature: opts.Temperature,
+		Stream:      stream,
+	}
+	var system []string
+	for _, m := range conversation(input, history) {
+		if m.Role == "system" {
+			system = append(system, m.Content)
+			continue
+		}
+		body.Messages = append(body.Messages, m)
+	}
+	body.System = strings.Join(system, "\n\n")
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/v1/messages", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("create HTTP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", c.apiKey)
+	req.Header.Set("anthropic-version", anthropicVersion)
+	return req, nil
+}
@@ -1,10 +1,14 @@
 package llm
 
 import (
+	"container/list"
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"strings"
 	"sync"
 	"time"
 
@@ -93,38 +97,100 @@ type Message struct {
 	Content string `json:"content"`
 }
 
+// GenerateOptions — параметры генерации; нулевые значения — по умолчанию клиента.
+// Temperature — указатель, чтобы отличать «не задана» (nil) от 0.
+type GenerateOptions struct {
+	Model       string
+	Temperature *float64
+	MaxTokens   int
+}
+
 type LLM interface {
-	GenerateResponse(ctx context.Context, input string, history []Message) (string, error)
+	GenerateResponse(ctx context.Context, input string, history []Message, opts GenerateOptions) (string, error)
+}
+
+// StreamingLLM — клиент, умеющий отдавать ответ по фрагментам.
+type StreamingLLM interface {
+	LLM
+	GenerateStream(ctx context.Context, input string, history []Message, opts GenerateOptions, onDelta func(delta string) error) (string, error)
 }
 
+// StreamEvent — фрагмент ответа, который генерируется прямо сейчас.
+// StreamID совпадает с metadata.streamId итогового сообщения.
+type StreamEvent struct {
+	StreamID string
+	Delta    string
+	Done     bool // генерация завершена, дальше придёт сохранённое сообщение
+	Aborted  bool // ответ отменён: показанный текст нужно убрать
+}
+
+// streamFlushInterval — как часто фрагменты отправляются клиентам;
+// токены между отправками объединяются
+const streamFlushInterval = 150 * time.Millisecond
+
+// errStreamEscalated прерывает поток при найденном запрещённом термине
+var errStreamEscalated = errors.New("stream escalated")
+
 type AutoResponderConfig struct {
-	Enabled         bool   `json:"enabled"`
-	BotName         string `json:"botName"`
-	DelaySeconds    int    `json:"delaySeconds"`
-	IdleTimeMinutes int    `json:"idleTimeMinutes"`
+	Enabled         bool    `json:"enabled"`
+	BotName         string  `json:"botName"`
+	Provider        string  `json:"provider"` // имя провайдера из Registry ("" — по умолчанию)
+	SystemPrompt    string  `json:"systemPrompt"`
+	DelaySeconds    int     `json:"delaySeconds"`
+	IdleTimeMinutes int     `json:"idleTimeMinutes"`
+	Model           string  `json:"model"` // "" — модель провайдера
+	Temperature     float64 `json:"temperature"`
+	MaxTokens       int     `json:"maxTokens"`
+	HistoryTokens   int     `json:"historyTokens"` // бюджет истории в промпте, старое сжимается в summary
 }
 
 func GetDefaultConfig() AutoResponderConfig {
 	return AutoResponderConfig{
 		Enabled:         true,
 		BotName:         "Автоответчик",
+		SystemPrompt:    systemPrompt,
 		DelaySeconds:    1,
 		IdleTimeMinutes: 5,
+		Temperature:     0.7,
+		MaxTokens:       1000,
+		HistoryTokens:   3000,
 	}
 }
 
 type AutoResponder struct {
-	client  LLM
-	config  AutoResponderConfig
+	llms    *Registry
+	config  AutoResponderConfig // настройки сервера; клиенты переопределяют их в БД
 	mu      sync.RWMutex
 	history map[string][]Message
+	// краткое содержание сжатой части истории и чаты, где сжатие идёт сейчас
+	summaries   map[string]string
+	summarizing map[string]bool
+	// порядок использования чатов для вытеснения истории из памяти
+	lru      *list.List
+	lruItems map[string]*list.Element
+
+	// HistoryCacheSize — сколько чатов держать в памяти (0 — DefaultHistoryCacheSize)
+	HistoryCacheSize int
+
+	configMu sync.Mutex
+	configs  map[uuid.UUID]cachedConfig
+
+	// OnDelta, если задан и клиент поддерживает StreamingLLM, получает
+	// фрагменты ответа по мере генерации
+	OnDelta func(chat *models.Chat, event StreamEvent)
 }
 
-func NewAutoResponder(client LLM, cfg AutoResponderConfig) *AutoResponder {
+func NewAutoResponder(llms *Registry, cfg AutoResponderConfig) *AutoResponder {
 	return &AutoResponder{
-		client:  client,
+		llms:    llms,
 		config:  cfg,
 		history: make(map[string][]Message),
+		configs: make(map[uuid.UUID]cachedConfig),
+
+		summaries:   make(map[string]string),
+		summarizing: make(map[string]bool),
+		lru:         list.New(),
+		lruItems:    make(map[string]*list.Element),
 	}
 }
 
@@ -133,45 +199,64 @@ func NewAutoResponder(client LLM, cfg AutoResponderConfig) *AutoResponder {
 // ---------------------------------------------------------------------------
 
 func (ar *AutoResponder) ProcessMessage(ctx context.Context, chat *models.Chat, msg *models.Message) (*models.Message, error) {
-	if !ar.config.Enabled || msg.Sender != "user" {
+	if msg.Sender != "user" {
 		return nil, nil
 	}
-	// чат уже закреплён за оператором
-	if chat.AssignedTo != nil && *chat.AssignedTo != uuid.Nil {
+	cfg := ar.ConfigFor(chat.ClientID)
+	if !cfg.Enabled {
 		return nil, nil
 	}
 
 	chatKey := chat.ID.String()
 
 	// ── история ───────────────────────────────────────────────
+	// Реплику клиента запоминаем и в чате оператора: бот может снова
+	// понадобиться после снятия назначения
+	ar.restoreHistory(ctx, chat.ID, msg.ID)
 	ar.mu.Lock()
-	hist := ar.history[chatKey]
-	if len(hist) == 0 {
-		hist = []Message{{Role: "system", Content: systemPrompt}}
-	}
-	hist = append(hist, Message{Role: "user", Content: msg.Content})
-	ar.history[chatKey] = hist
+	ar.appendTurn(chatKey, Message{Role: "user", Content: msg.Content})
+	hist := ar.buildPrompt(chatKey, cfg)
 	ar.mu.Unlock()
 
+	// чат уже закреплён за оператором
+	if chat.AssignedTo != nil && *chat.AssignedTo != uuid.Nil {
+		return nil, nil
+	}
+
 	// имитация «печатает…»
-	if ar.config.DelaySeconds > 0 {
+	if cfg.DelaySeconds > 0 {
 		select {
-		case <-time.After(time.Duration(ar.config.DelaySeconds) * time.Second):
+		case <-time.After(time.Duration(cfg.DelaySeconds) * time.Second):
 		case <-ctx.Done():
 			return nil, ctx.Err()
 		}
 	}
 
-	genCtx, cancel := context.WithTimeout(ctx, time.Duration(ar.config.IdleTimeMinutes)*time.Minute)
+	genCtx, cancel := context.WithTimeout(ctx, time.Duration(cfg.IdleTimeMinutes)*time.Minute)
 	defer cancel()
 
-	rawResp, err := ar.client.GenerateResponse(genCtx, msg.Content, hist)
-	if err != nil {
-		return nil, fmt.Errorf("GenerateResponse: %w", err)
+	var (
+		clean    string
+		escalate bool
+		streamID string
+	)
+	client := ar.provider(cfg)
+	if sc, ok := client.(StreamingLLM); ok && ar.OnDelta != nil {
+		streamID = uuid.NewString()
+		rawResp, escalated, err := ar.stream(genCtx, sc, chat, msg.Content, hist, cfg.options(), streamID)
+		if err != nil {
+			return nil, fmt.Errorf("GenerateStream: %w", err)
+		}
+		clean, escalate = sanitize(rawResp)
+		escalate = escalate || escalated
+	} else {
+		rawResp, err := client.GenerateResponse(genCtx, msg.Content, hist, cfg.options())
+		if err != nil {
+			return nil, fmt.Errorf("GenerateResponse: %w", err)
+		}
+		// ── фильтр самоидентификации ──────────────────────────
+		clean, escalate = sanitize(rawResp)
 	}
-
-	// ── фильтр самоидентификации ──────────────────────────────
-	clean, escalate := sanitize(rawResp)
 	if escalate {
 		clean = "Позвольте подключить нашего старшего специалиста. Одну минутку, пожалуйста. 🙏"
 	}
@@ -188,17 +273,92 @@ func (ar *AutoResponder) ProcessMessage(ctx context.Context, chat *models.Chat,
 		Type:     "text",
 		Metadata: map[string]interface{}{
 			"isAutoResponse": true,
-			"botName":        ar.config.BotName,
+			"botName":        cfg.BotName,
 			"needEscalation": escalate,
 		},
 	}
+	if streamID != "" {
+		botMsg.Metadata["streamId"] = streamID
+	}
+
+	// В историю ответ попадёт после сохранения: см. SaveReply и CommitReply
+	return botMsg, nil
+}
+
+// CommitReply добавляет сохранённый ответ бота в историю чата и при
+// необходимости запускает её сжатие. Вызывается после коммита транзакции
+// с ответом, иначе история в памяти разойдётся с сохранённой.
+func (ar *AutoResponder) CommitReply(chat *models.Chat, reply *models.Message) {
+	cfg := ar.ConfigFor(chat.ClientID)
+	chatKey := chat.ID.String()
 
-	// сохраняем в локальную историю
 	ar.mu.Lock()
-	ar.history[chatKey] = append(ar.history[chatKey], Message{Role: "assistant", Content: clean})
+	ar.appendTurn(chatKey, Message{Role: "assistant", Content: reply.Content})
 	ar.mu.Unlock()
+	ar.compactHistory(chatKey, cfg, ar.provider(cfg))
+}
 
-	return botMsg, nil
+// provider возвращает провайдер из конфига клиента.
+func (ar *AutoResponder) provider(cfg AutoResponderConfig) LLM {
+	client, ok := ar.llms.Get(cfg.Provider)
+	if !ok {
+		// Провайдер убрали из конфига сервера — отвечаем провайдером по умолчанию
+		log.Printf("AutoResponder: провайдер %q не настроен, используется %q", cfg.Provider, ar.llms.Default())
+		client, _ = ar.llms.Get("")
+	}
+	return client
+}
+
+// stream генерирует ответ по фрагментам и передаёт их в OnDelta, проверяя
+// текст sanitizer'ом на лету. escalated=true — найден запрещённый термин,
+// генерация прервана, а показанный текст отозван событием Aborted.
+func (ar *AutoResponder) stream(
+	ctx context.Context,
+	sc StreamingLLM,
+	chat *models.Chat,
+	input string,
+	hist []Message,
+	opts GenerateOptions,
+	streamID string,
+) (text string, escalated bool, err error) {
+	var (
+		san       streamSanitizer
+		pending   strings.Builder
+		lastFlush time.Time
+		shown     bool
+	)
+	emit := func(event StreamEvent) {
+		event.StreamID = streamID
+		ar.OnDelta(chat, event)
+		shown = true
+	}
+
+	_, err = sc.GenerateStream(ctx, input, hist, opts, func(delta string) error {
+		ready, escalate := san.write(delta)
+		if escalate {
+			return errStreamEscalated
+		}
+		pending.WriteString(ready)
+		if pending.Len() > 0 && time.Since(lastFlush) >= streamFlushInterval {
+			emit(StreamEvent{Delta: pending.String()})
+			pending.Reset()
+			lastFlush = time.Now()
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, errStreamEscalated):
+		emit(StreamEvent{Aborted: true})
+		return "", true, nil
+	case err != nil:
+		if shown {
+			emit(StreamEvent{Aborted: true})
+		}
+		return "", false, err
+	}
+
+	emit(StreamEvent{Delta: pending.String() + san.rest(), Done: true})
+	return san.String(), false, nil
 }
 
 // ---------------------------------------------------------------------------
@@ -206,9 +366,25 @@ func (ar *AutoResponder) ProcessMessage(ctx context.Context, chat *models.Chat,
 // ---------------------------------------------------------------------------
 
 func (ar *AutoResponder) SaveChatHistory(ctx context.Context, chatID string, tx *sql.Tx) error {
+	return ar.saveHistory(ctx, chatID, nil, tx)
+}
+
+// SaveReply сохраняет историю чата вместе с ответом бота, не меняя её в
+// памяти: вызывается в транзакции, которая сохраняет сам ответ.
+func (ar *AutoResponder) SaveReply(ctx context.Context, chatID string, reply *models.Message, tx *sql.Tx) error {
+	return ar.saveHistory(ctx, chatID, []Message{{Role: "assistant", Content: reply.Content}}, tx)
+}
+
+// saveHistory сохраняет в metadata чата реплики из памяти и pending.
+func (ar *AutoResponder) saveHistory(ctx context.Context, chatID string, pending []Message, tx *sql.Tx) error {
 	ar.mu.RLock()
 	hist := ar.history[chatID]
+	summary := ar.summaries[chatID]
 	ar.mu.RUnlock()
+	hist = append(hist[:len(hist):len(hist)], pending...)
+	if extra := len(hist) - maxStoredTurns; extra > 0 {
+		hist = hist[extra:]
+	}
 	if len(hist) == 0 {
 		return nil
 	}
@@ -219,41 +395,59 @@ func (ar *AutoResponder) SaveChatHistory(ctx context.Context, chatID string, tx
 
 	query := `
 		UPDATE chats
-		SET metadata = jsonb_set(coalesce(metadata, '{}'::jsonb), '{llmHistory}', $1)
-		WHERE id = $2
+		SET metadata = jsonb_set(
+			jsonb_set(coalesce(metadata, '{}'::jsonb), '{llmHistory}', $1),
+			'{llmSummary}', to_jsonb($2::text))
+		WHERE id = $3
 	`
 	if tx != nil {
-		_, err = tx.ExecContext(ctx, query, raw, chatID)
+		_, err = tx.ExecContext(ctx, query, raw, summary, chatID)
 	} else {
-		_, err = database.DB.ExecContext(ctx, query, raw, chatID)
+		_, err = database.DB.ExecContext(ctx, query, raw, summary, chatID)
 	}
 	return err
 }
 
+// LoadChatHistory загружает в память историю чата из metadata.
 func (ar *AutoResponder) LoadChatHistory(ctx context.Context, chatID string) error {
+	hist, summary, err := ar.loadChatHistory(ctx, chatID)
+	if err != nil || hist == nil {
+		return err
+	}
+	ar.mu.Lock()
+	ar.history[chatID] = hist
+	if summary != "" {
+		ar.summaries[chatID] = summary
+	} else {
+		delete(ar.summaries, chatID)
+	}
+	ar.touch(chatID)
+	ar.mu.Unlock()
+	return nil
+}
+
+// loadChatHistory читает историю и краткое содержание из metadata чата;
+// hist == nil — истории там нет.
+func (ar *AutoResponder) loadChatHistory(ctx context.Context, chatID string) (hist []Message, summary string, err error) {
 	var raw []byte
-	query := `SELECT metadata->'llmHistory' FROM chats WHERE id = $1`
-	if err := database.DB.QueryRowContext(ctx, query, chatID).Scan(&raw); err != nil {
+	query := `SELECT metadata->'llmHistory', coalesce(metadata->>'llmSummary', '') FROM chats WHERE id = $1`
+	if err := database.DB.QueryRowContext(ctx, query, chatID).Scan(&raw, &summary); err != nil {
 		if err == sql.ErrNoRows {
-			return nil
+			return nil, "", nil
 		}
-		return fmt.Errorf("LoadChatHistory: scan: %w", err)
+		return nil, "", fmt.Errorf("LoadChatHistory: scan: %w", err)
 	}
 	if len(raw) == 0 {
-		return nil
+		return nil, summary, nil
 	}
-	var hist []Message
 	if err := json.Unmarshal(raw, &hist); err != nil {
-		return fmt.Errorf("LoadChatHistory: unmarshal: %w", err)
+		return nil, "", fmt.Errorf("LoadChatHistory: unmarshal: %w", err)
 	}
-	ar.mu.Lock()
-	ar.history[chatID] = hist
-	ar.mu.Unlock()
-	return nil
+	return splitSystem(hist), summary, nil
 }
 
 func (ar *AutoResponder) ClearChatHistory(chatID string) {
 	ar.mu.Lock()
-	delete(ar.history, chatID)
+	ar.forget(chatID)
 	ar.mu.Unlock()
 }
\ No newline at end of file
@@ -0,0 +1,181 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/egor/ecochatserver/models"
+)
+
+// recordingLLM запоминает параметры последнего запроса к провайдеру.
+type recordingLLM struct {
+	*Fake
+	opts    GenerateOptions
+	history []Message
+}
+
+func (r *recordingLLM) GenerateResponse(ctx context.Context, input string, history []Message, opts GenerateOptions) (string, error) {
+	r.opts, r.history = opts, history
+	return r.Fake.GenerateResponse(ctx, input, history, opts)
+}
+
+// newTestResponder создаёт автоответчик без БД: настройки сервера
+// (у чата нет клиента) и пустая история в памяти.
+func newTestResponder(t *testing.T, p LLM) (*AutoResponder, *models.Chat) {
+	t.Helper()
+	ar := NewAutoResponder(SingleProvider("fake", p), AutoResponderConfig{
+		Enabled:         true,
+		BotName:         "Ева",
+		SystemPrompt:    "Ты консультант магазина",
+		IdleTimeMinutes: 1,
+	})
+	chat := &models.Chat{ID: uuid.New()}
+	ar.history[chat.ID.String()] = []Message{}
+	return ar, chat
+}
+
+func userMessage(chat *models.Chat, content string) *models.Message {
+	return &models.Message{ID: uuid.New(), ChatID: chat.ID, Content: content, Sender: "user"}
+}
+
+func TestProcessMessage(t *testing.T) {
+	ar, chat := newTestResponder(t, NewFake(""))
+
+	got, err := ar.ProcessMessage(context.Background(), chat, userMessage(chat, "Где мой заказ?"))
+	if err != nil {
+		t.Fatalf("ProcessMessage: %v", err)
+	}
+	if got == nil || got.Content != "Вы написали: Где мой заказ?" {
+		t.Fatalf("ответ = %+v", got)
+	}
+	if got.Sender != "admin" || got.ChatID != chat.ID || got.Metadata["botName"] != "Ева" || got.Metadata["needEscalation"] != false {
+		t.Errorf("сообщение бота: %+v", got)
+	}
+	if _, ok := got.Metadata["streamId"]; ok {
+		t.Error("streamId без потоковой генерации")
+	}
+
+	// Ответ попадает в историю только после сохранения
+	turns := ar.history[chat.ID.String()]
+	if len(turns) != 1 || turns[0].Role != "user" {
+		t.Errorf("история до сохранения ответа: %+v", turns)
+	}
+	ar.CommitReply(chat, got)
+	turns = ar.history[chat.ID.String()]
+	if len(turns) != 2 || turns[1].Role != "assistant" || turns[1].Content != got.Content {
+		t.Errorf("история после сохранения ответа: %+v", turns)
+	}
+}
+
+func TestProcessMessageSkips(t *testing.T) {
+	operator := uuid.New()
+	tests := []struct {
+		name     string
+		setup    func(ar *AutoResponder, chat *models.Chat, msg *models.Message)
+		recorded bool // реплика клиента попадает в историю
+	}{
+		{name: "operator message", setup: func(_ *AutoResponder, _ *models.Chat, msg *models.Message) { msg.Sender = "admin" }},
+		{name: "disabled", setup: func(ar *AutoResponder, _ *models.Chat, _ *models.Message) { ar.config.Enabled = false }},
+		{name: "assigned chat", setup: func(_ *AutoResponder, chat *models.Chat, _ *models.Message) { chat.AssignedTo = &operator }, recorded: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ar, chat := newTestResponder(t, NewFake("Здравствуйте"))
+			msg := userMessage(chat, "Привет")
+			tt.setup(ar, chat, msg)
+
+			got, err := ar.ProcessMessage(context.Background(), chat, msg)
+			if got != nil || err != nil {
+				t.Errorf("ProcessMessage = %+v, %v, ожидалось nil, nil", got, err)
+			}
+			if n := len(ar.history[chat.ID.String()]); (n == 1) != tt.recorded {
+				t.Errorf("реплик в истории: %d", n)
+			}
+		})
+	}
+}
+
+func TestProcessMessageEscalates(t *testing.T) {
+	ar, chat := newTestResponder(t, NewFake("Я бот и не знаю ответа"))
+
+	got, err := ar.ProcessMessage(context.Background(), chat, userMessage(chat, "Ты кто?"))
+	if err != nil {
+		t.Fatalf("ProcessMessage: %v", err)
+	}
+	if got.Metadata["needEscalation"] != true || strings.Contains(got.Content, "бот") {
+		t.Errorf("ответ не эскалирован: %+v", got)
+	}
+}
+
+func TestProcessMessageTemperature(t *testing.T) {
+	for _, temp := range []float64{0, 1.3} {
+		p := &recordingLLM{Fake: NewFake("Здравствуйте")}
+		ar, chat := newTestResponder(t, p)
+		ar.config.Temperature = temp
+
+		if _, err := ar.ProcessMessage(context.Background(), chat, userMessage(chat, "Привет")); err != nil {
+			t.Fatalf("ProcessMessage: %v", err)
+		}
+		if p.opts.Temperature == nil || *p.opts.Temperature != temp {
+			t.Errorf("температура %v передана как %v", temp, p.opts.Temperature)
+		}
+		if len(p.history) == 0 || p.history[0].Role != "system" || p.history[0].Content != "Ты консультант магазина" {
+			t.Errorf("промпт без системного сообщения: %+v", p.history)
+		}
+	}
+}
+
+func TestProcessMessageStream(t *testing.T) {
+	tests := []struct {
+		name     string
+		reply    string
+		escalate bool
+	}{
+		{name: "done", reply: "Добрый день, ваш заказ уже в пути"},
+		{name: "escalated", reply: "Добрый день, я бот поддержки", escalate: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ar, chat := newTestResponder(t, NewFake(tt.reply))
+			var events []StreamEvent
+			ar.OnDelta = func(c *models.Chat, event StreamEvent) {
+				if c != chat {
+					t.Errorf("событие чужого чата %s", c.ID)
+				}
+				events = append(events, event)
+			}
+
+			got, err := ar.ProcessMessage(context.Background(), chat, userMessage(chat, "Где заказ?"))
+			if err != nil {
+				t.Fatalf("ProcessMessage: %v", err)
+			}
+			if len(events) == 0 {
+				t.Fatal("нет событий потока")
+			}
+			var shown strings.Builder
+			for _, e := range events {
+				if e.StreamID != got.Metadata["streamId"] {
+					t.Errorf("StreamID события %q не совпадает с metadata.streamId %v", e.StreamID, got.Metadata["streamId"])
+				}
+				shown.WriteString(e.Delta)
+			}
+			last := events[len(events)-1]
+
+			if tt.escalate {
+				if !last.Aborted || got.Metadata["needEscalation"] != true {
+					t.Errorf("поток не прерван: последнее событие %+v, сообщение %+v", last, got)
+				}
+				if strings.Contains(shown.String(), "бот") {
+					t.Errorf("показан запрещённый термин: %q", shown.String())
+				}
+				return
+			}
+			if !last.Done || shown.String() != tt.reply || got.Content != tt.reply {
+				t.Errorf("показано %q, последнее событие %+v, сообщение %q", shown.String(), last, got.Content)
+			}
+		})
+	}
+}
@@ -4,26 +4,31 @@ import (
     "bytes"
     "context"
     "encoding/json"
+    "errors"
     "fmt"
-    "io"
     "net/http"
-    "os"
-    "time"
+    "strings"
 )
 
-// LLMClient представляет клиента для взаимодействия с локальной ЛЛМ-моделью.
+// maxStreamLine — предельная длина строки SSE
+const maxStreamLine = 1 << 20
+
+// LLMClient — клиент OpenAI-совместимого API (LM Studio, vLLM, OpenAI).
 type LLMClient struct {
     apiURL string
+    apiKey string
+    model  string
     client *http.Client
+    stream *http.Client // без общего таймаута: поток ограничивает контекст
 }
 
 // ChatCompletionRequest описывает тело POST‑запроса к LLM API.
 type ChatCompletionRequest struct {
-    Model       string     `json:"model"`
-    Messages    []Message  `json:"messages"`
-    Temperature float64    `json:"temperature,omitempty"`
-    MaxTokens   int        `json:"max_tokens,omitempty"`
-    Stream      bool       `json:"stream,omitempty"`
+    Model       string    `json:"model"`
+    Messages    []Message `json:"messages"`
+    Temperature *float64  `json:"temperature,omitempty"`
+    MaxTokens   int       `json:"max_tokens,omitempty"`
+    Stream      bool      `json:"stream,omitempty"`
 }
 
 // ChatCompletionChoice — один из вариантов ответа от LLM API.
@@ -43,25 +48,43 @@ type ChatCompletionResponse struct {
     Usage   map[string]int         `json:"usage"`
 }
 
-// NewLLMClient создаёт новый LLMClient.
-// Настраивается URL из LLM_API_URL и таймаут из LLM_API_TIMEOUT или по умолчанию 30s.
-func NewLLMClient() *LLMClient {
-    apiURL := os.Getenv("LLM_API_URL")
-    if apiURL == "" {
-        apiURL = "http://localhost:1234/v1"
-    }
+// ChatCompletionChunk — событие SSE при stream=true.
+type ChatCompletionChunk struct {
+    ID      string `json:"id"`
+    Choices []struct {
+        Index int `json:"index"`
+        Delta struct {
+            Role    string `json:"role,omitempty"`
+            Content string `json:"content"`
+        } `json:"delta"`
+        FinishReason *string `json:"finish_reason"`
+    } `json:"choices"`
+    Error *struct {
+        Message string `json:"message"`
+    } `json:"error,omitempty"`
+}
 
-    timeout := 30 * time.Second
-    if t := os.Getenv("LLM_API_TIMEOUT"); t != "" {
-        if d, err := time.ParseDuration(t); err == nil {
-            timeout = d
-        }
+// NewLLMClient создаёт клиента OpenAI-совместимого API
+// ({BaseURL}/chat/completions). Ключ, если задан, передаётся как Bearer.
+func NewLLMClient(cfg ProviderConfig) (*LLMClient, error) {
+    if cfg.BaseURL == "" {
+        cfg.BaseURL = "http://localhost:1234/v1"
+    }
+    if cfg.Model == "" {
+        cfg.Model = "gemma"
+    }
+    client, stream, err := httpClients(cfg)
+    if err != nil {
+        return nil, err
     }
 
     return &LLMClient{
-        apiURL: apiURL,
-        client: &http.Client{Timeout: timeout},
-    }
+        apiURL: strings.TrimRight(cfg.BaseURL, "/"),
+        apiKey: cfg.APIKey,
+        model:  cfg.Model,
+        client: client,
+        stream: stream,
+    }, nil
 }
 
 // GenerateResponse отправляет историю диалога и текущее сообщение в LLM API,
@@ -70,47 +93,13 @@ func (c *LLMClient) GenerateResponse(
     ctx context.Context,
     userMessage string,
     chatHistory []Message,
+    opts GenerateOptions,
 ) (string, error) {
-    // Если истории нет — инициализируем системным сообщением + первым user
-    if len(chatHistory) == 0 {
-        chatHistory = []Message{
-            {
-                Role:    "system",
-                Content: "Ты вежливый и полезный ассистент, отвечающий на вопросы клиентов. " +
-                    "Твои ответы должны быть краткими, информативными и дружелюбными.",
-            },
-            {
-                Role:    "user",
-                Content: userMessage,
-            },
-        }
-    } else {
-        chatHistory = append(chatHistory, Message{
-            Role:    "user",
-            Content: userMessage,
-        })
-    }
-
-    // Формируем тело запроса
-    reqBody := ChatCompletionRequest{
-        Model:       "gemma",
-        Messages:    chatHistory,
-        Temperature: 0.7,
-        MaxTokens:   1000,
-    }
-    payload, err := json.Marshal(reqBody)
+    req, err := c.newRequest(ctx, userMessage, chatHistory, opts, false)
     if err != nil {
-        return "", fmt.Errorf("marshal request body: %w", err)
+        return "", err
     }
 
-    // Собираем HTTP‑запрос с контекстом
-    endpoint := fmt.Sprintf("%s/chat/completions", c.apiURL)
-    req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
-    if err != nil {
-        return "", fmt.Errorf("create HTTP request: %w", err)
-    }
-    req.Header.Set("Content-Type", "application/json")
-
     // Выполняем запрос
     resp, err := c.client.Do(req)
     if err != nil {
@@ -120,8 +109,7 @@ func (c *LLMClient) GenerateResponse(
 
     // Обрабатываем код ответа
     if resp.StatusCode != http.StatusOK {
-        body, _ := io.ReadAll(resp.Body)
-        return "", fmt.Errorf("LLM API error: status %d, body: %s", resp.StatusCode, string(body))
+        return "", statusError(resp)
     }
 
     // Декодируем JSON-ответ
@@ -135,4 +123,96 @@ func (c *LLMClient) GenerateResponse(
     }
 
     return completion.Choices[0].Message.Content, nil
-}
\ No newline at end of file
+}
+
+// GenerateStream запрашивает ответ с stream=true и вызывает onDelta для
+// каждого фрагмента по мере получения SSE. Ошибка onDelta прерывает
+// генерацию и возвращается как есть. Возвращает полный текст ответа.
+func (c *LLMClient) GenerateStream(
+    ctx context.Context,
+    userMessage string,
+    chatHistory []Message,
+    opts GenerateOptions,
+    onDelta func(delta string) error,
+) (string, error) {
+    ctx, cancel := context.WithCancel(ctx)
+    defer cancel()
+
+    req, err := c.newRequest(ctx, userMessage, chatHistory, opts, true)
+    if err != nil {
+        return "", err
+    }
+    req.Header.Set("Accept", "text/event-stream")
+
+    resp, err := c.stream.Do(req)
+    if err != nil {
+        return "", fmt.Errorf("LLM API request failed: %w", err)
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != http.StatusOK {
+        return "", statusError(resp)
+    }
+
+    var full strings.Builder
+    err = readSSE(resp.Body, func(_, data string) error {
+        if data == "[DONE]" {
+            return errStreamDone
+        }
+
+        var chunk ChatCompletionChunk
+        if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+            return fmt.Errorf("decode stream chunk: %w", err)
+        }
+        if chunk.Error != nil {
+            return fmt.Errorf("LLM API stream error: %s", chunk.Error.Message)
+        }
+        if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+            return nil
+        }
+
+        delta := chunk.Choices[0].Delta.Content
+        full.WriteString(delta)
+        return onDelta(delta)
+    })
+    // Поток, закрытый без [DONE], тоже считаем полным, как и часть серверов
+    if err != nil && !errors.Is(err, errStreamDone) {
+        return full.String(), err
+    }
+    return full.String(), nil
+}
+
+// newRequest собирает запрос /chat/completions: история диалога плюс
+// текущее сообщение пользователя.
+func (c *LLMClient) newRequest(
+    ctx context.Context,
+    userMessage string,
+    chatHistory []Message,
+    opts GenerateOptions,
+    stream bool,
+) (*http.Request, error) {
+    opts = opts.withDefaults(c.model)
+    reqBody := ChatCompletionRequest{
+        Model:       opts.Model,
+        Messages:    conversation(userMessage, chatHistory),
+        Temperature: opts.Temperature,
+        MaxTokens:   opts.MaxTokens,
+        Stream:      stream,
+    }
+    payload, err := json.Marshal(reqBody)
+    if err != nil {
+        return nil, fmt.Errorf("marshal request body: %w", err)
+    }
+
+    // Собираем HTTP‑запрос с контекстом
+    endpoint := fmt.Sprintf("%s/chat/completions", c.apiURL)
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+    if err != nil {
+        return nil, fmt.Errorf("create HTTP request: %w", err)
+    }
+    req.Header.Set("Content-Type", "application/json")
+    if c.apiKey != "" {
+        req.Header.Set("Authorization", "Bearer "+c.apiKey)
+    }
+    return req, nil
+}
@@ -0,0 +1,104 @@
+package llm
+
+import (
+	"log"
+	"time"
+
+	"github.com/egor/ecochatserver/database"
+	"github.com/egor/ecochatserver/models"
+	"github.com/google/uuid"
+)
+
+// configCacheTTL — сколько настройки клиента живут в кэше. Изменения через
+// API сбрасывают кэш сразу (InvalidateConfig), на других узлах — по TTL.
+const configCacheTTL = time.Minute
+
+type cachedConfig struct {
+	config   AutoResponderConfig
+	loadedAt time.Time
+}
+
+// Apply возвращает конфиг с переопределениями клиента.
+func (cfg AutoResponderConfig) Apply(s *models.AutoResponderSettings) AutoResponderConfig {
+	if s == nil {
+		return cfg
+	}
+	if s.Enabled != nil {
+		cfg.Enabled = *s.Enabled
+	}
+	if s.BotName != nil {
+		cfg.BotName = *s.BotName
+	}
+	if s.SystemPrompt != nil {
+		cfg.SystemPrompt = *s.SystemPrompt
+	}
+	if s.DelaySeconds != nil {
+		cfg.DelaySeconds = *s.DelaySeconds
+	}
+	if s.Provider != nil {
+		cfg.Provider = *s.Provider
+	}
+	if s.Model != nil {
+		cfg.Model = *s.Model
+	}
+	if s.Temperature != nil {
+		cfg.Temperature = *s.Temperature
+	}
+	if s.MaxTokens != nil {
+		cfg.MaxTokens = *s.MaxTokens
+	}
+	if s.HistoryTokens != nil {
+		cfg.HistoryTokens = *s.HistoryTokens
+	}
+	return cfg
+}
+
+// options возвращает параметры генерации конфига.
+func (cfg AutoResponderConfig) options() GenerateOptions {
+	temperature := cfg.Temperature
+	return GenerateOptions{
+		Model:       cfg.Model,
+		Temperature: &temperature,
+		MaxTokens:   cfg.MaxTokens,
+	}
+}
+
+// ConfigFor возвращает действующий конфиг клиента: настройки сервера с
+// переопределениями из clients.autoresponder_settings. При ошибке БД
+// используются настройки сервера.
+func (ar *AutoResponder) ConfigFor(clientID uuid.UUID) AutoResponderConfig {
+	if clientID == uuid.Nil {
+		return ar.config
+	}
+
+	ar.configMu.Lock()
+	cached, ok := ar.configs[clientID]
+	ar.configMu.Unlock()
+	if ok && time.Since(cached.loadedAt) < configCacheTTL {
+		return cached.config
+	}
+
+	settings, err := database.GetAutoResponderSettings(clientID)
+	if err != nil {
+		log.Printf("AutoResponder: настройки клиента %s: %v", clientID, err)
+		return ar.config
+	}
+	cfg := ar.config.Apply(settings)
+
+	ar.configMu.Lock()
+	ar.configs[clientID] = cachedConfig{config: cfg, loadedAt: time.Now()}
+	ar.configMu.Unlock()
+	return cfg
+}
+
+// Providers возвращает имена настроенных провайдеров.
+func (ar *AutoResponder) Providers() []string {
+	return ar.llms.Names()
+}
+
+// InvalidateConfig сбрасывает кэш настроек клиента.
+func (ar *AutoResponder) InvalidateConfig(clientID uuid.UUID) {
+	ar.configMu.Lock()
+	delete(ar.configs, clientID)
+	ar.configMu.Unlock()
+}
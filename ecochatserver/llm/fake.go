@@ -0,0 +1,55 @@
+package llm
+
+import (
+	"context"
+	"strings"
+)
+
+// Fake — детерминированный провайдер для тестов и локальной разработки:
+// отвечает фиксированным текстом или повторяет сообщение пользователя.
+// Поток отдаёт ответ по словам.
+type Fake struct {
+	reply string
+}
+
+// NewFake создаёт провайдер с ответом reply ("" — эхо сообщения пользователя).
+func NewFake(reply string) *Fake {
+	return &Fake{reply: reply}
+}
+
+// GenerateResponse возвращает ответ сразу.
+func (f *Fake) GenerateResponse(ctx context.Context, input string, _ []Message, _ GenerateOptions) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if f.reply != "" {
+		return f.reply, nil
+	}
+	return "Вы написали: " + input, nil
+}
+
+// GenerateStream отдаёт ответ по словам вместе с пробелами после них.
+func (f *Fake) GenerateStream(ctx context.Context, input string, history []Message, opts GenerateOptions, onDelta func(delta string) error) (string, error) {
+	reply, err := f.GenerateResponse(ctx, input, history, opts)
+	if err != nil {
+		return "", err
+	}
+
+	var sent strings.Builder
+	rest := reply
+	for rest != "" {
+		if err := ctx.Err(); err != nil {
+			return sent.String(), err
+		}
+		end := strings.IndexByte(rest, ' ') + 1
+		if end == 0 {
+			end = len(rest)
+		}
+		sent.WriteString(rest[:end])
+		if err := onDelta(rest[:end]); err != nil {
+			return sent.String(), err
+		}
+		rest = rest[end:]
+	}
+	return sent.String(), nil
+}
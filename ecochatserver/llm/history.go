@@ -0,0 +1,309 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/egor/ecochatserver/database"
+	"github.com/google/uuid"
+)
+
+// История диалога хранится целиком только для последних реплик: в модель
+// уходят системный промпт, краткое содержание старой части разговора и
+// свежие реплики в пределах AutoResponderConfig.HistoryTokens. Когда
+// история превышает бюджет, старые реплики сжимаются в summary самой LLM.
+//
+// В истории лежат только реплики: системный промпт клиента может
+// измениться, поэтому он берётся из конфига при каждом запросе.
+// История каждого чата сохраняется в metadata вместе с ответом бота и
+// загружается при первом сообщении после перезапуска. В памяти держатся
+// только HistoryCacheSize последних активных чатов (LRU).
+
+const (
+	// Грубая оценка: ~4 символа на токен плюс служебные токены сообщения
+	charsPerToken   = 4
+	messageOverhead = 4
+
+	// maxStoredTurns ограничивает историю, если сжатие не удаётся
+	maxStoredTurns = 200
+
+	summaryMaxTokens   = 500
+	summaryTimeout     = 2 * time.Minute
+	historySaveTimeout = 5 * time.Second
+
+	// DefaultHistoryCacheSize — сколько чатов держать в памяти по умолчанию
+	DefaultHistoryCacheSize = 1000
+)
+
+const summaryPrompt = `Ты ведёшь краткий конспект переписки службы поддержки с клиентом.
+Объедини предыдущий конспект (если он есть) и новые реплики в один связный конспект
+на языке переписки: суть обращений, данные клиента (номера заказов, адреса, суммы),
+что уже сделано и обещано, что осталось нерешённым. Не более 10 предложений.
+Выведи только текст конспекта.`
+
+// estimateTokens оценивает размер сообщения в токенах.
+func estimateTokens(m Message) int {
+	return utf8.RuneCountInString(m.Content)/charsPerToken + messageOverhead
+}
+
+// splitSystem отделяет системное сообщение в начале истории от реплик
+// (его сохраняли в metadata прежние версии).
+func splitSystem(hist []Message) []Message {
+	if len(hist) > 0 && hist[0].Role == "system" {
+		return hist[1:]
+	}
+	return hist
+}
+
+// restoreHistory загружает историю чата, которой нет в памяти: из metadata
+// чата, а если её там нет — из последних сообщений. Текущее сообщение
+// пользователя (current) не включается: его добавит ProcessMessage.
+func (ar *AutoResponder) restoreHistory(ctx context.Context, chatID, current uuid.UUID) {
+	chatKey := chatID.String()
+	ar.mu.RLock()
+	_, ok := ar.history[chatKey]
+	ar.mu.RUnlock()
+	if ok {
+		return
+	}
+
+	hist, summary, err := ar.loadChatHistory(ctx, chatKey)
+	if err != nil {
+		log.Printf("AutoResponder: загрузка истории чата %s: %v", chatKey, err)
+	}
+	if hist == nil && err == nil {
+		hist, err = rebuildHistory(chatID, current)
+		if err != nil {
+			log.Printf("AutoResponder: восстановление истории чата %s: %v", chatKey, err)
+		}
+	}
+
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+	if _, ok := ar.history[chatKey]; ok {
+		return // историю уже загрузил параллельный ответ
+	}
+	ar.history[chatKey] = hist
+	if summary != "" {
+		ar.summaries[chatKey] = summary
+	}
+	ar.touch(chatKey)
+}
+
+// rebuildHistory собирает реплики из последних текстовых сообщений чата.
+// Ответы операторов и бота идут как реплики assistant.
+func rebuildHistory(chatID, current uuid.UUID) ([]Message, error) {
+	msgs, err := database.GetRecentMessages(chatID, maxStoredTurns+1)
+	if err != nil {
+		return nil, err
+	}
+	hist := []Message{}
+	for _, m := range msgs {
+		if m.ID == current || m.Content == "" || (m.Type != "" && m.Type != "text") {
+			continue
+		}
+		role := "assistant"
+		if m.Sender == "user" {
+			role = "user"
+		}
+		hist = append(hist, Message{Role: role, Content: m.Content})
+	}
+	return hist, nil
+}
+
+// touch отмечает чат как недавно использованный и вытесняет из памяти
+// самые давние чаты сверх HistoryCacheSize. Вызывается под ar.mu.
+func (ar *AutoResponder) touch(chatKey string) {
+	if el, ok := ar.lruItems[chatKey]; ok {
+		ar.lru.MoveToFront(el)
+	} else {
+		ar.lruItems[chatKey] = ar.lru.PushFront(chatKey)
+	}
+
+	size := ar.HistoryCacheSize
+	if size <= 0 {
+		size = DefaultHistoryCacheSize
+	}
+	for ar.lru.Len() > size {
+		// История вытесненного чата уже сохранена с последним ответом бота
+		ar.forget(ar.lru.Back().Value.(string))
+	}
+}
+
+// forget убирает историю чата из памяти. Вызывается под ar.mu.
+func (ar *AutoResponder) forget(chatKey string) {
+	if el, ok := ar.lruItems[chatKey]; ok {
+		ar.lru.Remove(el)
+		delete(ar.lruItems, chatKey)
+	}
+	delete(ar.history, chatKey)
+	delete(ar.summaries, chatKey)
+}
+
+// appendTurn добавляет реплику в историю чата. Вызывается под ar.mu.
+func (ar *AutoResponder) appendTurn(chatKey string, m Message) {
+	hist := append(ar.history[chatKey], m)
+	if extra := len(hist) - maxStoredTurns; extra > 0 {
+		hist = append([]Message(nil), hist[extra:]...)
+	}
+	ar.history[chatKey] = hist
+	ar.touch(chatKey)
+}
+
+// buildPrompt собирает сообщения для модели: системный промпт с кратким
+// содержанием и последние реплики, умещающиеся в бюджет. Последняя реплика
+// передаётся всегда. Вызывается под ar.mu.
+func (ar *AutoResponder) buildPrompt(chatKey string, cfg AutoResponderConfig) []Message {
+	system := Message{Role: "system", Content: cfg.SystemPrompt}
+	if summary := ar.summaries[chatKey]; summary != "" {
+		system.Content += "\n\nКраткое содержание предыдущей части разговора:\n" + summary
+	}
+
+	turns := ar.history[chatKey]
+	start := len(turns)
+	budget := cfg.HistoryTokens - estimateTokens(system)
+	for start > 0 {
+		t := estimateTokens(turns[start-1])
+		if cfg.HistoryTokens > 0 && t > budget && start < len(turns) {
+			break
+		}
+		budget -= t
+		start--
+	}
+
+	prompt := make([]Message, 0, len(turns)-start+1)
+	prompt = append(prompt, system)
+	return append(prompt, turns[start:]...)
+}
+
+// compactHistory, если реплики чата превысили бюджет, в фоне сжимает
+// старые из них в краткое содержание, оставляя свежие на половину бюджета,
+// и сохраняет результат в metadata чата.
+func (ar *AutoResponder) compactHistory(chatKey string, cfg AutoResponderConfig, client LLM) {
+	old, prevSummary, ok := ar.startCompaction(chatKey, cfg)
+	if !ok {
+		return
+	}
+
+	go func() {
+		if !ar.compact(chatKey, cfg, client, prevSummary, old) {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), historySaveTimeout)
+		defer cancel()
+		if err := ar.SaveChatHistory(ctx, chatKey, nil); err != nil {
+			log.Printf("AutoResponder: сохранение истории чата %s: %v", chatKey, err)
+		}
+	}()
+}
+
+// startCompaction выбирает старые реплики для сжатия и отмечает чат как
+// сжимаемый. ok=false — история в бюджете или уже сжимается.
+func (ar *AutoResponder) startCompaction(chatKey string, cfg AutoResponderConfig) (old []Message, prevSummary string, ok bool) {
+	if cfg.HistoryTokens <= 0 {
+		return nil, "", false
+	}
+
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+	turns := ar.history[chatKey]
+	total := 0
+	for _, m := range turns {
+		total += estimateTokens(m)
+	}
+	if total <= cfg.HistoryTokens || ar.summarizing[chatKey] {
+		return nil, "", false
+	}
+
+	keep, budget := 0, cfg.HistoryTokens/2
+	for keep < len(turns) {
+		t := estimateTokens(turns[len(turns)-1-keep])
+		if t > budget && keep > 0 {
+			break
+		}
+		budget -= t
+		keep++
+	}
+	if keep == len(turns) {
+		return nil, "", false
+	}
+	ar.summarizing[chatKey] = true
+	return append([]Message(nil), turns[:len(turns)-keep]...), ar.summaries[chatKey], true
+}
+
+// compact сжимает реплики old в краткое содержание и убирает их из
+// истории. false — сжать не удалось или история чата с тех пор сменилась.
+func (ar *AutoResponder) compact(chatKey string, cfg AutoResponderConfig, client LLM, prevSummary string, old []Message) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), summaryTimeout)
+	summary, err := ar.summarize(ctx, client, cfg, prevSummary, old)
+	cancel()
+
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+	delete(ar.summarizing, chatKey)
+	if err != nil {
+		log.Printf("AutoResponder: сжатие истории чата %s: %v", chatKey, err)
+		return false
+	}
+	hist := ar.history[chatKey]
+	if !startsWith(hist, old) {
+		return false
+	}
+	// Реплики только дописываются, поэтому сжатые по-прежнему в начале
+	ar.history[chatKey] = append([]Message(nil), hist[len(old):]...)
+	ar.summaries[chatKey] = summary
+	return true
+}
+
+// summarize просит модель объединить прошлое краткое содержание и реплики.
+func (ar *AutoResponder) summarize(ctx context.Context, client LLM, cfg AutoResponderConfig, prev string, turns []Message) (string, error) {
+	var b strings.Builder
+	if prev != "" {
+		b.WriteString("Предыдущий конспект:\n")
+		b.WriteString(prev)
+		b.WriteString("\n\n")
+	}
+	b.WriteString("Новые реплики:\n")
+	for _, m := range turns {
+		role := "Клиент"
+		if m.Role == "assistant" {
+			role = "Поддержка"
+		}
+		fmt.Fprintf(&b, "%s: %s\n", role, m.Content)
+	}
+
+	input := b.String()
+	opts := cfg.options()
+	temperature := 0.2
+	opts.Temperature = &temperature
+	opts.MaxTokens = summaryMaxTokens
+	summary, err := client.GenerateResponse(ctx, input, []Message{
+		{Role: "system", Content: summaryPrompt},
+		{Role: "user", Content: input},
+	}, opts)
+	if err != nil {
+		return "", err
+	}
+	summary = strings.TrimSpace(summary)
+	if summary == "" {
+		return "", fmt.Errorf("пустое краткое содержание")
+	}
+	return summary, nil
+}
+
+// startsWith сообщает, начинается ли hist с сообщений prefix.
+func startsWith(hist, prefix []Message) bool {
+	if len(hist) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if hist[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
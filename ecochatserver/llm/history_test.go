@@ -0,0 +1,167 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// turnTokens — оценка размера реплики из testTurns
+const turnTokens = 36/charsPerToken + messageOverhead
+
+// testTurns возвращает n реплик по turnTokens токенов, чередуя роли.
+func testTurns(n int) []Message {
+	turns := make([]Message, n)
+	for i := range turns {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		turns[i] = Message{Role: role, Content: strings.Repeat("x", 32) + fmt.Sprintf("%04d", i)}
+	}
+	return turns
+}
+
+func TestAppendTurn(t *testing.T) {
+	ar := NewAutoResponder(SingleProvider("fake", NewFake("")), AutoResponderConfig{})
+	for _, m := range testTurns(maxStoredTurns + 5) {
+		ar.appendTurn("chat", m)
+	}
+
+	hist := ar.history["chat"]
+	if len(hist) != maxStoredTurns {
+		t.Fatalf("в истории %d реплик, ожидалось %d", len(hist), maxStoredTurns)
+	}
+	if hist[0] != testTurns(6)[5] {
+		t.Errorf("первая реплика %+v, старые не вытеснены", hist[0])
+	}
+	for _, m := range hist {
+		if m.Role == "system" {
+			t.Fatal("системный промпт сохранён в истории")
+		}
+	}
+}
+
+func TestBuildPrompt(t *testing.T) {
+	const prompt = "Ты консультант магазина"
+	system := estimateTokens(Message{Content: prompt})
+	turns := testTurns(6)
+
+	tests := []struct {
+		name    string
+		budget  int
+		summary string
+		want    int // сколько последних реплик попадает в промпт
+	}{
+		{name: "no budget", budget: 0, want: 6},
+		{name: "fits all", budget: system + 6*turnTokens, want: 6},
+		{name: "trimmed", budget: system + 3*turnTokens, want: 3},
+		{name: "trimmed partially", budget: system + 3*turnTokens + turnTokens/2, want: 3},
+		{name: "last turn over budget", budget: 1, want: 1},
+		{name: "summary counts", budget: system + 3*turnTokens, summary: strings.Repeat("y", 4*turnTokens), want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ar := NewAutoResponder(SingleProvider("fake", NewFake("")), AutoResponderConfig{})
+			ar.history["chat"] = turns
+			if tt.summary != "" {
+				ar.summaries["chat"] = tt.summary
+			}
+
+			got := ar.buildPrompt("chat", AutoResponderConfig{SystemPrompt: prompt, HistoryTokens: tt.budget})
+			if len(got) != tt.want+1 {
+				t.Fatalf("в промпте %d сообщений, ожидалось %d", len(got), tt.want+1)
+			}
+			if got[0].Role != "system" || !strings.HasPrefix(got[0].Content, prompt) {
+				t.Errorf("первое сообщение %+v, ожидался системный промпт", got[0])
+			}
+			if tt.summary != "" && !strings.Contains(got[0].Content, tt.summary) {
+				t.Error("краткое содержание не добавлено в системный промпт")
+			}
+			if !startsWith(got[1:], turns[len(turns)-tt.want:]) {
+				t.Errorf("реплики промпта %+v", got[1:])
+			}
+		})
+	}
+}
+
+func TestCompaction(t *testing.T) {
+	cfg := AutoResponderConfig{SystemPrompt: "Ты консультант магазина", HistoryTokens: 10 * turnTokens}
+	turns := testTurns(12)
+
+	tests := []struct {
+		name    string
+		reply   string
+		forget  bool // история чата ушла из памяти во время сжатия
+		applied bool
+	}{
+		{name: "applied", reply: "Клиент ждёт заказ 1234", applied: true},
+		{name: "empty summary", reply: "   "},
+		{name: "history forgotten", reply: "Клиент ждёт заказ 1234", forget: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ar := NewAutoResponder(SingleProvider("fake", NewFake(tt.reply)), cfg)
+			ar.history["chat"] = append([]Message(nil), turns...)
+			ar.summaries["chat"] = "Клиент спрашивал о доставке"
+
+			old, prev, ok := ar.startCompaction("chat", cfg)
+			if !ok {
+				t.Fatal("история сверх бюджета не сжимается")
+			}
+			// Свежие реплики остаются на половину бюджета
+			if len(old) != 12-5 || !startsWith(turns, old) || prev != "Клиент спрашивал о доставке" {
+				t.Fatalf("к сжатию выбрано %d реплик, прошлое содержание %q", len(old), prev)
+			}
+			if _, _, ok := ar.startCompaction("chat", cfg); ok {
+				t.Error("повторное сжатие во время текущего")
+			}
+
+			if tt.forget {
+				ar.ClearChatHistory("chat")
+			}
+			// Реплика, пришедшая во время сжатия, остаётся в истории
+			ar.appendTurn("chat", Message{Role: "user", Content: "Ещё вопрос"})
+
+			if applied := ar.compact("chat", cfg, NewFake(tt.reply), prev, old); applied != tt.applied {
+				t.Fatalf("compact = %v, ожидалось %v", applied, tt.applied)
+			}
+			if ar.summarizing["chat"] {
+				t.Error("чат остался отмеченным как сжимаемый")
+			}
+
+			hist := ar.history["chat"]
+			switch {
+			case tt.applied:
+				if want := append(turns[7:], Message{Role: "user", Content: "Ещё вопрос"}); !startsWith(hist, want) || len(hist) != len(want) {
+					t.Errorf("история после сжатия: %+v", hist)
+				}
+				if ar.summaries["chat"] != tt.reply {
+					t.Errorf("краткое содержание %q", ar.summaries["chat"])
+				}
+			case tt.forget:
+				if len(hist) != 1 || ar.summaries["chat"] != "" {
+					t.Errorf("сжатие применено к новой истории: %+v, %q", hist, ar.summaries["chat"])
+				}
+			default:
+				if len(hist) != 13 || ar.summaries["chat"] != "Клиент спрашивал о доставке" {
+					t.Errorf("история изменена при ошибке сжатия: %d реплик, %q", len(hist), ar.summaries["chat"])
+				}
+			}
+		})
+	}
+}
+
+func TestStartCompactionWithinBudget(t *testing.T) {
+	ar := NewAutoResponder(SingleProvider("fake", NewFake("")), AutoResponderConfig{})
+	ar.history["chat"] = testTurns(4)
+
+	for _, budget := range []int{0, 4 * turnTokens} {
+		if _, _, ok := ar.startCompaction("chat", AutoResponderConfig{HistoryTokens: budget}); ok {
+			t.Errorf("бюджет %d: сжатие истории в пределах бюджета", budget)
+		}
+	}
+	if ar.summarizing["chat"] {
+		t.Error("чат отмечен как сжимаемый")
+	}
+}
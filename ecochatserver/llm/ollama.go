@@ -0,0 +1,159 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// OllamaClient — клиент нативного API Ollama ({BaseURL}/api/chat).
+// Потоковый ответ Ollama — NDJSON: по JSON-объекту на строку.
+type OllamaClient struct {
+	baseURL string
+	apiKey  string
+	model   string
+	client  *http.Client
+	stream  *http.Client
+}
+
+type ollamaChatRequest struct {
+	Model    string        `json:"model"`
+	Messages []Message     `json:"messages"`
+	Stream   bool          `json:"stream"`
+	Options  ollamaOptions `json:"options"`
+}
+
+type ollamaOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	NumPredict  int      `json:"num_predict,omitempty"`
+}
+
+type ollamaChatResponse struct {
+	Message Message `json:"message"`
+	Done    bool    `json:"done"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// NewOllamaClient создаёт клиента Ollama. Ключ, если задан (Ollama за
+// прокси), передаётся как Bearer.
+func NewOllamaClient(cfg ProviderConfig) (*OllamaClient, error) {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "http://localhost:11434"
+	}
+	if cfg.Model == "" {
+		return nil, fmt.Errorf("ollama: model is required")
+	}
+	client, stream, err := httpClients(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &OllamaClient{
+		baseURL: strings.TrimRight(cfg.BaseURL, "/"),
+		apiKey:  cfg.APIKey,
+		model:   cfg.Model,
+		client:  client,
+		stream:  stream,
+	}, nil
+}
+
+// GenerateResponse возвращает ответ модели целиком.
+func (c *OllamaClient) GenerateResponse(ctx context.Context, input string, history []Message, opts GenerateOptions) (string, error) {
+	req, err := c.newRequest(ctx, input, history, opts, false)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("LLM API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", statusError(resp)
+	}
+	var out ollamaChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("decode response: %w", err)
+	}
+	if out.Error != "" {
+		return "", fmt.Errorf("LLM API error: %s", out.Error)
+	}
+	return out.Message.Content, nil
+}
+
+// GenerateStream вызывает onDelta для каждого фрагмента ответа.
+func (c *OllamaClient) GenerateStream(ctx context.Context, input string, history []Message, opts GenerateOptions, onDelta func(delta string) error) (string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	req, err := c.newRequest(ctx, input, history, opts, true)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.stream.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("LLM API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", statusError(resp)
+	}
+
+	var full strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLine)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var chunk ollamaChatResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return full.String(), fmt.Errorf("decode stream chunk: %w", err)
+		}
+		if chunk.Error != "" {
+			return full.String(), fmt.Errorf("LLM API stream error: %s", chunk.Error)
+		}
+		if delta := chunk.Message.Content; delta != "" {
+			full.WriteString(delta)
+			if err := onDelta(delta); err != nil {
+				return full.String(), err
+			}
+		}
+		if chunk.Done {
+			return full.String(), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return full.String(), fmt.Errorf("read stream: %w", err)
+	}
+	return full.String(), nil
+}
+
+func (c *OllamaClient) newRequest(ctx context.Context, input string, history []Message, opts GenerateOptions, stream bool) (*http.Request, error) {
+	opts = opts.withDefaults(c.model)
+	payload, err := json.Marshal(ollamaChatRequest{
+		Model:    opts.Model,
+		Messages: conversation(input, history),
+		Stream:   stream,
+		Options:  ollamaOptions{Temperature: opts.Temperature, NumPredict: opts.MaxTokens},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal request body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/chat", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("create HTTP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	return req, nil
+}
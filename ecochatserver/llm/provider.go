@@ -0,0 +1,230 @@
+package llm
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Типы провайдеров LLM
+const (
+	ProviderOpenAI    = "openai"    // OpenAI-совместимый /chat/completions (LM Studio, vLLM, OpenAI)
+	ProviderOllama    = "ollama"    // нативный /api/chat Ollama
+	ProviderAnthropic = "anthropic" // Messages API
+	ProviderFake      = "fake"      // детерминированные ответы для тестов и разработки
+)
+
+// defaultLLMTimeout — таймаут запроса, если в конфиге он не задан
+const defaultLLMTimeout = 30 * time.Second
+
+// errStreamDone останавливает чтение потока после маркера завершения
+var errStreamDone = errors.New("stream done")
+
+// ProviderConfig — настройки одного провайдера.
+type ProviderConfig struct {
+	Type    string `json:"type"`
+	BaseURL string `json:"baseUrl"`
+	APIKey  string `json:"apiKey,omitempty"`
+	Model   string `json:"model,omitempty"`   // модель по умолчанию, если клиент не задал свою
+	Timeout string `json:"timeout,omitempty"` // Go duration; для потоков — только ожидание заголовков
+}
+
+// ProvidersConfig — провайдеры сервера по именам. Клиент выбирает провайдер
+// по имени в настройках автоответчика, без него используется Default.
+type ProvidersConfig struct {
+	Default   string                    `json:"default"`
+	Providers map[string]ProviderConfig `json:"providers"`
+}
+
+// LoadProvidersConfig читает конфиг провайдеров из JSON-файла.
+func LoadProvidersConfig(path string) (ProvidersConfig, error) {
+	var cfg ProvidersConfig
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("read providers config: %w", err)
+	}
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse providers config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// NewProvider создаёт клиента LLM по конфигу.
+func NewProvider(cfg ProviderConfig) (LLM, error) {
+	var (
+		p   LLM
+		err error
+	)
+	switch cfg.Type {
+	case ProviderOpenAI, "":
+		p, err = NewLLMClient(cfg)
+	case ProviderOllama:
+		p, err = NewOllamaClient(cfg)
+	case ProviderAnthropic:
+		p, err = NewAnthropicClient(cfg)
+	case ProviderFake:
+		p = NewFake("")
+	default:
+		err = fmt.Errorf("unknown LLM provider type %q", cfg.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Registry — провайдеры LLM, доступные автоответчику.
+type Registry struct {
+	providers map[string]LLM
+	def       string
+}
+
+// NewRegistry создаёт провайдеров из конфига. Default должен быть среди них.
+func NewRegistry(cfg ProvidersConfig) (*Registry, error) {
+	r := &Registry{providers: make(map[string]LLM, len(cfg.Providers)), def: cfg.Default}
+	for name, pc := range cfg.Providers {
+		p, err := NewProvider(pc)
+		if err != nil {
+			return nil, fmt.Errorf("provider %q: %w", name, err)
+		}
+		r.providers[name] = p
+	}
+	if _, ok := r.providers[r.def]; !ok {
+		return nil, fmt.Errorf("default provider %q is not configured", r.def)
+	}
+	return r, nil
+}
+
+// SingleProvider — реестр из одного провайдера с именем name.
+func SingleProvider(name string, p LLM) *Registry {
+	return &Registry{providers: map[string]LLM{name: p}, def: name}
+}
+
+// Get возвращает провайдер по имени ("" — по умолчанию).
+func (r *Registry) Get(name string) (LLM, bool) {
+	if name == "" {
+		name = r.def
+	}
+	p, ok := r.providers[name]
+	return p, ok
+}
+
+// Default возвращает имя провайдера по умолчанию.
+func (r *Registry) Default() string { return r.def }
+
+// Names возвращает имена провайдеров по алфавиту.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ---------------------------------------------------------------------------
+// общее для HTTP-провайдеров
+// ---------------------------------------------------------------------------
+
+// httpClients возвращает клиента для обычных запросов и клиента для потоков
+// (без общего таймаута: поток ограничивает контекст).
+func httpClients(cfg ProviderConfig) (*http.Client, *http.Client, error) {
+	timeout := defaultLLMTimeout
+	if cfg.Timeout != "" {
+		d, err := time.ParseDuration(cfg.Timeout)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid timeout %q: %w", cfg.Timeout, err)
+		}
+		timeout = d
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = timeout
+	return &http.Client{Timeout: timeout}, &http.Client{Transport: transport}, nil
+}
+
+// statusError описывает неуспешный ответ API.
+func statusError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	return fmt.Errorf("LLM API error: status %d, body: %s", resp.StatusCode, string(body))
+}
+
+// conversation возвращает историю с текущим сообщением пользователя в конце.
+// Автоответчик уже добавляет его в историю, повторно не дублируем.
+func conversation(userMessage string, history []Message) []Message {
+	if len(history) == 0 {
+		return []Message{
+			{
+				Role: "system",
+				Content: "Ты вежливый и полезный ассистент, отвечающий на вопросы клиентов. " +
+					"Твои ответы должны быть краткими, информативными и дружелюбными.",
+			},
+			{Role: "user", Content: userMessage},
+		}
+	}
+	if last := history[len(history)-1]; last.Role == "user" && last.Content == userMessage {
+		return history
+	}
+	return append(history[:len(history):len(history)], Message{Role: "user", Content: userMessage})
+}
+
+// readSSE читает поток server-sent events и вызывает handle для каждого
+// события с его типом (event:) и данными (data:). Ошибка handle прерывает чтение.
+func readSSE(body io.Reader, handle func(event, data string) error) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLine)
+
+	var event string
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if len(data) > 0 {
+				if err := handle(event, strings.Join(data, "\n")); err != nil {
+					return err
+				}
+			}
+			event, data = "", nil
+		case strings.HasPrefix(line, ":"):
+			// комментарий / keep-alive
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimSpace(strings.TrimPrefix(line, "data:")))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read stream: %w", err)
+	}
+	// Последнее событие без пустой строки в конце
+	if len(data) > 0 {
+		return handle(event, strings.Join(data, "\n"))
+	}
+	return nil
+}
+
+// defaultTemperature — температура, если она не задана (0 — допустимое значение)
+const defaultTemperature = 0.7
+
+// withDefaults подставляет параметры по умолчанию; model — модель провайдера.
+func (o GenerateOptions) withDefaults(model string) GenerateOptions {
+	if o.Model == "" {
+		o.Model = model
+	}
+	if o.Temperature == nil {
+		temperature := defaultTemperature
+		o.Temperature = &temperature
+	}
+	if o.MaxTokens <= 0 {
+		o.MaxTokens = 1000
+	}
+	return o
+}
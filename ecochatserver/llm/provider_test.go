@@ -0,0 +1,365 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// recorded — запрос, полученный тестовым сервером.
+type recorded struct {
+	path   string
+	header http.Header
+	body   map[string]any
+}
+
+// newTestServer отвечает на запросы status и body и записывает последний запрос.
+func newTestServer(t *testing.T, status int, body string) (*httptest.Server, *recorded) {
+	t.Helper()
+	rec := &recorded{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		rec.path, rec.header, rec.body = r.URL.Path, r.Header.Clone(), nil
+		if err := json.Unmarshal(raw, &rec.body); err != nil {
+			t.Errorf("тело запроса не JSON: %v", err)
+		}
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, rec
+}
+
+// collect собирает фрагменты потока.
+func collect(deltas *[]string) func(string) error {
+	return func(delta string) error {
+		*deltas = append(*deltas, delta)
+		return nil
+	}
+}
+
+func floatPtr(v float64) *float64 { return &v }
+
+func TestWithDefaults(t *testing.T) {
+	opts := GenerateOptions{}.withDefaults("m")
+	if opts.Model != "m" || opts.MaxTokens != 1000 {
+		t.Errorf("withDefaults: %+v", opts)
+	}
+	if opts.Temperature == nil || *opts.Temperature != defaultTemperature {
+		t.Errorf("температура по умолчанию: %v", opts.Temperature)
+	}
+
+	opts = GenerateOptions{Temperature: floatPtr(0)}.withDefaults("m")
+	if opts.Temperature == nil || *opts.Temperature != 0 {
+		t.Errorf("нулевая температура заменена: %v", opts.Temperature)
+	}
+}
+
+func TestOpenAIGenerateResponse(t *testing.T) {
+	srv, rec := newTestServer(t, http.StatusOK,
+		`{"choices":[{"index":0,"message":{"role":"assistant","content":"Здравствуйте!"}}]}`)
+	c, err := NewLLMClient(ProviderConfig{BaseURL: srv.URL + "/v1/", APIKey: "key", Model: "gemma"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := c.GenerateResponse(context.Background(), "Привет", nil, GenerateOptions{Temperature: floatPtr(0)})
+	if err != nil {
+		t.Fatalf("GenerateResponse: %v", err)
+	}
+	if got != "Здравствуйте!" {
+		t.Errorf("ответ = %q", got)
+	}
+	if rec.path != "/v1/chat/completions" {
+		t.Errorf("путь запроса = %s", rec.path)
+	}
+	if auth := rec.header.Get("Authorization"); auth != "Bearer key" {
+		t.Errorf("Authorization = %q", auth)
+	}
+	if v, ok := rec.body["temperature"]; !ok || v != 0.0 {
+		t.Errorf("temperature = %v (передана: %v), ожидалось 0", v, ok)
+	}
+	if rec.body["model"] != "gemma" || rec.body["stream"] != nil {
+		t.Errorf("тело запроса: %v", rec.body)
+	}
+	// Пустая история — системный промпт по умолчанию и сообщение пользователя
+	if msgs, _ := rec.body["messages"].([]any); len(msgs) != 2 {
+		t.Errorf("messages: %v", rec.body["messages"])
+	}
+}
+
+func TestOpenAIGenerateResponseErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{name: "status", status: http.StatusTooManyRequests, body: `{"error":"rate limit"}`, want: "status 429"},
+		{name: "no choices", status: http.StatusOK, body: `{"choices":[]}`, want: "no choices"},
+		{name: "bad json", status: http.StatusOK, body: `{`, want: "decode response"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := newTestServer(t, tt.status, tt.body)
+			c, err := NewLLMClient(ProviderConfig{BaseURL: srv.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = c.GenerateResponse(context.Background(), "Привет", nil, GenerateOptions{})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, ожидалось %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenAIGenerateStream(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "done",
+			body: ": keep-alive\n\n" +
+				"data: {\"choices\":[{\"delta\":{\"role\":\"assistant\",\"content\":\"\"}}]}\n\n" +
+				"data: {\"choices\":[{\"delta\":{\"content\":\"Добрый \"}}]}\n\n" +
+				"data: {\"choices\":[{\"delta\":{\"content\":\"день\"}}]}\n\n" +
+				"data: [DONE]\n\n" +
+				"data: {\"choices\":[{\"delta\":{\"content\":\"лишнее\"}}]}\n\n",
+			want: []string{"Добрый ", "день"},
+		},
+		{
+			name: "closed without done",
+			body: "data: {\"choices\":[{\"delta\":{\"content\":\"Да\"}}]}",
+			want: []string{"Да"},
+		},
+		{
+			name: "error chunk",
+			body: "data: {\"choices\":[{\"delta\":{\"content\":\"Нач\"}}]}\n\n" +
+				"data: {\"error\":{\"message\":\"model overloaded\"}}\n\n",
+			want:    []string{"Нач"},
+			wantErr: "model overloaded",
+		},
+		{
+			name:    "bad chunk",
+			body:    "data: {oops}\n\n",
+			wantErr: "decode stream chunk",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, rec := newTestServer(t, http.StatusOK, tt.body)
+			c, err := NewLLMClient(ProviderConfig{BaseURL: srv.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var deltas []string
+			full, err := c.GenerateStream(context.Background(), "Привет", nil, GenerateOptions{}, collect(&deltas))
+			checkStream(t, full, deltas, err, tt.want, tt.wantErr)
+			if rec.body["stream"] != true {
+				t.Errorf("stream = %v, ожидалось true", rec.body["stream"])
+			}
+		})
+	}
+}
+
+func TestOllamaGenerateResponse(t *testing.T) {
+	srv, rec := newTestServer(t, http.StatusOK,
+		`{"message":{"role":"assistant","content":"Готово"},"done":true}`)
+	c, err := NewOllamaClient(ProviderConfig{BaseURL: srv.URL, Model: "llama3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := c.GenerateResponse(context.Background(), "Привет", nil, GenerateOptions{Temperature: floatPtr(0), MaxTokens: 50})
+	if err != nil {
+		t.Fatalf("GenerateResponse: %v", err)
+	}
+	if got != "Готово" {
+		t.Errorf("ответ = %q", got)
+	}
+	if rec.path != "/api/chat" {
+		t.Errorf("путь запроса = %s", rec.path)
+	}
+	options, _ := rec.body["options"].(map[string]any)
+	if v, ok := options["temperature"]; !ok || v != 0.0 {
+		t.Errorf("options.temperature = %v (передана: %v), ожидалось 0", v, ok)
+	}
+	if options["num_predict"] != 50.0 || rec.body["stream"] != false {
+		t.Errorf("тело запроса: %v", rec.body)
+	}
+
+	srv, _ = newTestServer(t, http.StatusOK, `{"error":"model not found"}`)
+	c, _ = NewOllamaClient(ProviderConfig{BaseURL: srv.URL, Model: "llama3"})
+	if _, err := c.GenerateResponse(context.Background(), "Привет", nil, GenerateOptions{}); err == nil || !strings.Contains(err.Error(), "model not found") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestOllamaGenerateStream(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "done",
+			body: `{"message":{"role":"assistant","content":"Добрый "},"done":false}` + "\n\n" +
+				`{"message":{"role":"assistant","content":"день"},"done":false}` + "\n" +
+				`{"message":{"role":"assistant","content":""},"done":true}` + "\n" +
+				`{"message":{"role":"assistant","content":"лишнее"},"done":false}` + "\n",
+			want: []string{"Добрый ", "день"},
+		},
+		{
+			name: "error",
+			body: `{"message":{"content":"Нач"},"done":false}` + "\n" +
+				`{"error":"out of memory"}` + "\n",
+			want:    []string{"Нач"},
+			wantErr: "out of memory",
+		},
+		{
+			name:    "bad line",
+			body:    "not json\n",
+			wantErr: "decode stream chunk",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := newTestServer(t, http.StatusOK, tt.body)
+			c, err := NewOllamaClient(ProviderConfig{BaseURL: srv.URL, Model: "llama3"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var deltas []string
+			full, err := c.GenerateStream(context.Background(), "Привет", nil, GenerateOptions{}, collect(&deltas))
+			checkStream(t, full, deltas, err, tt.want, tt.wantErr)
+		})
+	}
+}
+
+func TestAnthropicGenerateResponse(t *testing.T) {
+	srv, rec := newTestServer(t, http.StatusOK,
+		`{"content":[{"type":"text","text":"Добрый "},{"type":"tool_use"},{"type":"text","text":"день"}]}`)
+	c, err := NewAnthropicClient(ProviderConfig{BaseURL: srv.URL, APIKey: "key", Model: "claude"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history := []Message{
+		{Role: "system", Content: "Будь вежлив"},
+		{Role: "user", Content: "Привет"},
+	}
+	got, err := c.GenerateResponse(context.Background(), "Привет", history, GenerateOptions{Temperature: floatPtr(0)})
+	if err != nil {
+		t.Fatalf("GenerateResponse: %v", err)
+	}
+	if got != "Добрый день" {
+		t.Errorf("ответ = %q", got)
+	}
+	if rec.path != "/v1/messages" {
+		t.Errorf("путь запроса = %s", rec.path)
+	}
+	if rec.header.Get("x-api-key") != "key" || rec.header.Get("anthropic-version") != anthropicVersion {
+		t.Errorf("заголовки: %v", rec.header)
+	}
+	if rec.body["system"] != "Будь вежлив" {
+		t.Errorf("system = %v", rec.body["system"])
+	}
+	// Системное сообщение уходит в system, а не в messages
+	if msgs, _ := rec.body["messages"].([]any); len(msgs) != 1 {
+		t.Errorf("messages: %v", rec.body["messages"])
+	}
+	if v, ok := rec.body["temperature"]; !ok || v != 0.0 {
+		t.Errorf("temperature = %v (передана: %v), ожидалось 0", v, ok)
+	}
+}
+
+func TestAnthropicGenerateStream(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "message_stop",
+			body: "event: message_start\ndata: {\"type\":\"message_start\"}\n\n" +
+				"event: ping\ndata: {\"type\":\"ping\"}\n\n" +
+				"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"Добрый \"}}\n\n" +
+				"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"input_json_delta\",\"partial_json\":\"{}\"}}\n\n" +
+				"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"день\"}}\n\n" +
+				"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n" +
+				"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"лишнее\"}}\n\n",
+			want: []string{"Добрый ", "день"},
+		},
+		{
+			name: "error",
+			body: "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"Нач\"}}\n\n" +
+				"event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n",
+			want:    []string{"Нач"},
+			wantErr: "Overloaded",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := newTestServer(t, http.StatusOK, tt.body)
+			c, err := NewAnthropicClient(ProviderConfig{BaseURL: srv.URL, APIKey: "key", Model: "claude"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var deltas []string
+			full, err := c.GenerateStream(context.Background(), "Привет", nil, GenerateOptions{}, collect(&deltas))
+			checkStream(t, full, deltas, err, tt.want, tt.wantErr)
+		})
+	}
+}
+
+func TestProvidersStatusError(t *testing.T) {
+	srv, _ := newTestServer(t, http.StatusUnauthorized, `{"error":"bad key"}`)
+	cfg := ProviderConfig{BaseURL: srv.URL, APIKey: "key", Model: "m"}
+	for _, typ := range []string{ProviderOpenAI, ProviderOllama, ProviderAnthropic} {
+		cfg.Type = typ
+		p, err := NewProvider(cfg)
+		if err != nil {
+			t.Fatalf("%s: %v", typ, err)
+		}
+		if _, err := p.GenerateResponse(context.Background(), "Привет", nil, GenerateOptions{}); err == nil || !strings.Contains(err.Error(), "status 401") {
+			t.Errorf("%s GenerateResponse: err = %v", typ, err)
+		}
+		_, err = p.(StreamingLLM).GenerateStream(context.Background(), "Привет", nil, GenerateOptions{}, func(string) error {
+			t.Errorf("%s: фрагмент при ошибке статуса", typ)
+			return nil
+		})
+		if err == nil || !strings.Contains(err.Error(), "bad key") {
+			t.Errorf("%s GenerateStream: err = %v", typ, err)
+		}
+	}
+}
+
+// checkStream сверяет результат GenerateStream с ожидаемыми фрагментами.
+func checkStream(t *testing.T, full string, deltas []string, err error, want []string, wantErr string) {
+	t.Helper()
+	switch {
+	case wantErr == "" && err != nil:
+		t.Fatalf("GenerateStream: %v", err)
+	case wantErr != "" && (err == nil || !strings.Contains(err.Error(), wantErr)):
+		t.Fatalf("err = %v, ожидалось %q", err, wantErr)
+	}
+	if strings.Join(deltas, "|") != strings.Join(want, "|") {
+		t.Errorf("фрагменты = %q, ожидалось %q", deltas, want)
+	}
+	if full != strings.Join(want, "") {
+		t.Errorf("полный текст = %q", full)
+	}
+}
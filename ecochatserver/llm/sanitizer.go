@@ -4,6 +4,8 @@ package llm
 import (
 	"regexp"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 // forbiddenTerms — слова/фразы, при которых диалог эскалируется.
@@ -22,18 +24,69 @@ var forbiddenTerms = []string{
 	"виртуальный", "digital agent",
 }
 
-// sanitize проверяет текст LLM. escalate=true => нужен живой оператор.
-func sanitize(resp string) (clean string, escalate bool) {
-	lower := strings.ToLower(resp)
+// containsForbidden сообщает, встречается ли в тексте запрещённый термин.
+func containsForbidden(text string) bool {
+	lower := strings.ToLower(text)
 	for _, term := range forbiddenTerms {
 		if strings.Contains(lower, term) {
-			return "", true
+			return true
 		}
 	}
+	return false
+}
+
+// sanitize проверяет текст LLM. escalate=true => нужен живой оператор.
+func sanitize(resp string) (clean string, escalate bool) {
+	if containsForbidden(resp) {
+		return "", true
+	}
 	// подчищаем единичные «AI-слова», чтобы не мелькали по ошибке
 	for _, term := range forbiddenTerms {
 		re := regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(term) + `\b`)
 		resp = re.ReplaceAllString(resp, "")
 	}
 	return strings.TrimSpace(resp), false
-}
\ No newline at end of file
+}
+
+// streamSanitizer проверяет ответ по мере генерации. Наружу отдаются только
+// завершённые слова: термин, разбитый между фрагментами, не успеет
+// показаться пользователю до того, как будет найден.
+type streamSanitizer struct {
+	text    strings.Builder
+	emitted int // сколько байт text уже отдано
+}
+
+// write добавляет фрагмент и возвращает текст, готовый к показу.
+// escalate=true — найден запрещённый термин, генерацию нужно прервать.
+func (s *streamSanitizer) write(chunk string) (ready string, escalate bool) {
+	s.text.WriteString(chunk)
+	full := s.text.String()
+	if containsForbidden(full) {
+		return "", true
+	}
+
+	// Отдаём всё до последнего пробела включительно
+	i := strings.LastIndexFunc(full, unicode.IsSpace)
+	if i < 0 {
+		return "", false
+	}
+	_, size := utf8.DecodeRuneInString(full[i:])
+	if end := i + size; end > s.emitted {
+		ready = full[s.emitted:end]
+		s.emitted = end
+	}
+	return ready, false
+}
+
+// rest возвращает неотданный хвост после завершения генерации.
+func (s *streamSanitizer) rest() string {
+	full := s.text.String()
+	tail := full[s.emitted:]
+	s.emitted = len(full)
+	return tail
+}
+
+// String возвращает весь полученный текст.
+func (s *streamSanitizer) String() string {
+	return s.text.String()
+}
@@ -2,9 +2,12 @@
 package main
 
 import (
+    "context"
+    "fmt"
     "log"
     "net/http"
     "os"
+    "strconv"
     "strings"
     "sync"
     "time"
@@ -13,10 +16,18 @@ import (
     "github.com/gin-gonic/gin"
     "github.com/joho/godotenv"
 
+    "github.com/egor/ecochatserver/backplane"
+    "github.com/egor/ecochatserver/channels"
     "github.com/egor/ecochatserver/database"
+    "github.com/egor/ecochatserver/email"
     "github.com/egor/ecochatserver/handlers"
+    "github.com/egor/ecochatserver/llm"
     "github.com/egor/ecochatserver/middleware"
+    "github.com/egor/ecochatserver/routing"
+    "github.com/egor/ecochatserver/telegram"
+    "github.com/egor/ecochatserver/webhooks"
     "github.com/egor/ecochatserver/websocket"
+    "github.com/egor/ecochatserver/whatsapp"
 )
 
 // Простой in-memory кэш для последних чатов
@@ -66,22 +77,127 @@ func main() {
 
     // ─── WebSocket hub ───────────────────────────────────────────────────────
     hub := websocket.NewHub()
+    // Сколько отключённая сессия ждёт переподключения с ?session_id=
+    hub.ResumeWindow = 2 * time.Minute
+    if v := os.Getenv("WS_RESUME_WINDOW"); v != "" {
+        if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+            hub.ResumeWindow = d
+        } else {
+            log.Printf("Некорректный WS_RESUME_WINDOW=%q, используется %s", v, hub.ResumeWindow)
+        }
+    }
+    hub.OnResumeFailed = handlers.ReplayMissedMessages
     go hub.Run()
     
     // Устанавливаем хаб для использования в обработчиках
     handlers.WebSocketHub = hub
+
+    // Доступ операторов к чатам: client — все чаты клиента, assigned —
+    // только свои и свободные (кроме ролей из CHAT_ACCESS_FULL_ROLES)
+    switch mode := getEnv("CHAT_ACCESS_MODE", handlers.ChatAccessClient); mode {
+    case handlers.ChatAccessClient, handlers.ChatAccessAssigned:
+        handlers.ChatAccessMode = mode
+    default:
+        log.Fatalf("Неизвестный CHAT_ACCESS_MODE=%q", mode)
+    }
+    if v := os.Getenv("CHAT_ACCESS_FULL_ROLES"); v != "" {
+        var roles []string
+        for _, role := range strings.Split(v, ",") {
+            if role = strings.TrimSpace(role); role != "" {
+                roles = append(roles, role)
+            }
+        }
+        handlers.ChatAccessFullRoles = roles
+    }
+
+    // Бэкплейн для нескольких реплик: события хаба доходят до соединений
+    // на любом узле
+    clustered := false
+    switch bp := getEnv("HUB_BACKPLANE", ""); bp {
+    case "":
+    case "postgres":
+        channel := getEnv("HUB_BACKPLANE_CHANNEL", "ecochat_hub")
+        hub.UseBackplane(context.Background(), backplane.NewPostgres(channel))
+        clustered = true
+        log.Printf("Бэкплейн хаба: PostgreSQL LISTEN/NOTIFY, канал %s", channel)
+    default:
+        log.Fatalf("Неизвестный HUB_BACKPLANE=%q (поддерживается: postgres)", bp)
+    }
     
     // Запускаем веб-сервер для статистики WebSocket (опционально)
     go startStatsServer(hub)
 
+    // ─── Каналы сообщений ───────────────────────────────────────────────────
+    channels.Register(channels.NewTelegram(telegram.NewClient()))
+    channels.Register(channels.NewWhatsApp(whatsapp.NewClient()))
+    channels.Register(channels.NewWidget())
+    channels.Register(channels.NewEmail(email.Relay{
+        Addr:     os.Getenv("EMAIL_SMTP_RELAY"),
+        Username: os.Getenv("EMAIL_SMTP_USERNAME"),
+        Password: os.Getenv("EMAIL_SMTP_PASSWORD"),
+    }))
+    log.Printf("Каналы сообщений зарегистрированы: %v", channels.Names())
+
+    // Встроенный SMTP-приёмник входящей почты (опционально)
+    go startEmailReceiver()
+
+    // ─── Исходящие вебхуки событий ──────────────────────────────────────────
+    // Внутренние адреса разрешаются только явно (разработка, закрытый контур)
+    webhooks.AllowPrivateTargets = os.Getenv("WEBHOOK_ALLOW_PRIVATE_TARGETS") == "true"
+    go webhooks.NewDispatcher().Run(context.Background())
+
+    // ─── Доставка ответов операторов во внешние каналы ──────────────────────
+    go handlers.RunOutbound(context.Background())
+
+    // ─── Маршрутизация чатов операторам ─────────────────────────────────────
+    router := routing.NewRouter(getEnv("ROUTING_STRATEGY", routing.StrategyLeastLoaded), handlers.OnChatRouted)
+    handlers.ChatRouter = router
+    go router.Run(context.Background())
+
+    // ─── Присутствие операторов ─────────────────────────────────────────────
+    // Соединения, открытые до перезапуска, уже закрыты. В кластере сброс
+    // пропускаем: операторы могут быть подключены к другим узлам, а
+    // операторов упавшего узла переводит в offline истечение его heartbeat.
+    if !clustered {
+        if err := database.ResetPresence(); err != nil {
+            log.Printf("Ошибка сброса статусов операторов: %v", err)
+        }
+    }
+    go handlers.RunPresenceHeartbeat(context.Background())
+    hub.OnAdminConnect = handlers.PresenceConnected
+    hub.OnAdminDisconnect = handlers.PresenceDisconnected
+    if v := os.Getenv("PRESENCE_AWAY_AFTER"); v != "" {
+        if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+            handlers.PresenceAwayAfter = d
+        } else {
+            log.Printf("Некорректный PRESENCE_AWAY_AFTER=%q, используется %s", v, handlers.PresenceAwayAfter)
+        }
+    }
+    go handlers.RunPresenceIdle(context.Background())
+
+    // ─── Автозакрытие неактивных чатов ──────────────────────────────────────
+    if v := os.Getenv("CHAT_AUTO_CLOSE_AFTER"); v != "" {
+        if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+            handlers.AutoCloseAfter = d
+        } else {
+            log.Printf("Некорректный CHAT_AUTO_CLOSE_AFTER=%q, используется %s", v, handlers.AutoCloseAfter)
+        }
+    }
+    go handlers.RunAutoClose(context.Background())
+
     // ─── Автоответчик (если используется) ───────────────────────────────────
-    handlers.InitAutoResponder()
+    handlers.InitAutoResponder(llmProviders())
     log.Println("Автоответчик инициализирован")
 
     // ─── REST API & WebSocket ───────────────────────────────────────────────
     setupAPIRoutes(r)
     log.Println("API маршруты настроены")
 
+    // Каждый маршрут должен быть описан в /api/docs/openapi.json
+    if diff := handlers.UndocumentedRoutes(r.Routes()); len(diff) > 0 {
+        log.Printf("ВНИМАНИЕ: маршруты расходятся с описанием API (handlers/api_spec.go): %v", diff)
+    }
+
     // ─── HTTP-server ─────────────────────────────────────────────────────────
     addr := ":" + getEnv("PORT", "8080")
     log.Printf("HTTP сервер запускается на %s", addr)
@@ -108,9 +224,14 @@ func SimpleDeduplicationMiddleware() gin.HandlerFunc {
             return
         }
         
-        // Исключаем некоторые пути из дедупликации
-        if strings.Contains(c.Request.URL.Path, "/auth/login") ||
-           strings.Contains(c.Request.URL.Path, "/health") {
+        // Исключаем некоторые пути из дедупликации.
+        // Вебхуки мессенджеров подписаны и дедуплицируются по ID обновления
+        // в обработчиках; /webhook/incoming дедуплицируется как обычный POST.
+        path := c.Request.URL.Path
+        channelWebhook := strings.Contains(path, "/webhook/") && !strings.HasSuffix(path, "/webhook/incoming")
+        if strings.Contains(path, "/auth/login") ||
+           strings.Contains(path, "/health") ||
+           channelWebhook {
             c.Next()
             return
         }
@@ -151,7 +272,7 @@ func startStatsServer(hub *websocket.Hub) {
     
     statsRouter.GET("/stats", func(c *gin.Context) {
         stats := hub.GetStats()
-        activeClients := hub.GetActiveClients()
+        activeClients := hub.GetActiveClients("")
         
         c.JSON(http.StatusOK, gin.H{
             "stats":         stats,
@@ -169,6 +290,57 @@ func startStatsServer(hub *websocket.Hub) {
     }
 }
 
+// startEmailReceiver запускает SMTP-приёмник для канала email
+func startEmailReceiver() {
+    addr := os.Getenv("EMAIL_SMTP_LISTEN")
+    if addr == "" {
+        return
+    }
+
+    var maxSize int64
+    if v := os.Getenv("EMAIL_MAX_SIZE"); v != "" {
+        if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+            maxSize = n
+        }
+    }
+
+    srv := &email.Server{
+        Addr:      addr,
+        Domain:    getEnv("EMAIL_SMTP_DOMAIN", "localhost"),
+        MaxSize:   maxSize,
+        Recipient: handlers.IsEmailMailbox,
+        Handler:   handlers.HandleInboundEmail,
+    }
+    if err := srv.ListenAndServe(); err != nil {
+        log.Printf("Ошибка запуска SMTP-приёмника: %v", err)
+    }
+}
+
+// llmProviders возвращает провайдеров LLM: из JSON-файла LLM_PROVIDERS_FILE
+// или, если он не задан, один провайдер "default" из LLM_PROVIDER,
+// LLM_API_URL, LLM_API_KEY, LLM_MODEL и LLM_API_TIMEOUT.
+func llmProviders() llm.ProvidersConfig {
+    if path := os.Getenv("LLM_PROVIDERS_FILE"); path != "" {
+        cfg, err := llm.LoadProvidersConfig(path)
+        if err != nil {
+            log.Fatalf("Ошибка загрузки провайдеров LLM: %v", err)
+        }
+        return cfg
+    }
+    return llm.ProvidersConfig{
+        Default: "default",
+        Providers: map[string]llm.ProviderConfig{
+            "default": {
+                Type:    getEnv("LLM_PROVIDER", llm.ProviderOpenAI),
+                BaseURL: os.Getenv("LLM_API_URL"),
+                APIKey:  os.Getenv("LLM_API_KEY"),
+                Model:   os.Getenv("LLM_MODEL"),
+                Timeout: os.Getenv("LLM_API_TIMEOUT"),
+            },
+        },
+    }
+}
+
 // getEnv возвращает значение или дефолт
 func getEnv(k, def string) string {
     if v := os.Getenv(k); v != "" {
@@ -255,11 +427,27 @@ func setupAPIRoutes(r *gin.Engine) {
             })
         })
 
+        // Документация API: OpenAPI (HTTP) и AsyncAPI (WebSocket)
+        api.GET("/docs", handlers.APIDocs)
+        api.GET("/docs/openapi.json", handlers.OpenAPIDocs)
+        api.GET("/docs/asyncapi.json", handlers.AsyncAPIDocs)
+
         // Авторизация через HTTP
         api.POST("/auth/login", handlers.Login)
         
-        // Webhook для Telegram и других внешних сервисов
-        api.POST("/telegram/webhook", handlers.TelegramWebhook)
+        // Webhook для нативных обновлений Telegram Bot API (отдельный URL на бота)
+        api.POST("/telegram/webhook/:botId", handlers.ChannelWebhook(channels.SourceTelegram))
+
+        // Webhook WhatsApp Cloud API: GET — подтверждение подписки, POST — события
+        api.GET("/whatsapp/webhook/:botId", handlers.ChannelChallenge(channels.SourceWhatsApp))
+        api.POST("/whatsapp/webhook/:botId", handlers.ChannelWebhook(channels.SourceWhatsApp))
+
+        // Универсальный webhook для любого зарегистрированного канала
+        api.GET("/channels/:source/webhook/:botId", handlers.ChannelChallenge(""))
+        api.POST("/channels/:source/webhook/:botId", handlers.ChannelWebhook(""))
+
+        // Webhook в собственном формате IncomingMessage (ретрансляторы и виджет)
+        api.POST("/webhook/incoming", handlers.IncomingWebhook)
         
         // Виджетный API (публичный, для iframe/web widget)
         // Оставляем для обратной совместимости, но рекомендуем использовать WebSocket
@@ -267,6 +455,9 @@ func setupAPIRoutes(r *gin.Engine) {
         {
             // Получение информации о подключении к WebSocket
             widget.GET("/chat/:id/messages", handlers.GetWidgetChatMessages)
+
+            // Индикатор «операторы на связи»
+            widget.GET("/availability", handlers.WidgetAvailability)
             
             // Добавляем новые эндпоинты для миграции на WebSocket
             widget.GET("/info", func(c *gin.Context) {
@@ -291,7 +482,8 @@ func setupAPIRoutes(r *gin.Engine) {
             // Статистика для администраторов
             auth.GET("/admin/stats", func(c *gin.Context) {
                 stats := handlers.WebSocketHub.GetStats()
-                activeClients := handlers.WebSocketHub.GetActiveClients()
+                // Только соединения клиента из токена
+                activeClients := handlers.WebSocketHub.GetActiveClients(c.GetString("clientID"))
                 
                 c.JSON(http.StatusOK, gin.H{
                     "websocket": gin.H{
@@ -301,6 +493,46 @@ func setupAPIRoutes(r *gin.Engine) {
                     "timestamp": time.Now().Format(time.RFC3339),
                 })
             })
+
+            // REST-аналоги WebSocket-команд для интеграций и скриптов
+            v1 := auth.Group("/v1")
+            {
+                v1.GET("/chats", handlers.ListChatsREST)
+                v1.GET("/chats/:id", handlers.GetChatREST)
+                v1.POST("/chats/:id/messages", handlers.SendMessageREST)
+                v1.POST("/chats/:id/read", handlers.MarkAsReadREST)
+                v1.POST("/chats/:id/claim", handlers.ClaimChatREST)
+                v1.POST("/chats/:id/transfer", handlers.TransferChatREST)
+                v1.POST("/chats/:id/unassign", handlers.UnassignChatREST)
+                v1.POST("/chats/:id/status", handlers.SetChatStatusREST)
+            }
+
+            // Подписки на исходящие вебхуки событий (только роль admin)
+            hooks := auth.Group("/webhooks", middleware.RequireRole("admin"))
+            {
+                hooks.GET("", handlers.ListWebhooks)
+                hooks.POST("", handlers.CreateWebhook)
+                hooks.PUT("/:id", handlers.UpdateWebhook)
+                hooks.DELETE("/:id", handlers.DeleteWebhook)
+                hooks.GET("/:id/deliveries", handlers.ListWebhookDeliveries)
+                hooks.POST("/:id/deliveries/:deliveryId/retry", handlers.RetryWebhookDelivery)
+            }
+
+            // Маршрутизация: смотреть могут все операторы, менять — роль admin
+            auth.GET("/routing", handlers.GetRouting)
+            routingGroup := auth.Group("/routing", middleware.RequireRole("admin"))
+            {
+                routingGroup.PUT("", handlers.UpdateRouting)
+                routingGroup.PUT("/operators/:id", handlers.UpdateOperatorRouting)
+            }
+
+            // Автозакрытие неактивных чатов клиента
+            auth.GET("/settings/auto-close", handlers.GetAutoCloseSettings)
+            auth.PUT("/settings/auto-close", middleware.RequireRole("admin"), handlers.UpdateAutoCloseSettings)
+
+            // Автоответчик клиента: промпт, модель, параметры генерации
+            auth.GET("/settings/autoresponder", handlers.GetAutoResponderSettings)
+            auth.PUT("/settings/autoresponder", middleware.RequireRole("admin"), handlers.UpdateAutoResponderSettings)
         }
     }
 
@@ -320,6 +552,8 @@ func setupAPIRoutes(r *gin.Engine) {
                 "websocket": "/ws",
                 "health":    "/api/health",
                 "login":     "/api/auth/login",
+                "chats":     "/api/v1/chats",
+                "docs":      "/api/docs",
             },
             "features": []string{
                 "message_deduplication",
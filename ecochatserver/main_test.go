@@ -0,0 +1,20 @@
+package main
+
+import (
+    "testing"
+
+    "github.com/gin-gonic/gin"
+
+    "github.com/egor/ecochatserver/handlers"
+)
+
+// Каждый маршрут сервера должен быть описан в handlers/api_spec.go.
+func TestRoutesDocumented(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    r := gin.New()
+    setupAPIRoutes(r)
+
+    if diff := handlers.UndocumentedRoutes(r.Routes()); len(diff) > 0 {
+        t.Fatalf("маршруты расходятся с описанием API (handlers/api_spec.go): %v", diff)
+    }
+}
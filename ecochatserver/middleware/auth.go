@@ -59,6 +59,22 @@ func AuthMiddleware() gin.HandlerFunc {
     }
 }
 
+// RequireRole пропускает только администраторов с одной из указанных ролей.
+// Должен стоять после AuthMiddleware.
+func RequireRole(roles ...string) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        role := c.GetString("role")
+        for _, r := range roles {
+            if r == role {
+                c.Next()
+                return
+            }
+        }
+        c.JSON(http.StatusForbidden, gin.H{"error": "недостаточно прав"})
+        c.Abort()
+    }
+}
+
 // JWTClaims определяет структуру данных токена
 type JWTClaims struct {
     AdminID  string `json:"adminId"`
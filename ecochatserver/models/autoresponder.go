@@ -0,0 +1,16 @@
+package models
+
+// AutoResponderSettings — настройки автоответчика клиента (clients.autoresponder_settings).
+// Незаданные поля (nil) берутся из настроек сервера.
+type AutoResponderSettings struct {
+	Enabled      *bool    `json:"enabled,omitempty"`
+	BotName      *string  `json:"botName,omitempty"`
+	SystemPrompt *string  `json:"systemPrompt,omitempty"`
+	Provider     *string  `json:"provider,omitempty"`     // Имя провайдера LLM из конфига сервера
+	DelaySeconds *int     `json:"delaySeconds,omitempty"` // Пауза перед ответом, имитация набора
+	Model        *string  `json:"model,omitempty"`
+	Temperature  *float64 `json:"temperature,omitempty"`
+	MaxTokens    *int     `json:"maxTokens,omitempty"`
+	// Бюджет истории в промпте (оценка в токенах); старые реплики сжимаются в краткое содержание
+	HistoryTokens *int `json:"historyTokens,omitempty"`
+}
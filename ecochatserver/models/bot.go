@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Bot — учётные данные бота/канала, через который клиент общается с пользователями
+type Bot struct {
+	ID        uuid.UUID         `json:"id"`
+	ClientID  uuid.UUID         `json:"clientId"`
+	Source    string            `json:"source"` // "telegram", "whatsapp", etc.
+	BotID     string            `json:"botId"`  // ID бота в источнике (совпадает с chats.bot_id)
+	Token     string            `json:"-"`      // токен API источника, наружу не отдаём
+	Secret    string            `json:"-"`      // секрет проверки входящего вебхука
+	Settings  map[string]string `json:"-"`      // настройки, специфичные для канала
+	Active    bool              `json:"active"`
+	CreatedAt time.Time         `json:"createdAt"`
+}
@@ -5,6 +5,18 @@ import (
 	"github.com/google/uuid"
 )
 
+// Статусы чата
+const (
+	ChatStatusActive  = "active"  // Идёт диалог
+	ChatStatusPending = "pending" // Ждём ответа пользователя
+	ChatStatusClosed  = "closed"  // Закрыт оператором или по неактивности
+)
+
+// IsValidChatStatus сообщает, известен ли статус.
+func IsValidChatStatus(s string) bool {
+	return s == ChatStatusActive || s == ChatStatusPending || s == ChatStatusClosed
+}
+
 // Chat представляет собой структуру чата
 type Chat struct {
 	ID         uuid.UUID              `json:"id"`
@@ -18,9 +30,21 @@ type Chat struct {
 	BotID      string                 `json:"botId"`  // ID бота, через который пришло сообщение
 	ClientID   uuid.UUID              `json:"clientId"` // ID клиента, которому принадлежит бот
 	AssignedTo *uuid.UUID             `json:"assignedTo,omitempty"` // ID сотрудника, которому назначен чат
+	ClosedAt   *time.Time             `json:"closedAt,omitempty"`   // Когда чат закрыт (только для status=closed)
+	Resolution string                 `json:"resolution,omitempty"` // Причина закрытия
 	Metadata   map[string]interface{} `json:"metadata,omitempty"`  // Метаданные чата, включая историю LLM
 }
 
+// ChatStatusChange — результат смены статуса чата
+type ChatStatusChange struct {
+	ChatID         uuid.UUID  `json:"chatId"`
+	ClientID       uuid.UUID  `json:"clientId"`
+	AssignedTo     *uuid.UUID `json:"assignedTo,omitempty"`
+	PreviousStatus string     `json:"previousStatus"`
+	Status         string     `json:"status"`
+	Resolution     string     `json:"resolution,omitempty"`
+}
+
 // ChatResponse для отправки на фронтенд
 type ChatResponse struct {
 	ID          uuid.UUID              `json:"id"`
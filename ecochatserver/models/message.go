@@ -23,16 +23,28 @@ type IncomingMessage struct {
 	UserID      string `json:"userId"`
 	UserName    string `json:"userName"`
 	UserEmail   string `json:"userEmail,omitempty"`
+	SourceID    string `json:"sourceId,omitempty"` // ID чата в источнике (куда отвечать), по умолчанию = UserID
 	Content     string `json:"content"`
 	Source      string `json:"source"` // "telegram", "whatsapp", etc.
 	BotID       string `json:"botId"`
 	ClientID    string `json:"clientId"`
 	MessageType string `json:"messageType,omitempty"`
 	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+
+	// Заполняются адаптером канала, не телом запроса
+	ThreadID   string   `json:"-"` // ветка внутри источника: отдельный чат на ветку (корневой Message-ID письма)
+	ThreadRefs []string `json:"-"` // ID сообщений источника, на которые отвечает это (In-Reply-To, References)
 }
 
 // OutgoingMessage представляет собой исходящее сообщение в WebSocket
 type OutgoingMessage struct {
 	Type    string      `json:"type"` // "new_message", "chat_updated", etc.
 	Payload interface{} `json:"payload"`
-}
\ No newline at end of file
+}
+// OutboundDelivery — сообщение в очереди доставки во внешний канал
+type OutboundDelivery struct {
+	ChatID   uuid.UUID
+	Channel  string
+	Message  Message
+	Attempts int // с учётом текущей попытки
+}
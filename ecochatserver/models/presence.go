@@ -0,0 +1,24 @@
+package models
+
+import "github.com/google/uuid"
+
+// Статусы присутствия оператора
+const (
+	PresenceOnline  = "online"  // Принимает чаты
+	PresenceAway    = "away"    // Отошёл (вручную или по неактивности)
+	PresenceBusy    = "busy"    // На месте, но новые чаты не принимает
+	PresenceOffline = "offline" // Нет подключения
+)
+
+// IsSettablePresence сообщает, может ли оператор выставить статус сам
+// (offline выставляется только при отключении).
+func IsSettablePresence(s string) bool {
+	return s == PresenceOnline || s == PresenceAway || s == PresenceBusy
+}
+
+// AdminPresence — статус присутствия оператора, изменённый сервером
+type AdminPresence struct {
+	AdminID  uuid.UUID
+	ClientID uuid.UUID
+	Status   string
+}
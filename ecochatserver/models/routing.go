@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RoutingOperator — оператор с настройками маршрутизации и текущей нагрузкой
+type RoutingOperator struct {
+	ID          uuid.UUID `json:"id"`
+	ClientID    uuid.UUID `json:"clientId"`
+	Name        string    `json:"name"`
+	Role        string    `json:"role"`
+	Skills      []string  `json:"skills"`      // Теги навыков: источники (telegram) и языки (ru, en)
+	MaxChats    int       `json:"maxChats"`    // Сколько открытых чатов можно назначить автоматически (0 — не назначать)
+	ActiveChats int       `json:"activeChats"` // Сколько открытых чатов назначено сейчас
+	Presence    string    `json:"presence"`    // online | away | busy | offline
+
+	LastRoutedAt time.Time `json:"-"` // Когда роутер последний раз назначил чат (курсор round-robin)
+}
+
+// QueuedChat — неназначенный чат в очереди маршрутизации
+type QueuedChat struct {
+	ID        uuid.UUID `json:"id"`
+	ClientID  uuid.UUID `json:"clientId"`
+	Source    string    `json:"source"`
+	Language  string    `json:"language,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebhookSubscription — подписка клиента на исходящие вебхуки событий
+type WebhookSubscription struct {
+	ID        uuid.UUID `json:"id"`
+	ClientID  uuid.UUID `json:"clientId"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"` // отдаём только при создании
+	Events    []string  `json:"events"`           // пустой список — все события
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// WebhookDelivery — одна попытка доставки события подписчику (строка журнала)
+type WebhookDelivery struct {
+	ID             uuid.UUID       `json:"id"`
+	SubscriptionID uuid.UUID       `json:"subscriptionId"`
+	ClientID       uuid.UUID       `json:"clientId"`
+	EventID        uuid.UUID       `json:"eventId"`
+	EventType      string          `json:"eventType"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"` // "pending", "delivered", "failed"
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"nextAttemptAt"`
+	LastStatusCode int             `json:"lastStatusCode,omitempty"`
+	LastError      string          `json:"lastError,omitempty"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	DeliveredAt    *time.Time      `json:"deliveredAt,omitempty"`
+
+	// Заполняются только при выборке очереди на отправку
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
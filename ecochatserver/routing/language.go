@@ -0,0 +1,51 @@
+package routing
+
+import "unicode"
+
+// scripts — письменности, по которым определяется язык сообщения.
+// Латиница считается английским: точнее без словарей не различить.
+var scripts = []struct {
+    table    *unicode.RangeTable
+    language string
+}{
+    {unicode.Cyrillic, "ru"},
+    {unicode.Georgian, "ka"},
+    {unicode.Armenian, "hy"},
+    {unicode.Arabic, "ar"},
+    {unicode.Hebrew, "he"},
+    {unicode.Han, "zh"},
+    {unicode.Latin, "en"},
+}
+
+// minLetters — меньше букв недостаточно для определения языка
+const minLetters = 3
+
+// DetectLanguage определяет язык текста по преобладающей письменности.
+// Возвращает пустую строку, если букв слишком мало.
+func DetectLanguage(text string) string {
+    counts := make([]int, len(scripts))
+    total := 0
+    for _, r := range text {
+        if !unicode.IsLetter(r) {
+            continue
+        }
+        for i, s := range scripts {
+            if unicode.Is(s.table, r) {
+                counts[i]++
+                total++
+                break
+            }
+        }
+    }
+    if total < minLetters {
+        return ""
+    }
+
+    best := 0
+    for i := range counts {
+        if counts[i] > counts[best] {
+            best = i
+        }
+    }
+    return scripts[best].language
+}
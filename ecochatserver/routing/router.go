@@ -0,0 +1,169 @@
+package routing
+
+import (
+    "context"
+    "errors"
+    "log"
+    "time"
+
+    "github.com/egor/ecochatserver/database"
+    "github.com/egor/ecochatserver/database/queries"
+    "github.com/egor/ecochatserver/models"
+    "github.com/google/uuid"
+)
+
+const (
+    // reevaluateInterval — как часто очередь пересматривается без внешних
+    // событий (освободилась ёмкость, поменялись навыки и т.п.)
+    reevaluateInterval = 30 * time.Second
+    // queueBatchSize — сколько чатов из очереди рассматривается за проход
+    queueBatchSize = 100
+    // lockRetryDelay — через сколько повторить проход, если его сейчас
+    // выполняет другой узел (чтобы не ждать reevaluateInterval)
+    lockRetryDelay = 2 * time.Second
+)
+
+// AssignFunc вызывается после того, как роутер назначил чат оператору.
+type AssignFunc func(chat *models.QueuedChat, adminID uuid.UUID)
+
+// Router назначает неназначенные чаты операторам в статусе online.
+// Очередь — это сами неназначенные чаты в БД, поэтому она переживает
+// перезапуск. Проход выполняется по Wake (новый чат, оператор стал
+// online, возврат чата в очередь) и периодически.
+//
+// При нескольких узлах проход в каждый момент выполняет один из них
+// (advisory-блокировка Postgres), а курсор round-robin хранится в
+// admins.last_routed_at, поэтому лимиты и очерёдность общие.
+type Router struct {
+    defaultStrategy string
+    onAssign        AssignFunc
+
+    wake chan struct{}
+}
+
+// NewRouter создаёт роутер. defaultStrategy применяется к клиентам без
+// своей стратегии.
+func NewRouter(defaultStrategy string, onAssign AssignFunc) *Router {
+    if !IsValidStrategy(defaultStrategy) {
+        log.Printf("[routing] неизвестная стратегия %q, используется %s", defaultStrategy, StrategyLeastLoaded)
+        defaultStrategy = StrategyLeastLoaded
+    }
+    return &Router{
+        defaultStrategy: defaultStrategy,
+        onAssign:        onAssign,
+        wake:            make(chan struct{}, 1),
+    }
+}
+
+// DefaultStrategy возвращает стратегию сервера по умолчанию.
+func (r *Router) DefaultStrategy() string {
+    return r.defaultStrategy
+}
+
+// Wake запрашивает внеочередной проход по очереди.
+func (r *Router) Wake() {
+    select {
+    case r.wake <- struct{}{}:
+    default:
+    }
+}
+
+// Run обрабатывает очередь до отмены ctx.
+func (r *Router) Run(ctx context.Context) {
+    ticker := time.NewTicker(reevaluateInterval)
+    defer ticker.Stop()
+
+    log.Printf("[routing] Маршрутизация чатов запущена (по умолчанию %s)", r.defaultStrategy)
+    for {
+        r.route()
+
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        case <-r.wake:
+        }
+    }
+}
+
+// route выполняет один проход: назначает чаты из очереди свободным операторам.
+func (r *Router) route() {
+    release, ok, err := database.TryAdvisoryLock(queries.LockRouting)
+    if err != nil {
+        log.Printf("[routing] ошибка блокировки прохода: %v", err)
+        return
+    }
+    if !ok {
+        // Проход выполняет другой узел; повторим, чтобы не потерять Wake
+        time.AfterFunc(lockRetryDelay, r.Wake)
+        return
+    }
+    defer release()
+
+    // Кандидаты — операторы online с живой сессией на каком-либо узле; без
+    // них чаты остаются в очереди
+    ops, err := database.ListAvailableOperators()
+    if err != nil {
+        log.Printf("[routing] ошибка загрузки операторов: %v", err)
+        return
+    }
+
+    byClient := make(map[uuid.UUID][]*models.RoutingOperator)
+    var clientIDs []string
+    for i := range ops {
+        op := &ops[i]
+        if _, ok := byClient[op.ClientID]; !ok {
+            clientIDs = append(clientIDs, op.ClientID.String())
+        }
+        byClient[op.ClientID] = append(byClient[op.ClientID], op)
+    }
+    if len(clientIDs) == 0 {
+        return
+    }
+
+    strategies, err := database.GetRoutingStrategies(clientIDs)
+    if err != nil {
+        log.Printf("[routing] ошибка загрузки стратегий: %v", err)
+        return
+    }
+
+    queue, err := database.ListQueuedChats(clientIDs, queueBatchSize)
+    if err != nil {
+        log.Printf("[routing] ошибка загрузки очереди: %v", err)
+        return
+    }
+
+    for i := range queue {
+        chat := &queue[i]
+        strategy := strategies[chat.ClientID]
+        if strategy == "" {
+            strategy = r.defaultStrategy
+        }
+        if strategy == StrategyManual {
+            continue
+        }
+
+        op := pick(strategy, chat, byClient[chat.ClientID])
+        if op == nil {
+            continue // ждёт в очереди, пока не освободится оператор
+        }
+
+        err := database.ChangeChatAssignment(chat.ID, chat.ClientID, nil, &op.ID, nil, queries.AssignmentRoute, strategy)
+        if errors.Is(err, database.ErrAssignmentConflict) {
+            continue // чат уже взяли вручную
+        }
+        if err != nil {
+            log.Printf("[routing] ошибка назначения чата %s: %v", chat.ID, err)
+            continue
+        }
+
+        op.ActiveChats++
+        op.LastRoutedAt = time.Now()
+        log.Printf("[routing] чат %s (%s/%s) назначен оператору %s (%s)",
+            chat.ID, chat.Source, chat.Language, op.ID, strategy)
+
+        if r.onAssign != nil {
+            r.onAssign(chat, op.ID)
+        }
+    }
+}
@@ -0,0 +1,98 @@
+package routing
+
+import (
+    "strings"
+
+    "github.com/egor/ecochatserver/models"
+)
+
+// Стратегии маршрутизации
+const (
+    // StrategyRoundRobin — по очереди: чат получает оператор, которому
+    // назначали дольше всех
+    StrategyRoundRobin = "round_robin"
+    // StrategyLeastLoaded — оператор с наименьшим числом открытых чатов
+    StrategyLeastLoaded = "least_loaded"
+    // StrategySkills — операторы, у которых есть навык с источником или
+    // языком чата; без совпадений — операторы без навыков; среди них
+    // выбирается наименее загруженный
+    StrategySkills = "skills"
+    // StrategyManual — не назначать автоматически, операторы берут чаты сами
+    StrategyManual = "manual"
+)
+
+// Strategies — все поддерживаемые стратегии
+var Strategies = []string{StrategyRoundRobin, StrategyLeastLoaded, StrategySkills, StrategyManual}
+
+// IsValidStrategy сообщает, известна ли стратегия.
+func IsValidStrategy(s string) bool {
+    for _, v := range Strategies {
+        if v == s {
+            return true
+        }
+    }
+    return false
+}
+
+// hasCapacity — можно ли назначить оператору ещё один чат
+func hasCapacity(op *models.RoutingOperator) bool {
+    return op.MaxChats > 0 && op.ActiveChats < op.MaxChats
+}
+
+// hasSkill сообщает, есть ли у оператора один из тегов (без учёта регистра).
+func hasSkill(op *models.RoutingOperator, tags ...string) bool {
+    for _, skill := range op.Skills {
+        for _, tag := range tags {
+            if tag != "" && strings.EqualFold(skill, tag) {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+// pick выбирает оператора для чата или возвращает nil, если свободных нет.
+// Для round-robin и разрешения ничьих используется LastRoutedAt оператора.
+func pick(strategy string, chat *models.QueuedChat, ops []*models.RoutingOperator) *models.RoutingOperator {
+    var candidates []*models.RoutingOperator
+    for _, op := range ops {
+        if hasCapacity(op) {
+            candidates = append(candidates, op)
+        }
+    }
+
+    if strategy == StrategySkills {
+        var matched, generalists []*models.RoutingOperator
+        for _, op := range candidates {
+            switch {
+            case hasSkill(op, chat.Source, chat.Language):
+                matched = append(matched, op)
+            case len(op.Skills) == 0:
+                generalists = append(generalists, op)
+            }
+        }
+        candidates = matched
+        if len(candidates) == 0 {
+            candidates = generalists
+        }
+    }
+
+    var best *models.RoutingOperator
+    for _, op := range candidates {
+        if best == nil || better(strategy, op, best) {
+            best = op
+        }
+    }
+    return best
+}
+
+// better сравнивает двух кандидатов по правилам стратегии.
+func better(strategy string, a, b *models.RoutingOperator) bool {
+    if strategy != StrategyRoundRobin && a.ActiveChats != b.ActiveChats {
+        return a.ActiveChats < b.ActiveChats
+    }
+    if !a.LastRoutedAt.Equal(b.LastRoutedAt) {
+        return a.LastRoutedAt.Before(b.LastRoutedAt)
+    }
+    return a.ID.String() < b.ID.String()
+}
@@ -0,0 +1,81 @@
+package routing
+
+import (
+    "testing"
+    "time"
+
+    "github.com/google/uuid"
+
+    "github.com/egor/ecochatserver/models"
+)
+
+func TestPick(t *testing.T) {
+    now := time.Now()
+    op := func(name string, active, max int, lastRouted time.Time, skills ...string) *models.RoutingOperator {
+        return &models.RoutingOperator{
+            ID: uuid.New(), Name: name, ActiveChats: active, MaxChats: max,
+            LastRoutedAt: lastRouted, Skills: skills,
+        }
+    }
+    chat := &models.QueuedChat{Source: "telegram", Language: "en"}
+
+    tests := []struct {
+        name     string
+        strategy string
+        ops      []*models.RoutingOperator
+        want     string
+    }{
+        {
+            name:     "round robin: дольше всех без назначения",
+            strategy: StrategyRoundRobin,
+            ops:      []*models.RoutingOperator{op("a", 0, 5, now), op("b", 3, 5, now.Add(-time.Minute)), op("c", 1, 5, now.Add(-time.Second))},
+            want:     "b",
+        },
+        {
+            name:     "round robin: никогда не назначали — первым",
+            strategy: StrategyRoundRobin,
+            ops:      []*models.RoutingOperator{op("a", 0, 5, now.Add(-time.Hour)), op("b", 0, 5, time.Time{})},
+            want:     "b",
+        },
+        {
+            name:     "least loaded",
+            strategy: StrategyLeastLoaded,
+            ops:      []*models.RoutingOperator{op("a", 2, 5, time.Time{}), op("b", 1, 5, now)},
+            want:     "b",
+        },
+        {
+            name:     "least loaded: ничья по курсору",
+            strategy: StrategyLeastLoaded,
+            ops:      []*models.RoutingOperator{op("a", 1, 5, now), op("b", 1, 5, now.Add(-time.Minute))},
+            want:     "b",
+        },
+        {
+            name:     "лимит исчерпан",
+            strategy: StrategyLeastLoaded,
+            ops:      []*models.RoutingOperator{op("a", 5, 5, time.Time{}), op("b", 0, 0, time.Time{})},
+        },
+        {
+            name:     "skills: совпадение по языку",
+            strategy: StrategySkills,
+            ops:      []*models.RoutingOperator{op("a", 0, 5, time.Time{}), op("b", 3, 5, now, "EN")},
+            want:     "b",
+        },
+        {
+            name:     "skills: без совпадений — операторы без навыков",
+            strategy: StrategySkills,
+            ops:      []*models.RoutingOperator{op("a", 0, 5, time.Time{}, "de"), op("b", 3, 5, now)},
+            want:     "b",
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got := pick(tt.strategy, chat, tt.ops)
+            switch {
+            case got == nil && tt.want != "":
+                t.Fatalf("оператор не выбран, ожидался %s", tt.want)
+            case got != nil && got.Name != tt.want:
+                t.Fatalf("выбран %s, ожидался %q", got.Name, tt.want)
+            }
+        })
+    }
+}
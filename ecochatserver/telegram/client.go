@@ -0,0 +1,158 @@
+// Package telegram — минимальный клиент Telegram Bot API для исходящих сообщений.
+package telegram
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "os"
+    "strings"
+    "time"
+)
+
+// DefaultAPIURL — адрес Bot API по умолчанию
+const DefaultAPIURL = "https://api.telegram.org"
+
+// Client отправляет запросы в Telegram Bot API.
+type Client struct {
+    baseURL string
+    client  *http.Client
+}
+
+// SentMessage — часть объекта Message, которую возвращает sendMessage.
+type SentMessage struct {
+    MessageID int64 `json:"message_id"`
+    Date      int64 `json:"date"`
+}
+
+// APIError описывает ошибку, возвращённую Bot API или транспортом.
+type APIError struct {
+    StatusCode  int
+    Description string
+    RetryAfter  int // секунды, из parameters.retry_after (только для 429)
+}
+
+func (e *APIError) Error() string {
+    return fmt.Sprintf("telegram api: status %d: %s", e.StatusCode, e.Description)
+}
+
+// Temporary сообщает, имеет ли смысл повторить запрос.
+func (e *APIError) Temporary() bool {
+    return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// apiResponse — общий конверт ответов Bot API.
+type apiResponse struct {
+    OK          bool            `json:"ok"`
+    Result      json.RawMessage `json:"result"`
+    ErrorCode   int             `json:"error_code"`
+    Description string          `json:"description"`
+    Parameters  *struct {
+        RetryAfter int `json:"retry_after"`
+    } `json:"parameters,omitempty"`
+}
+
+// NewClient создаёт клиента; адрес API берётся из TELEGRAM_API_URL
+// (удобно для локальной подмены api.telegram.org в тестах).
+func NewClient() *Client {
+    baseURL := os.Getenv("TELEGRAM_API_URL")
+    if baseURL == "" {
+        baseURL = DefaultAPIURL
+    }
+    return NewClientWithURL(baseURL, &http.Client{Timeout: 15 * time.Second})
+}
+
+// NewClientWithURL создаёт клиента с явным адресом API и HTTP-клиентом.
+func NewClientWithURL(baseURL string, httpClient *http.Client) *Client {
+    if httpClient == nil {
+        httpClient = http.DefaultClient
+    }
+    return &Client{
+        baseURL: strings.TrimRight(baseURL, "/"),
+        client:  httpClient,
+    }
+}
+
+// SendMessage отправляет текстовое сообщение в чат Telegram.
+func (c *Client) SendMessage(ctx context.Context, token, chatID, text string) (*SentMessage, error) {
+    body := map[string]interface{}{
+        "chat_id": chatID,
+        "text":    text,
+    }
+
+    var sent SentMessage
+    if err := c.call(ctx, token, "sendMessage", body, &sent); err != nil {
+        return nil, err
+    }
+    return &sent, nil
+}
+
+// call выполняет метод Bot API и декодирует result в out.
+func (c *Client) call(ctx context.Context, token, method string, body interface{}, out interface{}) error {
+    if token == "" {
+        return &APIError{StatusCode: http.StatusUnauthorized, Description: "пустой токен бота"}
+    }
+
+    payload, err := json.Marshal(body)
+    if err != nil {
+        return fmt.Errorf("marshal request body: %w", err)
+    }
+
+    endpoint := fmt.Sprintf("%s/bot%s/%s", c.baseURL, token, method)
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+    if err != nil {
+        return fmt.Errorf("create HTTP request: %w", err)
+    }
+    req.Header.Set("Content-Type", "application/json")
+
+    resp, err := c.client.Do(req)
+    if err != nil {
+        // Сетевые ошибки считаем временными
+        return &APIError{StatusCode: http.StatusBadGateway, Description: err.Error()}
+    }
+    defer resp.Body.Close()
+
+    raw, err := io.ReadAll(resp.Body)
+    if err != nil {
+        return &APIError{StatusCode: http.StatusBadGateway, Description: err.Error()}
+    }
+
+    var envelope apiResponse
+    if err := json.Unmarshal(raw, &envelope); err != nil {
+        return &APIError{StatusCode: resp.StatusCode, Description: "некорректный ответ: " + string(raw)}
+    }
+
+    if !envelope.OK {
+        apiErr := &APIError{StatusCode: envelope.ErrorCode, Description: envelope.Description}
+        if apiErr.StatusCode == 0 {
+            apiErr.StatusCode = resp.StatusCode
+        }
+        if envelope.Parameters != nil {
+            apiErr.RetryAfter = envelope.Parameters.RetryAfter
+        }
+        return apiErr
+    }
+
+    if out != nil && len(envelope.Result) > 0 {
+        if err := json.Unmarshal(envelope.Result, out); err != nil {
+            return fmt.Errorf("decode result: %w", err)
+        }
+    }
+    return nil
+}
+
+// AnswerCallbackQuery подтверждает нажатие inline-кнопки (убирает «часики» в клиенте).
+func (c *Client) AnswerCallbackQuery(ctx context.Context, token, callbackQueryID string) error {
+    body := map[string]interface{}{
+        "callback_query_id": callbackQueryID,
+    }
+    return c.call(ctx, token, "answerCallbackQuery", body, nil)
+}
+
+// RetryDelay возвращает задержку из parameters.retry_after.
+func (e *APIError) RetryDelay() time.Duration {
+    return time.Duration(e.RetryAfter) * time.Second
+}
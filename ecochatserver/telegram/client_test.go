@@ -0,0 +1,111 @@
+package telegram
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+)
+
+// stubAPI поднимает локальную подмену api.telegram.org с обработчиком handle.
+func stubAPI(t *testing.T, handle http.HandlerFunc) *Client {
+    t.Helper()
+    srv := httptest.NewServer(handle)
+    t.Cleanup(srv.Close)
+    return NewClientWithURL(srv.URL, srv.Client())
+}
+
+func TestSendMessageSuccess(t *testing.T) {
+    var got map[string]any
+    c := stubAPI(t, func(w http.ResponseWriter, r *http.Request) {
+        if r.URL.Path != "/bot123:abc/sendMessage" {
+            t.Errorf("путь %q", r.URL.Path)
+        }
+        if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+            t.Errorf("тело запроса: %v", err)
+        }
+        w.Write([]byte(`{"ok":true,"result":{"message_id":42,"date":1700000000}}`))
+    })
+
+    sent, err := c.SendMessage(context.Background(), "123:abc", "777", "привет")
+    if err != nil {
+        t.Fatalf("SendMessage: %v", err)
+    }
+    if sent.MessageID != 42 {
+        t.Errorf("MessageID = %d, want 42", sent.MessageID)
+    }
+    if got["chat_id"] != "777" || got["text"] != "привет" {
+        t.Errorf("тело запроса = %v", got)
+    }
+}
+
+func TestSendMessageErrors(t *testing.T) {
+    tests := []struct {
+        name      string
+        status    int
+        body      string
+        temporary bool
+        delay     time.Duration
+    }{
+        {
+            name:   "bad request",
+            status: http.StatusBadRequest,
+            body:   `{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`,
+        },
+        {
+            name:   "blocked by user",
+            status: http.StatusForbidden,
+            body:   `{"ok":false,"error_code":403,"description":"Forbidden: bot was blocked by the user"}`,
+        },
+        {
+            name:      "too many requests",
+            status:    http.StatusTooManyRequests,
+            body:      `{"ok":false,"error_code":429,"description":"Too Many Requests","parameters":{"retry_after":3}}`,
+            temporary: true,
+            delay:     3 * time.Second,
+        },
+        {
+            name:      "server error",
+            status:    http.StatusBadGateway,
+            body:      `<html>bad gateway</html>`,
+            temporary: true,
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            c := stubAPI(t, func(w http.ResponseWriter, r *http.Request) {
+                w.WriteHeader(tt.status)
+                w.Write([]byte(tt.body))
+            })
+
+            _, err := c.SendMessage(context.Background(), "123:abc", "777", "привет")
+            var apiErr *APIError
+            if !errors.As(err, &apiErr) {
+                t.Fatalf("ошибка %v, want *APIError", err)
+            }
+            if apiErr.StatusCode != tt.status {
+                t.Errorf("StatusCode = %d, want %d", apiErr.StatusCode, tt.status)
+            }
+            if apiErr.Temporary() != tt.temporary {
+                t.Errorf("Temporary() = %v, want %v", apiErr.Temporary(), tt.temporary)
+            }
+            if apiErr.RetryDelay() != tt.delay {
+                t.Errorf("RetryDelay() = %v, want %v", apiErr.RetryDelay(), tt.delay)
+            }
+        })
+    }
+}
+
+func TestSendMessageEmptyToken(t *testing.T) {
+    c := stubAPI(t, func(w http.ResponseWriter, r *http.Request) {
+        t.Error("запрос без токена не должен уходить в API")
+    })
+    _, err := c.SendMessage(context.Background(), "", "777", "привет")
+    var apiErr *APIError
+    if !errors.As(err, &apiErr) || apiErr.Temporary() {
+        t.Fatalf("ошибка %v, want постоянную *APIError", err)
+    }
+}
@@ -0,0 +1,208 @@
+package telegram
+
+import (
+    "strconv"
+    "strings"
+)
+
+// Update — входящее обновление Bot API (используемое подмножество полей).
+type Update struct {
+    UpdateID      int64          `json:"update_id"`
+    Message       *Message       `json:"message,omitempty"`
+    EditedMessage *Message       `json:"edited_message,omitempty"`
+    CallbackQuery *CallbackQuery `json:"callback_query,omitempty"`
+}
+
+// User — отправитель сообщения.
+type User struct {
+    ID           int64  `json:"id"`
+    IsBot        bool   `json:"is_bot"`
+    FirstName    string `json:"first_name"`
+    LastName     string `json:"last_name,omitempty"`
+    Username     string `json:"username,omitempty"`
+    LanguageCode string `json:"language_code,omitempty"`
+}
+
+// Chat — чат, в который пришло сообщение.
+type Chat struct {
+    ID       int64  `json:"id"`
+    Type     string `json:"type"`
+    Username string `json:"username,omitempty"`
+    Title    string `json:"title,omitempty"`
+}
+
+// Message — сообщение Telegram.
+type Message struct {
+    MessageID int64       `json:"message_id"`
+    From      *User       `json:"from,omitempty"`
+    Chat      Chat        `json:"chat"`
+    Date      int64       `json:"date"`
+    EditDate  int64       `json:"edit_date,omitempty"`
+    Text      string      `json:"text,omitempty"`
+    Caption   string      `json:"caption,omitempty"`
+    Photo     []PhotoSize `json:"photo,omitempty"`
+    Document  *Document   `json:"document,omitempty"`
+    Voice     *Voice      `json:"voice,omitempty"`
+    Sticker   *Sticker    `json:"sticker,omitempty"`
+}
+
+// PhotoSize — один из размеров фото.
+type PhotoSize struct {
+    FileID       string `json:"file_id"`
+    FileUniqueID string `json:"file_unique_id"`
+    Width        int    `json:"width"`
+    Height       int    `json:"height"`
+    FileSize     int64  `json:"file_size,omitempty"`
+}
+
+// Document — произвольный файл.
+type Document struct {
+    FileID       string `json:"file_id"`
+    FileUniqueID string `json:"file_unique_id"`
+    FileName     string `json:"file_name,omitempty"`
+    MimeType     string `json:"mime_type,omitempty"`
+    FileSize     int64  `json:"file_size,omitempty"`
+}
+
+// Voice — голосовое сообщение.
+type Voice struct {
+    FileID       string `json:"file_id"`
+    FileUniqueID string `json:"file_unique_id"`
+    Duration     int    `json:"duration"`
+    MimeType     string `json:"mime_type,omitempty"`
+    FileSize     int64  `json:"file_size,omitempty"`
+}
+
+// Sticker — стикер.
+type Sticker struct {
+    FileID       string `json:"file_id"`
+    FileUniqueID string `json:"file_unique_id"`
+    Emoji        string `json:"emoji,omitempty"`
+    SetName      string `json:"set_name,omitempty"`
+}
+
+// CallbackQuery — нажатие inline-кнопки.
+type CallbackQuery struct {
+    ID      string   `json:"id"`
+    From    User     `json:"from"`
+    Message *Message `json:"message,omitempty"`
+    Data    string   `json:"data,omitempty"`
+}
+
+// Inbound — нормализованное входящее сообщение, извлечённое из Update.
+type Inbound struct {
+    UserID      string // from.id
+    ChatID      string // chat.id — куда отвечать
+    UserName    string
+    Content     string
+    MessageType string // "text", "image", "file", "voice", "sticker", "callback"
+    Metadata    map[string]interface{}
+}
+
+// ParseUpdate извлекает сообщение пользователя из Update.
+// Возвращает ok=false для неподдерживаемых типов обновлений.
+func ParseUpdate(u *Update) (in *Inbound, ok bool) {
+    switch {
+    case u.Message != nil:
+        return parseMessage(u, u.Message, false)
+    case u.EditedMessage != nil:
+        return parseMessage(u, u.EditedMessage, true)
+    case u.CallbackQuery != nil:
+        return parseCallback(u, u.CallbackQuery)
+    }
+    return nil, false
+}
+
+func parseMessage(u *Update, m *Message, edited bool) (*Inbound, bool) {
+    if m.From == nil || m.From.IsBot {
+        return nil, false
+    }
+
+    in := newInbound(u, m.From, m.Chat)
+    in.Metadata["telegramMessageId"] = m.MessageID
+    if edited {
+        in.Metadata["edited"] = true
+        in.Metadata["editDate"] = m.EditDate
+    }
+
+    switch {
+    case len(m.Photo) > 0:
+        // Telegram присылает размеры по возрастанию — берём самый большой
+        p := m.Photo[len(m.Photo)-1]
+        in.MessageType = "image"
+        in.Content = m.Caption
+        in.Metadata["fileId"] = p.FileID
+        in.Metadata["fileUniqueId"] = p.FileUniqueID
+        in.Metadata["width"] = p.Width
+        in.Metadata["height"] = p.Height
+    case m.Document != nil:
+        in.MessageType = "file"
+        in.Content = m.Caption
+        in.Metadata["fileId"] = m.Document.FileID
+        in.Metadata["fileUniqueId"] = m.Document.FileUniqueID
+        in.Metadata["fileName"] = m.Document.FileName
+        in.Metadata["mimeType"] = m.Document.MimeType
+    case m.Voice != nil:
+        in.MessageType = "voice"
+        in.Content = m.Caption
+        in.Metadata["fileId"] = m.Voice.FileID
+        in.Metadata["fileUniqueId"] = m.Voice.FileUniqueID
+        in.Metadata["duration"] = m.Voice.Duration
+        in.Metadata["mimeType"] = m.Voice.MimeType
+    case m.Sticker != nil:
+        in.MessageType = "sticker"
+        in.Content = m.Sticker.Emoji
+        in.Metadata["fileId"] = m.Sticker.FileID
+        in.Metadata["fileUniqueId"] = m.Sticker.FileUniqueID
+        in.Metadata["stickerSet"] = m.Sticker.SetName
+    case m.Text != "":
+        in.MessageType = "text"
+        in.Content = m.Text
+    default:
+        return nil, false
+    }
+    return in, true
+}
+
+func parseCallback(u *Update, q *CallbackQuery) (*Inbound, bool) {
+    if q.Message == nil {
+        return nil, false
+    }
+    in := newInbound(u, &q.From, q.Message.Chat)
+    in.MessageType = "callback"
+    in.Content = q.Data
+    in.Metadata["callbackQueryId"] = q.ID
+    in.Metadata["telegramMessageId"] = q.Message.MessageID
+    return in, true
+}
+
+func newInbound(u *Update, from *User, chat Chat) *Inbound {
+    meta := map[string]interface{}{
+        "telegramUpdateId": u.UpdateID,
+        "telegramChatId":   chat.ID,
+    }
+    if from.Username != "" {
+        meta["username"] = from.Username
+    }
+    if from.LanguageCode != "" {
+        meta["languageCode"] = from.LanguageCode
+    }
+    return &Inbound{
+        UserID:   strconv.FormatInt(from.ID, 10),
+        ChatID:   strconv.FormatInt(chat.ID, 10),
+        UserName: displayName(from),
+        Metadata: meta,
+    }
+}
+
+// displayName собирает имя пользователя: «Имя Фамилия», иначе @username.
+func displayName(u *User) string {
+    name := strings.TrimSpace(u.FirstName + " " + u.LastName)
+    if name != "" {
+        return name
+    }
+    if u.Username != "" {
+        return "@" + u.Username
+    }
+    return strconv.FormatInt(u.ID, 10)
+}
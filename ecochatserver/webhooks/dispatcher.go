@@ -0,0 +1,164 @@
+package webhooks
+
+import (
+    "bytes"
+    "context"
+    "fmt"
+    "io"
+    "log"
+    "net/http"
+    "strconv"
+    "sync"
+    "time"
+
+    "github.com/egor/ecochatserver/database"
+    "github.com/egor/ecochatserver/database/queries"
+    "github.com/egor/ecochatserver/models"
+)
+
+const (
+    // MaxAttempts — после стольких неудачных попыток доставка помечается failed
+    MaxAttempts = 10
+
+    retryBaseDelay = 15 * time.Second
+    retryMaxDelay  = time.Hour
+
+    pollInterval   = 5 * time.Second
+    claimBatchSize = 20
+    requestTimeout = 10 * time.Second
+    // claimLease — на сколько откладывается забранная доставка; должна быть
+    // больше requestTimeout, чтобы не отправить одно событие дважды
+    claimLease = time.Minute
+
+    maxErrorBody = 1024
+)
+
+var wake = make(chan struct{}, 1)
+
+// wakeDispatcher будит Dispatcher, не дожидаясь очередного тика.
+func wakeDispatcher() {
+    select {
+    case wake <- struct{}{}:
+    default:
+    }
+}
+
+// Dispatcher отправляет доставки из очереди webhook_deliveries.
+type Dispatcher struct {
+    client *http.Client
+}
+
+// NewDispatcher создаёт диспетчер с HTTP-клиентом, который не ходит
+// во внутреннюю сеть (см. AllowPrivateTargets).
+func NewDispatcher() *Dispatcher {
+    return &Dispatcher{client: newSafeClient(requestTimeout)}
+}
+
+// Run обрабатывает очередь до отмены ctx.
+func (d *Dispatcher) Run(ctx context.Context) {
+    ticker := time.NewTicker(pollInterval)
+    defer ticker.Stop()
+
+    log.Println("[webhooks] Диспетчер исходящих вебхуков запущен")
+    for {
+        // Забираем пачки, пока очередь не опустеет
+        for ctx.Err() == nil && d.processBatch(ctx) == claimBatchSize {
+        }
+
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        case <-wake:
+        }
+    }
+}
+
+// processBatch отправляет одну пачку доставок и возвращает её размер.
+func (d *Dispatcher) processBatch(ctx context.Context) int {
+    batch, err := database.ClaimWebhookDeliveries(claimBatchSize, claimLease)
+    if err != nil {
+        log.Printf("[webhooks] ошибка выборки очереди: %v", err)
+        return 0
+    }
+
+    var wg sync.WaitGroup
+    for i := range batch {
+        wg.Add(1)
+        go func(del *models.WebhookDelivery) {
+            defer wg.Done()
+            d.deliver(ctx, del)
+        }(&batch[i])
+    }
+    wg.Wait()
+    return len(batch)
+}
+
+// deliver выполняет одну попытку и сохраняет её результат в журнал.
+func (d *Dispatcher) deliver(ctx context.Context, del *models.WebhookDelivery) {
+    code, err := d.post(ctx, del)
+    if err == nil {
+        if err := database.CompleteWebhookDelivery(del.ID, queries.WebhookDeliveryDelivered, code, "", time.Time{}); err != nil {
+            log.Printf("[webhooks] доставка %s: ошибка сохранения результата: %v", del.ID, err)
+        }
+        return
+    }
+
+    status := queries.WebhookDeliveryPending
+    nextAt := time.Now().Add(retryDelay(del.Attempts))
+    if del.Attempts >= MaxAttempts {
+        status = queries.WebhookDeliveryFailed
+    }
+    log.Printf("[webhooks] доставка %s (%s → %s), попытка %d/%d: %v",
+        del.ID, del.EventType, del.URL, del.Attempts, MaxAttempts, err)
+
+    if err := database.CompleteWebhookDelivery(del.ID, status, code, err.Error(), nextAt); err != nil {
+        log.Printf("[webhooks] доставка %s: ошибка сохранения результата: %v", del.ID, err)
+    }
+}
+
+// post отправляет подписанный запрос. Успех — любой ответ 2xx.
+func (d *Dispatcher) post(ctx context.Context, del *models.WebhookDelivery) (int, error) {
+    ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+    defer cancel()
+
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, del.URL, bytes.NewReader(del.Payload))
+    if err != nil {
+        return 0, err
+    }
+
+    ts := time.Now().Unix()
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set("User-Agent", "EcoChat-Webhooks/1.0")
+    req.Header.Set(HeaderEvent, del.EventType)
+    req.Header.Set(HeaderEventID, del.EventID.String())
+    req.Header.Set(HeaderDelivery, del.ID.String())
+    req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+    req.Header.Set(HeaderSignature, Sign(del.Secret, ts, del.Payload))
+
+    resp, err := d.client.Do(req)
+    if err != nil {
+        return 0, err
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+        _, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBody))
+        return resp.StatusCode, nil
+    }
+
+    body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+    return resp.StatusCode, fmt.Errorf("status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+}
+
+// retryDelay — экспоненциальная задержка перед попыткой attempt+1.
+func retryDelay(attempt int) time.Duration {
+    delay := retryBaseDelay
+    for i := 1; i < attempt && delay < retryMaxDelay; i++ {
+        delay *= 2
+    }
+    if delay > retryMaxDelay {
+        delay = retryMaxDelay
+    }
+    return delay
+}
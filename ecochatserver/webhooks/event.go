@@ -0,0 +1,83 @@
+// Package webhooks — исходящие вебхуки событий чата для внешних систем (CRM и т.п.).
+//
+// События ставятся в очередь в Postgres (таблица webhook_deliveries) и
+// отправляются Dispatcher'ом с HMAC-SHA256 подписью и экспоненциальными повторами.
+package webhooks
+
+import (
+    "encoding/json"
+    "log"
+    "time"
+
+    "github.com/google/uuid"
+
+    "github.com/egor/ecochatserver/database"
+)
+
+// Типы событий, на которые можно подписаться
+const (
+    EventChatCreated    = "chat.created"
+    EventMessageCreated = "message.created"
+    EventChatAssigned   = "chat.assigned"
+    EventChatClosed     = "chat.closed"
+    EventChatReopened   = "chat.reopened"
+)
+
+// Events — все поддерживаемые типы событий
+var Events = []string{
+    EventChatCreated,
+    EventMessageCreated,
+    EventChatAssigned,
+    EventChatClosed,
+    EventChatReopened,
+}
+
+// IsValidEvent сообщает, поддерживается ли тип события.
+func IsValidEvent(eventType string) bool {
+    for _, e := range Events {
+        if e == eventType {
+            return true
+        }
+    }
+    return false
+}
+
+// Event — тело запроса, которое получает подписчик
+type Event struct {
+    ID        uuid.UUID `json:"id"`
+    Type      string    `json:"type"`
+    ClientID  uuid.UUID `json:"clientId"`
+    CreatedAt time.Time `json:"createdAt"`
+    Data      any       `json:"data"`
+}
+
+// Emit ставит событие в очередь доставки для подписок клиента.
+// Ошибки только логируются: вебхуки не должны ломать основной поток чата.
+func Emit(clientID uuid.UUID, eventType string, data any) {
+    if clientID == uuid.Nil {
+        log.Printf("webhooks.Emit: событие %s без клиента пропущено", eventType)
+        return
+    }
+
+    ev := Event{
+        ID:        uuid.New(),
+        Type:      eventType,
+        ClientID:  clientID,
+        CreatedAt: time.Now().UTC(),
+        Data:      data,
+    }
+    payload, err := json.Marshal(ev)
+    if err != nil {
+        log.Printf("webhooks.Emit: ошибка сериализации %s: %v", eventType, err)
+        return
+    }
+
+    n, err := database.EnqueueWebhookEvent(clientID, ev.ID, eventType, payload)
+    if err != nil {
+        log.Printf("webhooks.Emit: ошибка постановки %s в очередь: %v", eventType, err)
+        return
+    }
+    if n > 0 {
+        wakeDispatcher()
+    }
+}
@@ -0,0 +1,39 @@
+package webhooks
+
+import (
+    "crypto/hmac"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/hex"
+    "strconv"
+)
+
+// Заголовки исходящего запроса
+const (
+    HeaderEvent     = "X-EcoChat-Event"
+    HeaderEventID   = "X-EcoChat-Event-Id"
+    HeaderDelivery  = "X-EcoChat-Delivery"
+    HeaderTimestamp = "X-EcoChat-Timestamp"
+    HeaderSignature = "X-EcoChat-Signature"
+    signaturePrefix = "sha256="
+)
+
+// Sign вычисляет подпись "sha256=<hex>" от строки "<timestamp>.<body>".
+// Получатель пересчитывает её своим секретом и сравнивает с HeaderSignature;
+// timestamp в подписи защищает от повторного воспроизведения старых запросов.
+func Sign(secret string, timestamp int64, body []byte) string {
+    mac := hmac.New(sha256.New, []byte(secret))
+    mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+    mac.Write([]byte("."))
+    mac.Write(body)
+    return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// GenerateSecret создаёт случайный секрет подписи для новой подписки.
+func GenerateSecret() (string, error) {
+    b := make([]byte, 32)
+    if _, err := rand.Read(b); err != nil {
+        return "", err
+    }
+    return "whsec_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,126 @@
+package webhooks
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net"
+    "net/http"
+    "net/url"
+    "syscall"
+    "time"
+)
+
+// ErrForbiddenTarget — URL вебхука ведёт во внутреннюю сеть сервера
+var ErrForbiddenTarget = errors.New("адрес вебхука указывает на внутреннюю сеть")
+
+// AllowPrivateTargets разрешает вебхуки на внутренние адреса (loopback,
+// RFC 1918, link-local). Только для разработки и закрытых инсталляций.
+var AllowPrivateTargets = false
+
+// blockedNets — диапазоны, не покрытые методами net.IP
+var blockedNets = mustParseCIDRs(
+    "0.0.0.0/8",     // «этот» узел
+    "100.64.0.0/10", // CGNAT (RFC 6598)
+    "192.0.0.0/24",  // служебные IETF
+    "198.18.0.0/15", // стенды (RFC 2544)
+    "64:ff9b::/96",  // NAT64 — внутрь через шлюз
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+    nets := make([]*net.IPNet, 0, len(cidrs))
+    for _, c := range cidrs {
+        _, n, err := net.ParseCIDR(c)
+        if err != nil {
+            panic(err)
+        }
+        nets = append(nets, n)
+    }
+    return nets
+}
+
+// IsForbiddenIP сообщает, относится ли ip к адресам, куда вебхуки не ходят.
+func IsForbiddenIP(ip net.IP) bool {
+    if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+        ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+        ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+        return true
+    }
+    for _, n := range blockedNets {
+        if n.Contains(ip) {
+            return true
+        }
+    }
+    return false
+}
+
+// CheckURL проверяет URL подписки при регистрации: схема http(s) и все
+// адреса хоста публичные. Повторно адрес проверяется при каждом
+// соединении (DNS мог измениться) — см. safeDialer.
+func CheckURL(ctx context.Context, rawURL string) error {
+    u, err := url.Parse(rawURL)
+    if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+        return fmt.Errorf("некорректный URL вебхука")
+    }
+    if AllowPrivateTargets {
+        return nil
+    }
+
+    host := u.Hostname()
+    if ip := net.ParseIP(host); ip != nil {
+        if IsForbiddenIP(ip) {
+            return ErrForbiddenTarget
+        }
+        return nil
+    }
+
+    addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+    if err != nil {
+        return fmt.Errorf("не удалось разрешить %s: %w", host, err)
+    }
+    for _, a := range addrs {
+        if IsForbiddenIP(a.IP) {
+            return ErrForbiddenTarget
+        }
+    }
+    return nil
+}
+
+// controlDial отклоняет соединение с внутренним адресом. Вызывается после
+// разрешения имени, поэтому подмена DNS между проверкой и запросом
+// (DNS rebinding) не помогает.
+func controlDial(network, address string, _ syscall.RawConn) error {
+    if AllowPrivateTargets {
+        return nil
+    }
+    host, _, err := net.SplitHostPort(address)
+    if err != nil {
+        return err
+    }
+    ip := net.ParseIP(host)
+    if ip == nil || IsForbiddenIP(ip) {
+        return fmt.Errorf("%w: %s", ErrForbiddenTarget, address)
+    }
+    return nil
+}
+
+// newSafeClient возвращает HTTP-клиент, который не соединяется с
+// внутренними адресами (включая редиректы) и не ходит через прокси
+// окружения, в обход проверки.
+func newSafeClient(timeout time.Duration) *http.Client {
+    dialer := &net.Dialer{
+        Timeout:   timeout,
+        KeepAlive: 30 * time.Second,
+        Control:   controlDial,
+    }
+    transport := &http.Transport{
+        Proxy:                 nil,
+        DialContext:           dialer.DialContext,
+        ForceAttemptHTTP2:     true,
+        MaxIdleConns:          100,
+        IdleConnTimeout:       90 * time.Second,
+        TLSHandshakeTimeout:   timeout,
+        ExpectContinueTimeout: time.Second,
+    }
+    return &http.Client{Timeout: timeout, Transport: transport}
+}
@@ -0,0 +1,92 @@
+package webhooks
+
+import (
+    "context"
+    "errors"
+    "net"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+)
+
+func TestIsForbiddenIP(t *testing.T) {
+    tests := []struct {
+        ip   string
+        want bool
+    }{
+        {"127.0.0.1", true},
+        {"::1", true},
+        {"10.1.2.3", true},
+        {"172.16.0.1", true},
+        {"192.168.1.1", true},
+        {"169.254.169.254", true},
+        {"fe80::1", true},
+        {"fd00::1", true},
+        {"0.0.0.0", true},
+        {"100.64.0.1", true},
+        {"::ffff:127.0.0.1", true},
+        {"::ffff:169.254.169.254", true},
+        {"8.8.8.8", false},
+        {"93.184.216.34", false},
+        {"2606:4700:4700::1111", false},
+    }
+    for _, tt := range tests {
+        if got := IsForbiddenIP(net.ParseIP(tt.ip)); got != tt.want {
+            t.Errorf("IsForbiddenIP(%s) = %v, want %v", tt.ip, got, tt.want)
+        }
+    }
+}
+
+func TestCheckURL(t *testing.T) {
+    tests := []struct {
+        name      string
+        url       string
+        wantErr   bool
+        forbidden bool
+    }{
+        {name: "public ip", url: "https://8.8.8.8/hook"},
+        {name: "metadata", url: "http://169.254.169.254/latest/meta-data", wantErr: true, forbidden: true},
+        {name: "loopback", url: "http://127.0.0.1:8080/hook", wantErr: true, forbidden: true},
+        {name: "ipv6 loopback", url: "http://[::1]/hook", wantErr: true, forbidden: true},
+        {name: "private", url: "https://10.0.0.5/hook", wantErr: true, forbidden: true},
+        {name: "localhost name", url: "http://localhost/hook", wantErr: true, forbidden: true},
+        {name: "bad scheme", url: "ftp://8.8.8.8/hook", wantErr: true},
+        {name: "no host", url: "https:///hook", wantErr: true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            err := CheckURL(context.Background(), tt.url)
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+            }
+            if errors.Is(err, ErrForbiddenTarget) != tt.forbidden {
+                t.Fatalf("err = %v, ожидалось forbidden=%v", err, tt.forbidden)
+            }
+        })
+    }
+}
+
+// Проверка при соединении ловит адрес, который при регистрации был
+// публичным, а потом стал внутренним (DNS rebinding) — здесь это сам
+// httptest-сервер на loopback.
+func TestSafeClientRefusesInternalAddress(t *testing.T) {
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(http.StatusNoContent)
+    }))
+    defer srv.Close()
+
+    client := newSafeClient(time.Second)
+    _, err := client.Get(srv.URL)
+    if !errors.Is(err, ErrForbiddenTarget) {
+        t.Fatalf("err = %v, ожидался ErrForbiddenTarget", err)
+    }
+
+    AllowPrivateTargets = true
+    defer func() { AllowPrivateTargets = false }()
+    resp, err := client.Get(srv.URL)
+    if err != nil {
+        t.Fatalf("с AllowPrivateTargets: %v", err)
+    }
+    resp.Body.Close()
+}
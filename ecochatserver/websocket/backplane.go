@@ -0,0 +1,132 @@
+package websocket
+
+import (
+    "context"
+    "encoding/json"
+    "log"
+    "time"
+)
+
+// Backplane связывает хабы нескольких узлов. Publish рассылает данные всем
+// узлам (включая отправителя), Subscribe блокирует до отмены ctx или
+// ошибки и вызывает handle для каждого полученного сообщения.
+type Backplane interface {
+    Publish(ctx context.Context, data []byte) error
+    Subscribe(ctx context.Context, handle func(data []byte)) error
+}
+
+// Адресаты сообщений бэкплейна
+const (
+    routeChat           = "chat"
+    routeChatEvent      = "chatEvent"
+    routeAdmin          = "admin"
+    routeClient         = "client"
+    routeAdminsOfClient = "adminsOfClient"
+    routeUnsubscribe    = "unsubscribe" // отписка всех сессий админа от чата
+)
+
+const (
+    backplanePublishTimeout = 3 * time.Second
+    backplaneRetryMin       = time.Second
+    backplaneRetryMax       = 30 * time.Second
+)
+
+// backplaneEnvelope — сообщение хаба, переданное другим узлам
+type backplaneEnvelope struct {
+    Node    string          `json:"node"`
+    Route   string          `json:"route"`
+    Target  string          `json:"target"`
+    Client  string          `json:"client,omitempty"`
+    Chat    string          `json:"chat,omitempty"`
+    Except  []string        `json:"except,omitempty"`
+    Message json.RawMessage `json:"message"`
+}
+
+// UseBackplane подключает хаб к кластеру: Send*-методы публикуют события
+// всем узлам, а полученные от других узлов доставляются локальным
+// соединениям. Вызывается до начала приёма соединений.
+func (h *Hub) UseBackplane(ctx context.Context, b Backplane) {
+    h.backplane = b
+    go h.runBackplane(ctx)
+}
+
+// runBackplane держит подписку, переподключаясь с растущей паузой.
+// Сообщения, пришедшие во время переподключения, теряются.
+func (h *Hub) runBackplane(ctx context.Context) {
+    delay := backplaneRetryMin
+    for {
+        started := time.Now()
+        err := h.backplane.Subscribe(ctx, h.receiveFromBackplane)
+        if ctx.Err() != nil {
+            return
+        }
+        if time.Since(started) > backplaneRetryMax {
+            delay = backplaneRetryMin
+        }
+        log.Printf("Hub: подписка на бэкплейн прервана: %v, повтор через %s", err, delay)
+
+        select {
+        case <-ctx.Done():
+            return
+        case <-time.After(delay):
+        }
+        if delay *= 2; delay > backplaneRetryMax {
+            delay = backplaneRetryMax
+        }
+    }
+}
+
+// publish отправляет сообщение остальным узлам. Локальные соединения
+// обслуживает сам вызывающий метод.
+func (h *Hub) publish(route, target string, except []string, message []byte) {
+    h.publishEnvelope(backplaneEnvelope{Route: route, Target: target, Except: except, Message: message})
+}
+
+// publishEnvelope отправляет конверт остальным узлам.
+func (h *Hub) publishEnvelope(env backplaneEnvelope) {
+    if h.backplane == nil {
+        return
+    }
+    env.Node = h.nodeID
+    route, target := env.Route, env.Target
+    data, err := json.Marshal(env)
+    if err != nil {
+        log.Printf("Hub: ошибка сериализации для бэкплейна: %v", err)
+        return
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), backplanePublishTimeout)
+    defer cancel()
+    if err := h.backplane.Publish(ctx, data); err != nil {
+        log.Printf("Hub: ошибка публикации в бэкплейн (%s %s): %v", route, target, err)
+    }
+}
+
+// receiveFromBackplane доставляет сообщение другого узла локальным соединениям.
+func (h *Hub) receiveFromBackplane(data []byte) {
+    var env backplaneEnvelope
+    if err := json.Unmarshal(data, &env); err != nil {
+        log.Printf("Hub: некорректное сообщение бэкплейна: %v", err)
+        return
+    }
+    if env.Node == h.nodeID {
+        return
+    }
+
+    switch env.Route {
+    case routeChat:
+        h.deliverToChat(env.Target, env.Message)
+    case routeChatEvent:
+        h.deliverChatEvent(env.Client, env.Target, env.Message, env.Except)
+    case routeAdmin:
+        h.deliverToAdmin(env.Target, env.Message, env.Except)
+    case routeClient:
+        h.deliverToClient(env.Target, env.Message)
+    case routeAdminsOfClient:
+        h.deliverToAdminsOfClient(env.Target, env.Message, env.Except)
+    case routeUnsubscribe:
+        h.unsubscribeAdmin(env.Target, env.Chat)
+    default:
+        log.Printf("Hub: неизвестный адресат бэкплейна: %s", env.Route)
+    }
+}
@@ -3,7 +3,9 @@ package websocket
 import (
     "bytes"
     "encoding/json"
+    "errors"
     "log"
+    "strings"
     "time"
 
     "github.com/gin-gonic/gin"
@@ -31,18 +33,79 @@ type Client struct {
     ClientType string              // ЭКСПОРТИРОВАНО: "admin" или "widget"
     ID         uuid.UUID           // ЭКСПОРТИРОВАНО: adminID или widget-userID
     ChatID     uuid.UUID           // ЭКСПОРТИРОВАНО: для виджета — chatID
+    ClientID   uuid.UUID           // Клиент (компания): из JWT админа или из чата виджета
     Context    *gin.Context        // Gin context для доступа к данным запроса/аутентификации
+
+    // Метаданные сессии: у одного админа может быть несколько соединений
+    SessionID   uuid.UUID // уникален для каждого соединения
+    Device      string    // "desktop", "mobile", "tablet" или значение ?device=
+    UserAgent   string
+    ConnectedAt time.Time
+
+    // FollowAllChats — новая сессия админа подписана на все чаты клиента
+    // (false, если оператору доступны не все чаты, см. subscribe)
+    FollowAllChats bool
+
+    // Resume задаётся до регистрации, если клиент возобновляет сессию
+    Resume  *ResumeRequest
+    session *session
+    closed  bool // канал send закрыт; читается и меняется под session.mu
 }
 
+// Ошибки отправки ответа соединению
+var (
+    ErrConnectionClosed = errors.New("соединение закрыто")
+    ErrSlowClient       = errors.New("клиент не успевает читать, соединение отключено")
+)
+
 // NewClient создает нового WebSocket клиента
 func NewClient(hub *Hub, conn *websocket.Conn, clientType string, id uuid.UUID, chatID uuid.UUID) *Client {
     return &Client{
-        hub:        hub,
-        conn:       conn,
-        send:       make(chan []byte, 256),
-        ClientType: clientType,
-        ID:         id,
-        ChatID:     chatID,
+        hub:         hub,
+        conn:        conn,
+        send:        make(chan []byte, 256),
+        ClientType:  clientType,
+        ID:          id,
+        ChatID:      chatID,
+        SessionID:   uuid.New(),
+        ConnectedAt: time.Now(),
+    }
+}
+
+// Deliver отправляет событие через сессию: с номером seq и сохранением
+// до подтверждения. Для ответов на команды используйте SendJSON.
+func (c *Client) Deliver(message []byte) bool {
+    return c.session.push(message)
+}
+
+// Ack подтверждает получение событий до seq включительно.
+func (c *Client) Ack(seq uint64) {
+    c.session.ack(seq)
+}
+
+// inSessions сообщает, входит ли сессия клиента в список ID.
+func (c *Client) inSessions(ids []string) bool {
+    session := c.SessionID.String()
+    for _, id := range ids {
+        if id == session {
+            return true
+        }
+    }
+    return false
+}
+
+// DetectDevice определяет тип устройства по User-Agent.
+func DetectDevice(userAgent string) string {
+    ua := strings.ToLower(userAgent)
+    switch {
+    case strings.Contains(ua, "ipad") || strings.Contains(ua, "tablet"):
+        return "tablet"
+    case strings.Contains(ua, "mobi") || strings.Contains(ua, "android") || strings.Contains(ua, "iphone"):
+        return "mobile"
+    case ua == "":
+        return "unknown"
+    default:
+        return "desktop"
     }
 }
 
@@ -52,15 +115,38 @@ func (c *Client) SendJSON(data interface{}) error {
     if err != nil {
         return err
     }
-    
-    c.send <- json
-    return nil
+    return c.reply(json)
 }
 
 // SendError отправляет сообщение об ошибке
 func (c *Client) SendError(code, message string) {
-    errorMsg, _ := NewErrorMessage(code, message)
-    c.send <- errorMsg
+    c.ReplyError("", "", code, message)
+}
+
+// Reply отправляет ответ на команду с её requestId.
+func (c *Client) Reply(requestID, msgType string, payload interface{}) error {
+    msg, err := NewReply(msgType, requestID, payload)
+    if err != nil {
+        return err
+    }
+    return c.reply(msg)
+}
+
+// ReplyError отправляет ошибку команды с её requestId.
+func (c *Client) ReplyError(requestID, command, code, message string) {
+    errorMsg, _ := NewErrorReply(requestID, command, code, message)
+    if err := c.reply(errorMsg); err != nil {
+        log.Printf("WS reply to %s %s: %v", c.ClientType, c.ID, err)
+    }
+}
+
+// reply отправляет ответ через сессию соединения: сессия знает, закрыт ли
+// канал send (его закрывает Hub при отключении или перехвате сессии).
+func (c *Client) reply(message []byte) error {
+    if c.session == nil {
+        return ErrConnectionClosed
+    }
+    return c.session.reply(c, message)
 }
 
 // ReadPump читает сообщения из WebSocket, парсит их и вызывает handler.
@@ -3,7 +3,10 @@ package websocket
 import (
     "sync"
     "log"
+    "sort"
     "time"
+
+    "github.com/google/uuid"
 )
 
 const (
@@ -11,24 +14,54 @@ const (
     ClientTypeWidget = "widget"
 )
 
-// Hub отвечает за регистрацию клиентов и вещание сообщений.
+// sessionExpireInterval — как часто удалять невозобновлённые сессии
+const sessionExpireInterval = 15 * time.Second
+
+// Hub отвечает за регистрацию клиентов и адресную рассылку сообщений.
+// Рассылки «всем подряд» нет: любое событие адресуется чату, админу или
+// клиенту (компании), чтобы данные не выходили за пределы клиента.
+// С бэкплейном (см. UseBackplane) события расходятся по всем узлам
+// кластера, а каждый узел доставляет их своим соединениям.
 type Hub struct {
     clients     map[*Client]bool
-    adminsByID  map[string]*Client
+    adminsByID  map[string]map[*Client]bool // все сессии админа (вкладки, устройства)
     widgetsByID map[string]map[*Client]bool
-    chatClients map[string]map[*Client]bool
+    chatClients map[string]map[*Client]bool // виджеты чата и админы, подписанные на него
+    byClientID  map[string]map[*Client]bool // админы и виджеты клиента (компании)
 
-    Broadcast  chan []byte
     Register   chan *Client
     Unregister chan *Client
 
     mu sync.RWMutex
     
     // Статистика для мониторинга
-    stats HubStats
+    stats   HubStats
+    statsMu sync.RWMutex
     
     // Дедупликация сообщений
     sentMessages sync.Map // key: messageHash, value: time.Time
+
+    // Сессии по ID, включая отключённые, ожидающие возобновления
+    sessions map[string]*session
+
+    // ResumeWindow — сколько отключённая сессия ждёт переподключения
+    // (0 — не ждать). Задаётся до Run.
+    ResumeWindow time.Duration
+
+    // Кластер: nodeID отличает собственные сообщения в бэкплейне
+    backplane Backplane
+    nodeID    string
+
+    // OnAdminConnect вызывается (в отдельной горутине) после регистрации
+    // каждой сессии админа, OnAdminDisconnect — когда у админа не осталось
+    // ни одной сессии (включая ожидающие возобновления).
+    OnAdminConnect    func(c *Client)
+    OnAdminDisconnect func(c *Client)
+
+    // OnResumeFailed вызывается (в отдельной горутине), если запрошенную
+    // сессию не удалось возобновить из буфера (нет на этом узле, истекла
+    // или события вытеснены). Клиент получил session с resumed=false.
+    OnResumeFailed func(c *Client)
 }
 
 type HubStats struct {
@@ -36,19 +69,20 @@ type HubStats struct {
     ActiveConnections   int64
     TotalMessages       int64
     DisconnectedClients int64
-    mu                  sync.RWMutex
 }
 
 // NewHub создаёт и инициализирует Hub.
 func NewHub() *Hub {
     hub := &Hub{
         clients:     make(map[*Client]bool),
-        adminsByID:  make(map[string]*Client),
+        adminsByID:  make(map[string]map[*Client]bool),
         widgetsByID: make(map[string]map[*Client]bool),
         chatClients: make(map[string]map[*Client]bool),
-        Broadcast:   make(chan []byte),
+        byClientID:  make(map[string]map[*Client]bool),
+        sessions:    make(map[string]*session),
         Register:    make(chan *Client),
         Unregister:  make(chan *Client),
+        nodeID:      uuid.New().String(),
     }
     
     // Запускаем очистку старых сообщений
@@ -79,6 +113,9 @@ func (h *Hub) cleanupOldMessages() {
 func (h *Hub) Run() {
     // Запускаем горутину для периодического логирования статистики
     go h.logStats()
+    if h.ResumeWindow > 0 {
+        go h.expireSessions()
+    }
     
     for {
         select {
@@ -87,63 +124,168 @@ func (h *Hub) Run() {
 
         case c := <-h.Unregister:
             h.unregisterClient(c)
-
-        case msg := <-h.Broadcast:
-            h.broadcastMessage(msg)
         }
     }
 }
 
-// registerClient регистрирует нового клиента
+// registerClient регистрирует нового клиента. Если клиент возобновляет
+// свою сессию, она переходит к нему вместе с неподтверждёнными событиями.
 func (h *Hub) registerClient(c *Client) {
     h.mu.Lock()
     defer h.mu.Unlock()
-    
-    h.clients[c] = true
-    
-    // Регистрируем по типу клиента
-    if c.ClientType == ClientTypeAdmin {
-        h.adminsByID[c.ID.String()] = c
-    } else if c.ClientType == ClientTypeWidget {
-        if _, ok := h.widgetsByID[c.ChatID.String()]; !ok {
-            h.widgetsByID[c.ChatID.String()] = make(map[*Client]bool)
+
+    var s *session
+    if c.Resume != nil {
+        if prev, ok := h.sessions[c.Resume.SessionID.String()]; ok && prev.owns(c) {
+            s = prev
+            h.takeOver(s)
         }
-        h.widgetsByID[c.ChatID.String()][c] = true
     }
-    
-    // Добавляем в карту клиентов чата
-    chatID := c.ChatID.String()
-    if chatID != "" {
-        if _, ok := h.chatClients[chatID]; !ok {
-            h.chatClients[chatID] = make(map[*Client]bool)
-        }
-        h.chatClients[chatID][c] = true
+    if s == nil {
+        s = newSession(c)
+        h.sessions[s.id.String()] = s
     }
-    
+
+    var from uint64
+    if c.Resume != nil {
+        from = c.Resume.From
+    }
+    resumed, replayed := s.attach(c, from, c.Resume != nil && s.id == c.Resume.SessionID)
+
+    h.clients[c] = true
+    h.indexClient(c)
+
     // Обновляем статистику
-    h.stats.mu.Lock()
+    h.statsMu.Lock()
     h.stats.TotalConnections++
     h.stats.ActiveConnections++
-    h.stats.mu.Unlock()
+    h.statsMu.Unlock()
     
-    log.Printf("Клиент зарегистрирован: type=%s, id=%s, chatID=%s", 
-        c.ClientType, c.ID, c.ChatID)
+    log.Printf("Клиент зарегистрирован: type=%s, id=%s, chatID=%s, session=%s, device=%s, resumed=%v, replayed=%d", 
+        c.ClientType, c.ID, c.ChatID, c.SessionID, c.Device, resumed, replayed)
+
+    if c.Resume != nil && !resumed && h.OnResumeFailed != nil {
+        go h.OnResumeFailed(c)
+    }
+    if c.ClientType == ClientTypeAdmin && h.OnAdminConnect != nil {
+        go h.OnAdminConnect(c)
+    }
+}
+
+// takeOver отключает от сессии прежнее соединение (оно могло ещё не
+// заметить обрыв) и убирает его из индексов. Вызывается под h.mu.
+func (h *Hub) takeOver(s *session) {
+    old := s.client
+    if _, ok := h.clients[old]; ok {
+        delete(h.clients, old)
+        s.detach(old)
+
+        h.statsMu.Lock()
+        h.stats.ActiveConnections--
+        h.stats.DisconnectedClients++
+        h.statsMu.Unlock()
+    }
+    h.unindexClient(old)
 }
 
-// unregisterClient отменяет регистрацию клиента
+// unregisterClient отключает клиента. При ненулевом ResumeWindow сессия
+// остаётся в индексах и копит события, пока клиент не переподключится.
 func (h *Hub) unregisterClient(c *Client) {
     h.mu.Lock()
     defer h.mu.Unlock()
     
-    // Удаляем из основной мапы
-    if _, ok := h.clients[c]; ok {
-        delete(h.clients, c)
-        close(c.send)
+    // Уже отключён (ReadPump и WritePump оба сообщают об отключении)
+    // или сессию забрало новое соединение
+    if _, ok := h.clients[c]; !ok {
+        return
     }
+    delete(h.clients, c)
+    c.session.detach(c)
     
-    // Удаляем по типу клиента
+    // Обновляем статистику
+    h.statsMu.Lock()
+    h.stats.ActiveConnections--
+    h.stats.DisconnectedClients++
+    h.statsMu.Unlock()
+
+    if h.ResumeWindow > 0 {
+        log.Printf("Клиент отключен: type=%s, id=%s, session=%s (ожидает возобновления)", c.ClientType, c.ID, c.SessionID)
+        return
+    }
+    h.dropSession(c.session)
+    log.Printf("Клиент отключен: type=%s, id=%s, session=%s", c.ClientType, c.ID, c.SessionID)
+}
+
+// dropSession окончательно удаляет отключённую сессию. Вызывается под h.mu.
+func (h *Hub) dropSession(s *session) {
+    delete(h.sessions, s.id.String())
+    if h.unindexClient(s.client) && h.OnAdminDisconnect != nil {
+        go h.OnAdminDisconnect(s.client)
+    }
+}
+
+// expireSessions удаляет сессии, не возобновлённые за ResumeWindow.
+func (h *Hub) expireSessions() {
+    ticker := time.NewTicker(sessionExpireInterval)
+    defer ticker.Stop()
+
+    for range ticker.C {
+        h.mu.Lock()
+        for _, s := range h.sessions {
+            if s.expired(h.ResumeWindow) {
+                h.dropSession(s)
+                log.Printf("Сессия %s (%s %s) не возобновлена и удалена", s.id, s.clientType, s.ownerID)
+            }
+        }
+        h.mu.Unlock()
+    }
+}
+
+// indexClient добавляет клиента в индексы рассылки. Вызывается под h.mu.
+func (h *Hub) indexClient(c *Client) {
+    // Регистрируем по типу клиента
+    if c.ClientType == ClientTypeAdmin {
+        if _, ok := h.adminsByID[c.ID.String()]; !ok {
+            h.adminsByID[c.ID.String()] = make(map[*Client]bool)
+        }
+        h.adminsByID[c.ID.String()][c] = true
+    } else if c.ClientType == ClientTypeWidget {
+        if _, ok := h.widgetsByID[c.ChatID.String()]; !ok {
+            h.widgetsByID[c.ChatID.String()] = make(map[*Client]bool)
+        }
+        h.widgetsByID[c.ChatID.String()][c] = true
+    }
+    
+    // Добавляем в карту клиентов чата: виджет — в свой чат, админ — в чаты
+    // из подписок
+    for _, chatID := range h.clientChats(c) {
+        h.addChatClient(chatID, c)
+    }
+
+    // Индекс по клиенту (компании)
+    if c.ClientID != uuid.Nil {
+        clientID := c.ClientID.String()
+        if _, ok := h.byClientID[clientID]; !ok {
+            h.byClientID[clientID] = make(map[*Client]bool)
+        }
+        h.byClientID[clientID][c] = true
+    }
+}
+
+// unindexClient убирает клиента из индексов рассылки и сообщает, была ли
+// это последняя сессия админа. Вызывается под h.mu.
+func (h *Hub) unindexClient(c *Client) (lastAdminSession bool) {
     if c.ClientType == ClientTypeAdmin {
-        delete(h.adminsByID, c.ID.String())
+        adminID := c.ID.String()
+        if sessions, ok := h.adminsByID[adminID]; ok {
+            if _, own := sessions[c]; own {
+                delete(sessions, c)
+                if len(sessions) == 0 {
+                    delete(h.adminsByID, adminID)
+                    lastAdminSession = true
+                }
+            }
+        }
     } else if c.ClientType == ClientTypeWidget {
         chatID := c.ChatID.String()
         if widgets, ok := h.widgetsByID[chatID]; ok {
@@ -155,50 +297,19 @@ func (h *Hub) unregisterClient(c *Client) {
     }
     
     // Удаляем из карты клиентов чата
-    chatID := c.ChatID.String()
-    if chatID != "" {
-        if clients, ok := h.chatClients[chatID]; ok {
-            delete(clients, c)
-            if len(clients) == 0 {
-                delete(h.chatClients, chatID)
-            }
-        }
+    for _, chatID := range h.clientChats(c) {
+        h.removeChatClient(chatID, c)
     }
-    
-    // Обновляем статистику
-    h.stats.mu.Lock()
-    h.stats.ActiveConnections--
-    h.stats.DisconnectedClients++
-    h.stats.mu.Unlock()
-    
-    log.Printf("Клиент отключен: type=%s, id=%s", c.ClientType, c.ID)
-}
 
-// broadcastMessage отправляет сообщение всем клиентам (исправлена race condition)
-func (h *Hub) broadcastMessage(msg []byte) {
-    h.mu.Lock()
-    disconnected := make([]*Client, 0)
-    
-    for client := range h.clients {
-        select {
-        case client.send <- msg:
-            // Сообщение успешно отправлено
-        default:
-            // Клиент не готов принять сообщение
-            disconnected = append(disconnected, client)
+    // Удаляем из индекса по клиенту
+    clientID := c.ClientID.String()
+    if clients, ok := h.byClientID[clientID]; ok {
+        delete(clients, c)
+        if len(clients) == 0 {
+            delete(h.byClientID, clientID)
         }
     }
-    h.mu.Unlock()
-    
-    // Отключаем клиентов, которые не смогли получить сообщение
-    for _, client := range disconnected {
-        h.cleanupClient(client)
-    }
-    
-    // Обновляем статистику
-    h.stats.mu.Lock()
-    h.stats.TotalMessages++
-    h.stats.mu.Unlock()
+    return lastAdminSession
 }
 
 // cleanupClient асинхронно очищает клиента
@@ -210,30 +321,249 @@ func (h *Hub) cleanupClient(client *Client) {
     }()
 }
 
-// BroadcastMessage шлёт сообщение всем подключённым клиентам.
-func (h *Hub) BroadcastMessage(message []byte) {
-    h.Broadcast <- message
+// SendToAdmin отправляет сообщение во все сессии админа, кроме сессий
+// из except. Возвращает true, если сообщение ушло хотя бы в одну сессию
+// на этом узле.
+func (h *Hub) SendToAdmin(adminID string, message []byte, except ...string) bool {
+    h.publish(routeAdmin, adminID, except, message)
+    return h.deliverToAdmin(adminID, message, except)
+}
+
+// deliverToAdmin доставляет сообщение локальным сессиям админа.
+func (h *Hub) deliverToAdmin(adminID string, message []byte, except []string) bool {
+    h.mu.RLock()
+    targets := make([]*Client, 0)
+    for c := range h.adminsByID[adminID] {
+        if !c.inSessions(except) {
+            targets = append(targets, c)
+        }
+    }
+    h.mu.RUnlock()
+
+    sent := false
+    for _, c := range targets {
+        if c.session.push(message) {
+            sent = true
+        } else {
+            go h.cleanupClient(c)
+        }
+    }
+    return sent
+}
+
+// SendToClient отправляет сообщение всем соединениям клиента (компании):
+// админам и виджетам. Для событий конкретного чата используйте SendToChat
+// и SendToAdminsOfClient — виджеты других чатов их видеть не должны.
+func (h *Hub) SendToClient(clientID string, message []byte) int {
+    h.publish(routeClient, clientID, nil, message)
+    return h.deliverToClient(clientID, message)
+}
+
+// deliverToClient доставляет сообщение локальным соединениям клиента.
+func (h *Hub) deliverToClient(clientID string, message []byte) int {
+    return h.sendToTenant(clientID, message, func(*Client) bool { return true })
 }
 
-// SendToAdmin пытается отправить сообщение конкретному админу.
-func (h *Hub) SendToAdmin(adminID string, message []byte) bool {
+// SendToAdminsOfClient отправляет сообщение подключённым админам клиента,
+// кроме сессий из except (например, сессии-инициатора, получившей ответ на
+// команду). Другие сессии того же админа сообщение получают.
+func (h *Hub) SendToAdminsOfClient(clientID string, message []byte, except ...string) int {
+    h.publish(routeAdminsOfClient, clientID, except, message)
+    return h.deliverToAdminsOfClient(clientID, message, except)
+}
+
+// deliverToAdminsOfClient доставляет сообщение локальным админам клиента.
+func (h *Hub) deliverToAdminsOfClient(clientID string, message []byte, except []string) int {
+    return h.sendToTenant(clientID, message, func(c *Client) bool {
+        return c.ClientType == ClientTypeAdmin && !c.inSessions(except)
+    })
+}
+
+// sendToTenant отправляет сообщение соединениям клиента, прошедшим фильтр.
+func (h *Hub) sendToTenant(clientID string, message []byte, match func(*Client) bool) int {
+    h.mu.RLock()
+    targets := make([]*Client, 0)
+    for c := range h.byClientID[clientID] {
+        if match(c) {
+            targets = append(targets, c)
+        }
+    }
+    h.mu.RUnlock()
+
+    sent := 0
+    for _, c := range targets {
+        if c.session.push(message) {
+            sent++
+        } else {
+            go h.cleanupClient(c)
+        }
+    }
+    return sent
+}
+
+// clientChats возвращает чаты, в индексе которых состоит клиент.
+// Вызывается под h.mu.
+func (h *Hub) clientChats(c *Client) []string {
+    var chats []string
+    if c.ChatID != uuid.Nil {
+        chats = append(chats, c.ChatID.String())
+    }
+    if c.session != nil {
+        for chatID := range c.session.chats {
+            chats = append(chats, chatID)
+        }
+    }
+    return chats
+}
+
+func (h *Hub) addChatClient(chatID string, c *Client) {
+    if _, ok := h.chatClients[chatID]; !ok {
+        h.chatClients[chatID] = make(map[*Client]bool)
+    }
+    h.chatClients[chatID][c] = true
+}
+
+func (h *Hub) removeChatClient(chatID string, c *Client) {
+    if clients, ok := h.chatClients[chatID]; ok {
+        delete(clients, c)
+        if len(clients) == 0 {
+            delete(h.chatClients, chatID)
+        }
+    }
+}
+
+// Subscribe подписывает админа на события чатов chatIDs или, если all,
+// на все чаты его клиента. Принадлежность чатов клиенту проверяет
+// вызывающий. Подписки принадлежат сессии и переживают возобновление.
+func (h *Hub) Subscribe(c *Client, chatIDs []string, all bool) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+
+    s := c.session
+    if all {
+        s.followAll = true
+    }
+    for _, chatID := range chatIDs {
+        s.chats[chatID] = true
+        h.addChatClient(chatID, s.client)
+    }
+}
+
+// Unsubscribe отменяет подписки на чаты chatIDs и, если all, на все чаты
+// клиента.
+func (h *Hub) Unsubscribe(c *Client, chatIDs []string, all bool) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+
+    s := c.session
+    if all {
+        s.followAll = false
+    }
+    for _, chatID := range chatIDs {
+        delete(s.chats, chatID)
+        h.removeChatClient(chatID, s.client)
+    }
+}
+
+// UnsubscribeAdmin отписывает все сессии админа на всех узлах от событий
+// чата (например, когда чат передан другому оператору и прежнему больше
+// недоступен). Подписка на все чаты клиента не меняется.
+func (h *Hub) UnsubscribeAdmin(adminID, chatID string) {
+    h.publishEnvelope(backplaneEnvelope{Route: routeUnsubscribe, Target: adminID, Chat: chatID})
+    h.unsubscribeAdmin(adminID, chatID)
+}
+
+// unsubscribeAdmin отписывает локальные сессии админа от чата.
+func (h *Hub) unsubscribeAdmin(adminID, chatID string) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+
+    for c := range h.adminsByID[adminID] {
+        delete(c.session.chats, chatID)
+        h.removeChatClient(chatID, c)
+    }
+}
+
+// NodeID возвращает идентификатор узла хаба в кластере.
+func (h *Hub) NodeID() string {
+    return h.nodeID
+}
+
+// AdminConnected сообщает, есть ли у админа сессии на этом узле (включая
+// ожидающие возобновления).
+func (h *Hub) AdminConnected(adminID string) bool {
     h.mu.RLock()
     defer h.mu.RUnlock()
-    
-    if c, ok := h.adminsByID[adminID]; ok {
-        select {
-        case c.send <- message:
-            return true
-        default:
+    return len(h.adminsByID[adminID]) > 0
+}
+
+// Subscriptions возвращает текущие подписки соединения.
+func (h *Hub) Subscriptions(c *Client) (all bool, chatIDs []string) {
+    h.mu.RLock()
+    defer h.mu.RUnlock()
+
+    chatIDs = make([]string, 0, len(c.session.chats))
+    for chatID := range c.session.chats {
+        chatIDs = append(chatIDs, chatID)
+    }
+    sort.Strings(chatIDs)
+    return c.session.followAll, chatIDs
+}
+
+// SendChatEvent рассылает событие чата: виджетам чата, админам, подписанным
+// на этот чат, и админам клиента, подписанным на все его чаты, — кроме
+// сессий из except. Соединения других клиентов событие не получают, даже
+// если подписаны на чат. Каждое соединение получает событие один раз.
+func (h *Hub) SendChatEvent(clientID, chatID string, message []byte, except ...string) int {
+    h.publishEnvelope(backplaneEnvelope{
+        Route:   routeChatEvent,
+        Target:  chatID,
+        Client:  clientID,
+        Except:  except,
+        Message: message,
+    })
+    return h.deliverChatEvent(clientID, chatID, message, except)
+}
+
+// deliverChatEvent доставляет событие чата локальным соединениям.
+func (h *Hub) deliverChatEvent(clientID, chatID string, message []byte, except []string) int {
+    h.mu.RLock()
+    targets := make(map[*Client]bool)
+    for c := range h.chatClients[chatID] {
+        if c.ClientID.String() != clientID {
+            continue
+        }
+        if c.ClientType != ClientTypeAdmin || !c.inSessions(except) {
+            targets[c] = true
+        }
+    }
+    for c := range h.byClientID[clientID] {
+        if c.ClientType == ClientTypeAdmin && c.session.followAll && !c.inSessions(except) {
+            targets[c] = true
+        }
+    }
+    h.mu.RUnlock()
+
+    sent := 0
+    for c := range targets {
+        if c.session.push(message) {
+            sent++
+        } else {
             go h.cleanupClient(c)
-            return false
         }
     }
-    return false
+    return sent
 }
 
-// SendToChat вещает сообщение всем клиентам конкретного чата.
+// SendToChat вещает сообщение всем клиентам конкретного чата. Возвращает
+// число соединений на этом узле.
 func (h *Hub) SendToChat(chatID string, message []byte) int {
+    h.publish(routeChat, chatID, nil, message)
+    return h.deliverToChat(chatID, message)
+}
+
+// deliverToChat доставляет сообщение локальным соединениям чата.
+func (h *Hub) deliverToChat(chatID string, message []byte) int {
     h.mu.RLock()
     clients := make([]*Client, 0)
     if chatClients, ok := h.chatClients[chatID]; ok {
@@ -249,10 +579,9 @@ func (h *Hub) SendToChat(chatID string, message []byte) int {
     
     sent := 0
     for _, c := range clients {
-        select {
-        case c.send <- message:
+        if c.session.push(message) {
             sent++
-        default:
+        } else {
             go h.cleanupClient(c)
         }
     }
@@ -261,15 +590,9 @@ func (h *Hub) SendToChat(chatID string, message []byte) int {
     return sent
 }
 
-// SendConnectionStatus уведомляет о подключении/отключении.
+// SendConnectionStatus уведомляет админов клиента о подключении/отключении.
 func (h *Hub) SendConnectionStatus(c *Client, online bool) {
-    payload := struct {
-        ClientType string `json:"clientType"`
-        ID         string `json:"id"`
-        ChatID     string `json:"chatId,omitempty"`
-        Online     bool   `json:"online"`
-        Timestamp  string `json:"timestamp"`
-    }{
+    payload := ConnectionStatusPayload{
         ClientType: c.ClientType,
         ID:         c.ID.String(),
         ChatID:     c.ChatID.String(),
@@ -277,13 +600,13 @@ func (h *Hub) SendConnectionStatus(c *Client, online bool) {
         Timestamp:  time.Now().Format(time.RFC3339),
     }
     msg, _ := NewMessage("connection_status", payload)
-    h.BroadcastMessage(msg)
+    h.SendToAdminsOfClient(c.ClientID.String(), msg)
 }
 
 // GetStats возвращает статистику хаба
 func (h *Hub) GetStats() HubStats {
-    h.stats.mu.RLock()
-    defer h.stats.mu.RUnlock()
+    h.statsMu.RLock()
+    defer h.statsMu.RUnlock()
     
     return HubStats{
         TotalConnections:    h.stats.TotalConnections,
@@ -309,14 +632,64 @@ func (h *Hub) logStats() {
     }
 }
 
-// GetActiveClients возвращает текущее количество активных клиентов
-func (h *Hub) GetActiveClients() map[string]int {
+// SessionInfo — сведения об одной сессии админа
+type SessionInfo struct {
+    SessionID   string    `json:"sessionId"`
+    AdminID     string    `json:"adminId"`
+    ClientID    string    `json:"clientId"`
+    Device      string    `json:"device"`
+    UserAgent   string    `json:"userAgent"`
+    ConnectedAt time.Time `json:"connectedAt"`
+}
+
+// ActiveClients — снимок активных соединений
+type ActiveClients struct {
+    Total         int                      `json:"total"`
+    Admin         int                      `json:"admin"`         // админов с хотя бы одной сессией
+    AdminSessions int                      `json:"adminSessions"` // всего сессий админов
+    Widget        int                      `json:"widget"`
+    Detached      int                      `json:"detached"`      // сессий, ожидающих возобновления
+    Sessions      map[string][]SessionInfo `json:"sessions"`      // adminID → сессии
+}
+
+// GetActiveClients возвращает активные соединения и сессии админов.
+// Если clientID не пуст, учитываются только соединения этого клиента.
+func (h *Hub) GetActiveClients(clientID string) ActiveClients {
     h.mu.RLock()
     defer h.mu.RUnlock()
-    
-    return map[string]int{
-        "total":  len(h.clients),
-        "admin":  len(h.adminsByID),
-        "widget": len(h.widgetsByID),
+
+    result := ActiveClients{Sessions: make(map[string][]SessionInfo)}
+    for c := range h.clients {
+        if clientID != "" && c.ClientID.String() != clientID {
+            continue
+        }
+        result.Total++
+        switch c.ClientType {
+        case ClientTypeWidget:
+            result.Widget++
+        case ClientTypeAdmin:
+            adminID := c.ID.String()
+            if _, ok := result.Sessions[adminID]; !ok {
+                result.Admin++
+            }
+            result.AdminSessions++
+            result.Sessions[adminID] = append(result.Sessions[adminID], SessionInfo{
+                SessionID:   c.SessionID.String(),
+                AdminID:     adminID,
+                ClientID:    c.ClientID.String(),
+                Device:      c.Device,
+                UserAgent:   c.UserAgent,
+                ConnectedAt: c.ConnectedAt,
+            })
+        }
+    }
+    for _, s := range h.sessions {
+        if clientID != "" && s.clientID.String() != clientID {
+            continue
+        }
+        if _, attached := h.clients[s.client]; !attached {
+            result.Detached++
+        }
     }
-}
\ No newline at end of file
+    return result
+}
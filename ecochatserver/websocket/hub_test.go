@@ -0,0 +1,199 @@
+package websocket
+
+import (
+    "testing"
+
+    "github.com/google/uuid"
+)
+
+// testClient регистрирует в хабе соединение без сокета и очищает его
+// канал от события session.
+func testClient(t *testing.T, h *Hub, clientType string, clientID, chatID uuid.UUID, followAll bool) *Client {
+    t.Helper()
+    c := NewClient(h, nil, clientType, uuid.New(), chatID)
+    c.ClientID = clientID
+    c.FollowAllChats = followAll
+    h.registerClient(c)
+    drain(c)
+    return c
+}
+
+// drain возвращает число событий в канале отправки и очищает его.
+func drain(c *Client) int {
+    n := 0
+    for {
+        select {
+        case _, ok := <-c.send:
+            if !ok {
+                return n
+            }
+            n++
+        default:
+            return n
+        }
+    }
+}
+
+// tenantFixture — два клиента (компании) с одним и тем же набором соединений.
+type tenantFixture struct {
+    chatA, chatB uuid.UUID
+    clientA      uuid.UUID
+
+    followAllA, subscribedA, idleA, widgetA *Client
+    followAllB, subscribedB, widgetB        *Client
+    all                                     []*Client
+}
+
+func newTenantFixture(t *testing.T, h *Hub) *tenantFixture {
+    f := &tenantFixture{chatA: uuid.New(), chatB: uuid.New(), clientA: uuid.New()}
+    clientB := uuid.New()
+
+    f.followAllA = testClient(t, h, ClientTypeAdmin, f.clientA, uuid.Nil, true)
+    f.subscribedA = testClient(t, h, ClientTypeAdmin, f.clientA, uuid.Nil, false)
+    f.idleA = testClient(t, h, ClientTypeAdmin, f.clientA, uuid.Nil, false)
+    f.widgetA = testClient(t, h, ClientTypeWidget, f.clientA, f.chatA, false)
+    h.Subscribe(f.subscribedA, []string{f.chatA.String()}, false)
+
+    f.followAllB = testClient(t, h, ClientTypeAdmin, clientB, uuid.Nil, true)
+    f.subscribedB = testClient(t, h, ClientTypeAdmin, clientB, uuid.Nil, false)
+    f.widgetB = testClient(t, h, ClientTypeWidget, clientB, f.chatB, false)
+    // Подписка на чужой чат (ошибка проверки доступа выше по стеку)
+    // не должна открывать его события
+    h.Subscribe(f.subscribedB, []string{f.chatA.String()}, false)
+
+    f.all = []*Client{f.followAllA, f.subscribedA, f.idleA, f.widgetA, f.followAllB, f.subscribedB, f.widgetB}
+    return f
+}
+
+func TestSendChatEventStaysWithinClient(t *testing.T) {
+    h := NewHub()
+    f := newTenantFixture(t, h)
+
+    sent := h.SendChatEvent(f.clientA.String(), f.chatA.String(), []byte(`{"type":"newMessage"}`))
+
+    want := map[*Client]bool{f.followAllA: true, f.subscribedA: true, f.widgetA: true}
+    if sent != len(want) {
+        t.Errorf("отправлено %d, ожидалось %d", sent, len(want))
+    }
+    for _, c := range f.all {
+        got := drain(c)
+        if want[c] && got != 1 {
+            t.Errorf("%s %s клиента %s: получено %d событий, ожидалось 1", c.ClientType, c.ID, c.ClientID, got)
+        }
+        if !want[c] && got != 0 {
+            t.Errorf("%s %s клиента %s получил чужое событие", c.ClientType, c.ID, c.ClientID)
+        }
+    }
+}
+
+func TestSendChatEventWithForeignClientID(t *testing.T) {
+    h := NewHub()
+    f := newTenantFixture(t, h)
+
+    // Событие чата A с ID другого клиента не доходит ни до кого из A
+    h.SendChatEvent(uuid.New().String(), f.chatA.String(), []byte(`{"type":"newMessage"}`))
+    for _, c := range f.all {
+        if got := drain(c); got != 0 {
+            t.Errorf("%s %s клиента %s: получено %d событий", c.ClientType, c.ID, c.ClientID, got)
+        }
+    }
+}
+
+func TestSendToTenantStaysWithinClient(t *testing.T) {
+    tests := []struct {
+        name string
+        send func(h *Hub, clientID string) int
+        want func(f *tenantFixture) []*Client
+    }{
+        {
+            name: "SendToAdminsOfClient",
+            send: func(h *Hub, clientID string) int {
+                return h.SendToAdminsOfClient(clientID, []byte(`{"type":"chatAssigned"}`))
+            },
+            want: func(f *tenantFixture) []*Client { return []*Client{f.followAllA, f.subscribedA, f.idleA} },
+        },
+        {
+            name: "SendToClient",
+            send: func(h *Hub, clientID string) int {
+                return h.SendToClient(clientID, []byte(`{"type":"availability"}`))
+            },
+            want: func(f *tenantFixture) []*Client {
+                return []*Client{f.followAllA, f.subscribedA, f.idleA, f.widgetA}
+            },
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            h := NewHub()
+            f := newTenantFixture(t, h)
+
+            want := map[*Client]bool{}
+            for _, c := range tt.want(f) {
+                want[c] = true
+            }
+            if sent := tt.send(h, f.clientA.String()); sent != len(want) {
+                t.Errorf("отправлено %d, ожидалось %d", sent, len(want))
+            }
+            for _, c := range f.all {
+                got := drain(c)
+                if want[c] && got != 1 {
+                    t.Errorf("%s %s: получено %d событий, ожидалось 1", c.ClientType, c.ID, got)
+                }
+                if !want[c] && got != 0 {
+                    t.Errorf("%s %s клиента %s получил чужое событие", c.ClientType, c.ID, c.ClientID)
+                }
+            }
+        })
+    }
+}
+
+func TestUnsubscribeAdmin(t *testing.T) {
+    h := NewHub()
+    clientID, chatID, otherChat := uuid.New(), uuid.New().String(), uuid.New().String()
+
+    first := testClient(t, h, ClientTypeAdmin, clientID, uuid.Nil, false)
+    // Вторая сессия того же админа (другая вкладка)
+    second := NewClient(h, nil, ClientTypeAdmin, first.ID, uuid.Nil)
+    second.ClientID = clientID
+    h.registerClient(second)
+    drain(second)
+    other := testClient(t, h, ClientTypeAdmin, clientID, uuid.Nil, false)
+
+    for _, c := range []*Client{first, second, other} {
+        h.Subscribe(c, []string{chatID, otherChat}, false)
+    }
+    h.UnsubscribeAdmin(first.ID.String(), chatID)
+
+    h.SendChatEvent(clientID.String(), chatID, []byte(`{"type":"newMessage"}`))
+    for c, want := range map[*Client]int{first: 0, second: 0, other: 1} {
+        if got := drain(c); got != want {
+            t.Errorf("сессия %s: получено %d событий, ожидалось %d", c.SessionID, got, want)
+        }
+    }
+
+    // Подписки на другие чаты остаются
+    _, chats := h.Subscriptions(first)
+    if len(chats) != 1 || chats[0] != otherChat {
+        t.Errorf("подписки после отписки: %v", chats)
+    }
+}
+
+func TestAdminConnected(t *testing.T) {
+    h := NewHub()
+    c := testClient(t, h, ClientTypeAdmin, uuid.New(), uuid.Nil, false)
+    adminID := c.ID.String()
+
+    if !h.AdminConnected(adminID) {
+        t.Fatal("подключённый админ не найден")
+    }
+    if h.AdminConnected(uuid.New().String()) {
+        t.Error("найден неподключённый админ")
+    }
+
+    h.mu.Lock()
+    h.unindexClient(c)
+    h.mu.Unlock()
+    if h.AdminConnected(adminID) {
+        t.Error("админ без сессий считается подключённым")
+    }
+}
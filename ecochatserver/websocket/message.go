@@ -7,10 +7,53 @@ import (
     "github.com/egor/ecochatserver/models"
 )
 
+// Статусы ответа на команду
+const (
+    StatusOK    = "ok"
+    StatusError = "error"
+)
+
 // WebSocketMessage — общая обёртка для всех JSON-сообщений по WS.
+// Seq есть только у событий, разосланных хабом (см. session). RequestID
+// клиент может передать в команде — он возвращается в ответе или ошибке
+// вместе со Status.
 type WebSocketMessage struct {
-    Type    string          `json:"type"`
-    Payload json.RawMessage `json:"payload"`
+    Seq       uint64          `json:"seq,omitempty"`
+    Type      string          `json:"type"`
+    RequestID string          `json:"requestId,omitempty"`
+    Status    string          `json:"status,omitempty"`
+    Payload   json.RawMessage `json:"payload"`
+}
+
+// TypingPayload — payload сообщения typing
+type TypingPayload struct {
+    ChatID   string `json:"chatId"`
+    IsTyping bool   `json:"isTyping"`
+    Sender   string `json:"sender"`
+}
+
+// ErrorPayload — payload сообщения error
+type ErrorPayload struct {
+    Code    string `json:"code"`
+    Text    string `json:"text"`
+    Command string `json:"command,omitempty"` // команда, вызвавшая ошибку
+}
+
+// ConnectionStatusPayload — payload сообщения connection_status
+type ConnectionStatusPayload struct {
+    ClientType string `json:"clientType"`
+    ID         string `json:"id"`
+    ChatID     string `json:"chatId,omitempty"`
+    Online     bool   `json:"online"`
+    Timestamp  string `json:"timestamp"`
+}
+
+// SessionPayload — payload сообщения session, первого после подключения
+type SessionPayload struct {
+    SessionID string `json:"sessionId"`
+    Resumed   bool   `json:"resumed"`  // пропущенные события повторены из буфера
+    Replayed  int    `json:"replayed"` // сколько событий повторено
+    LastSeq   uint64 `json:"lastSeq"`
 }
 
 // NewMessage упаковывает любой payload в JSON вида:
@@ -27,6 +70,33 @@ func NewMessage(msgType string, payload interface{}) ([]byte, error) {
     return json.Marshal(envelope)
 }
 
+// NewReply упаковывает успешный ответ на команду с requestId запроса.
+func NewReply(msgType, requestID string, payload interface{}) ([]byte, error) {
+    return newReply(msgType, requestID, StatusOK, payload)
+}
+
+// NewErrorReply формирует ошибку команды с requestId запроса.
+func NewErrorReply(requestID, command, code, text string) ([]byte, error) {
+    return newReply("error", requestID, StatusError, ErrorPayload{
+        Code:    code,
+        Text:    text,
+        Command: command,
+    })
+}
+
+func newReply(msgType, requestID, status string, payload interface{}) ([]byte, error) {
+    raw, err := json.Marshal(payload)
+    if err != nil {
+        return nil, err
+    }
+    return json.Marshal(WebSocketMessage{
+        Type:      msgType,
+        RequestID: requestID,
+        Status:    status,
+        Payload:   raw,
+    })
+}
+
 // NewChatMessage строит сообщение о новом чате или сообщении.
 func NewChatMessage(chat *models.Chat, message *models.Message) ([]byte, error) {
     payload := struct {
@@ -77,11 +147,7 @@ func NewWidgetMessage(message *models.Message) ([]byte, error) {
 
 // NewTypingMessage уведомляет, что пользователь печатает.
 func NewTypingMessage(chatID uuid.UUID, isTyping bool, sender string) ([]byte, error) {
-    payload := struct {
-        ChatID   string `json:"chatId"`
-        IsTyping bool   `json:"isTyping"`
-        Sender   string `json:"sender"`
-    }{
+    payload := TypingPayload{
         ChatID:   chatID.String(),
         IsTyping: isTyping,
         Sender:   sender,
@@ -91,10 +157,7 @@ func NewTypingMessage(chatID uuid.UUID, isTyping bool, sender string) ([]byte, e
 
 // NewErrorMessage формирует ошибку на WS-канале.
 func NewErrorMessage(code, text string) ([]byte, error) {
-    payload := struct {
-        Code string `json:"code"`
-        Text string `json:"text"`
-    }{
+    payload := ErrorPayload{
         Code: code,
         Text: text,
     }
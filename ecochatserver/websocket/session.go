@@ -0,0 +1,201 @@
+package websocket
+
+import (
+    "strconv"
+    "sync"
+    "time"
+
+    "github.com/google/uuid"
+)
+
+// maxSessionBuffer — сколько неподтверждённых событий хранит сессия.
+// Меньше ёмкости канала send, чтобы повтор при возобновлении не блокировался.
+const maxSessionBuffer = 200
+
+// ResumeRequest — параметры возобновления сессии после переподключения
+// (?session_id=&resume_from=&since=).
+type ResumeRequest struct {
+    SessionID uuid.UUID
+    From      uint64    // последний полученный клиентом seq
+    Since     time.Time // время последнего события; для восстановления из БД
+}
+
+// sessionEvent — отправленное событие, ожидающее подтверждения
+type sessionEvent struct {
+    seq  uint64
+    data []byte
+}
+
+// session нумерует исходящие события соединения и хранит неподтверждённые,
+// чтобы после обрыва повторить их новому соединению. Отключённая сессия
+// продолжает копить события до истечения Hub.ResumeWindow.
+type session struct {
+    mu sync.Mutex
+
+    id         uuid.UUID
+    clientType string
+    ownerID    uuid.UUID
+    chatID     uuid.UUID
+    clientID   uuid.UUID
+
+    client     *Client // текущее соединение
+    detached   bool
+    detachedAt time.Time
+
+    seq     uint64
+    evicted uint64 // seq последнего события, вытесненного без подтверждения
+    buffer  []sessionEvent
+
+    // Подписки админа на события чатов; читаются и меняются под Hub.mu
+    followAll bool            // все чаты своего клиента
+    chats     map[string]bool // отдельные чаты
+}
+
+// newSession создаёт сессию соединения c.
+func newSession(c *Client) *session {
+    return &session{
+        id:         c.SessionID,
+        clientType: c.ClientType,
+        ownerID:    c.ID,
+        chatID:     c.ChatID,
+        clientID:   c.ClientID,
+        client:     c,
+        followAll:  c.ClientType == ClientTypeAdmin && c.FollowAllChats,
+        chats:      make(map[string]bool),
+    }
+}
+
+// owns сообщает, может ли соединение c возобновить сессию.
+func (s *session) owns(c *Client) bool {
+    return s.clientType == c.ClientType && s.ownerID == c.ID &&
+        s.chatID == c.ChatID && s.clientID == c.ClientID
+}
+
+// push нумерует событие, сохраняет его и отправляет в текущее соединение.
+// false — канал отправки переполнен.
+func (s *session) push(message []byte) bool {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    s.seq++
+    data := withSeq(message, s.seq)
+    s.buffer = append(s.buffer, sessionEvent{seq: s.seq, data: data})
+    if len(s.buffer) > maxSessionBuffer {
+        s.evicted = s.buffer[0].seq
+        s.buffer = s.buffer[1:]
+    }
+
+    if s.detached {
+        return true
+    }
+    select {
+    case s.client.send <- data:
+        return true
+    default:
+        return false
+    }
+}
+
+// reply отправляет соединению c ответ на команду без номера и сохранения.
+// Отправка не блокируется: закрытое соединение ответ не получает, а
+// соединение с переполненным каналом отключается как медленное.
+func (s *session) reply(c *Client, message []byte) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if c.closed {
+        return ErrConnectionClosed
+    }
+    select {
+    case c.send <- message:
+        return nil
+    default:
+        go c.hub.cleanupClient(c)
+        return ErrSlowClient
+    }
+}
+
+// ack удаляет из буфера события до seq включительно.
+func (s *session) ack(seq uint64) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    i := 0
+    for i < len(s.buffer) && s.buffer[i].seq <= seq {
+        i++
+    }
+    s.buffer = s.buffer[i:]
+}
+
+// detach отключает соединение c от сессии и закрывает его канал отправки.
+// Отправки в c после этого отклоняются (см. reply).
+func (s *session) detach(c *Client) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if s.client == c {
+        s.detached = true
+        s.detachedAt = time.Now()
+    }
+    if !c.closed {
+        c.closed = true
+        close(c.send)
+    }
+}
+
+// attach передаёт сессию соединению c и отправляет ему событие session,
+// а затем пропущенные события после from. resumed == false, если часть
+// событий уже вытеснена из буфера — клиенту нужно перезагрузить данные.
+func (s *session) attach(c *Client, from uint64, resuming bool) (resumed bool, replayed int) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    s.client = c
+    s.detached = false
+    s.detachedAt = time.Time{}
+    c.session = s
+    c.SessionID = s.id
+
+    resumed = resuming && from >= s.evicted
+    var replay [][]byte
+    if resumed {
+        for _, e := range s.buffer {
+            if e.seq > from {
+                replay = append(replay, e.data)
+            }
+        }
+    }
+
+    info, _ := NewMessage("session", SessionPayload{
+        SessionID: s.id.String(),
+        Resumed:   resumed,
+        Replayed:  len(replay),
+        LastSeq:   s.seq,
+    })
+    c.send <- info
+    for _, data := range replay {
+        c.send <- data
+    }
+    return resumed, len(replay)
+}
+
+// expired сообщает, истекло ли время ожидания возобновления.
+func (s *session) expired(window time.Duration) bool {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.detached && time.Since(s.detachedAt) > window
+}
+
+// withSeq добавляет номер события в JSON-объект сообщения.
+func withSeq(message []byte, seq uint64) []byte {
+    if len(message) < 2 || message[0] != '{' {
+        return message
+    }
+    out := make([]byte, 0, len(message)+24)
+    out = append(out, `{"seq":`...)
+    out = strconv.AppendUint(out, seq, 10)
+    if message[1] != '}' {
+        out = append(out, ',')
+    }
+    return append(out, message[1:]...)
+}
@@ -0,0 +1,74 @@
+package websocket
+
+import (
+    "errors"
+    "sync"
+    "testing"
+
+    "github.com/google/uuid"
+)
+
+// Ответ соединению, сессию которого забрало новое соединение, не должен
+// паниковать на закрытом канале.
+func TestReplyAfterTakeOver(t *testing.T) {
+    h := NewHub()
+    old := testClient(t, h, ClientTypeAdmin, uuid.New(), uuid.Nil, true)
+
+    resumed := NewClient(h, nil, ClientTypeAdmin, old.ID, uuid.Nil)
+    resumed.ClientID = old.ClientID
+    resumed.Resume = &ResumeRequest{SessionID: old.SessionID}
+    h.registerClient(resumed)
+
+    if err := old.Reply("req-1", "chats", nil); !errors.Is(err, ErrConnectionClosed) {
+        t.Fatalf("Reply: err = %v, ожидался ErrConnectionClosed", err)
+    }
+    if err := old.SendJSON(map[string]string{"type": "pong"}); !errors.Is(err, ErrConnectionClosed) {
+        t.Fatalf("SendJSON: err = %v, ожидался ErrConnectionClosed", err)
+    }
+    old.ReplyError("req-2", "sendMessage", "internal", "ошибка")
+
+    drain(resumed)
+    if err := resumed.Reply("req-3", "chats", nil); err != nil {
+        t.Fatalf("Reply новому соединению: %v", err)
+    }
+    if got := drain(resumed); got != 1 {
+        t.Fatalf("новое соединение получило %d ответов, ожидался 1", got)
+    }
+}
+
+// Перехват сессии во время обработки команды (ReadPump ещё отвечает).
+func TestReplyConcurrentWithTakeOver(t *testing.T) {
+    h := NewHub()
+    old := testClient(t, h, ClientTypeAdmin, uuid.New(), uuid.Nil, true)
+
+    var wg sync.WaitGroup
+    wg.Add(1)
+    go func() {
+        defer wg.Done()
+        for i := 0; i < 1000; i++ {
+            old.ReplyError("", "", "internal", "ошибка")
+            drain(old)
+        }
+    }()
+
+    resumed := NewClient(h, nil, ClientTypeAdmin, old.ID, uuid.Nil)
+    resumed.ClientID = old.ClientID
+    resumed.Resume = &ResumeRequest{SessionID: old.SessionID}
+    h.registerClient(resumed)
+    wg.Wait()
+}
+
+// Переполненный канал не блокирует ответ.
+func TestReplySlowClient(t *testing.T) {
+    h := NewHub()
+    c := testClient(t, h, ClientTypeWidget, uuid.New(), uuid.New(), false)
+
+    for i := 0; i < cap(c.send); i++ {
+        if err := c.Reply("", "pong", nil); err != nil {
+            t.Fatalf("ответ %d: %v", i, err)
+        }
+    }
+    if err := c.Reply("", "pong", nil); !errors.Is(err, ErrSlowClient) {
+        t.Fatalf("err = %v, ожидался ErrSlowClient", err)
+    }
+}
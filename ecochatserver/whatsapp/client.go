@@ -0,0 +1,159 @@
+// Package whatsapp — минимальный клиент WhatsApp Cloud API (Graph API).
+package whatsapp
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "os"
+    "strings"
+    "time"
+)
+
+// Адрес и версия Graph API по умолчанию
+const (
+    DefaultGraphURL   = "https://graph.facebook.com"
+    DefaultAPIVersion = "v19.0"
+)
+
+// Коды ошибок Cloud API, связанные с ограничением частоты
+const (
+    codeRateLimit     = 130429
+    codePairRateLimit = 131056
+    codeThrottled     = 4
+)
+
+// Client отправляет сообщения через Graph API.
+type Client struct {
+    baseURL string
+    version string
+    client  *http.Client
+}
+
+// APIError — ошибка Graph API.
+type APIError struct {
+    StatusCode int
+    Code       int    `json:"code"`
+    Subcode    int    `json:"error_subcode"`
+    Type       string `json:"type"`
+    Message    string `json:"message"`
+    TraceID    string `json:"fbtrace_id"`
+}
+
+func (e *APIError) Error() string {
+    return fmt.Sprintf("whatsapp api: status %d, code %d: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Temporary сообщает, имеет ли смысл повторить запрос.
+func (e *APIError) Temporary() bool {
+    switch e.Code {
+    case codeRateLimit, codePairRateLimit, codeThrottled:
+        return true
+    }
+    return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// NewClient создаёт клиента; адрес и версия берутся из WHATSAPP_GRAPH_URL
+// и WHATSAPP_API_VERSION (для тестов можно указать локальный фейковый Graph).
+func NewClient() *Client {
+    baseURL := os.Getenv("WHATSAPP_GRAPH_URL")
+    if baseURL == "" {
+        baseURL = DefaultGraphURL
+    }
+    version := os.Getenv("WHATSAPP_API_VERSION")
+    if version == "" {
+        version = DefaultAPIVersion
+    }
+    return NewClientWithURL(baseURL, version, &http.Client{Timeout: 15 * time.Second})
+}
+
+// NewClientWithURL создаёт клиента с явным адресом, версией и HTTP-клиентом.
+func NewClientWithURL(baseURL, version string, httpClient *http.Client) *Client {
+    if httpClient == nil {
+        httpClient = http.DefaultClient
+    }
+    return &Client{
+        baseURL: strings.TrimRight(baseURL, "/"),
+        version: version,
+        client:  httpClient,
+    }
+}
+
+// SendText отправляет текстовое сообщение и возвращает wamid.
+func (c *Client) SendText(ctx context.Context, token, phoneNumberID, to, text string) (string, error) {
+    body := map[string]interface{}{
+        "messaging_product": "whatsapp",
+        "recipient_type":    "individual",
+        "to":                to,
+        "type":              "text",
+        "text": map[string]interface{}{
+            "body": text,
+        },
+    }
+
+    var out struct {
+        Messages []struct {
+            ID string `json:"id"`
+        } `json:"messages"`
+    }
+    if err := c.post(ctx, token, phoneNumberID+"/messages", body, &out); err != nil {
+        return "", err
+    }
+    if len(out.Messages) == 0 {
+        return "", &APIError{StatusCode: http.StatusBadGateway, Message: "пустой список messages в ответе"}
+    }
+    return out.Messages[0].ID, nil
+}
+
+// post выполняет POST {baseURL}/{version}/{path} и декодирует ответ в out.
+func (c *Client) post(ctx context.Context, token, path string, body interface{}, out interface{}) error {
+    if token == "" {
+        return &APIError{StatusCode: http.StatusUnauthorized, Message: "пустой токен доступа"}
+    }
+
+    payload, err := json.Marshal(body)
+    if err != nil {
+        return fmt.Errorf("marshal request body: %w", err)
+    }
+
+    endpoint := fmt.Sprintf("%s/%s/%s", c.baseURL, c.version, path)
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+    if err != nil {
+        return fmt.Errorf("create HTTP request: %w", err)
+    }
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set("Authorization", "Bearer "+token)
+
+    resp, err := c.client.Do(req)
+    if err != nil {
+        // Сетевые ошибки считаем временными
+        return &APIError{StatusCode: http.StatusBadGateway, Message: err.Error()}
+    }
+    defer resp.Body.Close()
+
+    raw, err := io.ReadAll(resp.Body)
+    if err != nil {
+        return &APIError{StatusCode: http.StatusBadGateway, Message: err.Error()}
+    }
+
+    if resp.StatusCode != http.StatusOK {
+        var envelope struct {
+            Error *APIError `json:"error"`
+        }
+        if err := json.Unmarshal(raw, &envelope); err != nil || envelope.Error == nil {
+            return &APIError{StatusCode: resp.StatusCode, Message: string(raw)}
+        }
+        envelope.Error.StatusCode = resp.StatusCode
+        return envelope.Error
+    }
+
+    if out != nil {
+        if err := json.Unmarshal(raw, out); err != nil {
+            return fmt.Errorf("decode response: %w", err)
+        }
+    }
+    return nil
+}
@@ -0,0 +1,98 @@
+package whatsapp
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+)
+
+// fakeGraph поднимает локальную подмену Graph API.
+func fakeGraph(t *testing.T, handle http.HandlerFunc) *Client {
+    t.Helper()
+    srv := httptest.NewServer(handle)
+    t.Cleanup(srv.Close)
+    return NewClientWithURL(srv.URL, "v19.0", srv.Client())
+}
+
+func TestSendText(t *testing.T) {
+    var body map[string]any
+    c := fakeGraph(t, func(w http.ResponseWriter, r *http.Request) {
+        if r.URL.Path != "/v19.0/1001/messages" {
+            t.Errorf("путь %q", r.URL.Path)
+        }
+        if got := r.Header.Get("Authorization"); got != "Bearer tok" {
+            t.Errorf("Authorization = %q", got)
+        }
+        if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+            t.Errorf("тело запроса: %v", err)
+        }
+        w.Write([]byte(`{"messaging_product":"whatsapp","contacts":[{"input":"79990001122","wa_id":"79990001122"}],"messages":[{"id":"wamid.ABC"}]}`))
+    })
+
+    wamid, err := c.SendText(context.Background(), "tok", "1001", "79990001122", "привет")
+    if err != nil {
+        t.Fatalf("SendText: %v", err)
+    }
+    if wamid != "wamid.ABC" {
+        t.Errorf("wamid = %q", wamid)
+    }
+    if body["to"] != "79990001122" || body["type"] != "text" || body["messaging_product"] != "whatsapp" {
+        t.Errorf("тело запроса = %v", body)
+    }
+    if text, _ := body["text"].(map[string]any); text["body"] != "привет" {
+        t.Errorf("text = %v", body["text"])
+    }
+}
+
+func TestSendTextErrors(t *testing.T) {
+    tests := []struct {
+        name      string
+        status    int
+        body      string
+        code      int
+        temporary bool
+    }{
+        {
+            name:   "invalid recipient",
+            status: http.StatusBadRequest,
+            body:   `{"error":{"message":"Invalid parameter","type":"OAuthException","code":100,"fbtrace_id":"x"}}`,
+            code:   100,
+        },
+        {
+            name:      "rate limit",
+            status:    http.StatusBadRequest,
+            body:      `{"error":{"message":"Rate limit hit","type":"OAuthException","code":130429}}`,
+            code:      130429,
+            temporary: true,
+        },
+        {
+            name:      "server error",
+            status:    http.StatusServiceUnavailable,
+            body:      `upstream unavailable`,
+            temporary: true,
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            c := fakeGraph(t, func(w http.ResponseWriter, r *http.Request) {
+                w.WriteHeader(tt.status)
+                w.Write([]byte(tt.body))
+            })
+
+            _, err := c.SendText(context.Background(), "tok", "1001", "79990001122", "привет")
+            var apiErr *APIError
+            if !errors.As(err, &apiErr) {
+                t.Fatalf("ошибка %v, want *APIError", err)
+            }
+            if apiErr.StatusCode != tt.status || apiErr.Code != tt.code {
+                t.Errorf("status %d code %d, want %d %d", apiErr.StatusCode, apiErr.Code, tt.status, tt.code)
+            }
+            if apiErr.Temporary() != tt.temporary {
+                t.Errorf("Temporary() = %v, want %v", apiErr.Temporary(), tt.temporary)
+            }
+        })
+    }
+}
@@ -0,0 +1,141 @@
+package whatsapp
+
+import (
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "strings"
+)
+
+// Notification — тело вебхука Cloud API (используемое подмножество полей).
+type Notification struct {
+    Object string  `json:"object"`
+    Entry  []Entry `json:"entry"`
+}
+
+// Entry — запись по бизнес-аккаунту.
+type Entry struct {
+    ID      string   `json:"id"`
+    Changes []Change `json:"changes"`
+}
+
+// Change — изменение поля подписки (нас интересует field=messages).
+type Change struct {
+    Field string `json:"field"`
+    Value Value  `json:"value"`
+}
+
+// Value — содержимое изменения.
+type Value struct {
+    MessagingProduct string    `json:"messaging_product"`
+    Metadata         Metadata  `json:"metadata"`
+    Contacts         []Contact `json:"contacts,omitempty"`
+    Messages         []Message `json:"messages,omitempty"`
+    Statuses         []Status  `json:"statuses,omitempty"`
+}
+
+// Metadata — номер бизнеса, на который пришло событие.
+type Metadata struct {
+    DisplayPhoneNumber string `json:"display_phone_number"`
+    PhoneNumberID      string `json:"phone_number_id"`
+}
+
+// Contact — профиль пользователя.
+type Contact struct {
+    WaID    string `json:"wa_id"`
+    Profile struct {
+        Name string `json:"name"`
+    } `json:"profile"`
+}
+
+// Message — входящее сообщение пользователя.
+type Message struct {
+    From        string       `json:"from"`
+    ID          string       `json:"id"`
+    Timestamp   string       `json:"timestamp"`
+    Type        string       `json:"type"`
+    Text        *Text        `json:"text,omitempty"`
+    Image       *Media       `json:"image,omitempty"`
+    Video       *Media       `json:"video,omitempty"`
+    Audio       *Media       `json:"audio,omitempty"`
+    Document    *Media       `json:"document,omitempty"`
+    Sticker     *Media       `json:"sticker,omitempty"`
+    Location    *Location    `json:"location,omitempty"`
+    Button      *Button      `json:"button,omitempty"`
+    Interactive *Interactive `json:"interactive,omitempty"`
+    Context     *struct {
+        ID string `json:"id"`
+    } `json:"context,omitempty"`
+}
+
+// Text — текст сообщения.
+type Text struct {
+    Body string `json:"body"`
+}
+
+// Media — вложение (фото, видео, аудио, документ, стикер).
+type Media struct {
+    ID       string `json:"id"`
+    MimeType string `json:"mime_type,omitempty"`
+    SHA256   string `json:"sha256,omitempty"`
+    Caption  string `json:"caption,omitempty"`
+    Filename string `json:"filename,omitempty"`
+    Voice    bool   `json:"voice,omitempty"`
+}
+
+// Location — геопозиция.
+type Location struct {
+    Latitude  float64 `json:"latitude"`
+    Longitude float64 `json:"longitude"`
+    Name      string  `json:"name,omitempty"`
+    Address   string  `json:"address,omitempty"`
+}
+
+// Button — нажатие кнопки шаблона.
+type Button struct {
+    Text    string `json:"text"`
+    Payload string `json:"payload"`
+}
+
+// Interactive — ответ на интерактивное сообщение.
+type Interactive struct {
+    Type        string `json:"type"`
+    ButtonReply *struct {
+        ID    string `json:"id"`
+        Title string `json:"title"`
+    } `json:"button_reply,omitempty"`
+    ListReply *struct {
+        ID    string `json:"id"`
+        Title string `json:"title"`
+    } `json:"list_reply,omitempty"`
+}
+
+// Status — статус доставки исходящего сообщения.
+type Status struct {
+    ID          string        `json:"id"`
+    Status      string        `json:"status"` // sent, delivered, read, failed
+    Timestamp   string        `json:"timestamp"`
+    RecipientID string        `json:"recipient_id"`
+    Errors      []StatusError `json:"errors,omitempty"`
+}
+
+// StatusError — причина неуспешной доставки.
+type StatusError struct {
+    Code  int    `json:"code"`
+    Title string `json:"title"`
+}
+
+// VerifySignature проверяет X-Hub-Signature-256 ("sha256=<hex>") — HMAC-SHA256
+// тела запроса на секрете приложения.
+func VerifySignature(body []byte, header, appSecret string) bool {
+    if appSecret == "" || !strings.HasPrefix(header, "sha256=") {
+        return false
+    }
+    provided, err := hex.DecodeString(strings.TrimPrefix(header, "sha256="))
+    if err != nil {
+        return false
+    }
+    mac := hmac.New(sha256.New, []byte(appSecret))
+    mac.Write(body)
+    return hmac.Equal(provided, mac.Sum(nil))
+}
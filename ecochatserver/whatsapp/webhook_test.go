@@ -0,0 +1,39 @@
+package whatsapp
+
+import (
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "testing"
+)
+
+func sign(body []byte, secret string) string {
+    mac := hmac.New(sha256.New, []byte(secret))
+    mac.Write(body)
+    return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifySignature(t *testing.T) {
+    body := []byte(`{"object":"whatsapp_business_account"}`)
+
+    tests := []struct {
+        name   string
+        header string
+        secret string
+        want   bool
+    }{
+        {name: "valid", header: sign(body, "s3cret"), secret: "s3cret", want: true},
+        {name: "other secret", header: sign(body, "other"), secret: "s3cret"},
+        {name: "tampered body", header: sign([]byte(`{}`), "s3cret"), secret: "s3cret"},
+        {name: "no prefix", header: sign(body, "s3cret")[len("sha256="):], secret: "s3cret"},
+        {name: "not hex", header: "sha256=zz", secret: "s3cret"},
+        {name: "empty secret", header: sign(body, ""), secret: ""},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := VerifySignature(body, tt.header, tt.secret); got != tt.want {
+                t.Errorf("VerifySignature = %v, want %v", got, tt.want)
+            }
+        })
+    }
+}